@@ -0,0 +1,9 @@
+module common
+
+go 1.25.1
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/mux v1.8.1
+)
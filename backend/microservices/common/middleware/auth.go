@@ -0,0 +1,142 @@
+// Package middleware verifikuje JWT-ove koje izdaje users_service i proverava
+// politike pristupa po ruti; dele ga svi servisi (replace na ../common u go.mod).
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Identity je identitet pozivaoca izvucen iz verifikovanog JWT-a
+// (claim-ovi koje users_service upisuje u signJWT).
+type Identity struct {
+	UserID    uuid.UUID
+	Username  string
+	Role      string
+	Email     string
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+type ctxKey struct{}
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrRevoked      = errors.New("token revoked")
+	ErrMissingKid   = errors.New("missing kid header")
+)
+
+// KeySource vraca javni kljuc users_service-a za "kid" iz zaglavlja tokena.
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// RevocationChecker odlucuje da li je access token opozvan (odjava, deaktivacija naloga).
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
+
+// Authenticate verifikuje "Authorization: Bearer <jwt>" header, odbija istekle,
+// neispravne i opozvane tokene i stavlja identitet pozivaoca u context zahteva.
+// revoked moze biti nil.
+func Authenticate(keys KeySource, revoked RevocationChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := verify(r, keys)
+			if err == nil && revoked != nil {
+				var isRevoked bool
+				isRevoked, err = revoked.IsRevoked(r.Context(), id.TokenID, id.UserID, id.IssuedAt)
+				if err == nil && isRevoked {
+					err = ErrRevoked
+				}
+			}
+			if err != nil {
+				log.Printf("auth: %s %s rejected: %v", r.Method, r.URL.Path, err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="eUprava"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+func verify(r *http.Request, keys KeySource) (Identity, error) {
+	raw, ok := BearerToken(r)
+	if !ok {
+		return Identity{}, ErrMissingToken
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrMissingKid
+		}
+		return keys.PublicKey(r.Context(), kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return Identity{}, errors.New("invalid sub claim")
+	}
+	usr, _ := claims["usr"].(string)
+	if usr == "" {
+		return Identity{}, errors.New("missing usr claim")
+	}
+	if typ, _ := claims["typ"].(string); typ != "access" {
+		return Identity{}, errors.New("not an access token")
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return Identity{}, errors.New("missing jti claim")
+	}
+	role, _ := claims["role"].(string)
+	email, _ := claims["email"].(string)
+
+	id := Identity{UserID: userID, Username: usr, Role: role, Email: email, TokenID: jti}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		id.IssuedAt = iat.Time
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		id.ExpiresAt = exp.Time
+	}
+	return id, nil
+}
+
+// BearerToken vraca sirovi token iz Authorization header-a.
+func BearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return "", false
+	}
+	tok := strings.TrimSpace(h[7:])
+	return tok, tok != ""
+}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// IdentityFromContext vraca identitet koji je Authenticate stavio u context.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
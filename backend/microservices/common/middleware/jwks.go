@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minJWKSRefetch ogranicava koliko cesto nepoznat kid sme da izazove novo preuzimanje JWKS-a.
+const minJWKSRefetch = 10 * time.Second
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKS je lokalni kes javnih kljuceva koje objavljuje users_service
+// (GET /.well-known/jwks.json). Osvezava se periodicno i odmah kada stigne token
+// sa nepoznatim kid-om (rotacija kljuca); ako users_service nije dostupan,
+// koriste se poslednji poznati kljucevi.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	fetchMu     sync.Mutex
+	attemptedAt time.Time // poslednji pokusaj preuzimanja, stiti ga fetchMu
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+}
+
+func NewJWKS(usersBaseURL string) *JWKS {
+	return &JWKS{
+		url:    usersBaseURL + "/.well-known/jwks.json",
+		client: &http.Client{Timeout: 3 * time.Second},
+		keys:   map[string]crypto.PublicKey{},
+	}
+}
+
+// Run osvezava kljuceve na svakih every dok se ctx ne otkaze.
+func (j *JWKS) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		if err := j.refresh(ctx); err != nil {
+			log.Printf("jwks: refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// PublicKey vraca kljuc za kid (KeySource).
+func (j *JWKS) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if k, ok := j.lookup(kid); ok {
+		return k, nil
+	}
+
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+	// mozda ga je u medjuvremenu preuzeo drugi zahtev
+	if k, ok := j.lookup(kid); ok {
+		return k, nil
+	}
+	if time.Since(j.attemptedAt) < minJWKSRefetch {
+		return nil, ErrUnknownKey
+	}
+	if err := j.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if k, ok := j.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (j *JWKS) lookup(kid string) (crypto.PublicKey, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	k, ok := j.keys[kid]
+	return k, ok
+}
+
+func (j *JWKS) refresh(ctx context.Context) error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+	return j.fetch(ctx)
+}
+
+func (j *JWKS) fetch(ctx context.Context) error {
+	j.attemptedAt = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		var (
+			pub crypto.PublicKey
+			err error
+		)
+		switch {
+		case k.Kty == "RSA":
+			pub, err = rsaKey(k.N, k.E)
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			pub, err = ed25519Key(k.X)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("jwks: skipping key %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+	return nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func ed25519Key(x string) (ed25519.PublicKey, error) {
+	b, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 key size")
+	}
+	return ed25519.PublicKey(b), nil
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleStudent = "student"
+	RoleCashier = "cashier" // kasa u kantini, iskoriscava QR propusnice za obrok
+	RoleService = "service" // interni pozivi izmedju servisa (token izdaje users_service)
+)
+
+// Policy odlucuje da li pozivalac sme da pristupi ruti.
+type Policy struct {
+	Name  string
+	Allow func(r *http.Request, id Identity) bool
+}
+
+var (
+	// Authenticated propusta svakog pozivaoca sa validnim tokenom.
+	Authenticated = Policy{Name: "authenticated", Allow: func(*http.Request, Identity) bool { return true }}
+	Admin         = HasRole(RoleAdmin)
+	Student       = HasRole(RoleStudent)
+	Cashier       = HasRole(RoleCashier)
+	Service       = HasRole(RoleService)
+)
+
+func HasRole(role string) Policy {
+	return Policy{
+		Name:  "role:" + role,
+		Allow: func(_ *http.Request, id Identity) bool { return id.Role == role },
+	}
+}
+
+// Owner propusta pozivaoca ciji ID ili username odgovara mux parametru rute.
+func Owner(param string) Policy {
+	return Policy{
+		Name: "owner:" + param,
+		Allow: func(r *http.Request, id Identity) bool {
+			v := strings.Trim(strings.TrimSpace(mux.Vars(r)[param]), `"`)
+			return v != "" && (v == id.UserID.String() || v == id.Username)
+		},
+	}
+}
+
+// AnyOf propusta pozivaoca ako ga propusta bar jedna od politika.
+func AnyOf(policies ...Policy) Policy {
+	names := make([]string, len(policies))
+	for i, p := range policies {
+		names[i] = p.Name
+	}
+	return Policy{
+		Name: strings.Join(names, "|"),
+		Allow: func(r *http.Request, id Identity) bool {
+			for _, p := range policies {
+				if p.Allow(r, id) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Require stiti handler politikom; ocekuje da je Authenticate vec postavio identitet.
+func Require(p Policy, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := IdentityFromContext(r.Context())
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !p.Allow(r, id) {
+			Deny(w, r, p.Name)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Deny upisuje audit zapis o odbijenom pristupu i vraca 403.
+// Koriste ga i handleri kada se vlasnistvo nad resursom proverava tek nakon citanja iz baze.
+func Deny(w http.ResponseWriter, r *http.Request, policy string) {
+	id, _ := IdentityFromContext(r.Context())
+	log.Printf("AUDIT access_denied user=%q user_id=%s role=%q policy=%q method=%s path=%s remote=%s",
+		id.Username, id.UserID, id.Role, policy, r.Method, r.URL.Path, r.RemoteAddr)
+	http.Error(w, "forbidden", http.StatusForbidden)
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationList je lokalna kopija liste opoziva koju objavljuje users_service
+// (GET /api/token/revocations, samo za servisne tokene). Osvezava se periodicno;
+// ako users_service nije dostupan, koristi se poslednja poznata lista.
+type RevocationList struct {
+	url    string
+	tokens TokenSource
+	client *http.Client
+
+	mu      sync.RWMutex
+	revoked map[string]struct{}
+	users   map[uuid.UUID]time.Time
+}
+
+// TokenSource daje servisni access token kojim se servis predstavlja users_service-u.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+func NewRevocationList(usersBaseURL string, tokens TokenSource) *RevocationList {
+	return &RevocationList{
+		url:     usersBaseURL + "/api/token/revocations",
+		tokens:  tokens,
+		client:  &http.Client{Timeout: 3 * time.Second},
+		revoked: map[string]struct{}{},
+		users:   map[uuid.UUID]time.Time{},
+	}
+}
+
+// Run osvezava listu na svakih every dok se ctx ne otkaze.
+func (l *RevocationList) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		if err := l.refresh(ctx); err != nil {
+			log.Printf("revocations: refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (l *RevocationList) refresh(ctx context.Context) error {
+	token, err := l.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Tokens []string `json:"tokens"`
+		Users  []struct {
+			UserID    uuid.UUID `json:"user_id"`
+			RevokedAt time.Time `json:"revoked_at"`
+		} `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	revoked := make(map[string]struct{}, len(body.Tokens))
+	for _, jti := range body.Tokens {
+		revoked[jti] = struct{}{}
+	}
+	users := make(map[uuid.UUID]time.Time, len(body.Users))
+	for _, u := range body.Users {
+		users[u.UserID] = u.RevokedAt
+	}
+
+	l.mu.Lock()
+	l.revoked, l.users = revoked, users
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *RevocationList) IsRevoked(_ context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, ok := l.revoked[jti]; ok {
+		return true, nil
+	}
+	if at, ok := l.users[userID]; ok && at.After(issuedAt) {
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,205 @@
+// Package money: tacni novcani iznosi (cele pare i valuta) koje dele svi servisi.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency je valuta svih iznosa u bazi (NUMERIC kolone nemaju kolonu za valutu).
+const DefaultCurrency = "RSD"
+
+// minorDigits: broj decimala (para) koje valuta dozvoljava.
+const minorDigits = 2
+
+var (
+	ErrInvalidAmount    = errors.New("neispravan iznos")
+	ErrSubMinorAmount   = errors.New("iznos ne sme imati vise od dve decimale")
+	ErrCurrencyMismatch = errors.New("iznosi su u razlicitim valutama")
+)
+
+// Money je tacan novcani iznos: ceo broj para (minor units) i ISO 4217 valuta.
+// U JSON-u je {"amount": "350.00", "currency": "RSD"}; pri citanju prihvata i goli
+// broj ili string u podrazumevanoj valuti. Iznos se nikad ne prevodi kroz float64.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+func RSD(minor int64) Money { return Money{Minor: minor, Currency: DefaultCurrency} }
+
+// ParseMoney cita decimalni zapis ("-350", "350.5", "350.50"); odbija delove pare.
+func ParseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || !digits(whole) || !digits(frac) {
+		return Money{}, ErrInvalidAmount
+	}
+	// nule iza druge decimale ne menjaju iznos (NUMERIC moze vratiti "1500.000")
+	if len(frac) > minorDigits {
+		if strings.Trim(frac[minorDigits:], "0") != "" {
+			return Money{}, ErrSubMinorAmount
+		}
+		frac = frac[:minorDigits]
+	}
+	frac += strings.Repeat("0", minorDigits-len(frac))
+
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w > (math.MaxInt64-99)/100 {
+		return Money{}, ErrInvalidAmount
+	}
+	f, _ := strconv.ParseInt(frac, 10, 64)
+	minor := w*100 + f
+	if neg {
+		minor = -minor
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String vraca decimalni zapis bez valute, npr. "-350.00".
+func (m Money) String() string {
+	sign := ""
+	v := m.Minor
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) Neg() Money       { return Money{Minor: -m.Minor, Currency: m.Currency} }
+
+// Add sabira iznose iste valute; zbir van opsega int64 je ErrInvalidAmount.
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency() != o.currency() {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if (o.Minor > 0 && m.Minor > math.MaxInt64-o.Minor) || (o.Minor < 0 && m.Minor < math.MinInt64-o.Minor) {
+		return Money{}, ErrInvalidAmount
+	}
+	return Money{Minor: m.Minor + o.Minor, Currency: m.currency()}, nil
+}
+
+// Cmp vraca -1, 0 ili 1; iznosi moraju biti u istoj valuti.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.currency() != o.currency() {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Minor < o.Minor:
+		return -1, nil
+	case m.Minor > o.Minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.currency()})
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	switch {
+	case len(b) > 0 && b[0] == '{':
+		var in struct {
+			Amount   json.RawMessage `json:"amount"`
+			Currency string          `json:"currency"`
+		}
+		if err := json.Unmarshal(b, &in); err != nil {
+			return err
+		}
+		v, err := ParseMoney(rawAmount(in.Amount), strings.ToUpper(in.Currency))
+		if err != nil {
+			return err
+		}
+		*m = v
+	case bytes.Equal(b, []byte("null")):
+		*m = Money{}
+	default:
+		v, err := ParseMoney(rawAmount(b), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = v
+	}
+	return nil
+}
+
+// rawAmount uzima iznos iz JSON stringa ili broja bez prolaska kroz float64.
+func rawAmount(b json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return s
+	}
+	return string(b)
+}
+
+// Scan cita NUMERIC kolonu; iznosi u bazi su u podrazumevanoj valuti.
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*m = RSD(v * 100)
+		return nil
+	case nil:
+		*m = RSD(0)
+		return nil
+	default:
+		return fmt.Errorf("money: ne mogu da procitam %T", src)
+	}
+	v, err := ParseMoney(s, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value upisuje iznos kao decimalni string u NUMERIC kolonu.
+func (m Money) Value() (driver.Value, error) {
+	if m.currency() != DefaultCurrency {
+		return nil, ErrCurrencyMismatch
+	}
+	return m.String(), nil
+}
@@ -0,0 +1,192 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+		err      error
+	}{
+		{in: "350", want: RSD(35000)},
+		{in: "350.5", want: RSD(35050)},
+		{in: "350.50", want: RSD(35050)},
+		{in: "-350.05", want: RSD(-35005)},
+		{in: "+1", want: RSD(100)},
+		{in: " 12.3 ", want: RSD(1230)},
+		{in: "1500.000", want: RSD(150000)}, // NUMERIC moze vratiti nule iza druge decimale
+		{in: "10", currency: "EUR", want: Money{Minor: 1000, Currency: "EUR"}},
+		{in: "1.005", err: ErrSubMinorAmount},
+		{in: "0.001", err: ErrSubMinorAmount},
+		{in: "", err: ErrInvalidAmount},
+		{in: ".5", err: ErrInvalidAmount},
+		{in: "1.", want: RSD(100)},
+		{in: "abc", err: ErrInvalidAmount},
+		{in: "1.2.3", err: ErrInvalidAmount},
+		{in: "1e3", err: ErrInvalidAmount},
+		{in: "--1", err: ErrInvalidAmount},
+		{in: "92233720368547758", err: ErrInvalidAmount},
+		{in: "92233720368547757.07", want: RSD(math.MaxInt64 - 100)},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseMoney(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{RSD(0), "0.00"},
+		{RSD(5), "0.05"},
+		{RSD(35050), "350.50"},
+		{RSD(-35005), "-350.05"},
+		{RSD(-5), "-0.05"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	eur := Money{Minor: 100, Currency: "EUR"}
+	tests := []struct {
+		name string
+		a, b Money
+		want Money
+		err  error
+	}{
+		{name: "zbir", a: RSD(150), b: RSD(-50), want: RSD(100)},
+		{name: "prazna valuta je podrazumevana", a: Money{Minor: 1}, b: RSD(2), want: RSD(3)},
+		{name: "razlicite valute", a: RSD(100), b: eur, err: ErrCurrencyMismatch},
+		{name: "prekoracenje navise", a: RSD(math.MaxInt64), b: RSD(1), err: ErrInvalidAmount},
+		{name: "prekoracenje nanize", a: RSD(math.MinInt64 + 1), b: RSD(-2), err: ErrInvalidAmount},
+		{name: "granica", a: RSD(math.MaxInt64 - 1), b: RSD(1), want: RSD(math.MaxInt64)},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%s: = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyCmp(t *testing.T) {
+	tests := []struct {
+		a, b Money
+		want int
+		err  error
+	}{
+		{a: RSD(1), b: RSD(2), want: -1},
+		{a: RSD(2), b: RSD(2), want: 0},
+		{a: RSD(3), b: Money{Minor: 2}, want: 1},
+		{a: RSD(1), b: Money{Minor: 1, Currency: "EUR"}, err: ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Cmp(tt.b)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%+v.Cmp(%+v) = %d, %v; want %d, %v", tt.a, tt.b, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(RSD(-35005))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"amount":"-350.05","currency":"RSD"}` {
+		t.Errorf("Marshal = %s", b)
+	}
+
+	tests := []struct {
+		in   string
+		want Money
+		err  error
+	}{
+		{in: `{"amount":"-350.05","currency":"RSD"}`, want: RSD(-35005)},
+		{in: `{"amount":350.5,"currency":"rsd"}`, want: RSD(35050)},
+		{in: `{"amount":"1","currency":"EUR"}`, want: Money{Minor: 100, Currency: "EUR"}},
+		{in: `350.50`, want: RSD(35050)},
+		{in: `"350"`, want: RSD(35000)},
+		{in: `null`, want: Money{}},
+		{in: `0.1234`, err: ErrSubMinorAmount},
+		{in: `{"amount":"x"}`, err: ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Unmarshal(%s) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	// povratni put kroz JSON cuva tacan iznos i valutu
+	for _, m := range []Money{RSD(1), RSD(-1), RSD(math.MaxInt64 - 100), {Minor: 999, Currency: "EUR"}} {
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Money
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", b, err)
+		}
+		if got != m {
+			t.Errorf("round trip %+v -> %s -> %+v", m, b, got)
+		}
+	}
+}
+
+func TestMoneyScanValue(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Money
+	}{
+		{src: []byte("1500.000"), want: RSD(150000)},
+		{src: "-0.50", want: RSD(-50)},
+		{src: int64(7), want: RSD(700)},
+		{src: nil, want: RSD(0)},
+	}
+	for _, tt := range tests {
+		var got Money
+		if err := got.Scan(tt.src); err != nil || got != tt.want {
+			t.Errorf("Scan(%v) = %+v, %v; want %+v", tt.src, got, err, tt.want)
+		}
+	}
+	var m Money
+	if err := m.Scan(1.5); err == nil {
+		t.Error("Scan(float64) should fail")
+	}
+
+	v, err := RSD(-35005).Value()
+	if err != nil || v != "-350.05" {
+		t.Errorf("Value = %v, %v", v, err)
+	}
+	if _, err := (Money{Minor: 1, Currency: "EUR"}).Value(); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Value(EUR) error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
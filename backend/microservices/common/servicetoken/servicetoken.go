@@ -0,0 +1,71 @@
+// Package servicetoken pribavlja servisne access tokene od users_service-a
+// (client credentials) za pozive izmedju servisa bez korisnika.
+package servicetoken
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client pribavlja servisni access token i kesira ga do isteka.
+type Client struct {
+	url      string
+	clientID string
+	secret   string
+	client   *http.Client
+
+	mu    sync.Mutex
+	token string
+	exp   time.Time
+}
+
+func New(usersBaseURL, clientID, secret string) *Client {
+	return &Client{
+		url:      strings.TrimRight(usersBaseURL, "/") + "/api/token/service",
+		clientID: clientID,
+		secret:   secret,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (t *Client) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// token se obnavlja malo pre isteka da ne istekne usred poziva
+	if t.token != "" && time.Until(t.exp) > 30*time.Second {
+		return t.token, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"client_id": t.clientID, "client_secret": t.secret})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("service token: unexpected status code: %d", resp.StatusCode)
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	t.token = out.AccessToken
+	t.exp = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	return t.token, nil
+}
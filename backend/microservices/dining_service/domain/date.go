@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var ErrInvalidDate = errors.New("date must be in YYYY-MM-DD format")
+
+// Date je kalendarski dan bez vremena i zone; u JSON-u i bazi je "YYYY-MM-DD".
+type Date struct {
+	t time.Time // uvek ponoc UTC
+}
+
+// DateOf vraca dan kome t pripada u svojoj vremenskoj zoni.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{time.Date(y, m, d, 0, 0, 0, 0, time.UTC)}
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, ErrInvalidDate
+	}
+	return Date{t}, nil
+}
+
+func (d Date) String() string     { return d.t.Format(dateLayout) }
+func (d Date) IsZero() bool       { return d.t.IsZero() }
+func (d Date) AddDays(n int) Date { return Date{d.t.AddDate(0, 0, n)} }
+func (d Date) Before(o Date) bool { return d.t.Before(o.t) }
+func (d Date) After(o Date) bool  { return d.t.After(o.t) }
+func (d Date) Weekday() Weekday   { return WeekdayOf(d.t) }
+func (d Date) Equal(o Date) bool  { return d.t.Equal(o.t) }
+
+// At vraca trenutak u danu d u vreme c po zoni loc.
+func (d Date) At(c ClockTime, loc *time.Location) time.Time {
+	y, m, day := d.t.Date()
+	return time.Date(y, m, day, int(c)/60, int(c)%60, 0, 0, loc)
+}
+
+// WeekStart vraca ponedeljak nedelje kojoj dan pripada.
+func (d Date) WeekStart() Date {
+	return d.AddDays(-((int(d.t.Weekday()) + 6) % 7))
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return ErrInvalidDate
+	}
+	v, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scan cita DATE kolonu (lib/pq je vraca kao time.Time u UTC).
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case string:
+		return d.parseInto(v)
+	case []byte:
+		return d.parseInto(string(v))
+	}
+	return fmt.Errorf("date: ne mogu da procitam %T", src)
+}
+
+func (d *Date) parseInto(s string) error {
+	if len(s) > len(dateLayout) {
+		s = s[:len(dateLayout)]
+	}
+	v, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
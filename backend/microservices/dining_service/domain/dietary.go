@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Allergen je jedan od 14 alergena koje EU propisi (Uredba 1169/2011) traze na jelovniku.
+type Allergen string
+
+const (
+	AllergenGluten      Allergen = "gluten"
+	AllergenCrustaceans Allergen = "crustaceans"
+	AllergenEggs        Allergen = "eggs"
+	AllergenFish        Allergen = "fish"
+	AllergenPeanuts     Allergen = "peanuts"
+	AllergenSoybeans    Allergen = "soybeans"
+	AllergenMilk        Allergen = "milk"
+	AllergenNuts        Allergen = "nuts"
+	AllergenCelery      Allergen = "celery"
+	AllergenMustard     Allergen = "mustard"
+	AllergenSesame      Allergen = "sesame"
+	AllergenSulphites   Allergen = "sulphites"
+	AllergenLupin       Allergen = "lupin"
+	AllergenMolluscs    Allergen = "molluscs"
+)
+
+var Allergens = []Allergen{
+	AllergenGluten, AllergenCrustaceans, AllergenEggs, AllergenFish, AllergenPeanuts,
+	AllergenSoybeans, AllergenMilk, AllergenNuts, AllergenCelery, AllergenMustard,
+	AllergenSesame, AllergenSulphites, AllergenLupin, AllergenMolluscs,
+}
+
+func (a Allergen) Valid() bool {
+	for _, v := range Allergens {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// DietaryTag: nacin ishrane kome obrok odgovara.
+type DietaryTag string
+
+const (
+	TagVegetarian DietaryTag = "vegetarian"
+	TagVegan      DietaryTag = "vegan"
+	TagHalal      DietaryTag = "halal"
+	TagGlutenFree DietaryTag = "gluten_free"
+)
+
+var DietaryTags = []DietaryTag{TagVegetarian, TagVegan, TagHalal, TagGlutenFree}
+
+func (t DietaryTag) Valid() bool {
+	for _, v := range DietaryTags {
+		if t == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Nutrition: nutritivne vrednosti jedne porcije; makronutrijenti su u gramima.
+type Nutrition struct {
+	Calories int     `json:"calories"`
+	Protein  float64 `json:"protein_g"`
+	Carbs    float64 `json:"carbs_g"`
+	Fat      float64 `json:"fat_g"`
+}
+
+var ErrInvalidDietaryInfo = errors.New("invalid dietary info")
+
+// tagExcludes: alergeni koje obrok sa datom oznakom ne sme da sadrzi.
+var tagExcludes = map[DietaryTag][]Allergen{
+	TagVegetarian: {AllergenFish, AllergenCrustaceans, AllergenMolluscs},
+	TagVegan:      {AllergenFish, AllergenCrustaceans, AllergenMolluscs, AllergenMilk, AllergenEggs},
+	TagGlutenFree: {AllergenGluten},
+}
+
+// ValidateDietary proverava alergene, oznake i nutritivne vrednosti obroka; oznaka koja
+// protivreci alergenu (npr. vegan uz mleko) se odbija.
+func (m *Meal) ValidateDietary() error {
+	for _, a := range m.Allergens {
+		if !a.Valid() {
+			return fmt.Errorf("%w: unknown allergen %q", ErrInvalidDietaryInfo, a)
+		}
+	}
+	for _, t := range m.Tags {
+		if !t.Valid() {
+			return fmt.Errorf("%w: unknown dietary tag %q", ErrInvalidDietaryInfo, t)
+		}
+		for _, a := range tagExcludes[t] {
+			if m.Contains(a) {
+				return fmt.Errorf("%w: %s meal cannot contain %s", ErrInvalidDietaryInfo, t, a)
+			}
+		}
+	}
+	if n := m.Nutrition; n != nil && (n.Calories < 0 || n.Protein < 0 || n.Carbs < 0 || n.Fat < 0) {
+		return fmt.Errorf("%w: nutrition values cannot be negative", ErrInvalidDietaryInfo)
+	}
+	return nil
+}
+
+func (m *Meal) Contains(a Allergen) bool {
+	for _, v := range m.Allergens {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Meal) HasTag(t DietaryTag) bool {
+	for _, v := range m.Tags {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// MealFilter: alergeni koje obrok ne sme da sadrzi i oznake koje mora da ima.
+type MealFilter struct {
+	Exclude []Allergen
+	Tags    []DietaryTag
+}
+
+func (f MealFilter) IsZero() bool {
+	return len(f.Exclude) == 0 && len(f.Tags) == 0
+}
+
+func (f MealFilter) Validate() error {
+	for _, a := range f.Exclude {
+		if !a.Valid() {
+			return fmt.Errorf("%w: unknown allergen %q", ErrInvalidDietaryInfo, a)
+		}
+	}
+	for _, t := range f.Tags {
+		if !t.Valid() {
+			return fmt.Errorf("%w: unknown dietary tag %q", ErrInvalidDietaryInfo, t)
+		}
+	}
+	return nil
+}
+
+// Conflicts vraca razloge zbog kojih obrok ne prolazi filter ("contains gluten", "not vegan").
+func (f MealFilter) Conflicts(m *Meal) []string {
+	var out []string
+	for _, a := range f.Exclude {
+		if m.Contains(a) {
+			out = append(out, "contains "+string(a))
+		}
+	}
+	for _, t := range f.Tags {
+		if !m.HasTag(t) {
+			out = append(out, "not "+string(t))
+		}
+	}
+	return out
+}
+
+func (f MealFilter) Matches(m *Meal) bool {
+	return len(f.Conflicts(m)) == 0
+}
+
+// Merge spaja dva filtera bez ponavljanja.
+func (f MealFilter) Merge(o MealFilter) MealFilter {
+	out := MealFilter{Exclude: append([]Allergen{}, f.Exclude...), Tags: append([]DietaryTag{}, f.Tags...)}
+	for _, a := range o.Exclude {
+		if !containsAllergen(out.Exclude, a) {
+			out.Exclude = append(out.Exclude, a)
+		}
+	}
+	for _, t := range o.Tags {
+		if !containsTag(out.Tags, t) {
+			out.Tags = append(out.Tags, t)
+		}
+	}
+	return out
+}
+
+func containsAllergen(list []Allergen, a Allergen) bool {
+	for _, v := range list {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(list []DietaryTag, t DietaryTag) bool {
+	for _, v := range list {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DietaryPreferences: ishrana studenta; obroci koji im ne odgovaraju dobijaju upozorenja.
+type DietaryPreferences struct {
+	UserId         uuid.UUID    `json:"user_id"`
+	AvoidAllergens []Allergen   `json:"avoid_allergens"`
+	Tags           []DietaryTag `json:"tags"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+func (p *DietaryPreferences) Filter() MealFilter {
+	return MealFilter{Exclude: p.AvoidAllergens, Tags: p.Tags}
+}
@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"common/money"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,12 +16,74 @@ type Canteen struct {
 	Address string    `json:"address"`
 	OpenAt  time.Time `json:"open_at"`
 	CloseAt time.Time `json:"close_at"`
+	// ServingWindows: kada se koji obrok izdaje; obrok bez prozora se izdaje dok kantina radi.
+	ServingWindows []ServingWindow `json:"serving_windows,omitempty"`
+}
+
+// IsOpen: kantina radi u vreme at (u toku dana); open_at/close_at nose samo sat i minut.
+func (c *Canteen) IsOpen(at ClockTime) bool {
+	return at >= ClockOf(c.OpenAt) && at < ClockOf(c.CloseAt)
+}
+
+// ClockTime je vreme u toku dana u minutima od ponoci; u JSON-u je "HH:MM".
+type ClockTime int
+
+const endOfDay ClockTime = 24 * 60
+
+var ErrInvalidClockTime = errors.New("time must be in HH:MM format")
+
+func ParseClockTime(s string) (ClockTime, error) {
+	if s == "24:00" {
+		return endOfDay, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, ErrInvalidClockTime
+	}
+	return ClockOf(t), nil
+}
+
+// ClockOf vraca sat i minut od t, bez prevodjenja u drugu vremensku zonu.
+func ClockOf(t time.Time) ClockTime {
+	return ClockTime(t.Hour()*60 + t.Minute())
+}
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return ErrInvalidClockTime
+	}
+	v, err := ParseClockTime(s)
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// ServingWindow je period u kom kantina izdaje obrok [Start, End).
+type ServingWindow struct {
+	Slot  MealSlot  `json:"slot"`
+	Start ClockTime `json:"start"`
+	End   ClockTime `json:"end"`
+}
+
+func (w ServingWindow) Contains(at ClockTime) bool {
+	return at >= w.Start && at < w.End
 }
 
 type StudentCard struct {
-	ID        uuid.UUID `json:"id"`
-	Stanje    float64   `json:"stanje"`
-	StudentID uuid.UUID `json:"studentID"`
+	ID        uuid.UUID   `json:"id"`
+	Stanje    money.Money `json:"stanje"`
+	StudentID uuid.UUID   `json:"studentID"`
 }
 
 type CanteenDTO struct {
@@ -30,10 +96,15 @@ type CanteenDTO struct {
 type Canteens []Canteen
 
 type Meal struct {
-	Id          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
+	Id          uuid.UUID    `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Price       money.Money  `json:"price"`
+	Allergens   []Allergen   `json:"allergens"`
+	Tags        []DietaryTag `json:"tags"`
+	Nutrition   *Nutrition   `json:"nutrition,omitempty"`
+	// Warnings: zasto obrok ne odgovara filteru ili preferencama studenta koji ga gleda
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type Weekday string
@@ -48,6 +119,27 @@ const (
 	Sunday    Weekday = "Sunday"
 )
 
+// WeekdayOf vraca dan u nedelji za t (nazivi se poklapaju sa time.Weekday).
+func WeekdayOf(t time.Time) Weekday {
+	return Weekday(t.Weekday().String())
+}
+
+// MealSlot: obrok u okviru menija koji student bira pri kupovini.
+type MealSlot string
+
+const (
+	SlotBreakfast MealSlot = "breakfast"
+	SlotLunch     MealSlot = "lunch"
+	SlotDinner    MealSlot = "dinner"
+)
+
+// MealSlots: slotovi redom kojim se obroci izdaju u toku dana.
+var MealSlots = []MealSlot{SlotBreakfast, SlotLunch, SlotDinner}
+
+func (s MealSlot) Valid() bool {
+	return s == SlotBreakfast || s == SlotLunch || s == SlotDinner
+}
+
 type Menu struct {
 	Id        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
@@ -56,6 +148,93 @@ type Menu struct {
 	Breakfast Meal      `json:"breakfast"`
 	Lunch     Meal      `json:"lunch"`
 	Dinner    Meal      `json:"dinner"`
+	// Dishes: sva jela medju kojima se bira po slotu, ukljucujuci podrazumevana (position 0)
+	Dishes []Dish `json:"dishes,omitempty"`
+}
+
+// Meal vraca obrok menija za dati slot; false ako slot ne postoji ili obrok nije postavljen.
+func (m *Menu) Meal(slot MealSlot) (Meal, bool) {
+	var meal Meal
+	switch slot {
+	case SlotBreakfast:
+		meal = m.Breakfast
+	case SlotLunch:
+		meal = m.Lunch
+	case SlotDinner:
+		meal = m.Dinner
+	}
+	return meal, meal.Id != uuid.Nil
+}
+
+// Meals vraca postavljene obroke i jela menija (za izmenu na mestu).
+func (m *Menu) Meals() []*Meal {
+	var out []*Meal
+	for _, meal := range []*Meal{&m.Breakfast, &m.Lunch, &m.Dinner} {
+		if meal.Id != uuid.Nil {
+			out = append(out, meal)
+		}
+	}
+	for i := range m.Dishes {
+		out = append(out, &m.Dishes[i].Meal)
+	}
+	return out
+}
+
+// MenuPlan ponavlja meni kantine svakog Weekday-a u periodu [ValidFrom, ValidTo];
+// bez ValidTo plan vazi dok se ne zatvori (sezonski jelovnici su planovi sa krajem).
+type MenuPlan struct {
+	Id        uuid.UUID `json:"id"`
+	CanteenId uuid.UUID `json:"canteen_id"`
+	MenuId    uuid.UUID `json:"menu_id"`
+	Weekday   Weekday   `json:"weekday"`
+	ValidFrom Date      `json:"valid_from"`
+	ValidTo   *Date     `json:"valid_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Covers: plan vazi za dan d.
+func (p *MenuPlan) Covers(d Date) bool {
+	return p.Weekday == d.Weekday() && !d.Before(p.ValidFrom) && (p.ValidTo == nil || !d.After(*p.ValidTo))
+}
+
+// MenuCalendarEntry je meni za konkretan datum i ima prednost nad planovima za taj dan.
+// MenuId nil znaci da kantina tog dana ne radi (praznik). Nacrti (kopirana nedelja)
+// se ne vide studentima dok se ne objave.
+type MenuCalendarEntry struct {
+	Id        uuid.UUID  `json:"id"`
+	CanteenId uuid.UUID  `json:"canteen_id"`
+	Date      Date       `json:"date"`
+	MenuId    *uuid.UUID `json:"menu_id,omitempty"`
+	Published bool       `json:"published"`
+	Note      string     `json:"note"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Izvor menija za dan.
+const (
+	MenuSourcePlan     = "plan"     // ponavljajuci plan
+	MenuSourceCalendar = "calendar" // objavljen unos za datum (izuzetak ili objavljena nedelja)
+	MenuSourceDraft    = "draft"    // neobjavljen nacrt, vidi ga samo admin
+)
+
+// MenuDay su meniji kantine za jedan datum.
+type MenuDay struct {
+	Date      Date      `json:"date"`
+	CanteenId uuid.UUID `json:"canteen_id"`
+	Source    string    `json:"source"`
+	Closed    bool      `json:"closed"`
+	Note      string    `json:"note,omitempty"`
+	Menus     []*Menu   `json:"menus"`
+}
+
+func (d *MenuDay) HasMenu(id uuid.UUID) bool {
+	for _, m := range d.Menus {
+		if m.Id == id {
+			return true
+		}
+	}
+	return false
 }
 
 type MenuDTO struct {
@@ -65,6 +244,8 @@ type MenuDTO struct {
 	Breakfast Meal      `json:"breakfast"`
 	Lunch     Meal      `json:"lunch"`
 	Dinner    Meal      `json:"dinner"`
+	// Dishes: dodatna jela po slotu (uz podrazumevane obroke iznad)
+	Dishes []Dish `json:"dishes,omitempty"`
 }
 
 type MenuReview struct {
@@ -118,6 +299,91 @@ type MenuRating struct {
 	Score    float64   `json:"score"`
 }
 
+// PurchaseState: stanja kupovine obroka. Kupovina napreduje pending -> charged -> recorded
+// -> completed; ako se obrok ne moze upisati posle naplate, ide u refunding -> refunded.
+type PurchaseState string
+
+const (
+	PurchasePending   PurchaseState = "pending"   // zapisana, kartica jos nije zaduzena
+	PurchaseCharged   PurchaseState = "charged"   // kartica zaduzena, obrok jos nije upisan
+	PurchaseRecorded  PurchaseState = "recorded"  // obrok upisan, naplata jos nije potvrdjena
+	PurchaseCompleted PurchaseState = "completed" // naplata potvrdjena u housing servisu
+	PurchaseRefunding PurchaseState = "refunding" // naplata se ponistava
+	PurchaseRefunded  PurchaseState = "refunded"
+	PurchaseFailed    PurchaseState = "failed" // housing je odbio naplatu, nista nije skinuto
+)
+
+func (s PurchaseState) Terminal() bool {
+	return s == PurchaseCompleted || s == PurchaseRefunded || s == PurchaseFailed
+}
+
+// ErrMenuNotFound: meni kupovine vise ne postoji pa se obrok ne moze upisati.
+var ErrMenuNotFound = errors.New("menu not found")
+
+// ErrSlotAlreadyServed: student je danas vec uzeo obrok iz istog slota.
+var ErrSlotAlreadyServed = errors.New("meal slot already served today")
+
+// Razlozi odbijene kupovine (failure_code).
+const (
+	FailureInsufficientFunds = "insufficient_funds"
+	FailureChargeRejected    = "charge_rejected"
+)
+
+type MealPurchase struct {
+	Id             uuid.UUID     `json:"id"` // ujedno referenca naplate i id istorije obroka
+	IdempotencyKey string        `json:"idempotency_key"`
+	UserId         uuid.UUID     `json:"user_id"`
+	Username       string        `json:"username"`
+	MenuId         uuid.UUID     `json:"menu_id"`
+	CanteenId      uuid.UUID     `json:"canteen_id"`
+	Slots          []MealSlot    `json:"slots"`
+	Dishes         DishChoice    `json:"dishes"`     // izabrano jelo po slotu
+	ListPrice      money.Money   `json:"list_price"` // zbir cena obroka iz menija
+	Discount       money.Money   `json:"discount"`   // subvencije i popusti
+	Amount         money.Money   `json:"amount"`     // naplaceno: list_price - discount
+	State          PurchaseState `json:"state"`
+	Attempts       int           `json:"attempts"`
+	LastError      *string       `json:"last_error,omitempty"`
+	FailureCode    *string       `json:"failure_code,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// SubsidyKind: vrsta umanjenja cene obroka za studenta.
+type SubsidyKind string
+
+const (
+	SubsidyPercent SubsidyKind = "percent" // procenat cene obroka
+	SubsidyFixed   SubsidyKind = "fixed"   // fiksan iznos po obroku
+)
+
+// MealSubsidy je subvencija ili popust za jednog studenta; vazi u periodu
+// [valid_from, valid_to) za dati slot ili za sve obroke ako slot nije zadat.
+// Kada vise pravila vazi za isti obrok, primenjuje se najpovoljnije.
+type MealSubsidy struct {
+	Id        uuid.UUID   `json:"id"`
+	UserId    uuid.UUID   `json:"user_id"`
+	Kind      SubsidyKind `json:"kind"`
+	Percent   int         `json:"percent,omitempty"`
+	AmountOff money.Money `json:"amount_off"`
+	Slot      *MealSlot   `json:"slot,omitempty"`
+	ValidFrom time.Time   `json:"valid_from"`
+	ValidTo   *time.Time  `json:"valid_to,omitempty"`
+	Note      string      `json:"note"`
+	CreatedBy string      `json:"created_by"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// MealQuote je cena kupovine izracunata na serveru.
+type MealQuote struct {
+	MenuId    uuid.UUID   `json:"menu_id"`
+	Slots     []MealSlot  `json:"slots"`
+	Dishes    DishChoice  `json:"dishes"`
+	ListPrice money.Money `json:"list_price"`
+	Discount  money.Money `json:"discount"`
+	Amount    money.Money `json:"amount"`
+}
+
 type DiningRepository interface {
 	GetAllCanteens() ([]Canteen, error)
 	CreateCanteen(c *Canteen) error
@@ -149,4 +415,60 @@ type DiningRepository interface {
 
 	// NEW: svi meniji za dati dan (preko teksta u koloni weekday)
 	GetMenusByWeekday(weekday Weekday) ([]*Menu, error)
+
+	// Kupovine obroka
+	CreateMealPurchase(p *MealPurchase, servedOn time.Time, pass *MealPassRedemption) (bool, error)
+	GetMealPurchase(id uuid.UUID) (*MealPurchase, error)
+	GetMealPurchaseByKey(userId uuid.UUID, idempotencyKey string) (*MealPurchase, error)
+	TransitionMealPurchase(id uuid.UUID, from, to PurchaseState, lastErr, failureCode *string) (bool, error)
+	NoteMealPurchaseFailure(id uuid.UUID, cause string) error
+	RecordMealPurchase(p *MealPurchase) error
+	ListStalledMealPurchases(olderThan time.Duration, limit int) ([]MealPurchase, error)
+
+	// Subvencije
+	CreateMealSubsidy(s *MealSubsidy) error
+	ListMealSubsidies(userId uuid.UUID) ([]MealSubsidy, error)
+	ListActiveMealSubsidies(userId uuid.UUID, at time.Time) ([]MealSubsidy, error)
+	DeleteMealSubsidy(id uuid.UUID) error
+
+	CreateMenuPlan(p *MenuPlan) error
+	ListMenuPlans(canteenId uuid.UUID) ([]MenuPlan, error)
+	EndMenuPlan(id uuid.UUID, validTo Date) error
+	DeleteMenuPlan(id uuid.UUID) error
+	ListCalendarEntries(canteenId uuid.UUID, from, to Date) ([]MenuCalendarEntry, error)
+	ReplaceCalendarEntries(canteenId uuid.UUID, dates []Date, entries []MenuCalendarEntry, draftsOnly bool) error
+	DeleteCalendarEntries(canteenId uuid.UUID, date Date) error
+	PublishCalendarRange(canteenId uuid.UUID, from, to Date) (int, error)
+
+	// Jela po slotu, zalihe, ocene, popularnost i istorija jela
+	ListMenuDishes(menuIds []uuid.UUID) ([]Dish, error)
+	GetMenuDish(mealId uuid.UUID) (*Dish, error)
+	AddMenuDish(d *Dish) error
+	SetDishStock(mealId uuid.UUID, stock *int) error
+	DeleteMenuDish(mealId uuid.UUID) error
+	CountTakenPortions(mealIds []uuid.UUID, day Date) (map[uuid.UUID]int, error)
+	HasEatenDish(userId, mealId uuid.UUID) (bool, error)
+	SaveDishReview(r *DishReview) error
+	ListDishReviews(mealId uuid.UUID) ([]DishReview, error)
+	ListDishHistory(userId uuid.UUID) ([]DishHistory, error)
+	ListPopularDishes(canteenId uuid.UUID, limit int) ([]DishStats, error)
+	ListTopRatedDishes(limit int) ([]DishStats, error)
+
+	// Rezervacije obroka i kapacitet po slotu
+	GetSlotCapacities(canteenId uuid.UUID) ([]SlotCapacity, error)
+	ReplaceSlotCapacities(canteenId uuid.UUID, caps []SlotCapacity) error
+	CreateReservation(r *Reservation) error
+	GetReservation(id uuid.UUID) (*Reservation, error)
+	ListReservationsByUser(userId uuid.UUID) ([]Reservation, error)
+	CancelReservation(id uuid.UUID) (bool, error)
+	ListDueReservations(today Date, now ClockTime, limit int) ([]Reservation, error)
+	SetReservationOutcome(id uuid.UUID, status ReservationStatus, purchaseId *uuid.UUID, reason string) error
+	CountReservations(canteenId uuid.UUID, from, to Date) ([]ReservationCount, error)
+	ReservationOutcomes(canteenId uuid.UUID, from, to Date) (fulfilled, released int, err error)
+
+	GetDietaryPreferences(userId uuid.UUID) (*DietaryPreferences, error)
+	SaveDietaryPreferences(p *DietaryPreferences) error
+
+	GetServingWindows(canteenId uuid.UUID) ([]ServingWindow, error)
+	ReplaceServingWindows(canteenId uuid.UUID, windows []ServingWindow) error
 }
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dish je jelo koje student moze da izabere za slot menija. Obrok menija za slot
+// (Breakfast/Lunch/Dinner) je podrazumevano jelo i uvek je prvo (position 0).
+type Dish struct {
+	Meal
+	MenuId   uuid.UUID `json:"menu_id"`
+	Slot     MealSlot  `json:"slot"`
+	Position int       `json:"position"`
+	// Stock: broj porcija dnevno; bez vrednosti jela ima neograniceno.
+	Stock *int `json:"stock,omitempty"`
+	// Remaining: preostale porcije za dan za koji je meni trazen.
+	Remaining *int `json:"remaining,omitempty"`
+}
+
+var (
+	ErrDishNotFound = errors.New("dish not found")
+	ErrDishSoldOut  = errors.New("dish sold out for that day")
+	ErrDefaultDish  = errors.New("default dish of a meal slot cannot be removed")
+)
+
+// Dish vraca jelo za slot: id jela iz menija ili, za uuid.Nil, podrazumevano jelo slota.
+func (m *Menu) Dish(slot MealSlot, id uuid.UUID) (Meal, bool) {
+	if id == uuid.Nil {
+		return m.Meal(slot)
+	}
+	if meal, ok := m.Meal(slot); ok && meal.Id == id {
+		return meal, true
+	}
+	for _, d := range m.Dishes {
+		if d.Slot == slot && d.Id == id {
+			return d.Meal, true
+		}
+	}
+	return Meal{}, false
+}
+
+// DishChoice: izabrano jelo po slotu kupovine; u bazi je JSON objekat.
+type DishChoice map[MealSlot]uuid.UUID
+
+func (c DishChoice) Equal(o DishChoice) bool {
+	if len(c) != len(o) {
+		return false
+	}
+	for slot, id := range c {
+		if o[slot] != id {
+			return false
+		}
+	}
+	return true
+}
+
+func (c DishChoice) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(map[MealSlot]uuid.UUID(c))
+	return string(b), err
+}
+
+func (c *DishChoice) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*c = DishChoice{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("dish choice: ne mogu da procitam %T", src)
+	}
+	out := DishChoice{}
+	if err := json.Unmarshal(b, (*map[MealSlot]uuid.UUID)(&out)); err != nil {
+		return err
+	}
+	*c = out
+	return nil
+}
+
+// DishReview: ocena jela (1-5) od studenta koji ga je jeo; jedna po studentu i jelu.
+type DishReview struct {
+	Id        uuid.UUID `json:"id"`
+	MealId    uuid.UUID `json:"meal_id"`
+	UserId    uuid.UUID `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DishHistory: jedno pojedeno jelo iz kupovine, sa ocenom studenta ako postoji.
+type DishHistory struct {
+	PurchaseId uuid.UUID   `json:"purchase_id"`
+	MealId     uuid.UUID   `json:"meal_id"`
+	DishName   string      `json:"dish_name"`
+	Slot       MealSlot    `json:"slot"`
+	MenuId     uuid.UUID   `json:"menu_id"`
+	MenuName   string      `json:"menu_name"`
+	CanteenId  uuid.UUID   `json:"canteen_id"`
+	SelectedAt time.Time   `json:"selected_at"`
+	Review     *DishReview `json:"review,omitempty"`
+}
+
+type DishStats struct {
+	MealId        uuid.UUID `json:"meal_id"`
+	DishName      string    `json:"dish_name"`
+	TimesSelected int       `json:"times_selected"`
+	AvgRating     float64   `json:"avg_rating"`
+	Reviews       int       `json:"reviews"`
+}
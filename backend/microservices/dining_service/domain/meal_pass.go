@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidMealPass = errors.New("invalid or expired meal pass")
+	ErrMealPassUsed    = errors.New("meal pass already redeemed")
+)
+
+// MealPass: kratkotrajna propusnica za izabrane obroke iz menija koju student pokazuje
+// na kasi kao QR kod; potpisuje je dining servis, a kasa je iskoristi samo jednom.
+type MealPass struct {
+	Id        uuid.UUID  `json:"id"`
+	UserId    uuid.UUID  `json:"user_id"`
+	Username  string     `json:"username"`
+	MenuId    uuid.UUID  `json:"menu_id"`
+	Slots     []MealSlot `json:"slots"`
+	Dishes    DishChoice `json:"dishes,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// MealPassRedemption: iskoriscenje propusnice na kasi; belezi se u istoj transakciji
+// kao kupovina koju je propusnica platila.
+type MealPassRedemption struct {
+	Pass       *MealPass
+	RedeemedBy string
+}
+
+func (p *MealPass) Expired(at time.Time) bool {
+	return !at.Before(p.ExpiresAt)
+}
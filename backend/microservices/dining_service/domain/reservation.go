@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReservationStatus: rezervacija je aktivna dok je student ne otkaze ili dok se pri
+// pocetku izdavanja obroka ne naplati (fulfilled) ili oslobodi (released).
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "reserved"
+	ReservationCancelled ReservationStatus = "cancelled"
+	ReservationFulfilled ReservationStatus = "fulfilled" // naplacena kao kupovina obroka
+	ReservationReleased  ReservationStatus = "released"  // nije mogla da se naplati, mesto je oslobodjeno
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrCapacityReached     = errors.New("no more reservations available for this meal")
+	ErrAlreadyReserved     = errors.New("meal slot already reserved for that day")
+)
+
+// Reservation: student unapred rezervise slot menija za dan; jelo je izabrano ili
+// podrazumevano jelo slota.
+type Reservation struct {
+	Id         uuid.UUID         `json:"id"`
+	UserId     uuid.UUID         `json:"user_id"`
+	Username   string            `json:"username"`
+	CanteenId  uuid.UUID         `json:"canteen_id"`
+	MenuId     uuid.UUID         `json:"menu_id"`
+	Slot       MealSlot          `json:"slot"`
+	DishId     uuid.UUID         `json:"dish_id"`
+	Date       Date              `json:"date"`
+	Status     ReservationStatus `json:"status"`
+	PurchaseId *uuid.UUID        `json:"purchase_id,omitempty"`
+	Reason     string            `json:"reason,omitempty"` // zasto je oslobodjena
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// SlotCapacity: najvise rezervacija po obroku i danu u kantini.
+type SlotCapacity struct {
+	Slot     MealSlot `json:"slot"`
+	Capacity int      `json:"capacity"`
+}
+
+// ReservationCount: broj rezervacija (aktivnih i naplacenih) za dan, slot i jelo.
+type ReservationCount struct {
+	Date     Date
+	Slot     MealSlot
+	DishId   uuid.UUID
+	DishName string
+	Count    int
+}
+
+type DishForecast struct {
+	DishId   uuid.UUID `json:"dish_id"`
+	DishName string    `json:"dish_name"`
+	Reserved int       `json:"reserved"`
+}
+
+type SlotForecast struct {
+	Slot     MealSlot       `json:"slot"`
+	Capacity *int           `json:"capacity,omitempty"`
+	Reserved int            `json:"reserved"`
+	Expected int            `json:"expected"` // rezervacije umanjene za istorijski odziv
+	Dishes   []DishForecast `json:"dishes"`
+}
+
+type DayForecast struct {
+	Date  Date           `json:"date"`
+	Slots []SlotForecast `json:"slots"`
+}
+
+// Forecast: procena porcija po danu i obroku iz rezervacija; ShowRate je udeo
+// rezervacija iz poslednjih nedelja koje su zaista naplacene.
+type Forecast struct {
+	CanteenId uuid.UUID     `json:"canteen_id"`
+	ShowRate  float64       `json:"show_rate"`
+	Days      []DayForecast `json:"days"`
+}
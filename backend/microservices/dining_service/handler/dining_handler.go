@@ -1,14 +1,18 @@
 package handler
 
 import (
-	"bytes"
+	"common/middleware"
+	"common/money"
 	"dining/domain"
 	"dining/service"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -112,6 +116,365 @@ func (dh *DiningHandler) CreateCanteen(rw http.ResponseWriter, r *http.Request)
 	_ = json.NewEncoder(rw).Encode(canteen)
 }
 
+// GET /api/canteens/{id}/windows — prozori izdavanja obroka kantine
+func (dh *DiningHandler) GetServingWindows(rw http.ResponseWriter, r *http.Request) {
+	canteenId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "invalid id", http.StatusBadRequest)
+		return
+	}
+	windows, err := dh.service.GetServingWindows(canteenId)
+	if err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(rw, windows)
+}
+
+// PUT /api/canteens/{id}/windows
+// Body: [ { "slot": "breakfast", "start": "08:00", "end": "10:00" }, ... ] — zamenjuje sve prozore
+func (dh *DiningHandler) SetServingWindows(rw http.ResponseWriter, r *http.Request) {
+	canteenId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var windows []domain.ServingWindow
+	if err := json.NewDecoder(r.Body).Decode(&windows); err != nil {
+		msg := "bad json"
+		if errors.Is(err, domain.ErrInvalidClockTime) {
+			msg = err.Error()
+		}
+		http.Error(rw, msg, http.StatusBadRequest)
+		return
+	}
+	if err := dh.service.SetServingWindows(canteenId, windows); err != nil {
+		if errors.Is(err, service.ErrInvalidServingWindows) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(rw, "canteen not found", http.StatusNotFound)
+		return
+	}
+	dh.renderJSON(rw, windows)
+}
+
+// canteenDate cita {id} kantine iz rute i datum iz ?date= (podrazumevano danas).
+func canteenDate(r *http.Request, raw string) (uuid.UUID, domain.Date, error) {
+	canteenId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return uuid.Nil, domain.Date{}, errors.New("invalid canteen id")
+	}
+	if raw == "" {
+		return canteenId, domain.DateOf(time.Now()), nil
+	}
+	date, err := domain.ParseDate(raw)
+	return canteenId, date, err
+}
+
+// GET /api/canteens/{id}/calendar?date=YYYY-MM-DD — meniji kantine za dan
+func (dh *DiningHandler) GetMenusForDate(rw http.ResponseWriter, r *http.Request) {
+	var ok bool
+	canteenId, date, err := canteenDate(r, r.URL.Query().Get("date"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	day, err := dh.service.MenusForDate(canteenId, date)
+	if err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	if day.Menus, ok = dh.filterMenus(rw, r, day.Menus); !ok {
+		return
+	}
+	dh.renderJSON(rw, day)
+}
+
+// GET /api/canteens/{id}/calendar/week?date=YYYY-MM-DD[&drafts=true] — nedelja (pon-ned) kojoj
+// datum pripada; nacrte vidi samo admin.
+func (dh *DiningHandler) GetMenusForWeek(rw http.ResponseWriter, r *http.Request) {
+	canteenId, date, err := canteenDate(r, r.URL.Query().Get("date"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	drafts := r.URL.Query().Get("drafts") == "true" && id.Role == middleware.RoleAdmin
+	days, err := dh.service.MenusForWeek(canteenId, date, drafts)
+	if err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	for i := range days {
+		var ok bool
+		if days[i].Menus, ok = dh.filterMenus(rw, r, days[i].Menus); !ok {
+			return
+		}
+	}
+	dh.renderJSON(rw, days)
+}
+
+// PUT /api/canteens/{id}/calendar/{date}
+// Body: { "menu_ids": ["..."], "note": "..." } ili { "closed": true, "note": "Praznik" }
+func (dh *DiningHandler) OverrideCalendarDate(rw http.ResponseWriter, r *http.Request) {
+	canteenId, date, err := canteenDate(r, mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var in struct {
+		MenuIds []uuid.UUID `json:"menu_ids"`
+		Closed  bool        `json:"closed"`
+		Note    string      `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(rw, "bad json", http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	day, err := dh.service.OverrideDate(canteenId, date, in.MenuIds, in.Closed, in.Note, id.Username)
+	if err != nil {
+		dh.calendarError(rw, err)
+		return
+	}
+	dh.renderJSON(rw, day)
+}
+
+// DELETE /api/canteens/{id}/calendar/{date} — dan se vraca na planove
+func (dh *DiningHandler) ClearCalendarDate(rw http.ResponseWriter, r *http.Request) {
+	canteenId, date, err := canteenDate(r, mux.Vars(r)["date"])
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	day, err := dh.service.ClearOverride(canteenId, date)
+	if err != nil {
+		dh.calendarError(rw, err)
+		return
+	}
+	dh.renderJSON(rw, day)
+}
+
+// POST /api/canteens/{id}/calendar/week/copy
+// Body: { "from": "2025-03-03", "to": "2025-03-10" } — kopira nedelju u nacrt ciljne nedelje
+func (dh *DiningHandler) CopyMenuWeek(rw http.ResponseWriter, r *http.Request) {
+	var in struct {
+		From domain.Date `json:"from"`
+		To   domain.Date `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.From.IsZero() || in.To.IsZero() {
+		http.Error(rw, "from and to dates are required", http.StatusBadRequest)
+		return
+	}
+	canteenId, _, err := canteenDate(r, "")
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	days, err := dh.service.CopyWeek(canteenId, in.From, in.To, id.Username)
+	if err != nil {
+		dh.calendarError(rw, err)
+		return
+	}
+	dh.renderJSON(rw, days)
+}
+
+// POST /api/canteens/{id}/calendar/week/publish
+// Body: { "date": "2025-03-10" } — objavljuje nacrte te nedelje
+func (dh *DiningHandler) PublishMenuWeek(rw http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Date domain.Date `json:"date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Date.IsZero() {
+		http.Error(rw, "date is required", http.StatusBadRequest)
+		return
+	}
+	canteenId, _, err := canteenDate(r, "")
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := dh.service.PublishWeek(canteenId, in.Date)
+	if err != nil {
+		dh.calendarError(rw, err)
+		return
+	}
+	dh.renderJSON(rw, map[string]any{"week_start": in.Date.WeekStart(), "published_days": n})
+}
+
+// GET /api/canteens/{id}/menu-plans
+func (dh *DiningHandler) ListMenuPlans(rw http.ResponseWriter, r *http.Request) {
+	canteenId, _, err := canteenDate(r, "")
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	plans, err := dh.service.ListMenuPlans(canteenId)
+	if err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(rw, plans)
+}
+
+// POST /api/canteens/{id}/menu-plans
+// Body: { "menu_id": "...", "weekday": "Monday", "valid_from": "2025-03-01", "valid_to": "2025-06-30" }
+func (dh *DiningHandler) CreateMenuPlan(rw http.ResponseWriter, r *http.Request) {
+	var p domain.MenuPlan
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(rw, "bad json", http.StatusBadRequest)
+		return
+	}
+	canteenId, _, err := canteenDate(r, "")
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.CanteenId = canteenId
+	if err := dh.service.CreateMenuPlan(&p); err != nil {
+		dh.calendarError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(rw).Encode(p)
+}
+
+// PUT /api/menu-plans/{id}
+// Body: { "valid_to": "2025-06-30" } — poslednji dan vazenja plana
+func (dh *DiningHandler) EndMenuPlan(rw http.ResponseWriter, r *http.Request) {
+	planId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var in struct {
+		ValidTo domain.Date `json:"valid_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.ValidTo.IsZero() {
+		http.Error(rw, "valid_to is required", http.StatusBadRequest)
+		return
+	}
+	if err := dh.service.EndMenuPlan(planId, in.ValidTo); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest) // valid_to pre valid_from krsi CHECK
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/menu-plans/{id}
+func (dh *DiningHandler) DeleteMenuPlan(rw http.ResponseWriter, r *http.Request) {
+	planId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := dh.service.DeleteMenuPlan(planId); err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (dh *DiningHandler) calendarError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidMenuCalendar) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(rw, "Database exception", http.StatusInternalServerError)
+}
+
+// mealFilter cita ?exclude=gluten,milk&tag=vegan; parametri se mogu ponoviti ili nositi listu.
+func mealFilter(r *http.Request) (domain.MealFilter, error) {
+	var f domain.MealFilter
+	q := r.URL.Query()
+	for _, v := range queryList(q["exclude"]) {
+		f.Exclude = append(f.Exclude, domain.Allergen(v))
+	}
+	for _, v := range queryList(q["tag"]) {
+		f.Tags = append(f.Tags, domain.DietaryTag(v))
+	}
+	return f, f.Validate()
+}
+
+func queryList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// dietaryViewer: preference ishrane se primenjuju samo kada menije gleda student.
+func dietaryViewer(r *http.Request) uuid.UUID {
+	id, ok := middleware.IdentityFromContext(r.Context())
+	if !ok || id.Role != middleware.RoleStudent {
+		return uuid.Nil
+	}
+	return id.UserID
+}
+
+// filterMenus primenjuje filter iz upita i preference studenta; false znaci da je greska vec upisana.
+func (dh *DiningHandler) filterMenus(rw http.ResponseWriter, r *http.Request, menus []*domain.Menu) ([]*domain.Menu, bool) {
+	filter, err := mealFilter(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	menus, err = dh.service.FilterMenus(menus, filter, dietaryViewer(r))
+	if err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return nil, false
+	}
+	return menus, true
+}
+
+// GET /api/dietary-preferences/{userId}
+func (dh *DiningHandler) GetDietaryPreferences(rw http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(rw, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	prefs, err := dh.service.GetDietaryPreferences(userId)
+	if err != nil {
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(rw, prefs)
+}
+
+// PUT /api/dietary-preferences/{userId}
+// Body: { "avoid_allergens": ["gluten", "milk"], "tags": ["vegetarian"] }
+func (dh *DiningHandler) SaveDietaryPreferences(rw http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(rw, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	var prefs domain.DietaryPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(rw, "bad json", http.StatusBadRequest)
+		return
+	}
+	prefs.UserId = userId
+	if err := dh.service.SaveDietaryPreferences(&prefs); err != nil {
+		if errors.Is(err, domain.ErrInvalidDietaryInfo) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(rw, prefs)
+}
+
 func (dh *DiningHandler) GetMenusByCanteenID(rw http.ResponseWriter, r *http.Request) {
 	canteenId := mux.Vars(r)["id"]
 
@@ -120,6 +483,10 @@ func (dh *DiningHandler) GetMenusByCanteenID(rw http.ResponseWriter, r *http.Req
 		http.Error(rw, "Database exception", http.StatusInternalServerError)
 		return
 	}
+	menus, ok := dh.filterMenus(rw, r, menus)
+	if !ok {
+		return
+	}
 
 	rw.WriteHeader(http.StatusOK)
 	rw.Header().Set("Content-Type", "application/json")
@@ -131,11 +498,25 @@ func (dh *DiningHandler) CreateMenu(rw http.ResponseWriter, r *http.Request) {
 
 	var menu domain.MenuDTO
 	if err := json.NewDecoder(r.Body).Decode(&menu); err != nil {
-		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+		http.Error(rw, decodeErrorMessage(err, "Invalid request body"), http.StatusBadRequest)
 		return
 	}
+	for _, m := range []domain.Meal{menu.Breakfast, menu.Lunch, menu.Dinner} {
+		if m.Price.IsNegative() || m.Price.Currency != money.DefaultCurrency {
+			http.Error(rw, "Invalid meal price", http.StatusBadRequest)
+			return
+		}
+		if err := m.ValidateDietary(); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	if err := dh.service.CreateMenu(&menu); err != nil {
+		if errors.Is(err, service.ErrInvalidDishInput) || errors.Is(err, domain.ErrInvalidDietaryInfo) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(rw, "Failed to create menu", http.StatusInternalServerError)
 		return
 	}
@@ -215,6 +596,16 @@ func (h *DiningHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existing, err := h.service.GetMenuReview(review.Id.String())
+	if err != nil {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	if id, _ := middleware.IdentityFromContext(r.Context()); existing.UserId != id.UserID {
+		middleware.Deny(w, r, "owner:review")
+		return
+	}
+
 	if err := h.service.UpdateMenuReview(&review); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -231,10 +622,17 @@ func (h *DiningHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	id, _ := middleware.IdentityFromContext(r.Context())
+	menuId, err := uuid.Parse(input.MenuId)
+	if err != nil {
+		http.Error(w, "Invalid menu_id", http.StatusBadRequest)
+		return
+	}
+
 	review := domain.MenuReview{
 		Id:              uuid.New(),
-		MenuId:          uuid.MustParse(input.MenuId),
-		UserId:          uuid.MustParse(input.UserId),
+		MenuId:          menuId,
+		UserId:          id.UserID,
 		BreakfastReview: input.BreakfastReview,
 		LunchReview:     input.LunchReview,
 		DinnerReview:    input.DinnerReview,
@@ -249,17 +647,20 @@ func (h *DiningHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(review)
 }
 
-func (dh *DiningHandler) fetchStudentCard(studentID string) (*domain.StudentCard, error) {
+// fetchStudentCard dohvata karticu pozivaoca iz housing servisa; token se
+// prosleđuje pa housing sam odredjuje ciju karticu vraca.
+func (dh *DiningHandler) fetchStudentCard(r *http.Request) (*domain.StudentCard, error) {
 	client := &http.Client{
 		Timeout: 3 * time.Second,
 	}
 
-	fmt.Println("Fetching student card: ", studentID)
-
-	// URL sa query parametrom studentId
-	url := fmt.Sprintf("http://housing-server:8003/api/housing/students/cards?studentUsername=%s", studentID)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, "http://housing-server:8003/api/housing/students/cards", nil)
+	if err != nil {
+		return nil, err
+	}
+	forwardAuth(req, r)
 
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch student card: %w", err)
 	}
@@ -290,14 +691,13 @@ func (dh *DiningHandler) GetMenu(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	studentIDStr := r.Header.Get("X-Student-ID")
-	if studentIDStr == "" {
-		http.Error(w, "missing student ID", http.StatusBadRequest)
+	// jedan meni se ne filtrira, samo se oznacavaju obroci koji ne odgovaraju studentu
+	if _, err := dh.service.FilterMenus([]*domain.Menu{menu}, domain.MealFilter{}, dietaryViewer(r)); err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
 		return
 	}
 
-	card, err := dh.fetchStudentCard(studentIDStr)
+	card, err := dh.fetchStudentCard(r)
 	if err != nil {
 		fmt.Println("Warning: failed to fetch student card:", err)
 		// možemo i ovde samo logovati, a ne prekidati
@@ -330,56 +730,568 @@ func (dh *DiningHandler) GetTopRatedMeals(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// mealChoice je izbor obroka iz menija; cenu odredjuje server.
+type mealChoice struct {
+	MenuId string            `json:"menuId"`
+	Slots  []domain.MealSlot `json:"slots"`
+	// Dishes: izabrano jelo po slotu; slot bez izbora dobija podrazumevano jelo
+	Dishes domain.DishChoice `json:"dishes"`
+}
+
+// quote cita izbor iz tela zahteva i racuna cenu za pozivaoca; greska je vec upisana u w.
+func (dh *DiningHandler) quote(w http.ResponseWriter, r *http.Request) (*domain.Menu, *domain.MealQuote, bool) {
+	var in mealChoice
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	menu, err := dh.service.GetMenu(in.MenuId)
+	if err != nil {
+		http.Error(w, "menu not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	q, err := dh.service.QuoteMeal(menu, in.Slots, in.Dishes, id.UserID, time.Now())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSlots) || errors.Is(err, service.ErrInvalidDish) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, false
+		}
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return menu, q, true
+}
+
+// POST /api/meal/quote — cena izabranih obroka sa subvencijama, bez naplate.
+// Body: { "menuId": "...", "slots": ["breakfast", "lunch"], "dishes": { "lunch": "<id jela>" } }
+func (dh *DiningHandler) QuoteMeal(w http.ResponseWriter, r *http.Request) {
+	if _, q, ok := dh.quote(w, r); ok {
+		dh.renderJSON(w, q)
+	}
+}
+
+// TakeMeal: POST /api/meal/ (Idempotency-Key: <jedinstven po kupovini>)
+// Body: { "menuId": "...", "slots": ["breakfast", "lunch"], "dishes": { "lunch": "<id jela>" } }
+// Cena se racuna iz menija i subvencija; kupovina se naplacuje preko housing servisa,
+// a ponovljen zahtev sa istim kljucem vraca istu kupovinu umesto nove naplate.
 func (dh *DiningHandler) TakeMeal(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" || len(key) > 128 {
+		http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
+		return
+	}
+	menu, q, ok := dh.quote(w, r)
+	if !ok {
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+
+	p := &domain.MealPurchase{
+		IdempotencyKey: key,
+		UserId:         id.UserID,
+		Username:       id.Username,
+		MenuId:         menu.Id,
+		CanteenId:      menu.CanteenId,
+		Slots:          q.Slots,
+		Dishes:         q.Dishes,
+		ListPrice:      q.ListPrice,
+		Discount:       q.Discount,
+		Amount:         q.Amount,
+	}
+	created, err := dh.service.PurchaseMeal(r.Context(), menu, p)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrIdempotencyKeyReused):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, service.ErrMenuNotToday),
+			errors.Is(err, service.ErrCanteenClosed),
+			errors.Is(err, service.ErrOutsideServingWindow),
+			errors.Is(err, domain.ErrSlotAlreadyServed),
+			errors.Is(err, domain.ErrDishSoldOut),
+			errors.Is(err, domain.ErrDishNotFound):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+
+	dh.writePurchase(w, p, created)
+}
+
+// POST /api/meal/pass — QR propusnica za izabrane obroke, vazi kratko i samo jednom
+// Body: kao za /api/meal/quote. Sa ?format=png odgovor je sama PNG slika QR koda.
+func (dh *DiningHandler) IssueMealPass(w http.ResponseWriter, r *http.Request) {
+	menu, q, ok := dh.quote(w, r)
+	if !ok {
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	pass, token, err := dh.service.IssueMealPass(menu, q, id.UserID, id.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMenuNotToday),
+			errors.Is(err, service.ErrCanteenClosed),
+			errors.Is(err, service.ErrOutsideServingWindow):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	png, err := service.MealPassQR(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "png" {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(png)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(struct {
+		Token string           `json:"token"`
+		Pass  *domain.MealPass `json:"pass"`
+		QR    string           `json:"qr_png"` // data URL, moze direktno u <img src>
+	}{token, pass, "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)})
+}
+
+// POST /api/meal/redeem — kasa skenira QR propusnicu i izdaje obroke
+// Body: { "token": "<sadrzaj QR koda>" }. Odgovor je kupovina kao za /api/meal/.
+func (dh *DiningHandler) RedeemMealPass(w http.ResponseWriter, r *http.Request) {
 	var in struct {
-		StudentUsername string  `json:"studentUsername"`
-		Delta           float64 `json:"delta"`
-		MenuId          string  `json:"menuId"`
-		StudentID       string  `json:"studentId"`
+		Token string `json:"token"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	p, err := dh.service.RedeemMealPass(r.Context(), in.Token, id.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidMealPass):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case errors.Is(err, domain.ErrMenuNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrMealPassUsed),
+			errors.Is(err, service.ErrMenuNotToday),
+			errors.Is(err, service.ErrCanteenClosed),
+			errors.Is(err, service.ErrOutsideServingWindow),
+			errors.Is(err, domain.ErrSlotAlreadyServed),
+			errors.Is(err, domain.ErrDishSoldOut),
+			errors.Is(err, domain.ErrDishNotFound),
+			errors.Is(err, service.ErrInvalidSlots),
+			errors.Is(err, service.ErrInvalidDish):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.writePurchase(w, p, true)
+}
 
-	client := &http.Client{Timeout: 3 * time.Second}
+// GET /api/meal/purchases/{id} — stanje kupovine (vlasnik ili admin)
+func (dh *DiningHandler) GetMealPurchase(w http.ResponseWriter, r *http.Request) {
+	purchaseId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	p, err := dh.service.GetMealPurchase(purchaseId)
+	if err != nil {
+		http.Error(w, "purchase not found", http.StatusNotFound)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	if p.UserId != id.UserID && id.Role != middleware.RoleAdmin {
+		http.Error(w, "purchase not found", http.StatusNotFound)
+		return
+	}
+	dh.writePurchase(w, p, false)
+}
 
-	url := "http://housing-server:8003/api/housing/students/cards/balance"
+// writePurchase: zavrsena kupovina je 201 (nova) ili 200, odbijena zbog nedovoljno
+// sredstava 402, ostale odbijene ili ponistene 409, a ona koja se jos obradjuje 202 —
+// dovrsice je oporavak.
+func (dh *DiningHandler) writePurchase(w http.ResponseWriter, p *domain.MealPurchase, created bool) {
+	status := http.StatusAccepted
+	switch p.State {
+	case domain.PurchaseCompleted:
+		status = http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+	case domain.PurchaseFailed, domain.PurchaseRefunded:
+		status = http.StatusConflict
+		if p.FailureCode != nil && *p.FailureCode == domain.FailureInsufficientFunds {
+			status = http.StatusPaymentRequired
+		}
+	}
+	js, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// POST /api/subsidies/ — admin dodeljuje subvenciju ili popust studentu.
+func (dh *DiningHandler) CreateMealSubsidy(w http.ResponseWriter, r *http.Request) {
+	var s domain.MealSubsidy
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, decodeErrorMessage(err, "bad json"), http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	s.CreatedBy = id.Username
+	if err := dh.service.CreateMealSubsidy(&s); err != nil {
+		if errors.Is(err, service.ErrInvalidSubsidy) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(s)
+}
 
-	reqBody, _ := json.Marshal(in)
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(reqBody))
+// GET /api/subsidies/{userId}
+func (dh *DiningHandler) GetMealSubsidies(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["userId"])
 	if err != nil {
-		http.Error(w, "failed to contact housing service", http.StatusBadGateway)
+		http.Error(w, "invalid user id", http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
+	subsidies, err := dh.service.GetMealSubsidies(userId)
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, subsidies)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		http.Error(w, "failed to update balance", resp.StatusCode)
+// DELETE /api/subsidies/{id}
+func (dh *DiningHandler) DeleteMealSubsidy(w http.ResponseWriter, r *http.Request) {
+	subsidyId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
+	if err := dh.service.DeleteMealSubsidy(subsidyId); err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	menu, _ := dh.service.GetMenu(in.MenuId)
+// POST /api/menus/{id}/dishes — dodatno jelo za slot menija
+// Body: { "slot": "lunch", "name": "...", "price": {...}, "stock": 40, "allergens": [...], "tags": [...] }
+func (dh *DiningHandler) AddMenuDish(w http.ResponseWriter, r *http.Request) {
+	menuId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid menu id", http.StatusBadRequest)
+		return
+	}
+	var d domain.Dish
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, decodeErrorMessage(err, "bad json"), http.StatusBadRequest)
+		return
+	}
+	d.MenuId = menuId
+	if err := dh.service.AddMenuDish(&d); err != nil {
+		dh.dishError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(d)
+}
 
-	mh := &domain.MealHistory{
-		MenuId:     in.MenuId,
-		MenuName:   menu.Name,
-		SelectedAt: time.Time{},
+// PUT /api/dishes/{id}/stock
+// Body: { "stock": 40 } — porcija dnevno; { "stock": null } ukida ogranicenje
+func (dh *DiningHandler) SetDishStock(w http.ResponseWriter, r *http.Request) {
+	mealId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid dish id", http.StatusBadRequest)
+		return
+	}
+	var in struct {
+		Stock *int `json:"stock"`
 	}
-	err = dh.service.CreateMealHistory(mh, in.StudentID)
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := dh.service.SetDishStock(mealId, in.Stock); err != nil {
+		dh.dishError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/dishes/{id} — uklanja dodatno jelo; podrazumevano jelo slota ostaje
+func (dh *DiningHandler) DeleteMenuDish(w http.ResponseWriter, r *http.Request) {
+	mealId, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		fmt.Println("Warning: failed to create meal history:", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "invalid dish id", http.StatusBadRequest)
+		return
 	}
+	if err := dh.service.DeleteMenuDish(mealId); err != nil {
+		dh.dishError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	err = dh.service.IncrementPopularMeal(menu.Id, menu.CanteenId)
+// PUT /api/dishes/{id}/review
+// Body: { "rating": 4, "comment": "..." } — jedna ocena po studentu, ponovni upis je menja
+func (dh *DiningHandler) ReviewDish(w http.ResponseWriter, r *http.Request) {
+	mealId, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		fmt.Println("Warning: failed to increment popular meal:", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "invalid dish id", http.StatusBadRequest)
+		return
 	}
+	var rv domain.DishReview
+	if err := json.NewDecoder(r.Body).Decode(&rv); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	rv.MealId = mealId
+	rv.UserId = id.UserID
+	if err := dh.service.ReviewDish(&rv); err != nil {
+		dh.dishError(w, err)
+		return
+	}
+	dh.renderJSON(w, rv)
+}
 
+// GET /api/dishes/{id}/reviews
+func (dh *DiningHandler) GetDishReviews(w http.ResponseWriter, r *http.Request) {
+	mealId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid dish id", http.StatusBadRequest)
+		return
+	}
+	reviews, err := dh.service.ListDishReviews(mealId)
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, reviews)
+}
+
+// GET /api/dishes/history/{userId} — pojedena jela studenta sa njegovim ocenama
+func (dh *DiningHandler) GetDishHistory(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	history, err := dh.service.DishHistory(userId)
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, history)
+}
+
+// GET /api/canteens/{id}/popular-dishes
+func (dh *DiningHandler) GetPopularDishes(w http.ResponseWriter, r *http.Request) {
+	canteenId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid canteen id", http.StatusBadRequest)
+		return
+	}
+	stats, err := dh.service.PopularDishes(canteenId)
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, stats)
+}
+
+// GET /api/dishes/top-rated
+func (dh *DiningHandler) GetTopRatedDishes(w http.ResponseWriter, r *http.Request) {
+	stats, err := dh.service.TopRatedDishes()
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, stats)
+}
+
+func (dh *DiningHandler) dishError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidDishInput), errors.Is(err, service.ErrInvalidRating),
+		errors.Is(err, domain.ErrInvalidDietaryInfo):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrDishNotEaten):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrDishNotFound), errors.Is(err, domain.ErrMenuNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrDefaultDish):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+	}
+}
+
+// POST /api/reservations/ — rezervacija obroka unapred
+// Body: { "menu_id": "...", "slot": "lunch", "date": "2025-03-10", "dish_id": "..." } (dish_id nije obavezan)
+func (dh *DiningHandler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		MenuId uuid.UUID       `json:"menu_id"`
+		Slot   domain.MealSlot `json:"slot"`
+		Date   domain.Date     `json:"date"`
+		DishId uuid.UUID       `json:"dish_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		msg := "bad json"
+		if errors.Is(err, domain.ErrInvalidDate) {
+			msg = err.Error()
+		}
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	res := &domain.Reservation{
+		UserId:   id.UserID,
+		Username: id.Username,
+		MenuId:   in.MenuId,
+		Slot:     in.Slot,
+		Date:     in.Date,
+		DishId:   in.DishId,
+	}
+	if err := dh.service.Reserve(res); err != nil {
+		dh.reservationError(w, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	io.Copy(w, resp.Body)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// GET /api/reservations/user/{userId} — rezervacije studenta, najnovije prve
+func (dh *DiningHandler) GetUserReservations(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	list, err := dh.service.ListReservations(userId)
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, list)
+}
+
+// POST /api/reservations/{id}/cancel — otkazivanje do roka (vlasnik) ili bilo kad (admin)
+func (dh *DiningHandler) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	resId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	res, err := dh.service.CancelReservation(resId, id.UserID, id.Role == middleware.RoleAdmin)
+	if err != nil {
+		dh.reservationError(w, err)
+		return
+	}
+	dh.renderJSON(w, res)
+}
+
+// GET /api/canteens/{id}/capacity — najvise rezervacija po obroku i danu
+func (dh *DiningHandler) GetSlotCapacities(w http.ResponseWriter, r *http.Request) {
+	canteenId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid canteen id", http.StatusBadRequest)
+		return
+	}
+	caps, err := dh.service.GetSlotCapacities(canteenId)
+	if err != nil {
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+		return
+	}
+	dh.renderJSON(w, caps)
+}
+
+// PUT /api/canteens/{id}/capacity
+// Body: [ { "slot": "lunch", "capacity": 200 }, ... ] — slot koji nije naveden nema ogranicenje
+func (dh *DiningHandler) SetSlotCapacities(w http.ResponseWriter, r *http.Request) {
+	canteenId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid canteen id", http.StatusBadRequest)
+		return
+	}
+	var caps []domain.SlotCapacity
+	if err := json.NewDecoder(r.Body).Decode(&caps); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := dh.service.SetSlotCapacities(canteenId, caps); err != nil {
+		if errors.Is(err, service.ErrInvalidCapacity) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "canteen not found", http.StatusNotFound)
+		return
+	}
+	dh.renderJSON(w, caps)
+}
+
+// GET /api/canteens/{id}/forecast?from=YYYY-MM-DD&days=7 — procena porcija iz rezervacija
+func (dh *DiningHandler) GetReservationForecast(w http.ResponseWriter, r *http.Request) {
+	canteenId, from, err := canteenDate(r, r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days := 7
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		if days, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "days must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	f, err := dh.service.Forecast(canteenId, from, days)
+	if err != nil {
+		dh.reservationError(w, err)
+		return
+	}
+	dh.renderJSON(w, f)
+}
+
+func (dh *DiningHandler) reservationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidReservation), errors.Is(err, service.ErrInvalidDish):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrReservationNotFound), errors.Is(err, domain.ErrMenuNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrCapacityReached), errors.Is(err, domain.ErrAlreadyReserved), errors.Is(err, domain.ErrDishSoldOut),
+		errors.Is(err, service.ErrReservationCutoff), errors.Is(err, service.ErrReservationInactive):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Database exception", http.StatusInternalServerError)
+	}
 }
 
 func (dh *DiningHandler) CheckDoesStudentInRoom(w http.ResponseWriter, r *http.Request) {
@@ -392,7 +1304,14 @@ func (dh *DiningHandler) CheckDoesStudentInRoom(w http.ResponseWriter, r *http.R
 
 	url := fmt.Sprintf("http://housing-server:8003/api/housing/rooms/checkStudent/%s", userId)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	forwardAuth(req, r)
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		http.Error(w, "Failed to contact housing service: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -415,41 +1334,31 @@ func (dh *DiningHandler) CheckDoesStudentInRoom(w http.ResponseWriter, r *http.R
 	w.Write(body)
 }
 
+// GetMealRoomHistory: istorija obroka stanara sobe; spisak stanara salje housing
+// servis (servisni token), jer samo on zna ko stanuje u sobi.
 func (dh *DiningHandler) GetMealRoomHistory(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
 	var request struct {
 		Usernames []string `json:"usernames"`
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		log.Printf("Invalid JSON format: %v", err)
-		// vraćamo praznu listu
-		json.NewEncoder(w).Encode([]domain.MealRoomHistory{})
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
 	if len(request.Usernames) == 0 {
-		log.Printf("Usernames array empty")
-		// vraćamo praznu listu
-		json.NewEncoder(w).Encode([]domain.MealRoomHistory{})
+		dh.renderJSON(w, []domain.MealRoomHistory{})
 		return
 	}
 
 	history, err := dh.service.GetMealHistoryForUsernames(request.Usernames)
 	if err != nil {
-		log.Printf("Failed to get meal history for usernames %v: %v", request.Usernames, err)
-		// vraćamo praznu listu
-		json.NewEncoder(w).Encode([]domain.MealRoomHistory{})
+		log.Printf("meal history for %d usernames: %v", len(request.Usernames), err)
+		http.Error(w, "Database exception", http.StatusInternalServerError)
 		return
 	}
-
 	if history == nil {
 		history = []domain.MealRoomHistory{}
 	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(history)
+	dh.renderJSON(w, history)
 }
 
 // NEW: HTTP handler koji vraća sve menije za DANAŠNJI dan (lokalno vreme servera)
@@ -459,11 +1368,30 @@ func (dh *DiningHandler) GetTodayMenus(rw http.ResponseWriter, r *http.Request)
 		http.Error(rw, "Database exception", http.StatusInternalServerError)
 		return
 	}
+	menus, ok := dh.filterMenus(rw, r, menus)
+	if !ok {
+		return
+	}
 	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(http.StatusOK)
 	dh.renderJSON(rw, menus)
 }
 
+// forwardAuth prosleđuje JWT pozivaoca ka drugom servisu.
+func forwardAuth(out *http.Request, in *http.Request) {
+	if h := in.Header.Get("Authorization"); h != "" {
+		out.Header.Set("Authorization", h)
+	}
+}
+
+// decodeErrorMessage: neispravan novcani iznos (npr. deo pare) se prijavljuje kao takav.
+func decodeErrorMessage(err error, fallback string) string {
+	if errors.Is(err, money.ErrInvalidAmount) || errors.Is(err, money.ErrSubMinorAmount) {
+		return err.Error()
+	}
+	return fallback
+}
+
 func (dh *DiningHandler) renderJSON(w http.ResponseWriter, v interface{}) {
 	js, err := json.Marshal(v)
 
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"common/middleware"
+	"common/servicetoken"
 	"context"
 	"dining/handler"
 	"dining/repo"
@@ -11,6 +13,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // TZ odredjuje "danas" i radno vreme kantina i u kontejneru bez zoneinfo
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -27,40 +30,105 @@ func main() {
 		log.Fatal("Creating repository error: ", err)
 	}
 
+	usersURL := envOr("USERS_BASE_URL", "http://user-server:8002")
+
 	// Service Init
-	diningService := service.NewDiningService(repository)
+	// Naplata obroka ide preko housing servisa sa servisnim tokenom (client credentials)
+	serviceTokens := servicetoken.New(usersURL, envOr("SERVICE_CLIENT_ID", "dining_service"), os.Getenv("SERVICE_CLIENT_SECRET"))
+	cards := service.NewHousingCards(envOr("HOUSING_BASE_URL", "http://housing-server:8003"), serviceTokens)
+	// QR propusnice za obrok na kasi potpisuje sam dining servis
+	passSecret := os.Getenv("MEAL_PASS_SECRET")
+	if passSecret == "" {
+		log.Fatal("MEAL_PASS_SECRET is required")
+	}
+	diningService := service.NewDiningService(repository, cards, service.NewMealPasses(passSecret))
 
 	// Handler Init
 	diningHandler := handler.NewDiningHandler(*diningService)
 
-	// Rute
-	router.HandleFunc("/api/canteens/", diningHandler.GetAllCanteens).Methods(http.MethodGet)
-	router.HandleFunc("/api/canteens/{id}", diningHandler.GetCanteen).Methods(http.MethodGet)
-	router.HandleFunc("/api/canteens/{id}", diningHandler.DeleteCanteen).Methods(http.MethodDelete)
-	router.HandleFunc("/api/canteens/", diningHandler.CreateCanteen).Methods(http.MethodPost)
-	router.HandleFunc("/api/canteens/popular-meals/{id}", diningHandler.GetPopularMeals).Methods(http.MethodGet)
-	router.HandleFunc("/api/canteens/meal-history/{id}", diningHandler.GetMealHistory).Methods(http.MethodGet)
-	router.HandleFunc("/api/canteens/meal-history/", diningHandler.GetMealRoomHistory).Methods(http.MethodPost)
-
-	router.HandleFunc("/api/menus/{id}", diningHandler.GetMenusByCanteenID).Methods(http.MethodGet)
-	router.HandleFunc("/api/menus/{id}", diningHandler.DeleteMenu).Methods(http.MethodDelete)
-	router.HandleFunc("/api/menus/", diningHandler.CreateMenu).Methods(http.MethodPost)
-	router.HandleFunc("/api/menu/{id}", diningHandler.GetMenu).Methods(http.MethodGet)
-
-	router.HandleFunc("/api/menus/reviews/", diningHandler.CreateReview).Methods(http.MethodPost)
-	router.HandleFunc("/api/menus/reviews/", diningHandler.UpdateReview).Methods(http.MethodPut)
-	router.HandleFunc("/api/menus/reviews/{userId}", diningHandler.GetMealHistoryWithReviews).Methods(http.MethodGet)
-	router.HandleFunc("/api/menus/top-rated/", diningHandler.GetTopRatedMeals).Methods(http.MethodGet)
-	router.HandleFunc("/api/menus/checkStudent/{userId}", diningHandler.CheckDoesStudentInRoom).Methods(http.MethodGet)
-
-	router.HandleFunc("/api/meal/", diningHandler.TakeMeal).Methods(http.MethodPost)
-
-	router.HandleFunc("/api/dining/menus/today", diningHandler.GetTodayMenus).Methods("GET")
+	// Pozadinski poslovi zive dok server radi
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+
+	// Oporavak kupovina obroka zaglavljenih usred naplate
+	go diningService.RunPurchaseRecovery(bgCtx, 10*time.Second)
+	// Naplata rezervacija kada obrok pocne da se izdaje
+	go diningService.RunReservationCharging(bgCtx, 30*time.Second)
+
+	// Auth: svi /api zahtevi moraju imati validan, neopozvan JWT izdat od users_service
+	jwks := middleware.NewJWKS(usersURL)
+	go jwks.Run(bgCtx, 5*time.Minute)
+	revocations := middleware.NewRevocationList(usersURL, serviceTokens)
+	go revocations.Run(bgCtx, 5*time.Second)
+	router.Use(middleware.Authenticate(jwks, revocations))
+
+	// Rute (politika pristupa je deklarisana uz svaku rutu)
+	router.Handle("/api/canteens/", middleware.Require(middleware.Authenticated, diningHandler.GetAllCanteens)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}", middleware.Require(middleware.Authenticated, diningHandler.GetCanteen)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}", middleware.Require(middleware.Admin, diningHandler.DeleteCanteen)).Methods(http.MethodDelete)
+	router.Handle("/api/canteens/", middleware.Require(middleware.Admin, diningHandler.CreateCanteen)).Methods(http.MethodPost)
+	router.Handle("/api/canteens/{id}/windows", middleware.Require(middleware.Authenticated, diningHandler.GetServingWindows)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}/windows", middleware.Require(middleware.Admin, diningHandler.SetServingWindows)).Methods(http.MethodPut)
+	// Kalendar menija: planovi sa periodom vazenja, izuzeci za datume, kopiranje i objava nedelje
+	router.Handle("/api/canteens/{id}/calendar", middleware.Require(middleware.Authenticated, diningHandler.GetMenusForDate)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}/calendar/week", middleware.Require(middleware.Authenticated, diningHandler.GetMenusForWeek)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}/calendar/week/copy", middleware.Require(middleware.Admin, diningHandler.CopyMenuWeek)).Methods(http.MethodPost)
+	router.Handle("/api/canteens/{id}/calendar/week/publish", middleware.Require(middleware.Admin, diningHandler.PublishMenuWeek)).Methods(http.MethodPost)
+	router.Handle("/api/canteens/{id}/calendar/{date}", middleware.Require(middleware.Admin, diningHandler.OverrideCalendarDate)).Methods(http.MethodPut)
+	router.Handle("/api/canteens/{id}/calendar/{date}", middleware.Require(middleware.Admin, diningHandler.ClearCalendarDate)).Methods(http.MethodDelete)
+	router.Handle("/api/canteens/{id}/menu-plans", middleware.Require(middleware.Authenticated, diningHandler.ListMenuPlans)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}/menu-plans", middleware.Require(middleware.Admin, diningHandler.CreateMenuPlan)).Methods(http.MethodPost)
+	router.Handle("/api/menu-plans/{id}", middleware.Require(middleware.Admin, diningHandler.EndMenuPlan)).Methods(http.MethodPut)
+	router.Handle("/api/menu-plans/{id}", middleware.Require(middleware.Admin, diningHandler.DeleteMenuPlan)).Methods(http.MethodDelete)
+	router.Handle("/api/canteens/popular-meals/{id}", middleware.Require(middleware.Authenticated, diningHandler.GetPopularMeals)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/meal-history/{id}", middleware.Require(ownerOrAdmin("id"), diningHandler.GetMealHistory)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/meal-history/", middleware.Require(middleware.AnyOf(middleware.Service, middleware.Admin), diningHandler.GetMealRoomHistory)).Methods(http.MethodPost) // spisak stanara salje housing
+
+	router.Handle("/api/menus/{id}", middleware.Require(middleware.Authenticated, diningHandler.GetMenusByCanteenID)).Methods(http.MethodGet)
+	router.Handle("/api/menus/{id}", middleware.Require(middleware.Admin, diningHandler.DeleteMenu)).Methods(http.MethodDelete)
+	router.Handle("/api/menus/", middleware.Require(middleware.Admin, diningHandler.CreateMenu)).Methods(http.MethodPost)
+	router.Handle("/api/menu/{id}", middleware.Require(middleware.Authenticated, diningHandler.GetMenu)).Methods(http.MethodGet)
+
+	router.Handle("/api/menus/reviews/", middleware.Require(middleware.Student, diningHandler.CreateReview)).Methods(http.MethodPost)
+	router.Handle("/api/menus/reviews/", middleware.Require(middleware.Student, diningHandler.UpdateReview)).Methods(http.MethodPut) // vlasnistvo se proverava u handleru
+	router.Handle("/api/menus/reviews/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.GetMealHistoryWithReviews)).Methods(http.MethodGet)
+	router.Handle("/api/menus/top-rated/", middleware.Require(middleware.Authenticated, diningHandler.GetTopRatedMeals)).Methods(http.MethodGet)
+	router.Handle("/api/menus/checkStudent/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.CheckDoesStudentInRoom)).Methods(http.MethodGet)
+
+	router.Handle("/api/meal/", middleware.Require(middleware.Student, diningHandler.TakeMeal)).Methods(http.MethodPost) // zahteva Idempotency-Key
+	router.Handle("/api/meal/quote", middleware.Require(middleware.Student, diningHandler.QuoteMeal)).Methods(http.MethodPost)
+	router.Handle("/api/meal/pass", middleware.Require(middleware.Student, diningHandler.IssueMealPass)).Methods(http.MethodPost)
+	router.Handle("/api/meal/redeem", middleware.Require(cashierOrAdmin, diningHandler.RedeemMealPass)).Methods(http.MethodPost)
+	router.Handle("/api/meal/purchases/{id}", middleware.Require(middleware.Authenticated, diningHandler.GetMealPurchase)).Methods(http.MethodGet)
+
+	// Jela po slotu menija: zalihe, ocene, popularnost i istorija po jelu
+	router.Handle("/api/menus/{id}/dishes", middleware.Require(middleware.Admin, diningHandler.AddMenuDish)).Methods(http.MethodPost)
+	router.Handle("/api/dishes/top-rated", middleware.Require(middleware.Authenticated, diningHandler.GetTopRatedDishes)).Methods(http.MethodGet)
+	router.Handle("/api/dishes/history/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.GetDishHistory)).Methods(http.MethodGet)
+	router.Handle("/api/dishes/{id}/stock", middleware.Require(middleware.Admin, diningHandler.SetDishStock)).Methods(http.MethodPut)
+	router.Handle("/api/dishes/{id}/review", middleware.Require(middleware.Student, diningHandler.ReviewDish)).Methods(http.MethodPut)
+	router.Handle("/api/dishes/{id}/reviews", middleware.Require(middleware.Authenticated, diningHandler.GetDishReviews)).Methods(http.MethodGet)
+	router.Handle("/api/dishes/{id}", middleware.Require(middleware.Admin, diningHandler.DeleteMenuDish)).Methods(http.MethodDelete)
+	router.Handle("/api/canteens/{id}/popular-dishes", middleware.Require(middleware.Authenticated, diningHandler.GetPopularDishes)).Methods(http.MethodGet)
+	// Rezervacije obroka unapred, kapacitet kantine i procena porcija
+	router.Handle("/api/reservations/", middleware.Require(middleware.Student, diningHandler.CreateReservation)).Methods(http.MethodPost)
+	router.Handle("/api/reservations/user/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.GetUserReservations)).Methods(http.MethodGet)
+	router.Handle("/api/reservations/{id}/cancel", middleware.Require(middleware.Authenticated, diningHandler.CancelReservation)).Methods(http.MethodPost) // vlasnistvo se proverava u servisu
+	router.Handle("/api/canteens/{id}/capacity", middleware.Require(middleware.Authenticated, diningHandler.GetSlotCapacities)).Methods(http.MethodGet)
+	router.Handle("/api/canteens/{id}/capacity", middleware.Require(middleware.Admin, diningHandler.SetSlotCapacities)).Methods(http.MethodPut)
+	router.Handle("/api/canteens/{id}/forecast", middleware.Require(middleware.Admin, diningHandler.GetReservationForecast)).Methods(http.MethodGet)
+	router.Handle("/api/dietary-preferences/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.GetDietaryPreferences)).Methods(http.MethodGet)
+	router.Handle("/api/dietary-preferences/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.SaveDietaryPreferences)).Methods(http.MethodPut)
+	router.Handle("/api/subsidies/", middleware.Require(middleware.Admin, diningHandler.CreateMealSubsidy)).Methods(http.MethodPost)
+	router.Handle("/api/subsidies/{userId}", middleware.Require(ownerOrAdmin("userId"), diningHandler.GetMealSubsidies)).Methods(http.MethodGet)
+	router.Handle("/api/subsidies/{id}", middleware.Require(middleware.Admin, diningHandler.DeleteMealSubsidy)).Methods(http.MethodDelete)
+
+	router.Handle("/api/dining/menus/today", middleware.Require(middleware.Authenticated, diningHandler.GetTodayMenus)).Methods(http.MethodGet)
 
 	corsObj := handlers.CORS(
 		handlers.AllowedOrigins([]string{"http://localhost:4200"}), // Angular frontend
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Student-ID"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "Idempotency-Key"}),
 	)
 
 	port := os.Getenv("PORT")
@@ -87,7 +155,7 @@ func main() {
 	}()
 
 	// Graceful shutdown
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
@@ -99,3 +167,16 @@ func main() {
 	}
 	log.Println("server stopped")
 }
+
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+var cashierOrAdmin = middleware.AnyOf(middleware.Cashier, middleware.Admin)
+
+func ownerOrAdmin(param string) middleware.Policy {
+	return middleware.AnyOf(middleware.Owner(param), middleware.Admin)
+}
@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"database/sql"
+	"dining/domain"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// mealColumns vraca kolone obroka za alias tabele (meniji JOIN-uju meals tri puta).
+func mealColumns(alias string) string {
+	return fmt.Sprintf(`%[1]s.id, %[1]s.name, %[1]s.description, %[1]s.price, %[1]s.allergens, %[1]s.tags,
+		%[1]s.calories, %[1]s.protein_g, %[1]s.carbs_g, %[1]s.fat_g`, alias)
+}
+
+var menuWithMealsColumns = `m.id, m.name, m.canteen_id, m.weekday, ` +
+	mealColumns("b") + `, ` + mealColumns("l") + `, ` + mealColumns("d")
+
+// mealRow prihvata kolone obroka iz mealColumns; finish prepisuje nizove i
+// nutritivne vrednosti u obrok (bez kalorija obrok nema nutritivnih podataka).
+type mealRow struct {
+	meal                *domain.Meal
+	allergens, tags     pq.StringArray
+	calories            sql.NullInt64
+	protein, carbs, fat sql.NullFloat64
+}
+
+func newMealRow(m *domain.Meal) *mealRow {
+	return &mealRow{meal: m}
+}
+
+func (r *mealRow) dest() []any {
+	return []any{&r.meal.Id, &r.meal.Name, &r.meal.Description, &r.meal.Price, &r.allergens, &r.tags,
+		&r.calories, &r.protein, &r.carbs, &r.fat}
+}
+
+func (r *mealRow) finish() {
+	r.meal.Allergens = make([]domain.Allergen, len(r.allergens))
+	for i, a := range r.allergens {
+		r.meal.Allergens[i] = domain.Allergen(a)
+	}
+	r.meal.Tags = make([]domain.DietaryTag, len(r.tags))
+	for i, t := range r.tags {
+		r.meal.Tags[i] = domain.DietaryTag(t)
+	}
+	r.meal.Nutrition = nil
+	if r.calories.Valid {
+		r.meal.Nutrition = &domain.Nutrition{
+			Calories: int(r.calories.Int64),
+			Protein:  r.protein.Float64,
+			Carbs:    r.carbs.Float64,
+			Fat:      r.fat.Float64,
+		}
+	}
+}
+
+func scanMenuWithMeals(row interface{ Scan(...any) error }, m *domain.Menu) error {
+	meals := []*mealRow{newMealRow(&m.Breakfast), newMealRow(&m.Lunch), newMealRow(&m.Dinner)}
+	dest := []any{&m.Id, &m.Name, &m.CanteenId, &m.Weekday}
+	for _, mr := range meals {
+		dest = append(dest, mr.dest()...)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	for _, mr := range meals {
+		mr.finish()
+	}
+	return nil
+}
+
+func nutritionArgs(n *domain.Nutrition) (calories, protein, carbs, fat any) {
+	if n == nil {
+		return nil, nil, nil, nil
+	}
+	return n.Calories, n.Protein, n.Carbs, n.Fat
+}
+
+func allergenStrings(list []domain.Allergen) pq.StringArray {
+	out := make(pq.StringArray, len(list))
+	for i, a := range list {
+		out[i] = string(a)
+	}
+	return out
+}
+
+func tagStrings(list []domain.DietaryTag) pq.StringArray {
+	out := make(pq.StringArray, len(list))
+	for i, t := range list {
+		out[i] = string(t)
+	}
+	return out
+}
+
+// GetDietaryPreferences vraca preference studenta; student koji ih nije zadao dobija prazne.
+func (r *DiningRepo) GetDietaryPreferences(userId uuid.UUID) (*domain.DietaryPreferences, error) {
+	p := domain.DietaryPreferences{UserId: userId, AvoidAllergens: []domain.Allergen{}, Tags: []domain.DietaryTag{}}
+	var allergens, tags pq.StringArray
+	err := r.DB.QueryRow(
+		`SELECT avoid_allergens, tags, updated_at FROM dietary_preferences WHERE user_id = $1`, userId,
+	).Scan(&allergens, &tags, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range allergens {
+		p.AvoidAllergens = append(p.AvoidAllergens, domain.Allergen(a))
+	}
+	for _, t := range tags {
+		p.Tags = append(p.Tags, domain.DietaryTag(t))
+	}
+	return &p, nil
+}
+
+func (r *DiningRepo) SaveDietaryPreferences(p *domain.DietaryPreferences) error {
+	return r.DB.QueryRow(
+		`INSERT INTO dietary_preferences (user_id, avoid_allergens, tags, updated_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (user_id)
+		 DO UPDATE SET avoid_allergens = EXCLUDED.avoid_allergens, tags = EXCLUDED.tags, updated_at = NOW()
+		 RETURNING updated_at`,
+		p.UserId, allergenStrings(p.AvoidAllergens), tagStrings(p.Tags),
+	).Scan(&p.UpdatedAt)
+}
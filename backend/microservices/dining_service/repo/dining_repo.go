@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"common/money"
 	"database/sql"
 	"dining/domain"
 	"fmt"
@@ -125,6 +126,191 @@ func (r *DiningRepo) Migrate() error {
 			menu_id UUID NOT NULL REFERENCES menus(id) ON DELETE CASCADE,
 			selected_at TIMESTAMP NOT NULL DEFAULT NOW()
 		);`,
+
+		// Kupovine obroka (saga naplate preko housing servisa)
+		`CREATE TABLE IF NOT EXISTS meal_purchases (
+			id UUID PRIMARY KEY,
+			idempotency_key TEXT NOT NULL,
+			user_id UUID NOT NULL,
+			username TEXT NOT NULL,
+			menu_id UUID NOT NULL,
+			canteen_id UUID NOT NULL,
+			amount NUMERIC NOT NULL,
+			state TEXT NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (user_id, idempotency_key)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_meal_purchases_open ON meal_purchases(updated_at)
+			WHERE state IN ('pending', 'charged', 'recorded', 'refunding');`,
+		// Cena se racuna na serveru iz menija (slotovi) uz subvencije
+		`ALTER TABLE meal_purchases ADD COLUMN IF NOT EXISTS slots TEXT[] NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE meal_purchases ADD COLUMN IF NOT EXISTS list_price NUMERIC NOT NULL DEFAULT 0;`,
+		`ALTER TABLE meal_purchases ADD COLUMN IF NOT EXISTS discount NUMERIC NOT NULL DEFAULT 0;`,
+		`ALTER TABLE meal_purchases ADD COLUMN IF NOT EXISTS failure_code TEXT;`,
+
+		// Subvencije i popusti po studentu
+		`CREATE TABLE IF NOT EXISTS meal_subsidies (
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL,
+			kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+			percent INT NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+			amount_off NUMERIC NOT NULL DEFAULT 0 CHECK (amount_off >= 0),
+			slot TEXT,
+			valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			valid_to TIMESTAMPTZ,
+			note TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_meal_subsidies_user ON meal_subsidies(user_id);`,
+
+		// Prozori izdavanja obroka po kantini (minuti od ponoci)
+		`CREATE TABLE IF NOT EXISTS canteen_serving_windows (
+			canteen_id UUID NOT NULL REFERENCES canteens(id) ON DELETE CASCADE,
+			slot TEXT NOT NULL CHECK (slot IN ('breakfast', 'lunch', 'dinner')),
+			start_minute INT NOT NULL CHECK (start_minute >= 0),
+			end_minute INT NOT NULL CHECK (end_minute <= 1440),
+			PRIMARY KEY (canteen_id, slot),
+			CHECK (start_minute < end_minute)
+		);`,
+
+		// Izdati obroci: svaki slot najvise jednom po studentu dnevno
+		`CREATE TABLE IF NOT EXISTS meal_servings (
+			user_id UUID NOT NULL,
+			served_on DATE NOT NULL,
+			slot TEXT NOT NULL,
+			purchase_id UUID NOT NULL,
+			PRIMARY KEY (user_id, served_on, slot)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_meal_servings_purchase ON meal_servings(purchase_id);`,
+
+		// Kalendar menija: ponavljajuci planovi sa periodom vazenja i unosi za konkretne datume
+		`CREATE TABLE IF NOT EXISTS menu_plans (
+			id UUID PRIMARY KEY,
+			canteen_id UUID NOT NULL REFERENCES canteens(id) ON DELETE CASCADE,
+			menu_id UUID NOT NULL REFERENCES menus(id) ON DELETE CASCADE,
+			weekday TEXT NOT NULL,
+			valid_from DATE NOT NULL,
+			valid_to DATE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			CHECK (valid_to IS NULL OR valid_to >= valid_from)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_menu_plans_canteen ON menu_plans(canteen_id, weekday);`,
+		`CREATE TABLE IF NOT EXISTS menu_calendar (
+			id UUID PRIMARY KEY,
+			canteen_id UUID NOT NULL REFERENCES canteens(id) ON DELETE CASCADE,
+			served_on DATE NOT NULL,
+			menu_id UUID REFERENCES menus(id) ON DELETE CASCADE,
+			published BOOL NOT NULL DEFAULT false,
+			note TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_menu_calendar_day ON menu_calendar(canteen_id, served_on);`,
+		// Meniji nastali pre kalendara dobijaju otvoren plan za svoj dan u nedelji (jednom)
+		`ALTER TABLE menus ADD COLUMN IF NOT EXISTS has_plan BOOL NOT NULL DEFAULT false;`,
+		`INSERT INTO menu_plans (id, canteen_id, menu_id, weekday, valid_from)
+			SELECT gen_random_uuid(), m.canteen_id, m.id, m.weekday, current_date
+			FROM menus m
+			WHERE NOT m.has_plan AND m.canteen_id IS NOT NULL;`,
+		`UPDATE menus SET has_plan = true WHERE NOT has_plan;`,
+
+		// Alergeni, oznake ishrane i nutritivne vrednosti obroka; preference studenata
+		`ALTER TABLE meals ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE meals ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE meals ADD COLUMN IF NOT EXISTS calories INT;`,
+		`ALTER TABLE meals ADD COLUMN IF NOT EXISTS protein_g NUMERIC;`,
+		`ALTER TABLE meals ADD COLUMN IF NOT EXISTS carbs_g NUMERIC;`,
+		`ALTER TABLE meals ADD COLUMN IF NOT EXISTS fat_g NUMERIC;`,
+		`CREATE TABLE IF NOT EXISTS dietary_preferences (
+			user_id UUID PRIMARY KEY,
+			avoid_allergens TEXT[] NOT NULL DEFAULT '{}',
+			tags TEXT[] NOT NULL DEFAULT '{}',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+
+		// Vise jela po slotu menija; obroci menija su podrazumevana jela (position 0)
+		`CREATE TABLE IF NOT EXISTS menu_dishes (
+			meal_id UUID PRIMARY KEY REFERENCES meals(id) ON DELETE CASCADE,
+			menu_id UUID NOT NULL REFERENCES menus(id) ON DELETE CASCADE,
+			slot TEXT NOT NULL CHECK (slot IN ('breakfast', 'lunch', 'dinner')),
+			position INT NOT NULL DEFAULT 0,
+			stock INT CHECK (stock IS NULL OR stock >= 0)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_menu_dishes_menu ON menu_dishes(menu_id, slot, position);`,
+		`INSERT INTO menu_dishes (meal_id, menu_id, slot, position)
+			SELECT breakfast_id, id, 'breakfast', 0 FROM menus WHERE breakfast_id IS NOT NULL
+			UNION ALL SELECT lunch_id, id, 'lunch', 0 FROM menus WHERE lunch_id IS NOT NULL
+			UNION ALL SELECT dinner_id, id, 'dinner', 0 FROM menus WHERE dinner_id IS NOT NULL
+			ON CONFLICT (meal_id) DO NOTHING;`,
+		`ALTER TABLE meal_purchases ADD COLUMN IF NOT EXISTS dishes JSONB NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE meal_servings ADD COLUMN IF NOT EXISTS meal_id UUID;`,
+		`CREATE INDEX IF NOT EXISTS idx_meal_servings_meal ON meal_servings(meal_id, served_on);`,
+		// Istorija i ocene po jelu; istorija cuva naziv jela i kad se meni obrise
+		`CREATE TABLE IF NOT EXISTS dish_history (
+			purchase_id UUID NOT NULL,
+			slot TEXT NOT NULL,
+			user_id UUID NOT NULL,
+			meal_id UUID NOT NULL,
+			dish_name TEXT NOT NULL,
+			menu_id UUID NOT NULL,
+			canteen_id UUID NOT NULL,
+			selected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (purchase_id, slot)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_dish_history_user ON dish_history(user_id, selected_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_dish_history_canteen ON dish_history(canteen_id, meal_id);`,
+		`CREATE TABLE IF NOT EXISTS dish_reviews (
+			id UUID PRIMARY KEY,
+			meal_id UUID NOT NULL REFERENCES meals(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL,
+			rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			comment TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (meal_id, user_id)
+		);`,
+
+		// Rezervacije obroka unapred i kapacitet kantine po obroku
+		`CREATE TABLE IF NOT EXISTS canteen_slot_capacity (
+			canteen_id UUID NOT NULL REFERENCES canteens(id) ON DELETE CASCADE,
+			slot TEXT NOT NULL CHECK (slot IN ('breakfast', 'lunch', 'dinner')),
+			capacity INT NOT NULL CHECK (capacity >= 0),
+			PRIMARY KEY (canteen_id, slot)
+		);`,
+		`CREATE TABLE IF NOT EXISTS meal_reservations (
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL,
+			username TEXT NOT NULL,
+			canteen_id UUID NOT NULL REFERENCES canteens(id) ON DELETE CASCADE,
+			menu_id UUID NOT NULL,
+			slot TEXT NOT NULL CHECK (slot IN ('breakfast', 'lunch', 'dinner')),
+			dish_id UUID NOT NULL,
+			served_on DATE NOT NULL,
+			status TEXT NOT NULL,
+			purchase_id UUID,
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_meal_reservations_user_slot
+			ON meal_reservations(user_id, served_on, slot) WHERE status IN ('reserved', 'fulfilled');`,
+		`CREATE INDEX IF NOT EXISTS idx_meal_reservations_day ON meal_reservations(canteen_id, served_on, slot, status);`,
+		`CREATE INDEX IF NOT EXISTS idx_meal_reservations_due ON meal_reservations(status, served_on);`,
+
+		// Iskoriscene QR propusnice za obrok (zastita od ponovnog skeniranja)
+		`CREATE TABLE IF NOT EXISTS meal_pass_redemptions (
+			pass_id UUID PRIMARY KEY,
+			user_id UUID NOT NULL,
+			menu_id UUID NOT NULL,
+			purchase_id UUID,
+			redeemed_by TEXT NOT NULL,
+			redeemed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL
+		);`,
 	}
 
 	for _, q := range queries {
@@ -164,6 +350,24 @@ func (r *DiningRepo) SeedCanteens() error {
 		if err != nil {
 			return err
 		}
+
+		// dorucak prva dva sata, rucak sledeca tri, vecera do zatvaranja
+		open := domain.ClockOf(c.OpenAt)
+		windows := []domain.ServingWindow{
+			{Slot: domain.SlotBreakfast, Start: open, End: open + 2*60},
+			{Slot: domain.SlotLunch, Start: open + 3*60, End: open + 6*60},
+			{Slot: domain.SlotDinner, Start: open + 6*60, End: domain.ClockOf(c.CloseAt)},
+		}
+		for _, w := range windows {
+			if _, err := r.DB.Exec(
+				`INSERT INTO canteen_serving_windows (canteen_id, slot, start_minute, end_minute)
+				 VALUES ($1, $2, $3, $4)
+				 ON CONFLICT (canteen_id, slot) DO NOTHING`,
+				c.Id, w.Slot, int(w.Start), int(w.End),
+			); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -184,17 +388,25 @@ func (r *DiningRepo) SeedMenusForCanteenA() error {
 				Breakfast: domain.Meal{
 					Name:        fmt.Sprintf("Breakfast %s #%d", day, i),
 					Description: "Test breakfast",
-					Price:       3.5,
+					Price:       money.RSD(350),
+					Allergens:   []domain.Allergen{domain.AllergenGluten, domain.AllergenMilk, domain.AllergenEggs},
+					Tags:        []domain.DietaryTag{domain.TagVegetarian},
+					Nutrition:   &domain.Nutrition{Calories: 450, Protein: 18, Carbs: 55, Fat: 16},
 				},
 				Lunch: domain.Meal{
 					Name:        fmt.Sprintf("Lunch %s #%d", day, i),
 					Description: "Test lunch",
-					Price:       5.0,
+					Price:       money.RSD(500),
+					Allergens:   []domain.Allergen{domain.AllergenCelery},
+					Tags:        []domain.DietaryTag{domain.TagHalal, domain.TagGlutenFree},
+					Nutrition:   &domain.Nutrition{Calories: 720, Protein: 42, Carbs: 70, Fat: 24},
 				},
 				Dinner: domain.Meal{
 					Name:        fmt.Sprintf("Dinner %s #%d", day, i),
 					Description: "Test dinner",
-					Price:       6.5,
+					Price:       money.RSD(650),
+					Allergens:   []domain.Allergen{domain.AllergenFish, domain.AllergenGluten},
+					Nutrition:   &domain.Nutrition{Calories: 610, Protein: 35, Carbs: 60, Fat: 20},
 				},
 			}
 
@@ -354,16 +566,24 @@ func (dr *DiningRepo) DeleteCanteenByID(id string) error {
 
 func (r *DiningRepo) CreateMeal(m *domain.Meal) error {
 	m.Id = uuid.New()
+	calories, protein, carbs, fat := nutritionArgs(m.Nutrition)
 	_, err := r.DB.Exec(
-		`INSERT INTO meals (id, name, description, price) VALUES ($1, $2, $3, $4)`,
-		m.Id, m.Name, m.Description, m.Price,
+		`INSERT INTO meals (id, name, description, price, allergens, tags, calories, protein_g, carbs_g, fat_g)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		m.Id, m.Name, m.Description, m.Price, allergenStrings(m.Allergens), tagStrings(m.Tags),
+		calories, protein, carbs, fat,
 	)
 	return err
 }
 
 func (r *DiningRepo) UpdateMeal(m *domain.Meal) error {
-	_, err := r.DB.Exec(`UPDATE meals SET name=$1, description=$2 WHERE id=$3`,
-		m.Name, m.Description, m.Id)
+	calories, protein, carbs, fat := nutritionArgs(m.Nutrition)
+	_, err := r.DB.Exec(
+		`UPDATE meals SET name=$1, description=$2, allergens=$3, tags=$4,
+		        calories=$5, protein_g=$6, carbs_g=$7, fat_g=$8
+		 WHERE id=$9`,
+		m.Name, m.Description, allergenStrings(m.Allergens), tagStrings(m.Tags),
+		calories, protein, carbs, fat, m.Id)
 	return err
 }
 
@@ -398,8 +618,8 @@ func (r *DiningRepo) CreateMenu(menu *domain.Menu) error {
 	// 4. Kreiraj sam meni
 	menu.Id = uuid.New()
 	_, err := r.DB.Exec(
-		`INSERT INTO menus (id, name, canteen_id, weekday, breakfast_id, lunch_id, dinner_id) 
-     VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		`INSERT INTO menus (id, name, canteen_id, weekday, breakfast_id, lunch_id, dinner_id, has_plan) 
+     VALUES ($1, $2, $3, $4, $5, $6, $7, true)`,
 		menu.Id, menu.Name, menu.CanteenId, menu.Weekday,
 		menu.Breakfast.Id, menu.Lunch.Id, menu.Dinner.Id,
 	)
@@ -407,6 +627,36 @@ func (r *DiningRepo) CreateMenu(menu *domain.Menu) error {
 		return fmt.Errorf("failed to create menu: %w", err)
 	}
 
+	// 5. Obroci su podrazumevana jela svojih slotova; dodatna jela idu iza njih
+	for slot, meal := range map[domain.MealSlot]domain.Meal{
+		domain.SlotBreakfast: menu.Breakfast, domain.SlotLunch: menu.Lunch, domain.SlotDinner: menu.Dinner,
+	} {
+		if _, err := r.DB.Exec(
+			`INSERT INTO menu_dishes (meal_id, menu_id, slot, position) VALUES ($1, $2, $3, 0)`,
+			meal.Id, menu.Id, slot,
+		); err != nil {
+			return fmt.Errorf("failed to create menu dish: %w", err)
+		}
+	}
+	for i := range menu.Dishes {
+		d := &menu.Dishes[i]
+		d.MenuId = menu.Id
+		if err := r.AddMenuDish(d); err != nil {
+			return fmt.Errorf("failed to create %s dish: %w", d.Slot, err)
+		}
+	}
+
+	// 6. Meni se ponavlja svog dana u nedelji od danas (plan u kalendaru)
+	plan := domain.MenuPlan{
+		CanteenId: menu.CanteenId,
+		MenuId:    menu.Id,
+		Weekday:   menu.Weekday,
+		ValidFrom: domain.DateOf(time.Now()),
+	}
+	if err := r.CreateMenuPlan(&plan); err != nil {
+		return fmt.Errorf("failed to create menu plan: %w", err)
+	}
+
 	return nil
 }
 
@@ -489,9 +739,11 @@ func (r *DiningRepo) GetCanteenByID(id string) (*domain.Canteen, error) {
 
 func (r *DiningRepo) GetMealByID(id string) (*domain.Meal, error) {
 	var m domain.Meal
+	row := newMealRow(&m)
 	err := r.DB.QueryRow(
-		`SELECT id, name, description FROM meals WHERE id = $1`, id,
-	).Scan(&m.Id, &m.Name, &m.Description)
+		`SELECT `+mealColumns("m")+` FROM meals m WHERE m.id = $1`, id,
+	).Scan(row.dest()...)
+	row.finish()
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("meal with id %s not found", id)
@@ -519,9 +771,9 @@ func (r *DiningRepo) GetMenuByID(id string) (*domain.Menu, error) {
 func (r *DiningRepo) GetMenuReviewByID(id string) (*domain.MenuReview, error) {
 	var mr domain.MenuReview
 	err := r.DB.QueryRow(
-		`SELECT id, menu_id, breakfast_review, lunch_review, dinner_review 
+		`SELECT id, menu_id, user_id, breakfast_review, lunch_review, dinner_review 
 		 FROM menu_reviews WHERE id = $1`, id,
-	).Scan(&mr.Id, &mr.MenuId, &mr.BreakfastReview, &mr.LunchReview, &mr.DinnerReview)
+	).Scan(&mr.Id, &mr.MenuId, &mr.UserId, &mr.BreakfastReview, &mr.LunchReview, &mr.DinnerReview)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("menu review with id %s not found", id)
@@ -533,11 +785,7 @@ func (r *DiningRepo) GetMenuReviewByID(id string) (*domain.MenuReview, error) {
 
 func (r *DiningRepo) GetMenusByCanteenID(canteenID string) ([]*domain.Menu, error) {
 	rows, err := r.DB.Query(`
-		SELECT 
-			m.id, m.name, m.canteen_id, m.weekday,
-			b.id, b.name, b.description, b.price,
-			l.id, l.name, l.description, l.price,
-			d.id, d.name, d.description, d.price
+		SELECT `+menuWithMealsColumns+`
 		FROM menus m
 		LEFT JOIN meals b ON m.breakfast_id = b.id
 		LEFT JOIN meals l ON m.lunch_id = l.id
@@ -554,22 +802,9 @@ func (r *DiningRepo) GetMenusByCanteenID(canteenID string) ([]*domain.Menu, erro
 
 	for rows.Next() {
 		var menu domain.Menu
-		var breakfast, lunch, dinner domain.Meal
-
-		err := rows.Scan(
-			&menu.Id, &menu.Name, &menu.CanteenId, &menu.Weekday,
-			&breakfast.Id, &breakfast.Name, &breakfast.Description, &breakfast.Price,
-			&lunch.Id, &lunch.Name, &lunch.Description, &lunch.Price,
-			&dinner.Id, &dinner.Name, &dinner.Description, &dinner.Price,
-		)
-		if err != nil {
+		if err := scanMenuWithMeals(rows, &menu); err != nil {
 			return nil, err
 		}
-
-		menu.Breakfast = breakfast
-		menu.Lunch = lunch
-		menu.Dinner = dinner
-
 		menus = append(menus, &menu)
 	}
 
@@ -602,6 +837,25 @@ func (r *DiningRepo) DeleteMenuAndMealsByID(id string) error {
 		return err
 	}
 
+	// dodatna jela menija se brisu zajedno sa njim
+	var dishIDs []*string
+	rows, err := tx.Query(`SELECT meal_id::STRING FROM menu_dishes WHERE menu_id=$1 AND position > 0`, id)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var dishID string
+		if err = rows.Scan(&dishID); err != nil {
+			rows.Close()
+			return err
+		}
+		dishIDs = append(dishIDs, &dishID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
 	result, err := tx.Exec(`DELETE FROM menus WHERE id=$1`, id)
 	if err != nil {
 		return err
@@ -611,7 +865,7 @@ func (r *DiningRepo) DeleteMenuAndMealsByID(id string) error {
 		return fmt.Errorf("menu with id %s not found", id)
 	}
 
-	mealIDs := []*string{breakfastID, lunchID, dinnerID}
+	mealIDs := append([]*string{breakfastID, lunchID, dinnerID}, dishIDs...)
 	for _, mealID := range mealIDs {
 		if mealID != nil {
 			_, err = tx.Exec(`DELETE FROM meals WHERE id=$1`, *mealID)
@@ -723,7 +977,9 @@ func (r *DiningRepo) GetMealHistoryByUser(userId string) ([]domain.MealHistory,
 }
 
 func (r *DiningRepo) CreateMealHistory(mh *domain.MealHistory, userId string) error {
-	mh.Id = uuid.New().String()
+	if mh.Id == "" {
+		mh.Id = uuid.New().String()
+	}
 	if mh.SelectedAt.IsZero() {
 		mh.SelectedAt = time.Now()
 	}
@@ -809,25 +1065,14 @@ func (r *DiningRepo) GetMealHistoryWithReviewsByUser(userId string) ([]domain.Me
 
 func (r *DiningRepo) GetMenuWithMealsByID(menuId string) (*domain.Menu, error) {
 	var menu domain.Menu
-	var breakfast, lunch, dinner domain.Meal
-
-	err := r.DB.QueryRow(`
-		SELECT 
-			m.id, m.name, m.canteen_id, m.weekday,
-			b.id, b.name, b.description, b.price,
-			l.id, l.name, l.description, l.price,
-			d.id, d.name, d.description, d.price
+	err := scanMenuWithMeals(r.DB.QueryRow(`
+		SELECT `+menuWithMealsColumns+`
 		FROM menus m
 		LEFT JOIN meals b ON m.breakfast_id = b.id
 		LEFT JOIN meals l ON m.lunch_id = l.id
 		LEFT JOIN meals d ON m.dinner_id = d.id
 		WHERE m.id = $1
-	`, menuId).Scan(
-		&menu.Id, &menu.Name, &menu.CanteenId, &menu.Weekday,
-		&breakfast.Id, &breakfast.Name, &breakfast.Description, &breakfast.Price,
-		&lunch.Id, &lunch.Name, &lunch.Description, &lunch.Price,
-		&dinner.Id, &dinner.Name, &dinner.Description, &dinner.Price,
-	)
+	`, menuId), &menu)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("menu with id %s not found", menuId)
@@ -835,10 +1080,6 @@ func (r *DiningRepo) GetMenuWithMealsByID(menuId string) (*domain.Menu, error) {
 		return nil, err
 	}
 
-	menu.Breakfast = breakfast
-	menu.Lunch = lunch
-	menu.Dinner = dinner
-
 	return &menu, nil
 }
 
@@ -928,11 +1169,7 @@ func (r *DiningRepo) GetMealHistoryForUsernames(usernames []string) ([]domain.Me
 // NEW: Svi meniji (sa kompletno uvezanim meal-ovima) za prosleđeni dan u nedelji
 func (r *DiningRepo) GetMenusByWeekday(weekday domain.Weekday) ([]*domain.Menu, error) {
 	rows, err := r.DB.Query(`
-		SELECT 
-			m.id, m.name, m.canteen_id, m.weekday,
-			b.id, b.name, b.description, b.price,
-			l.id, l.name, l.description, l.price,
-			d.id, d.name, d.description, d.price
+		SELECT `+menuWithMealsColumns+`
 		FROM menus m
 		LEFT JOIN meals b ON m.breakfast_id = b.id
 		LEFT JOIN meals l ON m.lunch_id = l.id
@@ -948,22 +1185,9 @@ func (r *DiningRepo) GetMenusByWeekday(weekday domain.Weekday) ([]*domain.Menu,
 	var menus []*domain.Menu
 	for rows.Next() {
 		var menu domain.Menu
-		var breakfast, lunch, dinner domain.Meal
-
-		err := rows.Scan(
-			&menu.Id, &menu.Name, &menu.CanteenId, &menu.Weekday,
-			&breakfast.Id, &breakfast.Name, &breakfast.Description, &breakfast.Price,
-			&lunch.Id, &lunch.Name, &lunch.Description, &lunch.Price,
-			&dinner.Id, &dinner.Name, &dinner.Description, &dinner.Price,
-		)
-		if err != nil {
+		if err := scanMenuWithMeals(rows, &menu); err != nil {
 			return nil, err
 		}
-
-		menu.Breakfast = breakfast
-		menu.Lunch = lunch
-		menu.Dinner = dinner
-
 		menus = append(menus, &menu)
 	}
 
@@ -0,0 +1,302 @@
+package repo
+
+import (
+	"database/sql"
+	"dining/domain"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const dishColumns = `md.menu_id, md.slot, md.position, md.stock, `
+
+func scanDish(row interface{ Scan(...any) error }, d *domain.Dish) error {
+	var stock sql.NullInt64
+	meal := newMealRow(&d.Meal)
+	dest := append([]any{&d.MenuId, &d.Slot, &d.Position, &stock}, meal.dest()...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	meal.finish()
+	d.Stock = nil
+	if stock.Valid {
+		n := int(stock.Int64)
+		d.Stock = &n
+	}
+	return nil
+}
+
+func uuidStrings(ids []uuid.UUID) pq.StringArray {
+	out := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+// ListMenuDishes vraca jela datih menija, po slotu i redosledu.
+func (r *DiningRepo) ListMenuDishes(menuIds []uuid.UUID) ([]domain.Dish, error) {
+	rows, err := r.DB.Query(
+		`SELECT `+dishColumns+mealColumns("f")+`
+		 FROM menu_dishes md
+		 JOIN meals f ON f.id = md.meal_id
+		 WHERE md.menu_id = ANY($1::UUID[])
+		 ORDER BY md.menu_id, md.slot, md.position`, uuidStrings(menuIds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Dish{}
+	for rows.Next() {
+		var d domain.Dish
+		if err := scanDish(rows, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *DiningRepo) GetMenuDish(mealId uuid.UUID) (*domain.Dish, error) {
+	var d domain.Dish
+	err := scanDish(r.DB.QueryRow(
+		`SELECT `+dishColumns+mealColumns("f")+`
+		 FROM menu_dishes md
+		 JOIN meals f ON f.id = md.meal_id
+		 WHERE md.meal_id = $1`, mealId,
+	), &d)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrDishNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// AddMenuDish upisuje novo jelo i dodaje ga na kraj liste jela za slot menija.
+func (r *DiningRepo) AddMenuDish(d *domain.Dish) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	d.Id = uuid.New()
+	calories, protein, carbs, fat := nutritionArgs(d.Nutrition)
+	if _, err = tx.Exec(
+		`INSERT INTO meals (id, name, description, price, allergens, tags, calories, protein_g, carbs_g, fat_g)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		d.Id, d.Name, d.Description, d.Price, allergenStrings(d.Allergens), tagStrings(d.Tags),
+		calories, protein, carbs, fat,
+	); err != nil {
+		return err
+	}
+	if err = tx.QueryRow(
+		`INSERT INTO menu_dishes (meal_id, menu_id, slot, position, stock)
+		 SELECT $1, $2, $3, COALESCE(MAX(position), -1) + 1, $4
+		 FROM menu_dishes WHERE menu_id = $2 AND slot = $3
+		 RETURNING position`, d.Id, d.MenuId, d.Slot, d.Stock,
+	).Scan(&d.Position); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+			err = domain.ErrMenuNotFound
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *DiningRepo) SetDishStock(mealId uuid.UUID, stock *int) error {
+	res, err := r.DB.Exec(`UPDATE menu_dishes SET stock = $2 WHERE meal_id = $1`, mealId, stock)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrDishNotFound
+	}
+	return nil
+}
+
+// DeleteMenuDish brise dodatno jelo (i njegov obrok); podrazumevano jelo slota ostaje.
+func (r *DiningRepo) DeleteMenuDish(mealId uuid.UUID) error {
+	res, err := r.DB.Exec(
+		`DELETE FROM meals WHERE id = $1
+		 AND EXISTS (SELECT 1 FROM menu_dishes WHERE meal_id = $1 AND position > 0)`, mealId,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrDishNotFound
+	}
+	return nil
+}
+
+// CountTakenPortions vraca broj zauzetih porcija po jelu za dan: izdate i one koje
+// drze aktivne rezervacije.
+func (r *DiningRepo) CountTakenPortions(mealIds []uuid.UUID, day domain.Date) (map[uuid.UUID]int, error) {
+	rows, err := r.DB.Query(
+		`SELECT meal_id, COUNT(*) FROM (
+		   SELECT meal_id FROM meal_servings
+		    WHERE meal_id = ANY($1::UUID[]) AND served_on = $2
+		   UNION ALL
+		   SELECT r.dish_id FROM meal_reservations r
+		    WHERE r.dish_id = ANY($1::UUID[]) AND r.served_on = $2 AND `+unservedReservation+`
+		 ) t
+		 GROUP BY meal_id`, uuidStrings(mealIds), day,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[uuid.UUID]int{}
+	for rows.Next() {
+		var id uuid.UUID
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		out[id] = n
+	}
+	return out, rows.Err()
+}
+
+func (r *DiningRepo) HasEatenDish(userId, mealId uuid.UUID) (bool, error) {
+	var ok bool
+	err := r.DB.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM dish_history WHERE user_id = $1 AND meal_id = $2)`, userId, mealId,
+	).Scan(&ok)
+	return ok, err
+}
+
+const dishReviewColumns = `id, meal_id, user_id, rating, comment, created_at, updated_at`
+
+func scanDishReview(row interface{ Scan(...any) error }, rv *domain.DishReview) error {
+	return row.Scan(&rv.Id, &rv.MealId, &rv.UserId, &rv.Rating, &rv.Comment, &rv.CreatedAt, &rv.UpdatedAt)
+}
+
+// SaveDishReview upisuje ocenu ili menja postojecu ocenu studenta za isto jelo.
+func (r *DiningRepo) SaveDishReview(rv *domain.DishReview) error {
+	return scanDishReview(r.DB.QueryRow(
+		`INSERT INTO dish_reviews (id, meal_id, user_id, rating, comment)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (meal_id, user_id)
+		 DO UPDATE SET rating = EXCLUDED.rating, comment = EXCLUDED.comment, updated_at = NOW()
+		 RETURNING `+dishReviewColumns,
+		uuid.New(), rv.MealId, rv.UserId, rv.Rating, rv.Comment,
+	), rv)
+}
+
+func (r *DiningRepo) ListDishReviews(mealId uuid.UUID) ([]domain.DishReview, error) {
+	rows, err := r.DB.Query(
+		`SELECT `+dishReviewColumns+` FROM dish_reviews
+		 WHERE meal_id = $1
+		 ORDER BY updated_at DESC`, mealId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.DishReview{}
+	for rows.Next() {
+		var rv domain.DishReview
+		if err := scanDishReview(rows, &rv); err != nil {
+			return nil, err
+		}
+		out = append(out, rv)
+	}
+	return out, rows.Err()
+}
+
+func (r *DiningRepo) ListDishHistory(userId uuid.UUID) ([]domain.DishHistory, error) {
+	rows, err := r.DB.Query(
+		`SELECT h.purchase_id, h.meal_id, h.dish_name, h.slot, h.menu_id, COALESCE(m.name, ''), h.canteen_id, h.selected_at,
+		        rv.id, rv.meal_id, rv.user_id, rv.rating, rv.comment, rv.created_at, rv.updated_at
+		 FROM dish_history h
+		 LEFT JOIN menus m ON m.id = h.menu_id
+		 LEFT JOIN dish_reviews rv ON rv.meal_id = h.meal_id AND rv.user_id = h.user_id
+		 WHERE h.user_id = $1
+		 ORDER BY h.selected_at DESC, h.slot`, userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.DishHistory{}
+	for rows.Next() {
+		var h domain.DishHistory
+		var (
+			rvId, rvMeal, rvUser *uuid.UUID
+			rvRating             sql.NullInt64
+			rvComment            sql.NullString
+			rvCreated, rvUpdated sql.NullTime
+		)
+		if err := rows.Scan(&h.PurchaseId, &h.MealId, &h.DishName, &h.Slot, &h.MenuId, &h.MenuName, &h.CanteenId, &h.SelectedAt,
+			&rvId, &rvMeal, &rvUser, &rvRating, &rvComment, &rvCreated, &rvUpdated); err != nil {
+			return nil, err
+		}
+		if rvId != nil {
+			h.Review = &domain.DishReview{
+				Id: *rvId, MealId: *rvMeal, UserId: *rvUser, Rating: int(rvRating.Int64),
+				Comment: rvComment.String, CreatedAt: rvCreated.Time, UpdatedAt: rvUpdated.Time,
+			}
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// ListPopularDishes: najcesce birana jela u kantini, sa prosecnom ocenom.
+func (r *DiningRepo) ListPopularDishes(canteenId uuid.UUID, limit int) ([]domain.DishStats, error) {
+	return r.queryDishStats(
+		`SELECT h.meal_id, MAX(h.dish_name), COUNT(*),
+		        COALESCE((SELECT AVG(rating)::FLOAT8 FROM dish_reviews WHERE meal_id = h.meal_id), 0),
+		        (SELECT COUNT(*) FROM dish_reviews WHERE meal_id = h.meal_id)
+		 FROM dish_history h
+		 WHERE h.canteen_id = $1
+		 GROUP BY h.meal_id
+		 ORDER BY COUNT(*) DESC
+		 LIMIT $2`, canteenId, limit)
+}
+
+// ListTopRatedDishes: jela sa najboljom prosecnom ocenom (bar jedna ocena).
+func (r *DiningRepo) ListTopRatedDishes(limit int) ([]domain.DishStats, error) {
+	return r.queryDishStats(
+		`SELECT rv.meal_id, f.name,
+		        (SELECT COUNT(*) FROM dish_history WHERE meal_id = rv.meal_id),
+		        AVG(rv.rating)::FLOAT8, COUNT(*)
+		 FROM dish_reviews rv
+		 JOIN meals f ON f.id = rv.meal_id
+		 GROUP BY rv.meal_id, f.name
+		 ORDER BY AVG(rv.rating) DESC, COUNT(*) DESC
+		 LIMIT $1`, limit)
+}
+
+func (r *DiningRepo) queryDishStats(query string, args ...any) ([]domain.DishStats, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.DishStats{}
+	for rows.Next() {
+		var s domain.DishStats
+		if err := rows.Scan(&s.MealId, &s.DishName, &s.TimesSelected, &s.AvgRating, &s.Reviews); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"database/sql"
+	"dining/domain"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// claimMealPass belezi da je propusnica iskoriscena za kupovinu purchaseId; drugi
+// pokusaj sa istom propusnicom dobija ErrMealPassUsed.
+func claimMealPass(tx *sql.Tx, r *domain.MealPassRedemption, purchaseId uuid.UUID) error {
+	_, err := tx.Exec(
+		`INSERT INTO meal_pass_redemptions (pass_id, user_id, menu_id, purchase_id, redeemed_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		r.Pass.Id, r.Pass.UserId, r.Pass.MenuId, purchaseId, r.RedeemedBy, r.Pass.ExpiresAt,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return domain.ErrMealPassUsed
+	}
+	return err
+}
@@ -0,0 +1,301 @@
+package repo
+
+import (
+	"database/sql"
+	"dining/domain"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const mealPurchaseColumns = `id, idempotency_key, user_id, username, menu_id, canteen_id, slots, dishes,
+	list_price, discount, amount, state, attempts, last_error, failure_code, created_at, updated_at`
+
+func scanMealPurchase(row interface{ Scan(...any) error }, p *domain.MealPurchase) error {
+	var slots pq.StringArray
+	if err := row.Scan(&p.Id, &p.IdempotencyKey, &p.UserId, &p.Username, &p.MenuId, &p.CanteenId, &slots, &p.Dishes,
+		&p.ListPrice, &p.Discount, &p.Amount, &p.State, &p.Attempts, &p.LastError, &p.FailureCode,
+		&p.CreatedAt, &p.UpdatedAt); err != nil {
+		return err
+	}
+	p.Slots = make([]domain.MealSlot, len(slots))
+	for i, s := range slots {
+		p.Slots[i] = domain.MealSlot(s)
+	}
+	return nil
+}
+
+func slotStrings(slots []domain.MealSlot) pq.StringArray {
+	out := make(pq.StringArray, len(slots))
+	for i, s := range slots {
+		out[i] = string(s)
+	}
+	return out
+}
+
+// CreateMealPurchase upisuje novu kupovinu u stanju pending i zauzima njene slotove i
+// porcije izabranih jela za dan servedOn. Ako korisnik vec ima kupovinu sa istim
+// idempotency kljucem, p se popunjava postojecom i vraca se false; ako je neki slot tog
+// dana vec izdat, ErrSlotAlreadyServed, a ako je jelo rasprodato, ErrDishSoldOut. Kupovina
+// placena propusnicom (pass != nil) upisuje se zajedno sa iskoriscenjem propusnice.
+func (r *DiningRepo) CreateMealPurchase(p *domain.MealPurchase, servedOn time.Time, pass *domain.MealPassRedemption) (created bool, err error) {
+	if p.Id == uuid.Nil {
+		p.Id = uuid.New()
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = scanMealPurchase(tx.QueryRow(
+		`INSERT INTO meal_purchases (id, idempotency_key, user_id, username, menu_id, canteen_id, slots, dishes,
+		                             list_price, discount, amount, state)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		 ON CONFLICT (user_id, idempotency_key) DO NOTHING
+		 RETURNING `+mealPurchaseColumns,
+		p.Id, p.IdempotencyKey, p.UserId, p.Username, p.MenuId, p.CanteenId, slotStrings(p.Slots), p.Dishes,
+		p.ListPrice, p.Discount, p.Amount, domain.PurchasePending,
+	), p)
+	switch {
+	case err == sql.ErrNoRows:
+		err = scanMealPurchase(tx.QueryRow(
+			`SELECT `+mealPurchaseColumns+` FROM meal_purchases
+			 WHERE user_id = $1 AND idempotency_key = $2`, p.UserId, p.IdempotencyKey,
+		), p)
+		if err != nil {
+			return false, err
+		}
+		return false, tx.Commit()
+	case err != nil:
+		return false, err
+	}
+	if pass != nil {
+		if err = claimMealPass(tx, pass, p.Id); err != nil {
+			return false, err
+		}
+	}
+
+	day := servedOn.Format("2006-01-02")
+	for _, slot := range p.Slots {
+		var mealId *uuid.UUID
+		if id, ok := p.Dishes[slot]; ok {
+			if err = reserveDish(tx, id, day, slot, p.UserId); err != nil {
+				return false, err
+			}
+			mealId = &id
+		}
+		if _, err = tx.Exec(
+			`INSERT INTO meal_servings (user_id, served_on, slot, purchase_id, meal_id) VALUES ($1, $2, $3, $4, $5)`,
+			p.UserId, day, slot, p.Id, mealId,
+		); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+				err = fmt.Errorf("%w: %s", domain.ErrSlotAlreadyServed, slot)
+			}
+			return false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// unservedReservation: aktivna rezervacija r ciji obrok jos nije izdat; njena porcija
+// je zauzeta dok se rezervacija ne naplati, otkaze ili oslobodi.
+const unservedReservation = `r.status = 'reserved' AND NOT EXISTS (
+	SELECT 1 FROM meal_servings s WHERE s.user_id = r.user_id AND s.served_on = r.served_on AND s.slot = r.slot)`
+
+// reserveDish proverava da jelo ima slobodnu porciju za dan; zauzete su izdate porcije
+// i porcije drzane rezervacijama drugih korisnika (rezervacija korisnika userId se
+// trosi ovom porcijom). Red jela se zakljucava pa istovremene kupovine i rezervacije
+// istog jela cekaju jedna drugu.
+func reserveDish(tx *sql.Tx, mealId uuid.UUID, day string, slot domain.MealSlot, userId uuid.UUID) error {
+	var stock sql.NullInt64
+	err := tx.QueryRow(`SELECT stock FROM menu_dishes WHERE meal_id = $1 FOR UPDATE`, mealId).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", domain.ErrDishNotFound, slot)
+	}
+	if err != nil || !stock.Valid {
+		return err
+	}
+	var taken int64
+	if err := tx.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM meal_servings WHERE meal_id = $1 AND served_on = $2)
+		      + (SELECT COUNT(*) FROM meal_reservations r
+		          WHERE r.dish_id = $1 AND r.served_on = $2 AND r.user_id <> $3 AND `+unservedReservation+`)`,
+		mealId, day, userId,
+	).Scan(&taken); err != nil {
+		return err
+	}
+	if taken >= stock.Int64 {
+		return fmt.Errorf("%w: %s", domain.ErrDishSoldOut, slot)
+	}
+	return nil
+}
+
+func (r *DiningRepo) GetMealPurchaseByKey(userId uuid.UUID, idempotencyKey string) (*domain.MealPurchase, error) {
+	var p domain.MealPurchase
+	err := scanMealPurchase(r.DB.QueryRow(
+		`SELECT `+mealPurchaseColumns+` FROM meal_purchases
+		 WHERE user_id = $1 AND idempotency_key = $2`, userId, idempotencyKey,
+	), &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *DiningRepo) GetMealPurchase(id uuid.UUID) (*domain.MealPurchase, error) {
+	var p domain.MealPurchase
+	err := scanMealPurchase(r.DB.QueryRow(
+		`SELECT `+mealPurchaseColumns+` FROM meal_purchases WHERE id = $1`, id,
+	), &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// TransitionMealPurchase prebacuje kupovinu iz stanja from u to; false znaci da je
+// kupovina u medjuvremenu promenila stanje (obradio ju je neko drugi). Odbijena ili
+// ponistena kupovina oslobadja svoje slotove za taj dan.
+func (r *DiningRepo) TransitionMealPurchase(id uuid.UUID, from, to domain.PurchaseState, lastErr, failureCode *string) (ok bool, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec(
+		`UPDATE meal_purchases
+		 SET state = $3, last_error = COALESCE($4, last_error), failure_code = COALESCE($5, failure_code), updated_at = NOW()
+		 WHERE id = $1 AND state = $2`, id, from, to, lastErr, failureCode,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 && (to == domain.PurchaseFailed || to == domain.PurchaseRefunded) {
+		if _, err = tx.Exec(`DELETE FROM meal_servings WHERE purchase_id = $1`, id); err != nil {
+			return false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// NoteMealPurchaseFailure belezi neuspeo korak; updated_at odlaze sledeci pokusaj oporavka.
+func (r *DiningRepo) NoteMealPurchaseFailure(id uuid.UUID, cause string) error {
+	_, err := r.DB.Exec(
+		`UPDATE meal_purchases SET attempts = attempts + 1, last_error = $2, updated_at = NOW()
+		 WHERE id = $1`, id, cause,
+	)
+	return err
+}
+
+// RecordMealPurchase u jednoj transakciji upisuje istoriju obroka (id = id kupovine) i
+// istoriju izabranih jela, uvecava popularnost menija i prebacuje kupovinu iz charged u recorded.
+func (r *DiningRepo) RecordMealPurchase(p *domain.MealPurchase) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec(
+		`INSERT INTO meal_history (id, user_id, menu_id, selected_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (id) DO NOTHING`, p.Id, p.UserId, p.MenuId,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+			return fmt.Errorf("%w: %s", domain.ErrMenuNotFound, p.MenuId)
+		}
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		if _, err = tx.Exec(
+			`INSERT INTO popular_meals (id, menu_id, canteen_id, times_selected)
+			 VALUES ($1, $2, $3, 1)
+			 ON CONFLICT (menu_id, canteen_id)
+			 DO UPDATE SET times_selected = popular_meals.times_selected + 1`,
+			uuid.New(), p.MenuId, p.CanteenId,
+		); err != nil {
+			return err
+		}
+	}
+	for slot, mealId := range p.Dishes {
+		if _, err = tx.Exec(
+			`INSERT INTO dish_history (purchase_id, slot, user_id, meal_id, dish_name, menu_id, canteen_id)
+			 SELECT $1, $2, $3, f.id, f.name, $5, $6 FROM meals f WHERE f.id = $4
+			 ON CONFLICT (purchase_id, slot) DO NOTHING`,
+			p.Id, slot, p.UserId, mealId, p.MenuId, p.CanteenId,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(
+		`UPDATE meal_purchases SET state = $2, updated_at = NOW()
+		 WHERE id = $1 AND state = $3`, p.Id, domain.PurchaseRecorded, domain.PurchaseCharged,
+	); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	p.State = domain.PurchaseRecorded
+	return nil
+}
+
+// maxRecoveryAttempts: kupovina sa toliko neuspelih koraka se vise ne pokusava
+// automatski; ostaje sa last_error za rucnu obradu.
+const maxRecoveryAttempts = 20
+
+// ListStalledMealPurchases vraca nedovrsene kupovine koje niko nije dirao bar olderThan.
+func (r *DiningRepo) ListStalledMealPurchases(olderThan time.Duration, limit int) ([]domain.MealPurchase, error) {
+	rows, err := r.DB.Query(
+		`SELECT `+mealPurchaseColumns+` FROM meal_purchases
+		 WHERE state IN ($1, $2, $3, $4) AND updated_at < $5 AND attempts < $6
+		 ORDER BY updated_at
+		 LIMIT $7`,
+		domain.PurchasePending, domain.PurchaseCharged, domain.PurchaseRecorded, domain.PurchaseRefunding,
+		time.Now().Add(-olderThan), maxRecoveryAttempts, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.MealPurchase
+	for rows.Next() {
+		var p domain.MealPurchase
+		if err := scanMealPurchase(rows, &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,153 @@
+package repo
+
+import (
+	"dining/domain"
+
+	"github.com/google/uuid"
+)
+
+const menuPlanColumns = `id, canteen_id, menu_id, weekday, valid_from, valid_to, created_at`
+
+func (r *DiningRepo) CreateMenuPlan(p *domain.MenuPlan) error {
+	p.Id = uuid.New()
+	return r.DB.QueryRow(
+		`INSERT INTO menu_plans (id, canteen_id, menu_id, weekday, valid_from, valid_to)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING created_at`,
+		p.Id, p.CanteenId, p.MenuId, p.Weekday, p.ValidFrom, p.ValidTo,
+	).Scan(&p.CreatedAt)
+}
+
+func (r *DiningRepo) ListMenuPlans(canteenId uuid.UUID) ([]domain.MenuPlan, error) {
+	rows, err := r.DB.Query(
+		`SELECT `+menuPlanColumns+` FROM menu_plans
+		 WHERE canteen_id = $1
+		 ORDER BY valid_from, created_at`, canteenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.MenuPlan{}
+	for rows.Next() {
+		var p domain.MenuPlan
+		if err := rows.Scan(&p.Id, &p.CanteenId, &p.MenuId, &p.Weekday, &p.ValidFrom, &p.ValidTo, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// EndMenuPlan zatvara plan: poslednji dan vazenja je validTo.
+func (r *DiningRepo) EndMenuPlan(id uuid.UUID, validTo domain.Date) error {
+	_, err := r.DB.Exec(`UPDATE menu_plans SET valid_to = $2 WHERE id = $1`, id, validTo)
+	return err
+}
+
+func (r *DiningRepo) DeleteMenuPlan(id uuid.UUID) error {
+	_, err := r.DB.Exec(`DELETE FROM menu_plans WHERE id = $1`, id)
+	return err
+}
+
+// ListCalendarEntries vraca objavljene unose i nacrte za dane [from, to].
+func (r *DiningRepo) ListCalendarEntries(canteenId uuid.UUID, from, to domain.Date) ([]domain.MenuCalendarEntry, error) {
+	rows, err := r.DB.Query(
+		`SELECT id, canteen_id, served_on, menu_id, published, note, created_by, created_at
+		 FROM menu_calendar
+		 WHERE canteen_id = $1 AND served_on BETWEEN $2 AND $3
+		 ORDER BY served_on, created_at`, canteenId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.MenuCalendarEntry{}
+	for rows.Next() {
+		var e domain.MenuCalendarEntry
+		if err := rows.Scan(&e.Id, &e.CanteenId, &e.Date, &e.MenuId, &e.Published, &e.Note, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceCalendarEntries u jednoj transakciji brise unose za date dane (samo nacrte
+// ako je draftsOnly) i upisuje nove.
+func (r *DiningRepo) ReplaceCalendarEntries(canteenId uuid.UUID, dates []domain.Date, entries []domain.MenuCalendarEntry, draftsOnly bool) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, d := range dates {
+		if _, err = tx.Exec(
+			`DELETE FROM menu_calendar
+			 WHERE canteen_id = $1 AND served_on = $2 AND (NOT $3 OR NOT published)`,
+			canteenId, d, draftsOnly,
+		); err != nil {
+			return err
+		}
+	}
+	for i := range entries {
+		e := &entries[i]
+		e.Id = uuid.New()
+		if err = tx.QueryRow(
+			`INSERT INTO menu_calendar (id, canteen_id, served_on, menu_id, published, note, created_by)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 RETURNING created_at`,
+			e.Id, canteenId, e.Date, e.MenuId, e.Published, e.Note, e.CreatedBy,
+		).Scan(&e.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *DiningRepo) DeleteCalendarEntries(canteenId uuid.UUID, date domain.Date) error {
+	_, err := r.DB.Exec(
+		`DELETE FROM menu_calendar WHERE canteen_id = $1 AND served_on = $2`, canteenId, date)
+	return err
+}
+
+// PublishCalendarRange objavljuje nacrte u [from, to]: za svaki dan sa nacrtom
+// dosadasnji objavljeni unosi se zamenjuju nacrtom. Vraca broj objavljenih dana.
+func (r *DiningRepo) PublishCalendarRange(canteenId uuid.UUID, from, to domain.Date) (n int, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(
+		`DELETE FROM menu_calendar
+		 WHERE canteen_id = $1 AND served_on BETWEEN $2 AND $3 AND published
+		   AND served_on IN (SELECT served_on FROM menu_calendar
+		                     WHERE canteen_id = $1 AND served_on BETWEEN $2 AND $3 AND NOT published)`,
+		canteenId, from, to,
+	); err != nil {
+		return 0, err
+	}
+	if err = tx.QueryRow(
+		`WITH published AS (
+			UPDATE menu_calendar SET published = true
+			WHERE canteen_id = $1 AND served_on BETWEEN $2 AND $3 AND NOT published
+			RETURNING served_on
+		 )
+		 SELECT count(DISTINCT served_on) FROM published`,
+		canteenId, from, to,
+	).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
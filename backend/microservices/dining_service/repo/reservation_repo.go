@@ -0,0 +1,230 @@
+package repo
+
+import (
+	"database/sql"
+	"dining/domain"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const reservationColumns = `r.id, r.user_id, r.username, r.canteen_id, r.menu_id, r.slot, r.dish_id, r.served_on,
+	r.status, r.purchase_id, r.reason, r.created_at, r.updated_at`
+
+func scanReservation(row interface{ Scan(...any) error }, res *domain.Reservation) error {
+	return row.Scan(&res.Id, &res.UserId, &res.Username, &res.CanteenId, &res.MenuId, &res.Slot, &res.DishId, &res.Date,
+		&res.Status, &res.PurchaseId, &res.Reason, &res.CreatedAt, &res.UpdatedAt)
+}
+
+func (r *DiningRepo) GetSlotCapacities(canteenId uuid.UUID) ([]domain.SlotCapacity, error) {
+	rows, err := r.DB.Query(
+		`SELECT slot, capacity FROM canteen_slot_capacity WHERE canteen_id = $1 ORDER BY slot`, canteenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.SlotCapacity{}
+	for rows.Next() {
+		var c domain.SlotCapacity
+		if err := rows.Scan(&c.Slot, &c.Capacity); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceSlotCapacities zamenjuje kapacitete kantine; slot bez kapaciteta nema ogranicenje.
+func (r *DiningRepo) ReplaceSlotCapacities(canteenId uuid.UUID, caps []domain.SlotCapacity) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM canteen_slot_capacity WHERE canteen_id = $1`, canteenId); err != nil {
+		return err
+	}
+	for _, c := range caps {
+		if _, err = tx.Exec(
+			`INSERT INTO canteen_slot_capacity (canteen_id, slot, capacity) VALUES ($1, $2, $3)`,
+			canteenId, c.Slot, c.Capacity,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateReservation upisuje rezervaciju ako kantina ima slobodno mesto za slot tog dana.
+// Red kapaciteta se zakljucava pa istovremene rezervacije ne mogu da predju kapacitet.
+func (r *DiningRepo) CreateReservation(res *domain.Reservation) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var capacity int
+	err = tx.QueryRow(
+		`SELECT capacity FROM canteen_slot_capacity WHERE canteen_id = $1 AND slot = $2 FOR UPDATE`,
+		res.CanteenId, res.Slot,
+	).Scan(&capacity)
+	switch {
+	case err == sql.ErrNoRows:
+		err = nil
+	case err != nil:
+		return err
+	default:
+		var taken int
+		if err = tx.QueryRow(
+			`SELECT COUNT(*) FROM meal_reservations
+			 WHERE canteen_id = $1 AND served_on = $2 AND slot = $3 AND status IN ($4, $5)`,
+			res.CanteenId, res.Date, res.Slot, domain.ReservationActive, domain.ReservationFulfilled,
+		).Scan(&taken); err != nil {
+			return err
+		}
+		if taken >= capacity {
+			return domain.ErrCapacityReached
+		}
+	}
+	if err = reserveDish(tx, res.DishId, res.Date.String(), res.Slot, res.UserId); err != nil {
+		return err
+	}
+
+	res.Id = uuid.New()
+	res.Status = domain.ReservationActive
+	err = scanReservation(tx.QueryRow(
+		`INSERT INTO meal_reservations AS r (id, user_id, username, canteen_id, menu_id, slot, dish_id, served_on, status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING `+reservationColumns,
+		res.Id, res.UserId, res.Username, res.CanteenId, res.MenuId, res.Slot, res.DishId, res.Date, res.Status,
+	), res)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+			err = domain.ErrAlreadyReserved
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *DiningRepo) GetReservation(id uuid.UUID) (*domain.Reservation, error) {
+	var res domain.Reservation
+	err := scanReservation(r.DB.QueryRow(
+		`SELECT `+reservationColumns+` FROM meal_reservations r WHERE r.id = $1`, id,
+	), &res)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *DiningRepo) ListReservationsByUser(userId uuid.UUID) ([]domain.Reservation, error) {
+	return r.queryReservations(
+		`SELECT `+reservationColumns+` FROM meal_reservations r
+		 WHERE r.user_id = $1
+		 ORDER BY r.served_on DESC, r.slot`, userId)
+}
+
+// CancelReservation otkazuje aktivnu rezervaciju; false ako vise nije aktivna.
+func (r *DiningRepo) CancelReservation(id uuid.UUID) (bool, error) {
+	res, err := r.DB.Exec(
+		`UPDATE meal_reservations SET status = $2, updated_at = NOW()
+		 WHERE id = $1 AND status = $3`, id, domain.ReservationCancelled, domain.ReservationActive)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ListDueReservations vraca aktivne rezervacije ciji je obrok poceo da se izdaje: ranijih
+// dana ili danas od pocetka prozora slota (bez prozora od otvaranja kantine).
+func (r *DiningRepo) ListDueReservations(today domain.Date, now domain.ClockTime, limit int) ([]domain.Reservation, error) {
+	return r.queryReservations(
+		`SELECT `+reservationColumns+` FROM meal_reservations r
+		 JOIN canteens c ON c.id = r.canteen_id
+		 LEFT JOIN canteen_serving_windows w ON w.canteen_id = r.canteen_id AND w.slot = r.slot
+		 WHERE r.status = $1
+		   AND (r.served_on < $2 OR (r.served_on = $2 AND
+		        COALESCE(w.start_minute, EXTRACT(HOUR FROM c.open_at)::INT * 60 + EXTRACT(MINUTE FROM c.open_at)::INT) <= $3))
+		 ORDER BY r.served_on, r.created_at
+		 LIMIT $4`, domain.ReservationActive, today, int(now), limit)
+}
+
+// SetReservationOutcome zatvara aktivnu rezervaciju kao naplacenu ili oslobodjenu.
+func (r *DiningRepo) SetReservationOutcome(id uuid.UUID, status domain.ReservationStatus, purchaseId *uuid.UUID, reason string) error {
+	_, err := r.DB.Exec(
+		`UPDATE meal_reservations SET status = $2, purchase_id = $3, reason = $4, updated_at = NOW()
+		 WHERE id = $1 AND status = $5`, id, status, purchaseId, reason, domain.ReservationActive)
+	return err
+}
+
+func (r *DiningRepo) CountReservations(canteenId uuid.UUID, from, to domain.Date) ([]domain.ReservationCount, error) {
+	rows, err := r.DB.Query(
+		`SELECT r.served_on, r.slot, r.dish_id, COALESCE(f.name, ''), COUNT(*)
+		 FROM meal_reservations r
+		 LEFT JOIN meals f ON f.id = r.dish_id
+		 WHERE r.canteen_id = $1 AND r.served_on BETWEEN $2 AND $3 AND r.status IN ($4, $5)
+		 GROUP BY r.served_on, r.slot, r.dish_id, f.name
+		 ORDER BY r.served_on, r.slot, COUNT(*) DESC`,
+		canteenId, from, to, domain.ReservationActive, domain.ReservationFulfilled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.ReservationCount{}
+	for rows.Next() {
+		var c domain.ReservationCount
+		if err := rows.Scan(&c.Date, &c.Slot, &c.DishId, &c.DishName, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ReservationOutcomes broji naplacene i oslobodjene rezervacije kantine u periodu.
+func (r *DiningRepo) ReservationOutcomes(canteenId uuid.UUID, from, to domain.Date) (fulfilled, released int, err error) {
+	err = r.DB.QueryRow(
+		`SELECT COUNT(*) FILTER (WHERE status = $4), COUNT(*) FILTER (WHERE status = $5)
+		 FROM meal_reservations
+		 WHERE canteen_id = $1 AND served_on BETWEEN $2 AND $3`,
+		canteenId, from, to, domain.ReservationFulfilled, domain.ReservationReleased,
+	).Scan(&fulfilled, &released)
+	return fulfilled, released, err
+}
+
+func (r *DiningRepo) queryReservations(query string, args ...any) ([]domain.Reservation, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Reservation{}
+	for rows.Next() {
+		var res domain.Reservation
+		if err := scanReservation(rows, &res); err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
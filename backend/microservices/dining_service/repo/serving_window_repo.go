@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"dining/domain"
+
+	"github.com/google/uuid"
+)
+
+func (r *DiningRepo) GetServingWindows(canteenId uuid.UUID) ([]domain.ServingWindow, error) {
+	rows, err := r.DB.Query(
+		`SELECT slot, start_minute, end_minute FROM canteen_serving_windows
+		 WHERE canteen_id = $1
+		 ORDER BY start_minute`, canteenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.ServingWindow{}
+	for rows.Next() {
+		var w domain.ServingWindow
+		if err := rows.Scan(&w.Slot, &w.Start, &w.End); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceServingWindows zamenjuje sve prozore kantine u jednoj transakciji.
+func (r *DiningRepo) ReplaceServingWindows(canteenId uuid.UUID, windows []domain.ServingWindow) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM canteen_serving_windows WHERE canteen_id = $1`, canteenId); err != nil {
+		return err
+	}
+	for _, w := range windows {
+		if _, err = tx.Exec(
+			`INSERT INTO canteen_serving_windows (canteen_id, slot, start_minute, end_minute)
+			 VALUES ($1, $2, $3, $4)`,
+			canteenId, w.Slot, int(w.Start), int(w.End),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
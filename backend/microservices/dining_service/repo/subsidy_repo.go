@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"dining/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const mealSubsidyColumns = `id, user_id, kind, percent, amount_off, slot, valid_from, valid_to, note, created_by, created_at`
+
+func scanMealSubsidy(row interface{ Scan(...any) error }, s *domain.MealSubsidy) error {
+	return row.Scan(&s.Id, &s.UserId, &s.Kind, &s.Percent, &s.AmountOff, &s.Slot,
+		&s.ValidFrom, &s.ValidTo, &s.Note, &s.CreatedBy, &s.CreatedAt)
+}
+
+func (r *DiningRepo) CreateMealSubsidy(s *domain.MealSubsidy) error {
+	s.Id = uuid.New()
+	return scanMealSubsidy(r.DB.QueryRow(
+		`INSERT INTO meal_subsidies (id, user_id, kind, percent, amount_off, slot, valid_from, valid_to, note, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING `+mealSubsidyColumns,
+		s.Id, s.UserId, s.Kind, s.Percent, s.AmountOff, s.Slot, s.ValidFrom, s.ValidTo, s.Note, s.CreatedBy,
+	), s)
+}
+
+func (r *DiningRepo) ListMealSubsidies(userId uuid.UUID) ([]domain.MealSubsidy, error) {
+	return r.queryMealSubsidies(
+		`SELECT `+mealSubsidyColumns+` FROM meal_subsidies
+		 WHERE user_id = $1
+		 ORDER BY valid_from DESC`, userId)
+}
+
+// ListActiveMealSubsidies vraca pravila studenta koja vaze u trenutku at.
+func (r *DiningRepo) ListActiveMealSubsidies(userId uuid.UUID, at time.Time) ([]domain.MealSubsidy, error) {
+	return r.queryMealSubsidies(
+		`SELECT `+mealSubsidyColumns+` FROM meal_subsidies
+		 WHERE user_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)`, userId, at)
+}
+
+func (r *DiningRepo) DeleteMealSubsidy(id uuid.UUID) error {
+	_, err := r.DB.Exec(`DELETE FROM meal_subsidies WHERE id = $1`, id)
+	return err
+}
+
+func (r *DiningRepo) queryMealSubsidies(query string, args ...any) ([]domain.MealSubsidy, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.MealSubsidy{}
+	for rows.Next() {
+		var s domain.MealSubsidy
+		if err := scanMealSubsidy(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"dining/domain"
+
+	"github.com/google/uuid"
+)
+
+func (ds *DiningService) GetDietaryPreferences(userId uuid.UUID) (*domain.DietaryPreferences, error) {
+	return ds.repo.GetDietaryPreferences(userId)
+}
+
+func (ds *DiningService) SaveDietaryPreferences(p *domain.DietaryPreferences) error {
+	if err := p.Filter().Validate(); err != nil {
+		return err
+	}
+	if p.AvoidAllergens == nil {
+		p.AvoidAllergens = []domain.Allergen{}
+	}
+	if p.Tags == nil {
+		p.Tags = []domain.DietaryTag{}
+	}
+	return ds.repo.SaveDietaryPreferences(p)
+}
+
+// FilterMenus izbacuje menije bez ijednog obroka koji prolazi filter, a obrocima koji ne
+// odgovaraju filteru ili preferencama studenta viewerId (uuid.Nil za ne-studente) upisuje
+// upozorenja. Meniji se menjaju na mestu.
+func (ds *DiningService) FilterMenus(menus []*domain.Menu, filter domain.MealFilter, viewerId uuid.UUID) ([]*domain.Menu, error) {
+	flags := filter
+	if viewerId != uuid.Nil {
+		prefs, err := ds.repo.GetDietaryPreferences(viewerId)
+		if err != nil {
+			return nil, err
+		}
+		flags = filter.Merge(prefs.Filter())
+	}
+
+	out := make([]*domain.Menu, 0, len(menus))
+	for _, m := range menus {
+		keep := filter.IsZero()
+		for _, meal := range m.Meals() {
+			meal.Warnings = flags.Conflicts(meal)
+			if !keep && filter.Matches(meal) {
+				keep = true
+			}
+		}
+		if keep {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
@@ -4,18 +4,21 @@ import (
 	"dining/domain"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
 )
 
 type DiningService struct {
-	repo domain.DiningRepository
+	repo   domain.DiningRepository
+	cards  Cards
+	passes *MealPasses
 }
 
-func NewDiningService(repo domain.DiningRepository) *DiningService {
+func NewDiningService(repo domain.DiningRepository, cards Cards, passes *MealPasses) *DiningService {
 	return &DiningService{
-		repo: repo,
+		repo:   repo,
+		cards:  cards,
+		passes: passes,
 	}
 }
 
@@ -24,7 +27,14 @@ func (ds *DiningService) GetAllCanteens() ([]domain.Canteen, error) {
 }
 
 func (ds *DiningService) GetCanteen(id string) (*domain.Canteen, error) {
-	return ds.repo.GetCanteenByID(id)
+	c, err := ds.repo.GetCanteenByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if c.ServingWindows, err = ds.repo.GetServingWindows(c.Id); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (ds *DiningService) DeleteCanteen(id string) error {
@@ -36,7 +46,11 @@ func (ds *DiningService) CreateCanteen(c *domain.Canteen) error {
 }
 
 func (ds *DiningService) GetMenusByCanteenID(id string) ([]*domain.Menu, error) {
-	return ds.repo.GetMenusByCanteenID(id)
+	menus, err := ds.repo.GetMenusByCanteenID(id)
+	if err != nil {
+		return nil, err
+	}
+	return menus, ds.attachDishes(menus, nil)
 }
 
 func (ds *DiningService) CreateMenu(c *domain.MenuDTO) error {
@@ -48,17 +62,32 @@ func (ds *DiningService) CreateMenu(c *domain.MenuDTO) error {
 			Name:        c.Breakfast.Name,
 			Description: c.Breakfast.Description,
 			Price:       c.Breakfast.Price,
+			Allergens:   c.Breakfast.Allergens,
+			Tags:        c.Breakfast.Tags,
+			Nutrition:   c.Breakfast.Nutrition,
 		},
 		Lunch: domain.Meal{
 			Name:        c.Lunch.Name,
 			Description: c.Lunch.Description,
 			Price:       c.Lunch.Price,
+			Allergens:   c.Lunch.Allergens,
+			Tags:        c.Lunch.Tags,
+			Nutrition:   c.Lunch.Nutrition,
 		},
 		Dinner: domain.Meal{
 			Name:        c.Dinner.Name,
 			Description: c.Dinner.Description,
 			Price:       c.Dinner.Price,
+			Allergens:   c.Dinner.Allergens,
+			Tags:        c.Dinner.Tags,
+			Nutrition:   c.Dinner.Nutrition,
 		},
+		Dishes: c.Dishes,
+	}
+	for i := range m.Dishes {
+		if err := validateDish(&m.Dishes[i]); err != nil {
+			return err
+		}
 	}
 	return ds.repo.CreateMenu(m)
 }
@@ -85,6 +114,10 @@ func (ds *DiningService) GetMealHistoryWithReviewsByUser(id string) ([]domain.Me
 	return ds.repo.GetMealHistoryWithReviewsByUser(id)
 }
 
+func (ds *DiningService) GetMenuReview(id string) (*domain.MenuReview, error) {
+	return ds.repo.GetMenuReviewByID(id)
+}
+
 func (ds *DiningService) UpdateMenuReview(r *domain.MenuReview) error {
 	return ds.repo.UpdateMenuReview(r)
 }
@@ -93,8 +126,14 @@ func (ds *DiningService) CreateMenuReview(review *domain.MenuReview) error {
 	return ds.repo.CreateMenuReview(review)
 }
 
+// GetMenu vraca meni sa jelima i preostalim porcijama za danas.
 func (ds *DiningService) GetMenu(id string) (*domain.Menu, error) {
-	return ds.repo.GetMenuWithMealsByID(id)
+	menu, err := ds.repo.GetMenuWithMealsByID(id)
+	if err != nil {
+		return nil, err
+	}
+	today := domain.DateOf(mealClock())
+	return menu, ds.attachDishes([]*domain.Menu{menu}, &today)
 }
 
 func (ds *DiningService) GetTopRatedMeals() ([]domain.MenuRating, error) {
@@ -113,27 +152,20 @@ func (ds *DiningService) GetMealHistoryForUsernames(usernames []string) ([]domai
 	return ds.repo.GetMealHistoryForUsernames(usernames)
 }
 
-// NEW: Svi meniji iz svih kantina za današnji dan (lokalno vreme servera)
+// GetMenusForToday: meniji svih kantina za danasnji dan po kalendaru (lokalno vreme servera)
 func (ds *DiningService) GetMenusForToday() ([]*domain.Menu, error) {
-	// mapiranje time.Weekday -> domain.Weekday
-	switch time.Now().Local().Weekday() {
-	case time.Monday:
-		return ds.repo.GetMenusByWeekday(domain.Monday)
-	case time.Tuesday:
-		return ds.repo.GetMenusByWeekday(domain.Tuesday)
-	case time.Wednesday:
-		return ds.repo.GetMenusByWeekday(domain.Wednesday)
-	case time.Thursday:
-		return ds.repo.GetMenusByWeekday(domain.Thursday)
-	case time.Friday:
-		return ds.repo.GetMenusByWeekday(domain.Friday)
-	case time.Saturday:
-		return ds.repo.GetMenusByWeekday(domain.Saturday)
-	case time.Sunday:
-		return ds.repo.GetMenusByWeekday(domain.Sunday)
-	default:
-		// fallback, ne bi trebalo nikad da se desi
-		return ds.repo.GetMenusByWeekday(domain.Weekday(time.Now().Weekday().String()))
+	canteens, err := ds.repo.GetAllCanteens()
+	if err != nil {
+		return nil, err
 	}
-
+	today := domain.DateOf(mealClock())
+	menus := []*domain.Menu{}
+	for _, c := range canteens {
+		day, err := ds.MenusForDate(c.Id, today)
+		if err != nil {
+			return nil, err
+		}
+		menus = append(menus, day.Menus...)
+	}
+	return menus, nil
 }
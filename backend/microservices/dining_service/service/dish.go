@@ -0,0 +1,131 @@
+package service
+
+import (
+	"common/money"
+	"dining/domain"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidDishInput = errors.New("invalid dish")
+	ErrInvalidRating    = errors.New("rating must be between 1 and 5")
+	ErrDishNotEaten     = errors.New("only dishes you have had can be reviewed")
+)
+
+const popularDishesLimit = 10
+
+// attachDishes ucitava jela menija; za zadati dan racuna i preostale porcije jela sa zalihom.
+func (ds *DiningService) attachDishes(menus []*domain.Menu, day *domain.Date) error {
+	if len(menus) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(menus))
+	for _, m := range menus {
+		ids = append(ids, m.Id)
+	}
+	dishes, err := ds.repo.ListMenuDishes(ids)
+	if err != nil {
+		return err
+	}
+
+	var taken map[uuid.UUID]int
+	if day != nil {
+		var limited []uuid.UUID
+		for _, d := range dishes {
+			if d.Stock != nil {
+				limited = append(limited, d.Id)
+			}
+		}
+		if len(limited) > 0 {
+			if taken, err = ds.repo.CountTakenPortions(limited, *day); err != nil {
+				return err
+			}
+		}
+	}
+
+	byMenu := map[uuid.UUID][]domain.Dish{}
+	for _, d := range dishes {
+		if d.Stock != nil && day != nil {
+			left := max(*d.Stock-taken[d.Id], 0)
+			d.Remaining = &left
+		}
+		byMenu[d.MenuId] = append(byMenu[d.MenuId], d)
+	}
+	for _, m := range menus {
+		m.Dishes = byMenu[m.Id]
+	}
+	return nil
+}
+
+// validateDish proverava jelo pre upisa; stock je broj porcija dnevno.
+func validateDish(d *domain.Dish) error {
+	if !d.Slot.Valid() || d.Name == "" || d.Price.IsNegative() || d.Price.Currency != money.DefaultCurrency {
+		return ErrInvalidDishInput
+	}
+	if d.Stock != nil && *d.Stock < 0 {
+		return ErrInvalidDishInput
+	}
+	return d.ValidateDietary()
+}
+
+func (ds *DiningService) AddMenuDish(d *domain.Dish) error {
+	if err := validateDish(d); err != nil {
+		return err
+	}
+	return ds.repo.AddMenuDish(d)
+}
+
+// SetDishStock menja dnevnu zalihu jela; nil znaci neograniceno.
+func (ds *DiningService) SetDishStock(mealId uuid.UUID, stock *int) error {
+	if stock != nil && *stock < 0 {
+		return ErrInvalidDishInput
+	}
+	return ds.repo.SetDishStock(mealId, stock)
+}
+
+func (ds *DiningService) DeleteMenuDish(mealId uuid.UUID) error {
+	d, err := ds.repo.GetMenuDish(mealId)
+	if err != nil {
+		return err
+	}
+	if d.Position == 0 {
+		return domain.ErrDefaultDish
+	}
+	return ds.repo.DeleteMenuDish(mealId)
+}
+
+// ReviewDish upisuje ili menja ocenu jela; oceniti se moze samo jelo iz istorije studenta.
+func (ds *DiningService) ReviewDish(rv *domain.DishReview) error {
+	if rv.Rating < 1 || rv.Rating > 5 {
+		return ErrInvalidRating
+	}
+	if _, err := ds.repo.GetMenuDish(rv.MealId); err != nil {
+		return err
+	}
+	eaten, err := ds.repo.HasEatenDish(rv.UserId, rv.MealId)
+	if err != nil {
+		return err
+	}
+	if !eaten {
+		return ErrDishNotEaten
+	}
+	return ds.repo.SaveDishReview(rv)
+}
+
+func (ds *DiningService) ListDishReviews(mealId uuid.UUID) ([]domain.DishReview, error) {
+	return ds.repo.ListDishReviews(mealId)
+}
+
+func (ds *DiningService) DishHistory(userId uuid.UUID) ([]domain.DishHistory, error) {
+	return ds.repo.ListDishHistory(userId)
+}
+
+func (ds *DiningService) PopularDishes(canteenId uuid.UUID) ([]domain.DishStats, error) {
+	return ds.repo.ListPopularDishes(canteenId, popularDishesLimit)
+}
+
+func (ds *DiningService) TopRatedDishes() ([]domain.DishStats, error) {
+	return ds.repo.ListTopRatedDishes(popularDishesLimit)
+}
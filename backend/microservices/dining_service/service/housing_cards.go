@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bytes"
+	"common/money"
+	"common/servicetoken"
+	"context"
+	"dining/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrChargeRejected: housing je trajno odbio zahtev (nema kartice, konflikt reference...);
+// ponavljanje ne pomaze. Ostale greske su prolazne i kupovinu nastavlja oporavak.
+var ErrChargeRejected = errors.New("card charge rejected")
+
+// ErrInsufficientFunds: housing je odbio naplatu jer na kartici nema dovoljno sredstava.
+var ErrInsufficientFunds = fmt.Errorf("%w: insufficient funds", ErrChargeRejected)
+
+// Cards vodi naplatu kupovine obroka sa kartice; koraci su idempotentni po id-u kupovine.
+type Cards interface {
+	Charge(ctx context.Context, p *domain.MealPurchase) error
+	Confirm(ctx context.Context, p *domain.MealPurchase) error
+	Compensate(ctx context.Context, p *domain.MealPurchase) error
+}
+
+// HousingCards je klijent za naplatu obroka sa studentske kartice u housing servisu
+// (charge -> confirm | compensate). Svi pozivi su idempotentni po id-u kupovine.
+type HousingCards struct {
+	baseURL string
+	tokens  *servicetoken.Client
+	client  *http.Client
+}
+
+func NewHousingCards(housingBaseURL string, tokens *servicetoken.Client) *HousingCards {
+	return &HousingCards{
+		baseURL: strings.TrimRight(housingBaseURL, "/"),
+		tokens:  tokens,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type cardCharge struct {
+	ReferencaID     string      `json:"referencaId"`
+	StudentUsername string      `json:"studentUsername"`
+	Iznos           money.Money `json:"iznos"`
+}
+
+func chargeOf(p *domain.MealPurchase) cardCharge {
+	return cardCharge{ReferencaID: p.Id.String(), StudentUsername: p.Username, Iznos: p.Amount}
+}
+
+func (c *HousingCards) Charge(ctx context.Context, p *domain.MealPurchase) error {
+	return c.post(ctx, "/internal/cards/charges", chargeOf(p))
+}
+
+func (c *HousingCards) Confirm(ctx context.Context, p *domain.MealPurchase) error {
+	return c.post(ctx, "/internal/cards/charges/"+p.Id.String()+"/confirm", nil)
+}
+
+func (c *HousingCards) Compensate(ctx context.Context, p *domain.MealPurchase) error {
+	return c.post(ctx, "/internal/cards/charges/"+p.Id.String()+"/compensate", chargeOf(p))
+}
+
+func (c *HousingCards) post(ctx context.Context, path string, body any) error {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusPaymentRequired:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: %s", ErrInsufficientFunds, strings.TrimSpace(string(msg)))
+	case resp.StatusCode == http.StatusBadRequest,
+		resp.StatusCode == http.StatusNotFound,
+		resp.StatusCode == http.StatusConflict:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: %d %s", ErrChargeRejected, resp.StatusCode, strings.TrimSpace(string(msg)))
+	default:
+		return fmt.Errorf("housing %s: unexpected status code: %d", path, resp.StatusCode)
+	}
+}
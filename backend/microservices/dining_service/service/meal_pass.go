@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dining/domain"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	// mealPassTTL: koliko dugo QR propusnica vazi; student je pravi tek kad stane na kasu.
+	mealPassTTL    = 2 * time.Minute
+	mealPassQRSize = 320 // px
+)
+
+// MealPasses potpisuje i proverava QR propusnice za obrok. Token je
+// <base64url(json propusnice)>.<base64url(hmac-sha256)> i ceo staje u QR kod.
+type MealPasses struct {
+	secret []byte
+}
+
+func NewMealPasses(secret string) *MealPasses {
+	return &MealPasses{secret: []byte(secret)}
+}
+
+func (m *MealPasses) sign(payload string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *MealPasses) encode(p *domain.MealPass) (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + m.sign(payload), nil
+}
+
+// decode proverava potpis i rok tokena; svaka greska je ErrInvalidMealPass.
+func (m *MealPasses) decode(token string, at time.Time) (*domain.MealPass, error) {
+	payload, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(m.sign(payload))) {
+		return nil, domain.ErrInvalidMealPass
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, domain.ErrInvalidMealPass
+	}
+	var p domain.MealPass
+	if err := json.Unmarshal(b, &p); err != nil || p.Id == uuid.Nil || p.Expired(at) {
+		return nil, domain.ErrInvalidMealPass
+	}
+	return &p, nil
+}
+
+// IssueMealPass pravi propusnicu za izbor iz ponude; obroci moraju moci da se izdaju
+// sada, pa student ne dobija QR koji bi kasa ionako odbila.
+func (ds *DiningService) IssueMealPass(menu *domain.Menu, q *domain.MealQuote, userId uuid.UUID, username string) (*domain.MealPass, string, error) {
+	now := mealClock()
+	if err := ds.checkServing(menu, q.Slots, now); err != nil {
+		return nil, "", err
+	}
+	p := &domain.MealPass{
+		Id:        uuid.New(),
+		UserId:    userId,
+		Username:  username,
+		MenuId:    menu.Id,
+		Slots:     q.Slots,
+		Dishes:    q.Dishes,
+		ExpiresAt: now.Add(mealPassTTL).UTC().Truncate(time.Second),
+	}
+	token, err := ds.passes.encode(p)
+	if err != nil {
+		return nil, "", err
+	}
+	return p, token, nil
+}
+
+// MealPassQR vraca PNG QR kod sa tokenom propusnice.
+func MealPassQR(token string) ([]byte, error) {
+	return qrcode.Encode(token, qrcode.Medium, mealPassQRSize)
+}
+
+// RedeemMealPass: kasa skenira propusnicu i kupuje obroke za studenta. Cena se racuna
+// u trenutku skeniranja; propusnica se zauzima u istoj transakciji u kojoj se upisuje
+// kupovina, pa greska pre upisa ne trosi propusnicu, a ista propusnica ne moze se
+// iskoristiti dva puta, ni istovremeno na dve kase.
+func (ds *DiningService) RedeemMealPass(ctx context.Context, token, cashier string) (*domain.MealPurchase, error) {
+	now := mealClock()
+	pass, err := ds.passes.decode(token, now)
+	if err != nil {
+		return nil, err
+	}
+	menu, err := ds.GetMenu(pass.MenuId.String())
+	if err != nil {
+		return nil, domain.ErrMenuNotFound
+	}
+	q, err := ds.QuoteMeal(menu, pass.Slots, pass.Dishes, pass.UserId, now)
+	if err != nil {
+		return nil, err
+	}
+	p := &domain.MealPurchase{
+		IdempotencyKey: "pass:" + pass.Id.String(),
+		UserId:         pass.UserId,
+		Username:       pass.Username,
+		MenuId:         menu.Id,
+		CanteenId:      menu.CanteenId,
+		Slots:          q.Slots,
+		Dishes:         q.Dishes,
+		ListPrice:      q.ListPrice,
+		Discount:       q.Discount,
+		Amount:         q.Amount,
+	}
+	created, err := ds.purchaseMeal(ctx, menu, p, &domain.MealPassRedemption{Pass: pass, RedeemedBy: cashier})
+	if err != nil {
+		return nil, err
+	}
+	if !created { // kupovina za ovu propusnicu vec postoji, obrok je vec izdat
+		return nil, domain.ErrMealPassUsed
+	}
+	return p, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"dining/domain"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key already used for a different purchase")
+
+const (
+	// purchaseRetryAfter: oporavak ne dira kupovinu koju je neko menjao skorije od ovoga
+	// (zahtev koji je jos u toku, ili prethodni neuspeo pokusaj).
+	purchaseRetryAfter = 30 * time.Second
+	// maxChargeAttempts: posle toliko neuspelih naplata kupovina se ponistava, jer se
+	// ne zna da li je neki od pokusaja ipak zaduzio karticu.
+	maxChargeAttempts = 5
+	recoveryBatchSize = 50
+)
+
+// PurchaseMeal zapocinje kupovinu obroka iz menija i vodi je koliko moze. Ponovljen
+// zahtev sa istim idempotency kljucem vraca postojecu kupovinu (created=false) i ne
+// naplacuje ponovo; nova kupovina mora biti u vreme izdavanja izabranih obroka.
+func (ds *DiningService) PurchaseMeal(ctx context.Context, menu *domain.Menu, p *domain.MealPurchase) (created bool, err error) {
+	return ds.purchaseMeal(ctx, menu, p, nil)
+}
+
+func (ds *DiningService) purchaseMeal(ctx context.Context, menu *domain.Menu, p *domain.MealPurchase, pass *domain.MealPassRedemption) (created bool, err error) {
+	req := *p
+	existing, err := ds.repo.GetMealPurchaseByKey(p.UserId, p.IdempotencyKey)
+	switch {
+	case err == nil:
+		*p = *existing
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	default:
+		now := mealClock()
+		if err := ds.checkServing(menu, p.Slots, now); err != nil {
+			return false, err
+		}
+		if created, err = ds.repo.CreateMealPurchase(p, now, pass); err != nil {
+			return false, err
+		}
+	}
+	if !created {
+		if p.MenuId != req.MenuId || !sameSlots(p.Slots, req.Slots) ||
+			(len(p.Dishes) > 0 && !p.Dishes.Equal(req.Dishes)) { // kupovine pre izbora jela nemaju dishes
+			return false, ErrIdempotencyKeyReused
+		}
+		return false, nil
+	}
+	// greska koraka je vec zabelezena; kupovinu dovrsava oporavak
+	_ = ds.advancePurchase(ctx, p)
+	return true, nil
+}
+
+// advancePurchase pomera kupovinu kroz stanja dok ne stigne do zavrsnog ili dok
+// neki korak ne uspe; svaki korak je idempotentan pa ga je bezbedno ponoviti.
+func (ds *DiningService) advancePurchase(ctx context.Context, p *domain.MealPurchase) error {
+	for !p.State.Terminal() {
+		from := p.State
+		var (
+			to      domain.PurchaseState
+			stepErr error
+		)
+		switch from {
+		case domain.PurchasePending:
+			to = domain.PurchaseCharged
+			if p.Amount.IsZero() {
+				break // potpuno subvencionisan obrok; kartica se ne dira
+			}
+			stepErr = ds.cards.Charge(ctx, p)
+			if errors.Is(stepErr, ErrChargeRejected) {
+				to, stepErr = domain.PurchaseFailed, ds.fail(p, from, domain.PurchaseFailed, stepErr)
+			} else if stepErr != nil && p.Attempts+1 >= maxChargeAttempts {
+				to, stepErr = domain.PurchaseRefunding, ds.fail(p, from, domain.PurchaseRefunding, stepErr)
+			}
+		case domain.PurchaseCharged:
+			// upis i prelaz u recorded su u istoj lokalnoj transakciji
+			stepErr = ds.repo.RecordMealPurchase(p)
+			if errors.Is(stepErr, domain.ErrMenuNotFound) {
+				stepErr = ds.fail(p, from, domain.PurchaseRefunding, stepErr)
+			}
+			if stepErr == nil {
+				continue
+			}
+		case domain.PurchaseRecorded:
+			to = domain.PurchaseCompleted
+			if !p.Amount.IsZero() {
+				stepErr = ds.cards.Confirm(ctx, p)
+			}
+		case domain.PurchaseRefunding:
+			to = domain.PurchaseRefunded
+			if !p.Amount.IsZero() {
+				stepErr = ds.cards.Compensate(ctx, p)
+			}
+		default:
+			return nil
+		}
+
+		if stepErr != nil {
+			log.Printf("meal purchase %s: %s step failed: %v", p.Id, from, stepErr)
+			if err := ds.repo.NoteMealPurchaseFailure(p.Id, stepErr.Error()); err != nil {
+				log.Printf("meal purchase %s: note failure: %v", p.Id, err)
+			}
+			p.Attempts++
+			return stepErr
+		}
+		if p.State != from {
+			continue // fail() je vec promenio stanje
+		}
+		if err := ds.transition(p, from, to, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail prebacuje kupovinu u to uz razlog; vraca nil ako je prelaz upisan.
+func (ds *DiningService) fail(p *domain.MealPurchase, from, to domain.PurchaseState, cause error) error {
+	msg := cause.Error()
+	code := failureCode(cause)
+	log.Printf("meal purchase %s: %s -> %s: %s", p.Id, from, to, msg)
+	if err := ds.transition(p, from, to, &msg, code); err != nil {
+		return err
+	}
+	p.LastError = &msg
+	if code != nil {
+		p.FailureCode = code
+	}
+	return nil
+}
+
+func failureCode(cause error) *string {
+	var code string
+	switch {
+	case errors.Is(cause, ErrInsufficientFunds):
+		code = domain.FailureInsufficientFunds
+	case errors.Is(cause, ErrChargeRejected):
+		code = domain.FailureChargeRejected
+	default:
+		return nil
+	}
+	return &code
+}
+
+func sameSlots(a, b []domain.MealSlot) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// transition upisuje prelaz; ako je kupovinu u medjuvremenu pomerio neko drugi,
+// p se osvezava iz baze i nastavlja od stanja koje je tamo.
+func (ds *DiningService) transition(p *domain.MealPurchase, from, to domain.PurchaseState, lastErr, failureCode *string) error {
+	ok, err := ds.repo.TransitionMealPurchase(p.Id, from, to, lastErr, failureCode)
+	if err != nil {
+		return err
+	}
+	if ok {
+		p.State = to
+		return nil
+	}
+	fresh, err := ds.repo.GetMealPurchase(p.Id)
+	if err != nil {
+		return err
+	}
+	*p = *fresh
+	return nil
+}
+
+func (ds *DiningService) GetMealPurchase(id uuid.UUID) (*domain.MealPurchase, error) {
+	return ds.repo.GetMealPurchase(id)
+}
+
+// RunPurchaseRecovery na svakih every dovrsava ili ponistava zaglavljene kupovine
+// (pad servisa, nedostupan housing) dok se ctx ne otkaze.
+func (ds *DiningService) RunPurchaseRecovery(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		ds.recoverPurchases(ctx)
+	}
+}
+
+// recoverPurchases je jedan prolaz oporavka: svaku zaglavljenu kupovinu pomera dalje.
+func (ds *DiningService) recoverPurchases(ctx context.Context) {
+	stalled, err := ds.repo.ListStalledMealPurchases(purchaseRetryAfter, recoveryBatchSize)
+	if err != nil {
+		log.Printf("meal purchase recovery: %v", err)
+		return
+	}
+	for i := range stalled {
+		if err := ds.advancePurchase(ctx, &stalled[i]); err == nil {
+			log.Printf("meal purchase %s recovered: %s", stalled[i].Id, stalled[i].State)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"common/money"
+	"context"
+	"database/sql"
+	"dining/domain"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memPurchases je repozitorijum kupovina u memoriji; kantina radi ceo dan i meni
+// menuId je objavljen za svaki dan.
+type memPurchases struct {
+	domain.DiningRepository
+	menuId    uuid.UUID
+	byId      map[uuid.UUID]*domain.MealPurchase
+	recordErr error // greska koju vraca RecordMealPurchase
+	recorded  int
+}
+
+func newMemPurchases(menuId uuid.UUID) *memPurchases {
+	return &memPurchases{menuId: menuId, byId: map[uuid.UUID]*domain.MealPurchase{}}
+}
+
+func (m *memPurchases) GetMealPurchaseByKey(userId uuid.UUID, key string) (*domain.MealPurchase, error) {
+	for _, p := range m.byId {
+		if p.UserId == userId && p.IdempotencyKey == key {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *memPurchases) CreateMealPurchase(p *domain.MealPurchase, _ time.Time, _ *domain.MealPassRedemption) (bool, error) {
+	if existing, err := m.GetMealPurchaseByKey(p.UserId, p.IdempotencyKey); err == nil {
+		*p = *existing
+		return false, nil
+	}
+	if p.Id == uuid.Nil {
+		p.Id = uuid.New()
+	}
+	p.State = domain.PurchasePending
+	m.put(p)
+	return true, nil
+}
+
+func (m *memPurchases) GetMealPurchase(id uuid.UUID) (*domain.MealPurchase, error) {
+	p, ok := m.byId[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	cp := *p
+	return &cp, nil
+}
+
+func (m *memPurchases) TransitionMealPurchase(id uuid.UUID, from, to domain.PurchaseState, lastErr, failureCode *string) (bool, error) {
+	p, ok := m.byId[id]
+	if !ok || p.State != from {
+		return false, nil
+	}
+	p.State = to
+	if lastErr != nil {
+		p.LastError = lastErr
+	}
+	if failureCode != nil {
+		p.FailureCode = failureCode
+	}
+	return true, nil
+}
+
+func (m *memPurchases) NoteMealPurchaseFailure(id uuid.UUID, cause string) error {
+	if p, ok := m.byId[id]; ok {
+		p.Attempts++
+		p.LastError = &cause
+	}
+	return nil
+}
+
+func (m *memPurchases) RecordMealPurchase(p *domain.MealPurchase) error {
+	if m.recordErr != nil {
+		return m.recordErr
+	}
+	m.recorded++
+	p.State = domain.PurchaseRecorded
+	m.byId[p.Id].State = domain.PurchaseRecorded
+	return nil
+}
+
+func (m *memPurchases) ListStalledMealPurchases(time.Duration, int) ([]domain.MealPurchase, error) {
+	var stalled []domain.MealPurchase
+	for _, p := range m.byId {
+		if !p.State.Terminal() {
+			stalled = append(stalled, *p)
+		}
+	}
+	return stalled, nil
+}
+
+func (m *memPurchases) ListCalendarEntries(canteenId uuid.UUID, from, _ domain.Date) ([]domain.MenuCalendarEntry, error) {
+	return []domain.MenuCalendarEntry{{CanteenId: canteenId, Date: from, MenuId: &m.menuId, Published: true}}, nil
+}
+
+func (m *memPurchases) ListMenuPlans(uuid.UUID) ([]domain.MenuPlan, error) { return nil, nil }
+
+func (m *memPurchases) GetMenuWithMealsByID(id string) (*domain.Menu, error) {
+	return &domain.Menu{Id: uuid.MustParse(id)}, nil
+}
+
+func (m *memPurchases) ListMenuDishes([]uuid.UUID) ([]domain.Dish, error) { return nil, nil }
+
+func (m *memPurchases) GetCanteenByID(id string) (*domain.Canteen, error) {
+	return &domain.Canteen{
+		Id:      uuid.MustParse(id),
+		OpenAt:  time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC),
+		CloseAt: time.Date(0, 1, 1, 23, 59, 0, 0, time.UTC),
+	}, nil
+}
+
+func (m *memPurchases) GetServingWindows(uuid.UUID) ([]domain.ServingWindow, error) { return nil, nil }
+
+func (m *memPurchases) put(p *domain.MealPurchase) {
+	cp := *p
+	m.byId[p.Id] = &cp
+}
+
+// stubCards broji pozive housing klijenta; chargeErr vraca Charge.
+type stubCards struct {
+	chargeErr                      error
+	charges, confirms, compensates int
+}
+
+func (c *stubCards) Charge(context.Context, *domain.MealPurchase) error {
+	c.charges++
+	return c.chargeErr
+}
+
+func (c *stubCards) Confirm(context.Context, *domain.MealPurchase) error {
+	c.confirms++
+	return nil
+}
+
+func (c *stubCards) Compensate(context.Context, *domain.MealPurchase) error {
+	c.compensates++
+	return nil
+}
+
+func newPurchaseTest(t *testing.T) (*DiningService, *memPurchases, *stubCards, *domain.Menu) {
+	t.Helper()
+	clock := mealClock
+	mealClock = func() time.Time { return time.Date(2026, time.October, 14, 12, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { mealClock = clock })
+
+	menu := &domain.Menu{Id: uuid.New(), CanteenId: uuid.New()}
+	repo := newMemPurchases(menu.Id)
+	cards := &stubCards{}
+	return NewDiningService(repo, cards, nil), repo, cards, menu
+}
+
+func purchaseOf(menu *domain.Menu, userId uuid.UUID, key string) *domain.MealPurchase {
+	return &domain.MealPurchase{
+		IdempotencyKey: key,
+		UserId:         userId,
+		Username:       "nikola123",
+		MenuId:         menu.Id,
+		CanteenId:      menu.CanteenId,
+		Slots:          []domain.MealSlot{domain.SlotLunch},
+		ListPrice:      money.RSD(30000),
+		Amount:         money.RSD(30000),
+	}
+}
+
+func TestPurchaseMealIdempotentReplay(t *testing.T) {
+	ds, _, cards, menu := newPurchaseTest(t)
+	user := uuid.New()
+
+	first := purchaseOf(menu, user, "k1")
+	created, err := ds.PurchaseMeal(context.Background(), menu, first)
+	if err != nil || !created {
+		t.Fatalf("first purchase: created=%v err=%v", created, err)
+	}
+	if first.State != domain.PurchaseCompleted {
+		t.Fatalf("first purchase state = %s, want %s", first.State, domain.PurchaseCompleted)
+	}
+
+	replay := purchaseOf(menu, user, "k1")
+	created, err = ds.PurchaseMeal(context.Background(), menu, replay)
+	if err != nil || created {
+		t.Fatalf("replay: created=%v err=%v, want existing purchase", created, err)
+	}
+	if replay.Id != first.Id || replay.State != domain.PurchaseCompleted {
+		t.Errorf("replay = %s (%s), want %s (%s)", replay.Id, replay.State, first.Id, domain.PurchaseCompleted)
+	}
+	if cards.charges != 1 || cards.confirms != 1 {
+		t.Errorf("charges=%d confirms=%d, want 1 and 1", cards.charges, cards.confirms)
+	}
+}
+
+func TestPurchaseMealIdempotencyKeyReused(t *testing.T) {
+	ds, _, cards, menu := newPurchaseTest(t)
+	user := uuid.New()
+
+	if _, err := ds.PurchaseMeal(context.Background(), menu, purchaseOf(menu, user, "k1")); err != nil {
+		t.Fatal(err)
+	}
+	other := purchaseOf(menu, user, "k1")
+	other.Slots = []domain.MealSlot{domain.SlotDinner}
+	if _, err := ds.PurchaseMeal(context.Background(), menu, other); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("err = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+	if cards.charges != 1 {
+		t.Errorf("charges = %d, want 1", cards.charges)
+	}
+}
+
+func TestPurchaseMealInsufficientFunds(t *testing.T) {
+	ds, repo, cards, menu := newPurchaseTest(t)
+	cards.chargeErr = fmt.Errorf("%w: balance 100.00 RSD", ErrInsufficientFunds)
+
+	p := purchaseOf(menu, uuid.New(), "k1")
+	created, err := ds.PurchaseMeal(context.Background(), menu, p)
+	if err != nil || !created {
+		t.Fatalf("created=%v err=%v", created, err)
+	}
+	if p.State != domain.PurchaseFailed {
+		t.Fatalf("state = %s, want %s", p.State, domain.PurchaseFailed)
+	}
+	if p.FailureCode == nil || *p.FailureCode != domain.FailureInsufficientFunds {
+		t.Errorf("failure code = %v, want %s", p.FailureCode, domain.FailureInsufficientFunds)
+	}
+	if stored := repo.byId[p.Id]; stored.State != domain.PurchaseFailed {
+		t.Errorf("stored state = %s, want %s", stored.State, domain.PurchaseFailed)
+	}
+	if repo.recorded != 0 || cards.compensates != 0 {
+		t.Errorf("recorded=%d compensates=%d, want nothing after a rejected charge", repo.recorded, cards.compensates)
+	}
+}
+
+func TestPurchaseMealRecordFailureRefunds(t *testing.T) {
+	ds, repo, cards, menu := newPurchaseTest(t)
+	repo.recordErr = fmt.Errorf("%w: %s", domain.ErrMenuNotFound, menu.Id)
+
+	p := purchaseOf(menu, uuid.New(), "k1")
+	if _, err := ds.PurchaseMeal(context.Background(), menu, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.State != domain.PurchaseRefunded {
+		t.Fatalf("state = %s, want %s", p.State, domain.PurchaseRefunded)
+	}
+	if p.LastError == nil {
+		t.Error("last error not set")
+	}
+	if cards.charges != 1 || cards.compensates != 1 || cards.confirms != 0 {
+		t.Errorf("charges=%d compensates=%d confirms=%d, want 1, 1, 0", cards.charges, cards.compensates, cards.confirms)
+	}
+}
+
+func TestRecoveryResumesChargedPurchase(t *testing.T) {
+	ds, repo, cards, menu := newPurchaseTest(t)
+
+	// servis je pao posle naplate, pre upisa istorije
+	p := purchaseOf(menu, uuid.New(), "k1")
+	p.Id = uuid.New()
+	p.State = domain.PurchaseCharged
+	repo.put(p)
+
+	ds.recoverPurchases(context.Background())
+
+	if stored := repo.byId[p.Id]; stored.State != domain.PurchaseCompleted {
+		t.Fatalf("state = %s, want %s", stored.State, domain.PurchaseCompleted)
+	}
+	if cards.charges != 0 {
+		t.Errorf("charges = %d, recovery must not charge a charged purchase again", cards.charges)
+	}
+	if repo.recorded != 1 || cards.confirms != 1 {
+		t.Errorf("recorded=%d confirms=%d, want 1 and 1", repo.recorded, cards.confirms)
+	}
+}
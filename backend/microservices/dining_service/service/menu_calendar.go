@@ -0,0 +1,237 @@
+package service
+
+import (
+	"dining/domain"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidMenuCalendar = errors.New("invalid menu calendar request")
+
+// MenusForDate vraca menije kantine za dan; vide se samo objavljeni unosi.
+func (ds *DiningService) MenusForDate(canteenId uuid.UUID, date domain.Date) (*domain.MenuDay, error) {
+	days, err := ds.menuDays(canteenId, date, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	return &days[0], nil
+}
+
+// MenusForWeek vraca menije od ponedeljka do nedelje nedelje kojoj date pripada;
+// sa drafts=true nacrti imaju prednost (pregled pre objave).
+func (ds *DiningService) MenusForWeek(canteenId uuid.UUID, date domain.Date, drafts bool) ([]domain.MenuDay, error) {
+	return ds.menuDays(canteenId, date.WeekStart(), 7, drafts)
+}
+
+// menuDays razresava menije za n dana od from: unosi za datum (izuzeci, objavljene
+// nedelje) imaju prednost nad ponavljajucim planovima.
+func (ds *DiningService) menuDays(canteenId uuid.UUID, from domain.Date, n int, drafts bool) ([]domain.MenuDay, error) {
+	to := from.AddDays(n - 1)
+	entries, err := ds.repo.ListCalendarEntries(canteenId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := ds.repo.ListMenuPlans(canteenId)
+	if err != nil {
+		return nil, err
+	}
+
+	menus := map[uuid.UUID]*domain.Menu{}
+	load := func(id uuid.UUID) (*domain.Menu, error) {
+		if m, ok := menus[id]; ok {
+			return m, nil
+		}
+		m, err := ds.repo.GetMenuWithMealsByID(id.String())
+		if err != nil {
+			return nil, err
+		}
+		menus[id] = m
+		return m, nil
+	}
+
+	days := make([]domain.MenuDay, 0, n)
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		day := domain.MenuDay{Date: d, CanteenId: canteenId, Menus: []*domain.Menu{}}
+
+		var published, draft []domain.MenuCalendarEntry
+		for _, e := range entries {
+			if !e.Date.Equal(d) {
+				continue
+			}
+			if e.Published {
+				published = append(published, e)
+			} else {
+				draft = append(draft, e)
+			}
+		}
+		var ids []uuid.UUID
+		switch {
+		case drafts && len(draft) > 0:
+			day.Source = domain.MenuSourceDraft
+			ids = calendarDay(&day, draft)
+		case len(published) > 0:
+			day.Source = domain.MenuSourceCalendar
+			ids = calendarDay(&day, published)
+		default:
+			day.Source = domain.MenuSourcePlan
+			for i := range plans {
+				if plans[i].Covers(d) {
+					ids = append(ids, plans[i].MenuId)
+				}
+			}
+		}
+		for _, id := range ids {
+			m, err := load(id)
+			if err != nil {
+				return nil, err
+			}
+			day.Menus = append(day.Menus, m)
+		}
+		days = append(days, day)
+	}
+
+	loaded := make([]*domain.Menu, 0, len(menus))
+	for _, m := range menus {
+		loaded = append(loaded, m)
+	}
+	var stockDay *domain.Date // preostale porcije imaju smisla samo za jedan dan
+	if n == 1 {
+		stockDay = &from
+	}
+	if err := ds.attachDishes(loaded, stockDay); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// calendarDay popunjava dan iz unosa za datum; unos bez menija zatvara kantinu.
+func calendarDay(day *domain.MenuDay, entries []domain.MenuCalendarEntry) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, e := range entries {
+		if e.Note != "" {
+			day.Note = e.Note
+		}
+		if e.MenuId == nil {
+			day.Closed = true
+			return nil
+		}
+		ids = append(ids, *e.MenuId)
+	}
+	return ids
+}
+
+func (ds *DiningService) ListMenuPlans(canteenId uuid.UUID) ([]domain.MenuPlan, error) {
+	return ds.repo.ListMenuPlans(canteenId)
+}
+
+// CreateMenuPlan dodaje ponavljajuci plan; dan u nedelji je podrazumevano dan menija.
+func (ds *DiningService) CreateMenuPlan(p *domain.MenuPlan) error {
+	menu, err := ds.repo.GetMenuByID(p.MenuId.String())
+	if err != nil {
+		return fmt.Errorf("%w: menu not found", ErrInvalidMenuCalendar)
+	}
+	if menu.CanteenId != p.CanteenId {
+		return fmt.Errorf("%w: menu belongs to another canteen", ErrInvalidMenuCalendar)
+	}
+	if p.Weekday == "" {
+		p.Weekday = menu.Weekday
+	}
+	switch {
+	case !validWeekday(p.Weekday):
+		return fmt.Errorf("%w: unknown weekday %q", ErrInvalidMenuCalendar, p.Weekday)
+	case p.ValidFrom.IsZero():
+		return fmt.Errorf("%w: valid_from is required", ErrInvalidMenuCalendar)
+	case p.ValidTo != nil && p.ValidTo.Before(p.ValidFrom):
+		return fmt.Errorf("%w: valid_to is before valid_from", ErrInvalidMenuCalendar)
+	}
+	return ds.repo.CreateMenuPlan(p)
+}
+
+func (ds *DiningService) EndMenuPlan(id uuid.UUID, validTo domain.Date) error {
+	return ds.repo.EndMenuPlan(id, validTo)
+}
+
+func (ds *DiningService) DeleteMenuPlan(id uuid.UUID) error {
+	return ds.repo.DeleteMenuPlan(id)
+}
+
+// OverrideDate odmah objavljuje menije za jedan datum umesto plana; bez menija
+// (closed) kantina tog dana ne radi.
+func (ds *DiningService) OverrideDate(canteenId uuid.UUID, date domain.Date, menuIds []uuid.UUID, closed bool, note, by string) (*domain.MenuDay, error) {
+	if closed == (len(menuIds) > 0) {
+		return nil, fmt.Errorf("%w: give either menu_ids or closed", ErrInvalidMenuCalendar)
+	}
+	entries := []domain.MenuCalendarEntry{}
+	if closed {
+		entries = append(entries, domain.MenuCalendarEntry{Date: date, Published: true, Note: note, CreatedBy: by})
+	}
+	for _, id := range menuIds {
+		menu, err := ds.repo.GetMenuByID(id.String())
+		if err != nil || menu.CanteenId != canteenId {
+			return nil, fmt.Errorf("%w: menu %s is not a menu of this canteen", ErrInvalidMenuCalendar, id)
+		}
+		menuId := id
+		entries = append(entries, domain.MenuCalendarEntry{Date: date, MenuId: &menuId, Published: true, Note: note, CreatedBy: by})
+	}
+	if err := ds.repo.ReplaceCalendarEntries(canteenId, []domain.Date{date}, entries, false); err != nil {
+		return nil, err
+	}
+	return ds.MenusForDate(canteenId, date)
+}
+
+// ClearOverride brise unose za datum; dan se ponovo razresava po planovima.
+func (ds *DiningService) ClearOverride(canteenId uuid.UUID, date domain.Date) (*domain.MenuDay, error) {
+	if err := ds.repo.DeleteCalendarEntries(canteenId, date); err != nil {
+		return nil, err
+	}
+	return ds.MenusForDate(canteenId, date)
+}
+
+// CopyWeek kopira objavljene menije nedelje from u nacrt nedelje to; nacrt se
+// vidi tek posle PublishWeek.
+func (ds *DiningService) CopyWeek(canteenId uuid.UUID, from, to domain.Date, by string) ([]domain.MenuDay, error) {
+	src, err := ds.MenusForWeek(canteenId, from, false)
+	if err != nil {
+		return nil, err
+	}
+	target := to.WeekStart()
+	if target.Equal(from.WeekStart()) {
+		return nil, fmt.Errorf("%w: source and target week are the same", ErrInvalidMenuCalendar)
+	}
+
+	dates := make([]domain.Date, 0, len(src))
+	entries := []domain.MenuCalendarEntry{}
+	for i, day := range src {
+		d := target.AddDays(i)
+		dates = append(dates, d)
+		if day.Closed {
+			entries = append(entries, domain.MenuCalendarEntry{Date: d, Note: day.Note, CreatedBy: by})
+			continue
+		}
+		for _, m := range day.Menus {
+			menuId := m.Id
+			entries = append(entries, domain.MenuCalendarEntry{Date: d, MenuId: &menuId, Note: day.Note, CreatedBy: by})
+		}
+	}
+	if err := ds.repo.ReplaceCalendarEntries(canteenId, dates, entries, true); err != nil {
+		return nil, err
+	}
+	return ds.MenusForWeek(canteenId, target, true)
+}
+
+// PublishWeek objavljuje nacrte nedelje kojoj date pripada; vraca broj objavljenih dana.
+func (ds *DiningService) PublishWeek(canteenId uuid.UUID, date domain.Date) (int, error) {
+	start := date.WeekStart()
+	return ds.repo.PublishCalendarRange(canteenId, start, start.AddDays(6))
+}
+
+func validWeekday(w domain.Weekday) bool {
+	switch w {
+	case domain.Monday, domain.Tuesday, domain.Wednesday, domain.Thursday,
+		domain.Friday, domain.Saturday, domain.Sunday:
+		return true
+	}
+	return false
+}
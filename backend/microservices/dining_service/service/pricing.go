@@ -0,0 +1,136 @@
+package service
+
+import (
+	"common/money"
+	"dining/domain"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidSlots   = errors.New("slots must be a non-empty list of distinct meals present in the menu")
+	ErrInvalidDish    = errors.New("chosen dish is not offered for that meal slot")
+	ErrInvalidSubsidy = errors.New("invalid subsidy")
+)
+
+// QuoteMeal racuna cenu izabranih obroka iz menija: cena izabranog jela (ili
+// podrazumevanog jela slota) je iz baze, a od nje se oduzima najpovoljnija subvencija
+// studenta koja vazi u at.
+func (ds *DiningService) QuoteMeal(menu *domain.Menu, slots []domain.MealSlot, dishes domain.DishChoice, userId uuid.UUID, at time.Time) (*domain.MealQuote, error) {
+	if len(slots) == 0 {
+		return nil, ErrInvalidSlots
+	}
+	for slot := range dishes {
+		if !containsSlot(slots, slot) {
+			return nil, ErrInvalidDish
+		}
+	}
+	subsidies, err := ds.repo.ListActiveMealSubsidies(userId, at)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &domain.MealQuote{
+		MenuId:    menu.Id,
+		Slots:     slots,
+		Dishes:    domain.DishChoice{},
+		ListPrice: money.RSD(0),
+		Discount:  money.RSD(0),
+	}
+	seen := map[domain.MealSlot]bool{}
+	for _, slot := range slots {
+		if !slot.Valid() || seen[slot] {
+			return nil, ErrInvalidSlots
+		}
+		seen[slot] = true
+		if _, ok := menu.Meal(slot); !ok {
+			return nil, ErrInvalidSlots
+		}
+		meal, ok := menu.Dish(slot, dishes[slot])
+		if !ok {
+			return nil, ErrInvalidDish
+		}
+		q.Dishes[slot] = meal.Id
+		if q.ListPrice, err = q.ListPrice.Add(meal.Price); err != nil {
+			return nil, err
+		}
+		if q.Discount, err = q.Discount.Add(bestDiscount(meal.Price, slot, subsidies)); err != nil {
+			return nil, err
+		}
+	}
+	if q.Amount, err = q.ListPrice.Add(q.Discount.Neg()); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func containsSlot(slots []domain.MealSlot, slot domain.MealSlot) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// bestDiscount: najvece umanjenje cene obroka od pravila koja vaze za slot;
+// umanjenje nikad nije vece od cene.
+func bestDiscount(price money.Money, slot domain.MealSlot, subsidies []domain.MealSubsidy) money.Money {
+	best := money.RSD(0)
+	for _, s := range subsidies {
+		if s.Slot != nil && *s.Slot != slot {
+			continue
+		}
+		var off int64
+		switch s.Kind {
+		case domain.SubsidyPercent:
+			off = price.Minor * int64(s.Percent) / 100 // zaokruzuje se na korist kantine
+		case domain.SubsidyFixed:
+			off = s.AmountOff.Minor
+		}
+		if off > price.Minor {
+			off = price.Minor
+		}
+		if off > best.Minor {
+			best = money.RSD(off)
+		}
+	}
+	return best
+}
+
+func (ds *DiningService) CreateMealSubsidy(s *domain.MealSubsidy) error {
+	switch s.Kind {
+	case domain.SubsidyPercent:
+		if s.Percent <= 0 || s.Percent > 100 {
+			return ErrInvalidSubsidy
+		}
+		s.AmountOff = money.RSD(0)
+	case domain.SubsidyFixed:
+		if !s.AmountOff.IsPositive() || s.AmountOff.Currency != money.DefaultCurrency {
+			return ErrInvalidSubsidy
+		}
+		s.Percent = 0
+	default:
+		return ErrInvalidSubsidy
+	}
+	if s.UserId == uuid.Nil || (s.Slot != nil && !s.Slot.Valid()) {
+		return ErrInvalidSubsidy
+	}
+	if s.ValidFrom.IsZero() {
+		s.ValidFrom = time.Now()
+	}
+	if s.ValidTo != nil && !s.ValidTo.After(s.ValidFrom) {
+		return ErrInvalidSubsidy
+	}
+	return ds.repo.CreateMealSubsidy(s)
+}
+
+func (ds *DiningService) GetMealSubsidies(userId uuid.UUID) ([]domain.MealSubsidy, error) {
+	return ds.repo.ListMealSubsidies(userId)
+}
+
+func (ds *DiningService) DeleteMealSubsidy(id uuid.UUID) error {
+	return ds.repo.DeleteMealSubsidy(id)
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"dining/domain"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidReservation  = errors.New("invalid reservation")
+	ErrReservationCutoff   = errors.New("reservations can no longer be changed for that meal")
+	ErrReservationInactive = errors.New("reservation is no longer active")
+	ErrInvalidCapacity     = errors.New("invalid slot capacity")
+)
+
+const (
+	// reservationCutoff: rezervacija se pravi i otkazuje najkasnije toliko pre pocetka izdavanja obroka.
+	reservationCutoff = 2 * time.Hour
+	// maxReservationDays: koliko dana unapred student moze da rezervise.
+	maxReservationDays = 14
+	// showRateWindow: period (u danima) iz kog se racuna odziv za prognozu porcija.
+	showRateWindow        = 28
+	maxForecastDays       = 31
+	reservationsBatchSize = 50
+)
+
+// slotStart: pocetak izdavanja obroka u kantini — prozor slota, a bez njega otvaranje kantine.
+func slotStart(canteen *domain.Canteen, slot domain.MealSlot) domain.ClockTime {
+	for _, w := range canteen.ServingWindows {
+		if w.Slot == slot {
+			return w.Start
+		}
+	}
+	return domain.ClockOf(canteen.OpenAt)
+}
+
+// reservationDeadline: do kada se rezervacija za dan i slot moze napraviti ili otkazati.
+func (ds *DiningService) reservationDeadline(canteenId uuid.UUID, date domain.Date, slot domain.MealSlot) (time.Time, error) {
+	canteen, err := ds.GetCanteen(canteenId.String())
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := mealClock()
+	return date.At(slotStart(canteen, slot), now.Location()).Add(-reservationCutoff), nil
+}
+
+// Reserve rezervise slot menija za buduci dan (najvise maxReservationDays unapred). Meni
+// mora biti u kalendaru kantine za taj dan, a jelo je izabrano ili podrazumevano jelo slota.
+func (ds *DiningService) Reserve(res *domain.Reservation) error {
+	if !res.Slot.Valid() || res.Date.IsZero() {
+		return fmt.Errorf("%w: slot and date are required", ErrInvalidReservation)
+	}
+	now := mealClock()
+	today := domain.DateOf(now)
+	if res.Date.Before(today) || res.Date.After(today.AddDays(maxReservationDays)) {
+		return fmt.Errorf("%w: date must be within %d days from today", ErrInvalidReservation, maxReservationDays)
+	}
+
+	menu, err := ds.repo.GetMenuWithMealsByID(res.MenuId.String())
+	if err != nil {
+		return domain.ErrMenuNotFound
+	}
+	day, err := ds.MenusForDate(menu.CanteenId, res.Date)
+	if err != nil {
+		return err
+	}
+	if !day.HasMenu(menu.Id) {
+		return fmt.Errorf("%w: menu is not served on %s", ErrInvalidReservation, res.Date)
+	}
+	if err := ds.attachDishes([]*domain.Menu{menu}, nil); err != nil {
+		return err
+	}
+	dish, ok := menu.Dish(res.Slot, res.DishId)
+	if !ok {
+		return ErrInvalidDish
+	}
+
+	deadline, err := ds.reservationDeadline(menu.CanteenId, res.Date, res.Slot)
+	if err != nil {
+		return err
+	}
+	if !now.Before(deadline) {
+		return fmt.Errorf("%w (until %s)", ErrReservationCutoff, deadline.Format("2006-01-02 15:04"))
+	}
+
+	res.CanteenId = menu.CanteenId
+	res.DishId = dish.Id
+	return ds.repo.CreateReservation(res)
+}
+
+// CancelReservation otkazuje rezervaciju pre roka; admin moze da otkaze i posle roka.
+func (ds *DiningService) CancelReservation(id, userId uuid.UUID, isAdmin bool) (*domain.Reservation, error) {
+	res, err := ds.repo.GetReservation(id)
+	if err != nil {
+		return nil, err
+	}
+	if res.UserId != userId && !isAdmin {
+		return nil, domain.ErrReservationNotFound
+	}
+	if res.Status != domain.ReservationActive {
+		return nil, ErrReservationInactive
+	}
+	if !isAdmin {
+		deadline, err := ds.reservationDeadline(res.CanteenId, res.Date, res.Slot)
+		if err != nil {
+			return nil, err
+		}
+		if !mealClock().Before(deadline) {
+			return nil, fmt.Errorf("%w (until %s)", ErrReservationCutoff, deadline.Format("2006-01-02 15:04"))
+		}
+	}
+	ok, err := ds.repo.CancelReservation(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReservationInactive
+	}
+	return ds.repo.GetReservation(id)
+}
+
+func (ds *DiningService) ListReservations(userId uuid.UUID) ([]domain.Reservation, error) {
+	return ds.repo.ListReservationsByUser(userId)
+}
+
+func (ds *DiningService) GetSlotCapacities(canteenId uuid.UUID) ([]domain.SlotCapacity, error) {
+	return ds.repo.GetSlotCapacities(canteenId)
+}
+
+// SetSlotCapacities zamenjuje kapacitete kantine; svaki slot najvise jednom.
+func (ds *DiningService) SetSlotCapacities(canteenId uuid.UUID, caps []domain.SlotCapacity) error {
+	if _, err := ds.repo.GetCanteenByID(canteenId.String()); err != nil {
+		return err
+	}
+	seen := map[domain.MealSlot]bool{}
+	for _, c := range caps {
+		if !c.Slot.Valid() || seen[c.Slot] {
+			return fmt.Errorf("%w: each slot at most once", ErrInvalidCapacity)
+		}
+		if c.Capacity < 0 {
+			return fmt.Errorf("%w: capacity cannot be negative", ErrInvalidCapacity)
+		}
+		seen[c.Slot] = true
+	}
+	return ds.repo.ReplaceSlotCapacities(canteenId, caps)
+}
+
+// Forecast procenjuje broj porcija po danu, obroku i jelu iz rezervacija, umanjeno za
+// odziv (udeo naplacenih rezervacija) u poslednjih showRateWindow dana.
+func (ds *DiningService) Forecast(canteenId uuid.UUID, from domain.Date, days int) (*domain.Forecast, error) {
+	if days < 1 || days > maxForecastDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidReservation, maxForecastDays)
+	}
+	today := domain.DateOf(mealClock())
+	fulfilled, released, err := ds.repo.ReservationOutcomes(canteenId, today.AddDays(-showRateWindow), today.AddDays(-1))
+	if err != nil {
+		return nil, err
+	}
+	rate := 1.0
+	if fulfilled+released > 0 {
+		rate = float64(fulfilled) / float64(fulfilled+released)
+	}
+
+	caps, err := ds.repo.GetSlotCapacities(canteenId)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := ds.repo.CountReservations(canteenId, from, from.AddDays(days-1))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &domain.Forecast{CanteenId: canteenId, ShowRate: rate, Days: make([]domain.DayForecast, days)}
+	for i := range f.Days {
+		day := domain.DayForecast{Date: from.AddDays(i), Slots: make([]domain.SlotForecast, len(domain.MealSlots))}
+		for j, slot := range domain.MealSlots {
+			sf := domain.SlotForecast{Slot: slot, Dishes: []domain.DishForecast{}}
+			for _, c := range caps {
+				if c.Slot == slot {
+					n := c.Capacity
+					sf.Capacity = &n
+				}
+			}
+			for _, c := range counts {
+				if c.Date.Equal(day.Date) && c.Slot == slot {
+					sf.Reserved += c.Count
+					sf.Dishes = append(sf.Dishes, domain.DishForecast{DishId: c.DishId, DishName: c.DishName, Reserved: c.Count})
+				}
+			}
+			sf.Expected = int(math.Ceil(float64(sf.Reserved) * rate))
+			day.Slots[j] = sf
+		}
+		f.Days[i] = day
+	}
+	return f, nil
+}
+
+// RunReservationCharging na svakih every naplacuje rezervacije ciji je obrok poceo da se
+// izdaje, kao obicnu kupovinu obroka; rezervacija koja ne moze da se naplati se oslobadja.
+func (ds *DiningService) RunReservationCharging(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		now := mealClock()
+		due, err := ds.repo.ListDueReservations(domain.DateOf(now), domain.ClockOf(now), reservationsBatchSize)
+		if err != nil {
+			log.Printf("reservation charging: %v", err)
+			continue
+		}
+		for i := range due {
+			if err := ds.chargeReservation(ctx, &due[i]); err != nil {
+				log.Printf("reservation %s: %v", due[i].Id, err)
+			}
+		}
+	}
+}
+
+// chargeReservation kupuje rezervisani obrok; idempotency kljuc je vezan za rezervaciju
+// pa ponovljen pokusaj ne naplacuje dva puta. Kupovina koja se jos obradjuje ostaje
+// rezervisana i proverava se u sledecem krugu.
+func (ds *DiningService) chargeReservation(ctx context.Context, res *domain.Reservation) error {
+	release := func(reason string) error {
+		return ds.repo.SetReservationOutcome(res.Id, domain.ReservationReleased, nil, reason)
+	}
+
+	menu, err := ds.GetMenu(res.MenuId.String())
+	if err != nil {
+		return release("menu no longer exists")
+	}
+	q, err := ds.QuoteMeal(menu, []domain.MealSlot{res.Slot}, domain.DishChoice{res.Slot: res.DishId}, res.UserId, mealClock())
+	if err != nil {
+		return release(err.Error())
+	}
+	p := &domain.MealPurchase{
+		IdempotencyKey: "reservation:" + res.Id.String(),
+		UserId:         res.UserId,
+		Username:       res.Username,
+		MenuId:         menu.Id,
+		CanteenId:      menu.CanteenId,
+		Slots:          q.Slots,
+		Dishes:         q.Dishes,
+		ListPrice:      q.ListPrice,
+		Discount:       q.Discount,
+		Amount:         q.Amount,
+	}
+	if _, err := ds.PurchaseMeal(ctx, menu, p); err != nil {
+		switch {
+		case errors.Is(err, ErrMenuNotToday),
+			errors.Is(err, ErrCanteenClosed),
+			errors.Is(err, ErrOutsideServingWindow),
+			errors.Is(err, domain.ErrSlotAlreadyServed),
+			errors.Is(err, domain.ErrDishSoldOut),
+			errors.Is(err, domain.ErrDishNotFound):
+			return release(err.Error())
+		}
+		return err
+	}
+
+	switch p.State {
+	case domain.PurchaseCharged, domain.PurchaseRecorded, domain.PurchaseCompleted:
+		return ds.repo.SetReservationOutcome(res.Id, domain.ReservationFulfilled, &p.Id, "")
+	case domain.PurchaseFailed, domain.PurchaseRefunded:
+		reason := "charge failed"
+		if p.FailureCode != nil {
+			reason = *p.FailureCode
+		}
+		return release(reason)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"dining/domain"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCanteenClosed         = errors.New("canteen is closed")
+	ErrMenuNotToday          = errors.New("menu is not served today")
+	ErrOutsideServingWindow  = errors.New("meal is not being served at this time")
+	ErrInvalidServingWindows = errors.New("invalid serving windows")
+)
+
+// mealClock je izvor trenutnog vremena za izdavanje obroka (lokalna zona servera, TZ).
+var mealClock = time.Now
+
+// checkServing proverava da se izabrani obroci iz menija mogu izdati u trenutku at:
+// meni je u kalendaru kantine za danas, kantina radi i svaki slot je u svom prozoru.
+func (ds *DiningService) checkServing(menu *domain.Menu, slots []domain.MealSlot, at time.Time) error {
+	today, err := ds.MenusForDate(menu.CanteenId, domain.DateOf(at))
+	if err != nil {
+		return err
+	}
+	if !today.HasMenu(menu.Id) {
+		return fmt.Errorf("%w (%s)", ErrMenuNotToday, today.Date)
+	}
+	canteen, err := ds.GetCanteen(menu.CanteenId.String())
+	if err != nil {
+		return err
+	}
+	now := domain.ClockOf(at)
+	if !canteen.IsOpen(now) {
+		return fmt.Errorf("%w (open %s-%s)", ErrCanteenClosed, domain.ClockOf(canteen.OpenAt), domain.ClockOf(canteen.CloseAt))
+	}
+	for _, slot := range slots {
+		for _, w := range canteen.ServingWindows {
+			if w.Slot == slot && !w.Contains(now) {
+				return fmt.Errorf("%w: %s is served %s-%s", ErrOutsideServingWindow, slot, w.Start, w.End)
+			}
+		}
+	}
+	return nil
+}
+
+func (ds *DiningService) GetServingWindows(canteenId uuid.UUID) ([]domain.ServingWindow, error) {
+	return ds.repo.GetServingWindows(canteenId)
+}
+
+// SetServingWindows zamenjuje prozore izdavanja kantine; svaki slot najvise jednom
+// i ceo prozor u okviru radnog vremena.
+func (ds *DiningService) SetServingWindows(canteenId uuid.UUID, windows []domain.ServingWindow) error {
+	canteen, err := ds.repo.GetCanteenByID(canteenId.String())
+	if err != nil {
+		return err
+	}
+	open, closeAt := domain.ClockOf(canteen.OpenAt), domain.ClockOf(canteen.CloseAt)
+	seen := map[domain.MealSlot]bool{}
+	for _, w := range windows {
+		switch {
+		case !w.Slot.Valid(), seen[w.Slot]:
+			return fmt.Errorf("%w: duplicate or unknown slot %q", ErrInvalidServingWindows, w.Slot)
+		case w.Start >= w.End:
+			return fmt.Errorf("%w: %s must start before it ends", ErrInvalidServingWindows, w.Slot)
+		case w.Start < open || w.End > closeAt:
+			return fmt.Errorf("%w: %s must be within opening hours %s-%s", ErrInvalidServingWindows, w.Slot, open, closeAt)
+		}
+		seen[w.Slot] = true
+	}
+	return ds.repo.ReplaceServingWindows(canteenId, windows)
+}
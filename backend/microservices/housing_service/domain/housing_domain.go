@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"common/money"
+	"encoding/json"
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -54,7 +58,413 @@ type Kvar struct {
 	PrijavioUsername string      `json:"prijavioUsername"`
 }
 type StudentskaKartica struct {
-	ID              uuid.UUID `json:"id"`
-	Stanje          float64   `json:"stanje"`
-	StudentUsername string    `json:"studentUsername"`
+	ID              uuid.UUID   `json:"id"`
+	Stanje          money.Money `json:"stanje"`
+	StudentUsername string      `json:"studentUsername"`
+}
+
+type TipTransakcije string
+
+const (
+	TransakcijaUplata    TipTransakcije = "top_up"
+	TransakcijaObrok     TipTransakcije = "meal_charge"
+	TransakcijaPovracaj  TipTransakcije = "refund"
+	TransakcijaKorekcija TipTransakcije = "adjustment"
+)
+
+func (t TipTransakcije) Valid() bool {
+	switch t {
+	case TransakcijaUplata, TransakcijaObrok, TransakcijaPovracaj, TransakcijaKorekcija:
+		return true
+	}
+	return false
+}
+
+// KarticaTransakcija je nepromenljiv unos u knjizi kartice; stanje kartice je zbir iznosa.
+type KarticaTransakcija struct {
+	ID              uuid.UUID      `json:"id"`
+	KarticaID       uuid.UUID      `json:"karticaId"`
+	StudentUsername string         `json:"studentUsername"`
+	Iznos           money.Money    `json:"iznos"` // pozitivan = uplata, negativan = zaduzenje
+	Tip             TipTransakcije `json:"tip"`
+	ReferencaID     *string        `json:"referencaId,omitempty"`
+	Izvrsio         string         `json:"izvrsio"`
+	Vreme           time.Time      `json:"vreme"`
+}
+
+// StatusNaplate: faze naplate obroka (charge -> confirm | compensate) koju vodi dining_service.
+type StatusNaplate string
+
+const (
+	NaplataNaplacena  StatusNaplate = "naplaceno"
+	NaplataPotvrdjena StatusNaplate = "potvrdjeno"
+	NaplataPonistena  StatusNaplate = "ponisteno"
+)
+
+// Naplata prati jedno zaduzenje kartice po referenci kupovine; referenca cini
+// naplatu, potvrdu i ponistenje idempotentnim.
+type Naplata struct {
+	ReferencaID     string             `json:"referencaId"`
+	StudentUsername string             `json:"studentUsername"`
+	Iznos           money.Money        `json:"iznos"`
+	Status          StatusNaplate      `json:"status"`
+	Kartica         *StudentskaKartica `json:"kartica,omitempty"`
+}
+
+// StatusUplate: tok dopune kartice preko platnog provajdera.
+type StatusUplate string
+
+const (
+	UplataKreirana  StatusUplate = "kreirana"  // namera placanja otvorena, ceka potvrdu provajdera
+	UplataUspesna   StatusUplate = "uspesna"   // provajder potvrdio, kartica je dopunjena
+	UplataNeuspesna StatusUplate = "neuspesna" // provajder odbio ili je placanje otkazano
+	UplataVracena   StatusUplate = "vracena"   // novac vracen studentu, kartica zaduzena nazad
+)
+
+// Uplata je jedna dopuna kartice; kartica se dopunjuje najvise jednom po uplati,
+// bez obzira koliko puta provajder isporuci potvrdu.
+type Uplata struct {
+	ID              uuid.UUID    `json:"id"`
+	StudentUsername string       `json:"studentUsername"`
+	Iznos           money.Money  `json:"iznos"`
+	Status          StatusUplate `json:"status"`
+	Provajder       string       `json:"provajder"`
+	IntentID        *string      `json:"intentId,omitempty"`
+	CheckoutURL     *string      `json:"checkoutUrl,omitempty"`
+	CreatedAt       time.Time    `json:"createdAt"`
+	UpdatedAt       time.Time    `json:"updatedAt"`
+}
+
+// TransakcijeFilter su parametri pregleda istorije kartice.
+type TransakcijeFilter struct {
+	Od   *time.Time
+	Do   *time.Time
+	Page int
+	Size int
+}
+
+type TransakcijePage struct {
+	Items []KarticaTransakcija `json:"items"`
+	Total int                  `json:"total"`
+	Page  int                  `json:"page"`
+	Size  int                  `json:"size"`
+}
+
+// Dogadjaji koje users_service objavljuje preko outbox-a (POST /internal/events).
+const EventUserRegistered = "UserRegistered"
+
+type Event struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type UserRegistered struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"firstname"`
+	LastName  string    `json:"lastname"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+}
+
+/* ======================= Prijave za smestaj i raspodela ======================= */
+
+// StatusPrijave: prijava je podneta dok je raspodela ne rangira kao primljenu ili na
+// listi cekanja; student je moze povuci pre objave rezultata.
+type StatusPrijave string
+
+const (
+	PrijavaPodneta      StatusPrijave = "podneta"
+	PrijavaPovucena     StatusPrijave = "povucena"
+	PrijavaPrimljena    StatusPrijave = "primljena"
+	PrijavaListaCekanja StatusPrijave = "lista_cekanja"
+)
+
+// PrijavaZaSmestaj: prijava studenta za dom u skolskoj godini (npr. "2025/2026"), sa
+// podacima za bodovanje i zeljama; jedna po studentu i godini.
+type PrijavaZaSmestaj struct {
+	ID              uuid.UUID     `json:"id"`
+	SkolskaGodina   string        `json:"skolskaGodina"`
+	StudentUsername string        `json:"studentUsername"`
+	Prosek          float64       `json:"prosek"`
+	UdaljenostKm    int           `json:"udaljenostKm"`
+	SocijalniStatus string        `json:"socijalniStatus,omitempty"`
+	Domovi          []uuid.UUID   `json:"domovi"`            // zeljeni domovi redom; prazno = bilo koji
+	TipSobe         *int          `json:"tipSobe,omitempty"` // zeljeni broj kreveta u sobi
+	Cimeri          []string      `json:"cimeri"`            // username-ovi zeljenih cimera
+	Status          StatusPrijave `json:"status"`
+	Bodovi          *float64      `json:"bodovi,omitempty"`
+	Rang            *int          `json:"rang,omitempty"`
+	SobaID          *uuid.UUID    `json:"sobaId,omitempty"`
+	PozicijaCekanja *int          `json:"pozicijaCekanja,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+}
+
+// KriterijumiBodovanja: koliko bodova nosi prosek, udaljenost i socijalni status u godini.
+// Prosek 6,00 donosi 0, a 10,00 TezinaProsek bodova; udaljenost linearno do MaxUdaljenostKm.
+type KriterijumiBodovanja struct {
+	SkolskaGodina    string             `json:"skolskaGodina"`
+	TezinaProsek     float64            `json:"tezinaProsek"`
+	TezinaUdaljenost float64            `json:"tezinaUdaljenost"`
+	MaxUdaljenostKm  int                `json:"maxUdaljenostKm"`
+	SocijalniBodovi  map[string]float64 `json:"socijalniBodovi"` // bodovi po socijalnom statusu
+	UpdatedBy        string             `json:"updatedBy"`
+	UpdatedAt        time.Time          `json:"updatedAt"`
+}
+
+type StatusRaspodele string
+
+const (
+	RaspodelaNacrt      StatusRaspodele = "nacrt"      // moze se ponovo pokrenuti
+	RaspodelaObjavljena StatusRaspodele = "objavljena" // studenti su useljeni, rezultati javni
+)
+
+// Raspodela: poslednje pokretanje rangiranja i rasporeda po sobama za skolsku godinu.
+type Raspodela struct {
+	SkolskaGodina  string          `json:"skolskaGodina"`
+	Status         StatusRaspodele `json:"status"`
+	Pokrenuo       string          `json:"pokrenuo"`
+	PokrenutaAt    time.Time       `json:"pokrenutaAt"`
+	ObjavljenaAt   *time.Time      `json:"objavljenaAt,omitempty"`
+	Primljeno      int             `json:"primljeno"`
+	NaListiCekanja int             `json:"naListiCekanja"`
+}
+
+// SobaPopunjenost: soba sa brojem zauzetih mesta (studenti u sobi i mesta pod otvorenom ponudom).
+type SobaPopunjenost struct {
+	Soba
+	Zauzeto int `json:"zauzeto"`
+}
+
+func (s SobaPopunjenost) SlobodnaMesta() int {
+	if n := s.Kapacitet - s.Zauzeto; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// StavkaRezultata: red rang liste (primljen) ili liste cekanja.
+type StavkaRezultata struct {
+	Rang            int        `json:"rang"`
+	StudentUsername string     `json:"studentUsername"`
+	Ime             string     `json:"ime"`
+	Prezime         string     `json:"prezime"`
+	Bodovi          float64    `json:"bodovi"`
+	Status          string     `json:"status"`
+	DomID           *uuid.UUID `json:"domId,omitempty"`
+	Dom             string     `json:"dom,omitempty"`
+	SobaID          *uuid.UUID `json:"sobaId,omitempty"`
+	BrojSobe        string     `json:"brojSobe,omitempty"`
+	PozicijaCekanja *int       `json:"pozicijaCekanja,omitempty"`
+}
+
+type RezultatRaspodele struct {
+	Raspodela    Raspodela         `json:"raspodela"`
+	Primljeni    []StavkaRezultata `json:"primljeni"`
+	ListaCekanja []StavkaRezultata `json:"listaCekanja"`
+}
+
+/* ======================= Lista cekanja i ponude soba ======================= */
+
+// StatusCekanja: student ceka na mesto u domu dok ne bude smesten ili uklonjen
+// (sam se odjavio, odbio ponudu ili je ponuda istekla).
+type StatusCekanja string
+
+const (
+	CekanjeCeka     StatusCekanja = "ceka"
+	CekanjeSmesten  StatusCekanja = "smesten"
+	CekanjeUklonjen StatusCekanja = "uklonjen"
+)
+
+// ListaCekanjaStavka: mesto studenta na listi cekanja doma; redosled je redosled upisa.
+type ListaCekanjaStavka struct {
+	ID              uuid.UUID     `json:"id"`
+	DomID           uuid.UUID     `json:"domId"`
+	StudentUsername string        `json:"studentUsername"`
+	TipSobe         *int          `json:"tipSobe,omitempty"` // zeljeni broj kreveta; prazno = bilo koja soba
+	Status          StatusCekanja `json:"status"`
+	Pozicija        int           `json:"pozicija,omitempty"` // trenutno mesto na listi (samo dok ceka)
+	CreatedAt       time.Time     `json:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+}
+
+type StatusPonude string
+
+const (
+	PonudaPonudjena  StatusPonude = "ponudjena"
+	PonudaPrihvacena StatusPonude = "prihvacena"
+	PonudaOdbijena   StatusPonude = "odbijena"
+	PonudaIstekla    StatusPonude = "istekla"
+	PonudaPonistena  StatusPonude = "ponistena" // mesto je u medjuvremenu popunjeno mimo ponude
+)
+
+// PonudaSobe: vremenski ogranicena ponuda mesta u sobi sledecem studentu sa liste cekanja.
+type PonudaSobe struct {
+	ID              uuid.UUID    `json:"id"`
+	ListaID         uuid.UUID    `json:"listaId"`
+	StudentUsername string       `json:"studentUsername"`
+	SobaID          uuid.UUID    `json:"sobaId"`
+	DomID           uuid.UUID    `json:"domId"`
+	BrojSobe        string       `json:"brojSobe"`
+	Status          StatusPonude `json:"status"`
+	IsticeAt        time.Time    `json:"isticeAt"`
+	CreatedAt       time.Time    `json:"createdAt"`
+	ResenaAt        *time.Time   `json:"resenaAt,omitempty"`
+}
+
+/* ======================= Premestaj i zamena soba ======================= */
+
+type TipZahteva string
+
+const (
+	ZahtevPremestaj TipZahteva = "premestaj" // u odredjenu sobu ili bilo koju sobu doma
+	ZahtevZamena    TipZahteva = "zamena"    // dva studenta menjaju sobe
+)
+
+// StatusZahteva: zamenu prvo potvrdjuje drugi student, zatim svaki zahtev odobrava admin.
+type StatusZahteva string
+
+const (
+	ZahtevCekaCimera    StatusZahteva = "ceka_cimera"
+	ZahtevCekaOdobrenje StatusZahteva = "ceka_odobrenje"
+	ZahtevOdobren       StatusZahteva = "odobren"
+	ZahtevOdbijen       StatusZahteva = "odbijen"
+	ZahtevPovucen       StatusZahteva = "povucen"
+)
+
+func (s StatusZahteva) Otvoren() bool {
+	return s == ZahtevCekaCimera || s == ZahtevCekaOdobrenje
+}
+
+// ZahtevPremestaja: zahtev studenta za premestaj ili zamenu sobe; sobe iz zahteva su
+// sobe u kojima su studenti bili kada je zahtev podnet.
+type ZahtevPremestaja struct {
+	ID              uuid.UUID     `json:"id"`
+	Tip             TipZahteva    `json:"tip"`
+	StudentUsername string        `json:"studentUsername"`
+	SobaID          uuid.UUID     `json:"sobaId"`
+	CiljnaSobaID    *uuid.UUID    `json:"ciljnaSobaId,omitempty"` // premestaj u odredjenu sobu
+	CiljniDomID     *uuid.UUID    `json:"ciljniDomId,omitempty"`  // premestaj u bilo koju sobu doma
+	DrugiStudent    *string       `json:"drugiStudent,omitempty"` // zamena
+	DrugaSobaID     *uuid.UUID    `json:"drugaSobaId,omitempty"`
+	Obrazlozenje    string        `json:"obrazlozenje,omitempty"`
+	Status          StatusZahteva `json:"status"`
+	NovaSobaID      *uuid.UUID    `json:"novaSobaId,omitempty"` // soba u koju je student premesten
+	Resio           *string       `json:"resio,omitempty"`
+	RazlogOdbijanja *string       `json:"razlogOdbijanja,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+}
+
+/* ======================= Boravak u sobi ======================= */
+
+// ObrokStanara: obrok stanara sobe iz istorije dining servisa.
+type ObrokStanara struct {
+	UserName   string    `json:"user_name"`
+	MenuId     string    `json:"menu_id"`
+	MenuName   string    `json:"menu_name"`
+	SelectedAt time.Time `json:"selected_at"`
+}
+
+// Boravak: period u kom je student stanovao u sobi; otvoren (bez IseljenAt) dok se student ne iseli.
+type Boravak struct {
+	ID              uuid.UUID  `json:"id"`
+	StudentUsername string     `json:"studentUsername"`
+	Ime             string     `json:"ime"`
+	Prezime         string     `json:"prezime"`
+	SobaID          uuid.UUID  `json:"sobaId"`
+	BrojSobe        string     `json:"brojSobe"`
+	DomID           uuid.UUID  `json:"domId"`
+	Dom             string     `json:"dom"`
+	UseljenAt       time.Time  `json:"useljenAt"`
+	IseljenAt       *time.Time `json:"iseljenAt,omitempty"`
+}
+
+/* ======================= Stanarina ======================= */
+
+// KategorijaCene: mesecna cena smestaja; dodeljuje se domu, a soba moze imati svoju kategoriju.
+type KategorijaCene struct {
+	ID          uuid.UUID   `json:"id"`
+	Naziv       string      `json:"naziv"`
+	MesecnaCena money.Money `json:"mesecnaCena"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+// BoravakZaObracun: boravak koji se preklapa sa obracunskim mesecom, sa cenom sobe ili doma.
+type BoravakZaObracun struct {
+	BoravakID       uuid.UUID
+	StudentUsername string
+	SobaID          uuid.UUID
+	UseljenAt       time.Time
+	IseljenAt       *time.Time
+	MesecnaCena     *money.Money // nil: ni soba ni dom nemaju kategoriju cene
+}
+
+type StatusRacuna string
+
+const (
+	RacunOtvoren StatusRacuna = "otvoren"
+	RacunPlacen  StatusRacuna = "placen"
+)
+
+// RacunStanarine: stanarina za jedan boravak u jednom mesecu, srazmerna broju dana boravka.
+// Dani se broje od dana useljenja do dana iseljenja (dan iseljenja se ne naplacuje).
+type RacunStanarine struct {
+	ID              uuid.UUID         `json:"id"`
+	BoravakID       uuid.UUID         `json:"boravakId"`
+	StudentUsername string            `json:"studentUsername"`
+	SobaID          uuid.UUID         `json:"sobaId"`
+	BrojSobe        string            `json:"brojSobe"`
+	DomID           uuid.UUID         `json:"domId"`
+	Dom             string            `json:"dom"`
+	Period          time.Time         `json:"period"`  // prvi dan obracunskog meseca
+	Pocetak         time.Time         `json:"pocetak"` // prvi naplaceni dan
+	Kraj            time.Time         `json:"kraj"`    // prvi dan posle naplacenih
+	Dana            int               `json:"dana"`
+	DanaUMesecu     int               `json:"danaUMesecu"`
+	MesecnaCena     money.Money       `json:"mesecnaCena"`
+	Iznos           money.Money       `json:"iznos"`
+	Placeno         money.Money       `json:"placeno"`
+	RokPlacanja     time.Time         `json:"rokPlacanja"` // poslednji dan za uplatu
+	Status          StatusRacuna      `json:"status"`
+	Kasni           bool              `json:"kasni"` // rok je prosao pre uplate celog iznosa
+	PlacenAt        *time.Time        `json:"placenAt,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	Uplate          []UplataStanarine `json:"uplate,omitempty"`
+}
+
+// UplataStanarine: uplata po racunu koju evidentira admin (uplatnica, gotovina); racun moze biti placen u delovima.
+type UplataStanarine struct {
+	ID          uuid.UUID   `json:"id"`
+	RacunID     uuid.UUID   `json:"racunId"`
+	Iznos       money.Money `json:"iznos"`
+	Napomena    string      `json:"napomena,omitempty"`
+	Evidentirao string      `json:"evidentirao"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// ObracunStanarine: ishod mesecnog obracuna; ponovljen obracun istog meseca ne pravi nove racune.
+type ObracunStanarine struct {
+	Period        time.Time   `json:"period"`
+	Kreirano      int         `json:"kreirano"`
+	VecObracunato int         `json:"vecObracunato"`
+	SobeBezCene   []uuid.UUID `json:"sobeBezCene"` // boravci u ovim sobama nisu obracunati
+}
+
+// DugovanjeStudenta: dospeli a neplaceni racuni jednog studenta.
+type DugovanjeStudenta struct {
+	StudentUsername string           `json:"studentUsername"`
+	Dug             money.Money      `json:"dug"`
+	Racuni          []RacunStanarine `json:"racuni"`
+}
+
+type DugovanjaDoma struct {
+	DomID    uuid.UUID           `json:"domId"`
+	Dom      string              `json:"dom"`
+	Ukupno   money.Money         `json:"ukupno"`
+	Studenti []DugovanjeStudenta `json:"studenti"`
 }
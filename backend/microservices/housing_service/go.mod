@@ -3,8 +3,14 @@ module housing
 go 1.25.1
 
 require (
-	github.com/cockroachdb/cockroach-go/v2 v2.4.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/cockroachdb/cockroach-go/v2 v2.4.2
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/mux v1.8.1
+	github.com/lib/pq v1.10.9
 )
+
+require common v0.0.0
+
+require github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
+
+replace common => ../common
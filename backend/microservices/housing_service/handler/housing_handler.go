@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 
 	"strings"
 
@@ -14,6 +17,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"common/middleware"
+	"common/money"
 	"fmt"
 	"housing/domain"
 	"housing/service"
@@ -44,200 +49,1194 @@ func (h *HousingHandler) badRequest(w http.ResponseWriter, msg string) {
 	http.Error(w, msg, http.StatusBadRequest)
 }
 
+// decodeError: neispravan iznos (npr. deo pare) se prijavljuje kao takav, ostalo kao "bad json".
+func (h *HousingHandler) decodeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, money.ErrInvalidAmount) || errors.Is(err, money.ErrSubMinorAmount) {
+		h.badRequest(w, err.Error())
+		return
+	}
+	h.badRequest(w, "bad json")
+}
+
+// caller vraca identitet koji je postavio auth middleware.
+func (h *HousingHandler) caller(r *http.Request) middleware.Identity {
+	id, _ := middleware.IdentityFromContext(r.Context())
+	return id
+}
+
+// forwardAuth prosleđuje JWT pozivaoca ka drugom servisu.
+func forwardAuth(out *http.Request, in *http.Request) {
+	if h := in.Header.Get("Authorization"); h != "" {
+		out.Header.Set("Authorization", h)
+	}
+}
+
 /* ========================= Domovi (read) ========================= */
 
-// GET /dom?id=<uuid>
-func (h *HousingHandler) GetDom(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		h.badRequest(w, "missing id")
+// GET /dom?id=<uuid>
+func (h *HousingHandler) GetDom(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		h.badRequest(w, "missing id")
+		return
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+
+	dom, err := h.service.GetDom(r.Context(), id)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	h.renderJSON(w, dom)
+}
+
+// GET /doms
+func (h *HousingHandler) ListDomovi(w http.ResponseWriter, r *http.Request) {
+	domovi, err := h.service.GetAllDomovi(r.Context())
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, domovi)
+}
+
+/* ========================= Students ========================= */
+
+// POST /students
+// Body: { "ime": "Marko", "prezime": "Markovic", "username": "marko123" }
+func (h *HousingHandler) CreateStudent(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Ime      string `json:"ime"`
+		Prezime  string `json:"prezime"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	if in.Ime == "" || in.Prezime == "" || in.Username == "" {
+		h.badRequest(w, "ime, prezime i username su obavezni")
+		return
+	}
+
+	st, err := h.service.CreateStudent(r.Context(), in.Ime, in.Prezime, in.Username)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, st)
+}
+
+// POST /internal/events  (users_service outbox)
+// Body: { "events": [ { "id", "type", "aggregate_id", "occurred_at", "payload" } ] }
+func (h *HousingHandler) ReceiveEvents(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Events []domain.Event `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	if err := h.service.HandleEvents(r.Context(), in.Events); err != nil {
+		log.Printf("events: %v", err)
+		http.Error(w, "could not process events", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /rooms/assign
+// Body: { "domId": "...uuid...", "broj": "101", "username": "nikola123" }
+func (h *HousingHandler) AssignStudentToRoom(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		DomID    string `json:"domId"`
+		Broj     string `json:"broj"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	if in.DomID == "" || in.Broj == "" || in.Username == "" {
+		h.badRequest(w, "domId, broj i username su obavezni")
+		return
+	}
+	domID, err := uuid.Parse(in.DomID)
+	if err != nil {
+		h.badRequest(w, "invalid domId")
+		return
+	}
+
+	st, err := h.service.UpisiPostojecegStudentaUSobu(r.Context(), domID, in.Broj, in.Username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, st)
+}
+
+// POST /students/release
+// Body: { "studentId": "...uuid..." }  // ovo ostaje po ID-u (interni admin-endpoint)
+func (h *HousingHandler) ReleaseStudentRoom(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		StudentID string `json:"studentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	id, err := uuid.Parse(in.StudentID)
+	if err != nil {
+		h.badRequest(w, "invalid studentId")
+		return
+	}
+
+	if err := h.service.OslobodiSobu(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.renderJSON(w, map[string]string{"status": "ok"})
+}
+
+/* ========================= Studentska kartica (po username) ========================= */
+
+// POST /students/cards
+// Kartica se kreira za ulogovanog studenta (username iz JWT-a).
+func (h *HousingHandler) CreateStudentCardIfMissing(w http.ResponseWriter, r *http.Request) {
+	card, err := h.service.KreirajStudentskuKarticuAkoNema(r.Context(), h.caller(r).Username)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, card)
+}
+
+// GET /students/cards
+// Vraca karticu ulogovanog studenta.
+func (h *HousingHandler) GetStudentCard(w http.ResponseWriter, r *http.Request) {
+	card, err := h.service.GetStudentskaKarticaByStudent(r.Context(), h.caller(r).Username)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	h.renderJSON(w, card)
+}
+
+// POST /students/cards/balance (admin)
+// Body: { "studentUsername": "nikola123", "delta": 500.0, "tip": "top_up", "referencaId": "..." }
+// tip je opcion (top_up ili adjustment): pozitivan delta je uplata, negativan korekcija.
+// referencaId se upisuje kao "admin:<referencaId>".
+func (h *HousingHandler) UpdateStudentCardBalance(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		StudentUsername string                `json:"studentUsername"`
+		Delta           money.Money           `json:"delta"`
+		Tip             domain.TipTransakcije `json:"tip"`
+		ReferencaID     *string               `json:"referencaId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.decodeError(w, err)
+		return
+	}
+	if in.StudentUsername == "" {
+		h.badRequest(w, "studentUsername je obavezan")
+		return
+	}
+	if in.Tip == "" {
+		in.Tip = domain.TransakcijaUplata
+		if in.Delta.IsNegative() {
+			in.Tip = domain.TransakcijaKorekcija
+		}
+	}
+
+	card, err := h.service.KnjiziNaKarticu(r.Context(), domain.KarticaTransakcija{
+		StudentUsername: in.StudentUsername,
+		Iznos:           in.Delta,
+		Tip:             in.Tip,
+		ReferencaID:     in.ReferencaID,
+		Izvrsio:         h.caller(r).Username,
+	})
+	if err != nil {
+		h.karticaError(w, err)
+		return
+	}
+	h.renderJSON(w, card)
+}
+
+// GET /students/cards/history?page=&size=&from=&to=
+// Istorija kartice ulogovanog studenta; from/to su datumi (YYYY-MM-DD) ili RFC3339, to je iskljucivo.
+func (h *HousingHandler) GetStudentCardHistory(w http.ResponseWriter, r *http.Request) {
+	h.cardHistory(w, r, h.caller(r).Username)
+}
+
+// GET /students/cards/{username}/history?page=&size=&from=&to= (admin)
+func (h *HousingHandler) GetStudentCardHistoryByUsername(w http.ResponseWriter, r *http.Request) {
+	h.cardHistory(w, r, mux.Vars(r)["username"])
+}
+
+func (h *HousingHandler) cardHistory(w http.ResponseWriter, r *http.Request, username string) {
+	q := r.URL.Query()
+	var (
+		f   domain.TransakcijeFilter
+		err error
+	)
+	if v := q.Get("page"); v != "" {
+		if f.Page, err = strconv.Atoi(v); err != nil {
+			h.badRequest(w, "invalid page")
+			return
+		}
+	}
+	if v := q.Get("size"); v != "" {
+		if f.Size, err = strconv.Atoi(v); err != nil {
+			h.badRequest(w, "invalid size")
+			return
+		}
+	}
+	if f.Od, err = parseVreme(q.Get("from")); err != nil {
+		h.badRequest(w, "invalid from")
+		return
+	}
+	if f.Do, err = parseVreme(q.Get("to")); err != nil {
+		h.badRequest(w, "invalid to")
+		return
+	}
+
+	page, err := h.service.IstorijaKartice(r.Context(), username, f)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, page)
+}
+
+// parseVreme prihvata datum (YYYY-MM-DD, pocetak dana UTC) ili RFC3339; prazan string je bez ogranicenja.
+func parseVreme(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func (h *HousingHandler) karticaError(w http.ResponseWriter, err error) {
+	var nedovoljno *service.NedovoljnoSredstavaError
+	switch {
+	case errors.As(err, &nedovoljno):
+		// tipizovan odgovor da bi pozivalac razlikovao manjak sredstava od ostalih odbijanja
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPaymentRequired)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error":  "insufficient_funds",
+			"poruka": nedovoljno.Error(),
+			"stanje": nedovoljno.Stanje,
+			"iznos":  nedovoljno.Iznos,
+		})
+	case errors.Is(err, service.ErrNeispravnaTransakcija), errors.Is(err, money.ErrCurrencyMismatch):
+		h.badRequest(w, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "kartica ne postoji", http.StatusNotFound)
+	default:
+		http.Error(w, "database exception", http.StatusInternalServerError)
+	}
+}
+
+/* ========================= Naplata obroka (interno) ========================= */
+
+// POST /internal/cards/charges
+// Body: { "referencaId": "...id kupovine...", "studentUsername": "nikola123", "iznos": {"amount": "350.00", "currency": "RSD"} }
+func (h *HousingHandler) ChargeMeal(w http.ResponseWriter, r *http.Request) {
+	var in domain.Naplata
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.decodeError(w, err)
+		return
+	}
+	n, err := h.service.NaplatiObrok(r.Context(), in, h.caller(r).Username)
+	if err != nil {
+		h.naplataError(w, err)
+		return
+	}
+	h.renderJSON(w, n)
+}
+
+// POST /internal/cards/charges/{ref}/confirm
+func (h *HousingHandler) ConfirmMealCharge(w http.ResponseWriter, r *http.Request) {
+	n, err := h.service.PotvrdiNaplatu(r.Context(), mux.Vars(r)["ref"])
+	if err != nil {
+		h.naplataError(w, err)
+		return
+	}
+	h.renderJSON(w, n)
+}
+
+// POST /internal/cards/charges/{ref}/compensate
+// Body: { "studentUsername": "nikola123", "iznos": {...} } — potrebno ako naplata jos nije stigla
+func (h *HousingHandler) CompensateMealCharge(w http.ResponseWriter, r *http.Request) {
+	var in domain.Naplata
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.decodeError(w, err)
+		return
+	}
+	in.ReferencaID = mux.Vars(r)["ref"]
+	n, err := h.service.PonistiNaplatu(r.Context(), in, h.caller(r).Username)
+	if err != nil {
+		h.naplataError(w, err)
+		return
+	}
+	h.renderJSON(w, n)
+}
+
+func (h *HousingHandler) naplataError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNaplataPonistena),
+		errors.Is(err, service.ErrNaplataPotvrdjena),
+		errors.Is(err, service.ErrNaplataKonflikt):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.karticaError(w, err)
+	}
+}
+
+/* ========================= Dopuna kartice ========================= */
+
+// POST /students/cards/topups
+// Body: { "amount": {"amount": "1000.00", "currency": "RSD"} } — otvara placanje kod provajdera;
+// kartica se dopunjuje kada provajder potvrdi placanje webhook-om.
+func (h *HousingHandler) StartCardTopUp(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Amount money.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.decodeError(w, err)
+		return
+	}
+	u, err := h.service.ZapocniUplatu(r.Context(), h.caller(r).Username, in.Amount)
+	if err != nil {
+		h.uplataError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, u)
+}
+
+// GET /students/cards/topups — dopune kartice ulogovanog studenta
+func (h *HousingHandler) ListCardTopUps(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.UplateStudenta(r.Context(), h.caller(r).Username)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, list)
+}
+
+// POST /payments/topups/{id}/refund (admin)
+func (h *HousingHandler) RefundCardTopUp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	u, err := h.service.VratiUplatu(r.Context(), id, h.caller(r).Username)
+	if err != nil {
+		h.uplataError(w, err)
+		return
+	}
+	h.renderJSON(w, u)
+}
+
+// POST /payments/webhook — isporuke platnog provajdera; bez JWT-a, autenticnost se
+// proverava potpisom provajdera.
+func (h *HousingHandler) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		h.badRequest(w, "bad body")
+		return
+	}
+	h.deliverPayment(w, r, payload, r.Header)
+}
+
+// POST /payments/fake/{intentId}/complete?outcome=succeeded|failed
+// Samo sa laznim provajderom: simulira zavrsetak placanja i isporucuje potpisan webhook.
+// Student moze zavrsiti samo svoju nameru.
+func (h *HousingHandler) CompleteFakePayment(w http.ResponseWriter, r *http.Request) {
+	fake, ok := h.service.Placanje.(*service.FakePaymentProvider)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	outcome := r.URL.Query().Get("outcome")
+	if outcome != "" && outcome != "succeeded" && outcome != "failed" {
+		h.badRequest(w, "outcome mora biti succeeded ili failed")
+		return
+	}
+	intentID := mux.Vars(r)["intentId"]
+	u, err := h.service.UplataZaNameru(r.Context(), intentID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	// tudja namera se ne otkriva
+	if c := h.caller(r); err != nil || (c.Role != middleware.RoleAdmin && u.StudentUsername != c.Username) {
+		http.Error(w, "namera placanja ne postoji", http.StatusNotFound)
+		return
+	}
+	payload, header, err := fake.Complete(intentID, outcome != "failed")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	h.deliverPayment(w, r, payload, header)
+}
+
+func (h *HousingHandler) deliverPayment(w http.ResponseWriter, r *http.Request, payload []byte, header http.Header) {
+	ev, err := h.service.Placanje.ParseWebhook(payload, header)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSignature) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		h.badRequest(w, "bad payload")
+		return
+	}
+	u, err := h.service.ObradiPlacanje(r.Context(), ev)
+	if err != nil {
+		log.Printf("payment webhook %s (%s): %v", ev.ID, ev.Type, err)
+		h.uplataError(w, err)
+		return
+	}
+	h.renderJSON(w, u)
+}
+
+func (h *HousingHandler) uplataError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUplataKonflikt), errors.Is(err, service.ErrUplataNijeUspesna):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "uplata ne postoji", http.StatusNotFound)
+	default:
+		h.karticaError(w, err)
+	}
+}
+
+/* ========================= Prijave i raspodela ========================= */
+
+// POST /applications
+// Body: { "skolskaGodina": "2025/2026", "prosek": 8.75, "udaljenostKm": 120, "socijalniStatus": "...", "domovi": [...], "tipSobe": 2, "cimeri": [...] }
+func (h *HousingHandler) SubmitApplication(w http.ResponseWriter, r *http.Request) {
+	var in domain.PrijavaZaSmestaj
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	p, err := h.service.PodnesiPrijavu(r.Context(), in, h.caller(r).Username)
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, p)
+}
+
+// GET /applications — prijave ulogovanog studenta, po godinama
+func (h *HousingHandler) ListMyApplications(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.MojePrijave(r.Context(), h.caller(r).Username)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, list)
+}
+
+// DELETE /applications/{id} — povlacenje sopstvene prijave pre objave raspodele
+func (h *HousingHandler) WithdrawApplication(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	if err := h.service.PovuciPrijavu(r.Context(), id, h.caller(r).Username); err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /applications/all?godina=2025/2026
+func (h *HousingHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.PrijaveZaGodinu(r.Context(), r.URL.Query().Get("godina"))
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	h.renderJSON(w, list)
+}
+
+// GET /allocation/criteria?godina=2025/2026
+func (h *HousingHandler) GetAllocationCriteria(w http.ResponseWriter, r *http.Request) {
+	k, err := h.service.KriterijumiBodovanja(r.Context(), r.URL.Query().Get("godina"))
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	h.renderJSON(w, k)
+}
+
+// PUT /allocation/criteria
+// Body: { "skolskaGodina": "2025/2026", "tezinaProsek": 60, "tezinaUdaljenost": 30, "maxUdaljenostKm": 300, "socijalniBodovi": { "bez_roditelja": 10 } }
+func (h *HousingHandler) SaveAllocationCriteria(w http.ResponseWriter, r *http.Request) {
+	var in domain.KriterijumiBodovanja
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	k, err := h.service.SacuvajKriterijume(r.Context(), in, h.caller(r).Username)
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	h.renderJSON(w, k)
+}
+
+// POST /allocation/run
+// Body: { "skolskaGodina": "2025/2026" } — rangiranje i raspored po sobama (nacrt)
+func (h *HousingHandler) RunAllocation(w http.ResponseWriter, r *http.Request) {
+	godina, ok := h.skolskaGodina(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.PokreniRaspodelu(r.Context(), godina, h.caller(r).Username)
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	h.renderJSON(w, res)
+}
+
+// POST /allocation/publish
+// Body: { "skolskaGodina": "2025/2026" } — useljava primljene studente i objavljuje rezultate
+func (h *HousingHandler) PublishAllocation(w http.ResponseWriter, r *http.Request) {
+	godina, ok := h.skolskaGodina(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.ObjaviRaspodelu(r.Context(), godina, h.caller(r).Username)
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	h.renderJSON(w, res)
+}
+
+// GET /allocation/results?godina=2025/2026 — rang lista i lista cekanja; admin vidi i nacrt
+func (h *HousingHandler) GetAllocationResults(w http.ResponseWriter, r *http.Request) {
+	admin := h.caller(r).Role == middleware.RoleAdmin
+	res, err := h.service.RezultatiRaspodele(r.Context(), r.URL.Query().Get("godina"), admin)
+	if err != nil {
+		h.raspodelaError(w, err)
+		return
+	}
+	h.renderJSON(w, res)
+}
+
+func (h *HousingHandler) skolskaGodina(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var in struct {
+		SkolskaGodina string `json:"skolskaGodina"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return "", false
+	}
+	return in.SkolskaGodina, true
+}
+
+func (h *HousingHandler) raspodelaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNeispravnaPrijava):
+		h.badRequest(w, err.Error())
+	case errors.Is(err, service.ErrStudentNePostoji),
+		errors.Is(err, service.ErrNemaKriterijuma),
+		errors.Is(err, service.ErrNemaRaspodele):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "prijava ne postoji", http.StatusNotFound)
+	case errors.Is(err, service.ErrStudentVecUSobi),
+		errors.Is(err, service.ErrPrijaveZatvorene),
+		errors.Is(err, service.ErrRaspodelaObjavljena),
+		errors.Is(err, service.ErrRaspodelaZastarela):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "database exception", http.StatusInternalServerError)
+	}
+}
+
+/* ========================= Lista cekanja i ponude ========================= */
+
+// POST /doms/{id}/waitlist
+// Body (opciono): { "tipSobe": 2 } — ulogovani student staje na listu cekanja doma
+func (h *HousingHandler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	domID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	var in struct {
+		TipSobe *int `json:"tipSobe"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		h.badRequest(w, "bad json")
+		return
+	}
+	l, err := h.service.UpisiNaListuCekanja(r.Context(), domID, h.caller(r).Username, in.TipSobe)
+	if err != nil {
+		h.ponudaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, l)
+}
+
+// DELETE /doms/{id}/waitlist
+func (h *HousingHandler) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	domID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	if err := h.service.OdjaviSaListeCekanja(r.Context(), domID, h.caller(r).Username); err != nil {
+		h.ponudaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /doms/{id}/waitlist — studenti koji cekaju, po redosledu
+func (h *HousingHandler) GetDomWaitlist(w http.ResponseWriter, r *http.Request) {
+	domID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	list, err := h.service.ListaCekanjaDoma(r.Context(), domID)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, list)
+}
+
+// GET /waitlist — liste cekanja ulogovanog studenta sa trenutnom pozicijom
+func (h *HousingHandler) GetMyWaitlist(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.MojaListaCekanja(r.Context(), h.caller(r).Username)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
 	}
-	id, err := uuid.Parse(idStr)
+	h.renderJSON(w, list)
+}
+
+// GET /offers — ponude mesta ulogovanom studentu
+func (h *HousingHandler) GetMyOffers(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.MojePonude(r.Context(), h.caller(r).Username)
 	if err != nil {
-		h.badRequest(w, "invalid id")
+		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
 	}
+	h.renderJSON(w, list)
+}
 
-	dom, err := h.service.GetDom(r.Context(), id)
+// POST /offers/{id}/accept — useljenje u ponudjenu sobu
+func (h *HousingHandler) AcceptOffer(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		h.badRequest(w, "invalid id")
 		return
 	}
-	h.renderJSON(w, dom)
+	p, err := h.service.PrihvatiPonudu(r.Context(), id, h.caller(r).Username)
+	if err != nil {
+		h.ponudaError(w, err)
+		return
+	}
+	h.renderJSON(w, p)
 }
 
-// GET /doms
-func (h *HousingHandler) ListDomovi(w http.ResponseWriter, r *http.Request) {
-	domovi, err := h.service.GetAllDomovi(r.Context())
+// POST /offers/{id}/decline — student odbija mesto i napusta listu doma
+func (h *HousingHandler) DeclineOffer(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "database exception", http.StatusInternalServerError)
+		h.badRequest(w, "invalid id")
 		return
 	}
-	h.renderJSON(w, domovi)
+	p, err := h.service.OdbijPonudu(r.Context(), id, h.caller(r).Username)
+	if err != nil {
+		h.ponudaError(w, err)
+		return
+	}
+	h.renderJSON(w, p)
 }
 
-/* ========================= Students ========================= */
+func (h *HousingHandler) ponudaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNijeNaListi):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrPonudaNijeOtvorena),
+		errors.Is(err, service.ErrPonudaIstekla),
+		errors.Is(err, service.ErrSobaPopunjena):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.raspodelaError(w, err)
+	}
+}
 
-// POST /students
-// Body: { "ime": "Marko", "prezime": "Markovic", "username": "marko123" }
-func (h *HousingHandler) CreateStudent(w http.ResponseWriter, r *http.Request) {
+/* ========================= Premestaj i zamena soba ========================= */
+
+// POST /transfers
+// Body: { "ciljnaSobaId": "...uuid..." } ili { "ciljniDomId": "...uuid..." }, uz opciono "obrazlozenje"
+func (h *HousingHandler) RequestTransfer(w http.ResponseWriter, r *http.Request) {
 	var in struct {
-		Ime      string `json:"ime"`
-		Prezime  string `json:"prezime"`
-		Username string `json:"username"`
+		CiljnaSobaID *uuid.UUID `json:"ciljnaSobaId"`
+		CiljniDomID  *uuid.UUID `json:"ciljniDomId"`
+		Obrazlozenje string     `json:"obrazlozenje"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		h.badRequest(w, "bad json")
 		return
 	}
-	if in.Ime == "" || in.Prezime == "" || in.Username == "" {
-		h.badRequest(w, "ime, prezime i username su obavezni")
+	z, err := h.service.ZatraziPremestaj(r.Context(), h.caller(r).Username, in.CiljnaSobaID, in.CiljniDomID, in.Obrazlozenje)
+	if err != nil {
+		h.zahtevError(w, err)
 		return
 	}
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, z)
+}
 
-	st, err := h.service.CreateStudent(r.Context(), in.Ime, in.Prezime, in.Username)
+// POST /swaps
+// Body: { "drugiStudent": "marko123", "obrazlozenje": "..." }
+func (h *HousingHandler) RequestSwap(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		DrugiStudent string `json:"drugiStudent"`
+		Obrazlozenje string `json:"obrazlozenje"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return
+	}
+	z, err := h.service.ZatraziZamenu(r.Context(), h.caller(r).Username, in.DrugiStudent, in.Obrazlozenje)
+	if err != nil {
+		h.zahtevError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	h.renderJSON(w, z)
+}
+
+// GET /transfers — zahtevi ulogovanog studenta (podneti i zamene koje su mu predlozene)
+func (h *HousingHandler) ListMyTransfers(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.MojiZahtevi(r.Context(), h.caller(r).Username)
 	if err != nil {
 		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
 	}
+	h.renderJSON(w, list)
+}
 
-	w.WriteHeader(http.StatusCreated)
-	h.renderJSON(w, st)
+// GET /transfers/all?status=ceka_odobrenje
+func (h *HousingHandler) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	var status *domain.StatusZahteva
+	if v := r.URL.Query().Get("status"); v != "" {
+		st := domain.StatusZahteva(v)
+		status = &st
+	}
+	list, err := h.service.ZahteviPremestaja(r.Context(), status)
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, list)
 }
 
-// POST /rooms/assign
-// Body: { "domId": "...uuid...", "broj": "101", "username": "nikola123" }
-func (h *HousingHandler) AssignStudentToRoom(w http.ResponseWriter, r *http.Request) {
+// POST /transfers/{id}/respond
+// Body: { "prihvata": true } — drugi student potvrdjuje ili odbija zamenu
+func (h *HousingHandler) RespondToSwap(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
 	var in struct {
-		DomID    string `json:"domId"`
-		Broj     string `json:"broj"`
-		Username string `json:"username"`
+		Prihvata bool `json:"prihvata"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		h.badRequest(w, "bad json")
 		return
 	}
-	if in.DomID == "" || in.Broj == "" || in.Username == "" {
-		h.badRequest(w, "domId, broj i username su obavezni")
+	z, err := h.service.OdgovoriNaZamenu(r.Context(), id, h.caller(r).Username, in.Prihvata)
+	if err != nil {
+		h.zahtevError(w, err)
 		return
 	}
-	domID, err := uuid.Parse(in.DomID)
+	h.renderJSON(w, z)
+}
+
+// POST /transfers/{id}/withdraw
+func (h *HousingHandler) WithdrawTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		h.badRequest(w, "invalid domId")
+		h.badRequest(w, "invalid id")
+		return
+	}
+	z, err := h.service.PovuciZahtev(r.Context(), id, h.caller(r).Username)
+	if err != nil {
+		h.zahtevError(w, err)
 		return
 	}
+	h.renderJSON(w, z)
+}
 
-	st, err := h.service.UpisiPostojecegStudentaUSobu(r.Context(), domID, in.Broj, in.Username)
+// POST /transfers/{id}/approve — izvrsava premestaj ili zamenu
+func (h *HousingHandler) ApproveTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	z, err := h.service.OdobriZahtev(r.Context(), id, h.caller(r).Username)
+	if err != nil {
+		h.zahtevError(w, err)
+		return
+	}
+	h.renderJSON(w, z)
+}
+
+// POST /transfers/{id}/reject
+// Body: { "razlog": "..." }
+func (h *HousingHandler) RejectTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	var in struct {
+		Razlog string `json:"razlog"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		h.badRequest(w, "bad json")
+		return
+	}
+	z, err := h.service.OdbijZahtev(r.Context(), id, h.caller(r).Username, in.Razlog)
 	if err != nil {
+		h.zahtevError(w, err)
+		return
+	}
+	h.renderJSON(w, z)
+}
+
+func (h *HousingHandler) zahtevError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNeispravanZahtev):
+		h.badRequest(w, err.Error())
+	case errors.Is(err, service.ErrStudentNePostoji):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "zahtev ne postoji", http.StatusNotFound)
+	case errors.Is(err, service.ErrStudentNijeUSobi),
+		errors.Is(err, service.ErrOtvorenZahtev),
+		errors.Is(err, service.ErrZahtevResen),
+		errors.Is(err, service.ErrZamenaNijePotvrdjena),
+		errors.Is(err, service.ErrNemaSlobodnogMesta),
+		errors.Is(err, service.ErrZahtevZastareo),
+		errors.Is(err, service.ErrSobaPopunjena):
 		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "database exception", http.StatusInternalServerError)
+	}
+}
+
+/* ========================= Istorija boravaka ========================= */
+
+// GET /rooms/{id}/occupancy?from=2025-01-01&to=2025-02-01 — boravci u sobi koji se preklapaju sa periodom
+func (h *HousingHandler) GetRoomOccupancy(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	q := r.URL.Query()
+	od, err := parseVreme(q.Get("from"))
+	if err != nil {
+		h.badRequest(w, "invalid from")
+		return
+	}
+	do, err := parseVreme(q.Get("to"))
+	if err != nil {
+		h.badRequest(w, "invalid to")
+		return
+	}
+
+	list, err := h.service.IstorijaSobe(r.Context(), id, od, do)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "soba ne postoji", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, list)
+}
+
+// GET /students/{username}/occupancy — sobe u kojima je student stanovao
+func (h *HousingHandler) GetStudentOccupancy(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.IstorijaStudenta(r.Context(), mux.Vars(r)["username"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "student ne postoji", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
+		return
+	}
+	h.renderJSON(w, list)
+}
+
+/* ========================= Stanarina ========================= */
+
+// GET /rent/categories
+func (h *HousingHandler) ListRentCategories(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.KategorijeCena(r.Context())
+	if err != nil {
+		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
 	}
+	h.renderJSON(w, list)
+}
 
+// POST /rent/categories
+// Body: { "naziv": "Dvokrevetna", "mesecnaCena": "9500.00" }
+func (h *HousingHandler) CreateRentCategory(w http.ResponseWriter, r *http.Request) {
+	var in domain.KategorijaCene
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.decodeError(w, err)
+		return
+	}
+	in.ID = uuid.Nil
+	k, err := h.service.SacuvajKategoriju(r.Context(), in)
+	if err != nil {
+		h.stanarinaError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusCreated)
-	h.renderJSON(w, st)
+	h.renderJSON(w, k)
 }
 
-// POST /students/release
-// Body: { "studentId": "...uuid..." }  // ovo ostaje po ID-u (interni admin-endpoint)
-func (h *HousingHandler) ReleaseStudentRoom(w http.ResponseWriter, r *http.Request) {
-	var in struct {
-		StudentID string `json:"studentId"`
+// PUT /rent/categories/{id}
+// Body: { "naziv": "Dvokrevetna", "mesecnaCena": "9800.00" } — vazi za mesece koji jos nisu obracunati
+func (h *HousingHandler) UpdateRentCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
 	}
+	var in domain.KategorijaCene
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		h.badRequest(w, "bad json")
+		h.decodeError(w, err)
 		return
 	}
-	id, err := uuid.Parse(in.StudentID)
+	in.ID = id
+	k, err := h.service.SacuvajKategoriju(r.Context(), in)
 	if err != nil {
-		h.badRequest(w, "invalid studentId")
+		h.stanarinaError(w, err)
 		return
 	}
+	h.renderJSON(w, k)
+}
 
-	if err := h.service.OslobodiSobu(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// PUT /doms/{id}/rent-category
+// Body: { "kategorijaId": "...uuid..." } — null uklanja kategoriju
+func (h *HousingHandler) SetDomRentCategory(w http.ResponseWriter, r *http.Request) {
+	id, kategorijaID, ok := h.dodelaKategorije(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.PostaviKategorijuDoma(r.Context(), id, kategorijaID); err != nil {
+		h.stanarinaError(w, err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	h.renderJSON(w, map[string]string{"status": "ok"})
+// PUT /rooms/{id}/rent-category
+// Body: { "kategorijaId": "...uuid..." } — vazi umesto kategorije doma; null vraca cenu doma
+func (h *HousingHandler) SetRoomRentCategory(w http.ResponseWriter, r *http.Request) {
+	id, kategorijaID, ok := h.dodelaKategorije(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.PostaviKategorijuSobe(r.Context(), id, kategorijaID); err != nil {
+		h.stanarinaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-/* ========================= Studentska kartica (po username) ========================= */
+func (h *HousingHandler) dodelaKategorije(w http.ResponseWriter, r *http.Request) (uuid.UUID, *uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return uuid.Nil, nil, false
+	}
+	var in struct {
+		KategorijaID *uuid.UUID `json:"kategorijaId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.badRequest(w, "bad json")
+		return uuid.Nil, nil, false
+	}
+	return id, in.KategorijaID, true
+}
 
-// POST /students/cards
-// Body: { "studentUsername": "nikola123" }
-func (h *HousingHandler) CreateStudentCardIfMissing(w http.ResponseWriter, r *http.Request) {
+// POST /rent/billing/run
+// Body: { "period": "2025-10" } — obracun zavrsenog meseca; ponovljen obracun ne pravi nove racune
+func (h *HousingHandler) RunRentBilling(w http.ResponseWriter, r *http.Request) {
 	var in struct {
-		StudentUsername string `json:"studentUsername"`
+		Period string `json:"period"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		h.badRequest(w, "bad json")
 		return
 	}
-	if in.StudentUsername == "" {
-		h.badRequest(w, "studentUsername je obavezan")
+	period, err := time.Parse("2006-01", in.Period)
+	if err != nil {
+		h.badRequest(w, "invalid period (ocekuje se YYYY-MM)")
 		return
 	}
+	o, err := h.service.ObracunajStanarinu(r.Context(), period)
+	if err != nil {
+		h.stanarinaError(w, err)
+		return
+	}
+	h.renderJSON(w, o)
+}
 
-	card, err := h.service.KreirajStudentskuKarticuAkoNema(r.Context(), in.StudentUsername)
+// GET /rent/invoices — racuni ulogovanog studenta
+func (h *HousingHandler) ListMyInvoices(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.MojiRacuni(r.Context(), h.caller(r).Username)
 	if err != nil {
 		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	h.renderJSON(w, card)
+	h.renderJSON(w, list)
 }
 
-// GET /students/cards?studentUsername=<username>
-func (h *HousingHandler) GetStudentCard(w http.ResponseWriter, r *http.Request) {
-	u := r.URL.Query().Get("studentUsername")
-	if u == "" {
-		h.badRequest(w, "missing studentUsername")
+// GET /rent/invoices/{id} — racun sa uplatama; student vidi samo svoje
+func (h *HousingHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
 		return
 	}
-
-	card, err := h.service.GetStudentskaKarticaByStudent(r.Context(), u)
+	c := h.caller(r)
+	rc, err := h.service.Racun(r.Context(), id, c.Username, c.Role == middleware.RoleAdmin)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		h.stanarinaError(w, err)
 		return
 	}
-	h.renderJSON(w, card)
+	h.renderJSON(w, rc)
 }
 
-// POST /students/cards/balance
-// Body: { "studentUsername": "nikola123", "delta": 500.0 }
-func (h *HousingHandler) UpdateStudentCardBalance(w http.ResponseWriter, r *http.Request) {
+// POST /rent/invoices/{id}/payments
+// Body: { "iznos": "5000.00", "napomena": "uplatnica 123" }
+func (h *HousingHandler) RecordRentPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
 	var in struct {
-		StudentUsername string  `json:"studentUsername"`
-		Delta           float64 `json:"delta"`
+		Iznos    money.Money `json:"iznos"`
+		Napomena string      `json:"napomena"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		h.badRequest(w, "bad json")
+		h.decodeError(w, err)
 		return
 	}
-	if in.StudentUsername == "" {
-		h.badRequest(w, "studentUsername je obavezan")
+	rc, err := h.service.EvidentirajUplatu(r.Context(), id, domain.UplataStanarine{
+		Iznos:       in.Iznos,
+		Napomena:    in.Napomena,
+		Evidentirao: h.caller(r).Username,
+	})
+	if err != nil {
+		h.stanarinaError(w, err)
 		return
 	}
+	h.renderJSON(w, rc)
+}
 
-	card, err := h.service.AzurirajStanjeStudentskeKartice(r.Context(), in.StudentUsername, in.Delta)
+// GET /rent/arrears — dospeli neplaceni racuni po domovima
+func (h *HousingHandler) ListArrears(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.DugovanjaPoDomovima(r.Context())
 	if err != nil {
 		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
 	}
-	h.renderJSON(w, card)
+	h.renderJSON(w, list)
+}
+
+// GET /doms/{id}/arrears — duznici jednog doma
+func (h *HousingHandler) GetDomArrears(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.badRequest(w, "invalid id")
+		return
+	}
+	d, err := h.service.DugovanjaDoma(r.Context(), id)
+	if err != nil {
+		h.stanarinaError(w, err)
+		return
+	}
+	h.renderJSON(w, d)
+}
+
+func (h *HousingHandler) stanarinaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNeispravnaKategorija),
+		errors.Is(err, service.ErrNeispravnaUplata),
+		errors.Is(err, money.ErrCurrencyMismatch):
+		h.badRequest(w, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "ne postoji", http.StatusNotFound)
+	case errors.Is(err, service.ErrPeriodNijeZavrsen),
+		errors.Is(err, service.ErrRacunPlacen):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "database exception", http.StatusInternalServerError)
+	}
 }
 
 /* ========================= Recenzije ========================= */
 
 // POST /rooms/reviews
-// Body: { "sobaId": "...uuid...", "autorUsername": "nikola123", "ocena": 5, "komentar": "..." }
+// Body: { "sobaId": "...uuid...", "ocena": 5, "komentar": "..." } — autor je ulogovani student
 func (h *HousingHandler) AddRoomReview(w http.ResponseWriter, r *http.Request) {
 	var in struct {
-		SobaID        string  `json:"sobaId"`
-		AutorUsername string  `json:"autorUsername"`
-		Ocena         int     `json:"ocena"`
-		Komentar      *string `json:"komentar"`
+		SobaID   string  `json:"sobaId"`
+		Ocena    int     `json:"ocena"`
+		Komentar *string `json:"komentar"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		h.badRequest(w, "bad json")
@@ -248,16 +1247,12 @@ func (h *HousingHandler) AddRoomReview(w http.ResponseWriter, r *http.Request) {
 		h.badRequest(w, "invalid sobaId")
 		return
 	}
-	if in.AutorUsername == "" {
-		h.badRequest(w, "autorUsername je obavezan")
-		return
-	}
 	if in.Ocena < 1 || in.Ocena > 5 {
 		h.badRequest(w, "ocena mora biti 1..5")
 		return
 	}
 
-	rc, err := h.service.DodajRecenziju(r.Context(), sobaID, in.AutorUsername, in.Ocena, in.Komentar)
+	rc, err := h.service.DodajRecenziju(r.Context(), sobaID, h.caller(r).Username, in.Ocena, in.Komentar)
 	if err != nil {
 		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
@@ -270,12 +1265,11 @@ func (h *HousingHandler) AddRoomReview(w http.ResponseWriter, r *http.Request) {
 /* ========================= Kvarovi ========================= */
 
 // POST /rooms/faults
-// Body: { "sobaId": "...uuid...", "prijavioUsername": "marko123", "opis": "..." }
+// Body: { "sobaId": "...uuid...", "opis": "..." } — prijavio je ulogovani student
 func (h *HousingHandler) ReportFault(w http.ResponseWriter, r *http.Request) {
 	var in struct {
-		SobaID           string `json:"sobaId"`
-		PrijavioUsername string `json:"prijavioUsername"`
-		Opis             string `json:"opis"`
+		SobaID string `json:"sobaId"`
+		Opis   string `json:"opis"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		h.badRequest(w, "bad json")
@@ -290,12 +1284,7 @@ func (h *HousingHandler) ReportFault(w http.ResponseWriter, r *http.Request) {
 		h.badRequest(w, "invalid sobaId")
 		return
 	}
-	if in.PrijavioUsername == "" {
-		h.badRequest(w, "prijavioUsername je obavezan")
-		return
-	}
-
-	k, err := h.service.PrijaviKvar(r.Context(), sobaID, in.PrijavioUsername, in.Opis)
+	k, err := h.service.PrijaviKvar(r.Context(), sobaID, h.caller(r).Username, in.Opis)
 	if err != nil {
 		http.Error(w, "database exception", http.StatusInternalServerError)
 		return
@@ -422,26 +1411,22 @@ func (h *HousingHandler) IsStudentAssignedToAnySoba(w http.ResponseWriter, r *ht
 	json.NewEncoder(w).Encode(bul)
 }
 
-func (h HousingHandler) GetRoomMealHistory(w http.ResponseWriter, r *http.Request) {
-	url := "http://dining-server:8001/api/canteens/meal-history/"
-
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Post(url, "application/json", r.Body)
-	if err != nil {
-		http.Error(w, "database exception", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+// GET /api/housing/rooms/mine/meal-history
+// Obroci stanara sobe pozivaoca; spisak stanara odredjuje housing, ne klijent.
+func (h *HousingHandler) GetMyRoomMealHistory(w http.ResponseWriter, r *http.Request) {
+	obroci, err := h.service.IstorijaObrokaMojeSobe(r.Context(), h.caller(r).Username)
 	if err != nil {
-		http.Error(w, "Failed to read dining service response", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, service.ErrStudentNePostoji), errors.Is(err, service.ErrStudentNijeUSobi):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrDiningNedostupan):
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		default:
+			http.Error(w, "database exception", http.StatusInternalServerError)
+		}
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(body)
+	h.renderJSON(w, obroci)
 }
 
 // GET /api/housing/notifications/menus
@@ -473,10 +1458,8 @@ func (h *HousingHandler) GetTodayDiningMenus(w http.ResponseWriter, r *http.Requ
 			continue
 		}
 
-		// (opciono) propagiraj identitet/korisne headere
-		if sid := r.Header.Get("X-Student-ID"); sid != "" {
-			req.Header.Set("X-Student-ID", sid)
-		}
+		// propagiraj identitet pozivaoca
+		forwardAuth(req, r)
 
 		res, err := client.Do(req)
 		if err != nil {
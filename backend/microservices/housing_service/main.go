@@ -1,6 +1,8 @@
 package main
 
 import (
+	"common/middleware"
+	"common/servicetoken"
 	"context"
 	"housing/handler"
 	"housing/repository"
@@ -17,7 +19,7 @@ import (
 )
 
 func main() {
-	router := mux.NewRouter()
+	root := mux.NewRouter()
 
 	// === Repository init (konekcija na DB) ===
 	repositor, err := repository.NewHousingRepo()
@@ -26,6 +28,10 @@ func main() {
 	}
 	defer repositor.Close()
 
+	// Servisni token (client credentials) za listu opoziva i pozive ka dining servisu
+	usersURL := envOr("USERS_BASE_URL", "http://user-server:8002")
+	serviceTokens := servicetoken.New(usersURL, envOr("SERVICE_CLIENT_ID", "housing_service"), os.Getenv("SERVICE_CLIENT_SECRET"))
+
 	// === Service init ===
 	svcs := service.New(
 		repositor.DB,
@@ -35,38 +41,138 @@ func main() {
 		repository.NewRecRepo(),
 		repository.NewKvarRepo(),
 		repository.NewStudentskaKarticaRepo(), // NOVO: repo za studentske kartice
+		repository.NewNaplataRepo(),
+		repository.NewEventRepo(),
+		repository.NewUplataRepo(),
+		repository.NewPrijavaRepo(),
+		repository.NewRaspodelaRepo(),
+		repository.NewListaCekanjaRepo(),
+		repository.NewPonudaRepo(),
+		repository.NewZahtevRepo(),
+		repository.NewBoravakRepo(),
+		repository.NewKategorijaCeneRepo(),
+		repository.NewRacunRepo(),
+		paymentProvider(),
+		service.NewDiningObroci(envOr("DINING_BASE_URL", "http://dining-server:8001"), serviceTokens),
 	)
 
 	// === Handler init (housing) ===
 	hh := handler.NewHousingHandler(svcs)
 
-	// === Routes (housing) ===
+	// Pozadinski poslovi zive dok server radi
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+	go svcs.RunIstekPonuda(bgCtx, time.Minute)
+	go svcs.RunObracunStanarine(bgCtx, time.Hour)
+
+	// === Auth (neopozvan JWT izdat od users_service) ===
+	jwks := middleware.NewJWKS(usersURL)
+	go jwks.Run(bgCtx, 5*time.Minute)
+	revocations := middleware.NewRevocationList(usersURL, serviceTokens)
+	go revocations.Run(bgCtx, 5*time.Second)
+
+	if svcs.Placanje != nil {
+		// Webhook platnog provajdera nema JWT; autenticnost se proverava potpisom
+		root.HandleFunc("/api/housing/payments/webhook", hh.PaymentWebhook).Methods(http.MethodPost)
+	}
+
+	router := root.PathPrefix("/").Subrouter()
+	router.Use(middleware.Authenticate(jwks, revocations))
+
+	// === Routes (housing) — politika pristupa deklarisana uz svaku rutu ===
 	// Doms
-	router.Handle("/api/housing/doms", http.HandlerFunc(hh.ListDomovi)).Methods(http.MethodGet) // svi domovi
-	router.Handle("/api/housing/dom", http.HandlerFunc(hh.GetDom)).Methods(http.MethodGet)      // jedan dom po ID-u (query param id)
+	router.Handle("/api/housing/doms", middleware.Require(middleware.Authenticated, hh.ListDomovi)).Methods(http.MethodGet) // svi domovi
+	router.Handle("/api/housing/dom", middleware.Require(middleware.Authenticated, hh.GetDom)).Methods(http.MethodGet)      // jedan dom po ID-u (query param id)
 	// Students
-	router.Handle("/api/housing/students", http.HandlerFunc(hh.CreateStudent)).Methods(http.MethodPost)
-	router.Handle("/api/housing/students/release", http.HandlerFunc(hh.ReleaseStudentRoom)).Methods(http.MethodPost)
+	router.Handle("/api/housing/students", middleware.Require(middleware.Admin, hh.CreateStudent)).Methods(http.MethodPost)
+	router.Handle("/api/housing/students/release", middleware.Require(middleware.Admin, hh.ReleaseStudentRoom)).Methods(http.MethodPost)
 
-	// Studentska kartica (NOVO)
-	router.Handle("/api/housing/students/cards", http.HandlerFunc(hh.CreateStudentCardIfMissing)).Methods(http.MethodPost) // create-if-missing
-	router.Handle("/api/housing/students/cards", http.HandlerFunc(hh.GetStudentCard)).Methods(http.MethodGet)              // get by studentId (query param)
-	router.Handle("/api/housing/students/cards/balance", http.HandlerFunc(hh.UpdateStudentCardBalance)).Methods(http.MethodPost)
+	// Studentska kartica
+	router.Handle("/api/housing/students/cards", middleware.Require(middleware.Student, hh.CreateStudentCardIfMissing)).Methods(http.MethodPost) // create-if-missing
+	router.Handle("/api/housing/students/cards", middleware.Require(middleware.Student, hh.GetStudentCard)).Methods(http.MethodGet)              // kartica ulogovanog studenta
+	router.Handle("/api/housing/students/cards/balance", middleware.Require(middleware.Admin, hh.UpdateStudentCardBalance)).Methods(http.MethodPost)
+	router.Handle("/api/housing/students/cards/history", middleware.Require(middleware.Student, hh.GetStudentCardHistory)).Methods(http.MethodGet) // istorija sopstvene kartice
+	router.Handle("/api/housing/students/cards/topups", middleware.Require(middleware.Student, hh.ListCardTopUps)).Methods(http.MethodGet)
+	if svcs.Placanje != nil {
+		// dopuna preko platnog provajdera
+		router.Handle("/api/housing/students/cards/topups", middleware.Require(middleware.Student, hh.StartCardTopUp)).Methods(http.MethodPost)
+		router.Handle("/api/housing/payments/topups/{id}/refund", middleware.Require(middleware.Admin, hh.RefundCardTopUp)).Methods(http.MethodPost)
+	}
+	if _, ok := svcs.Placanje.(*service.FakePaymentProvider); ok {
+		// vlasnik namere (ili admin) simulira ishod placanja
+		router.Handle("/api/housing/payments/fake/{intentId}/complete", middleware.Require(middleware.AnyOf(middleware.Student, middleware.Admin), hh.CompleteFakePayment)).Methods(http.MethodPost)
+	}
+	router.Handle("/api/housing/students/cards/{username}/history", middleware.Require(middleware.Admin, hh.GetStudentCardHistoryByUsername)).Methods(http.MethodGet)
 
 	// Rooms
-	router.Handle("/api/housing/rooms", http.HandlerFunc(hh.GetRoom)).Methods(http.MethodGet)
-	router.Handle("/api/housing/rooms/detail", http.HandlerFunc(hh.GetRoomDetail)).Methods(http.MethodGet)
-	router.Handle("/api/housing/rooms/assign", http.HandlerFunc(hh.AssignStudentToRoom)).Methods(http.MethodPost)
-	router.Handle("/api/housing/rooms/free", http.HandlerFunc(hh.ListFreeRooms)).Methods(http.MethodGet) // NOVO: slobodne sobe
-	router.Handle("/api/housing/rooms/checkStudent/{userId}", http.HandlerFunc(hh.IsStudentAssignedToAnySoba)).Methods(http.MethodGet)
-	router.Handle("/api/housing/rooms/meal-history/", http.HandlerFunc(hh.GetRoomMealHistory)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rooms", middleware.Require(middleware.Authenticated, hh.GetRoom)).Methods(http.MethodGet)
+	router.Handle("/api/housing/rooms/detail", middleware.Require(middleware.Authenticated, hh.GetRoomDetail)).Methods(http.MethodGet)
+	router.Handle("/api/housing/rooms/assign", middleware.Require(middleware.Admin, hh.AssignStudentToRoom)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rooms/free", middleware.Require(middleware.Authenticated, hh.ListFreeRooms)).Methods(http.MethodGet) // slobodne sobe
+	router.Handle("/api/housing/rooms/checkStudent/{userId}", middleware.Require(ownerOrAdmin("userId"), hh.IsStudentAssignedToAnySoba)).Methods(http.MethodGet)
+	router.Handle("/api/housing/rooms/mine/meal-history", middleware.Require(middleware.Student, hh.GetMyRoomMealHistory)).Methods(http.MethodGet)
+
+	// Prijave za smestaj i raspodela po sobama (skolska godina u ?godina= ili telu zahteva)
+	router.Handle("/api/housing/applications", middleware.Require(middleware.Student, hh.SubmitApplication)).Methods(http.MethodPost)
+	router.Handle("/api/housing/applications", middleware.Require(middleware.Student, hh.ListMyApplications)).Methods(http.MethodGet)
+	router.Handle("/api/housing/applications/all", middleware.Require(middleware.Admin, hh.ListApplications)).Methods(http.MethodGet)
+	router.Handle("/api/housing/applications/{id}", middleware.Require(middleware.Student, hh.WithdrawApplication)).Methods(http.MethodDelete)
+	router.Handle("/api/housing/allocation/criteria", middleware.Require(middleware.Authenticated, hh.GetAllocationCriteria)).Methods(http.MethodGet)
+	router.Handle("/api/housing/allocation/criteria", middleware.Require(middleware.Admin, hh.SaveAllocationCriteria)).Methods(http.MethodPut)
+	router.Handle("/api/housing/allocation/run", middleware.Require(middleware.Admin, hh.RunAllocation)).Methods(http.MethodPost)
+	router.Handle("/api/housing/allocation/publish", middleware.Require(middleware.Admin, hh.PublishAllocation)).Methods(http.MethodPost)
+	router.Handle("/api/housing/allocation/results", middleware.Require(middleware.Authenticated, hh.GetAllocationResults)).Methods(http.MethodGet)
+
+	// Lista cekanja po domu i ponude mesta koja se oslobode
+	router.Handle("/api/housing/doms/{id}/waitlist", middleware.Require(middleware.Student, hh.JoinWaitlist)).Methods(http.MethodPost)
+	router.Handle("/api/housing/doms/{id}/waitlist", middleware.Require(middleware.Student, hh.LeaveWaitlist)).Methods(http.MethodDelete)
+	router.Handle("/api/housing/doms/{id}/waitlist", middleware.Require(middleware.Admin, hh.GetDomWaitlist)).Methods(http.MethodGet)
+	router.Handle("/api/housing/waitlist", middleware.Require(middleware.Student, hh.GetMyWaitlist)).Methods(http.MethodGet)
+	router.Handle("/api/housing/offers", middleware.Require(middleware.Student, hh.GetMyOffers)).Methods(http.MethodGet)
+	router.Handle("/api/housing/offers/{id}/accept", middleware.Require(middleware.Student, hh.AcceptOffer)).Methods(http.MethodPost)
+	router.Handle("/api/housing/offers/{id}/decline", middleware.Require(middleware.Student, hh.DeclineOffer)).Methods(http.MethodPost)
+
+	// Premestaj u drugu sobu i zamena soba (odobrava admin)
+	router.Handle("/api/housing/transfers", middleware.Require(middleware.Student, hh.RequestTransfer)).Methods(http.MethodPost)
+	router.Handle("/api/housing/transfers", middleware.Require(middleware.Student, hh.ListMyTransfers)).Methods(http.MethodGet)
+	router.Handle("/api/housing/swaps", middleware.Require(middleware.Student, hh.RequestSwap)).Methods(http.MethodPost)
+	router.Handle("/api/housing/transfers/all", middleware.Require(middleware.Admin, hh.ListTransfers)).Methods(http.MethodGet)
+	router.Handle("/api/housing/transfers/{id}/respond", middleware.Require(middleware.Student, hh.RespondToSwap)).Methods(http.MethodPost)
+	router.Handle("/api/housing/transfers/{id}/withdraw", middleware.Require(middleware.Student, hh.WithdrawTransfer)).Methods(http.MethodPost)
+	router.Handle("/api/housing/transfers/{id}/approve", middleware.Require(middleware.Admin, hh.ApproveTransfer)).Methods(http.MethodPost)
+	router.Handle("/api/housing/transfers/{id}/reject", middleware.Require(middleware.Admin, hh.RejectTransfer)).Methods(http.MethodPost)
+
+	// Istorija boravaka po sobi i po studentu
+	router.Handle("/api/housing/rooms/{id}/occupancy", middleware.Require(middleware.Admin, hh.GetRoomOccupancy)).Methods(http.MethodGet)
+	router.Handle("/api/housing/students/{username}/occupancy", middleware.Require(ownerOrAdmin("username"), hh.GetStudentOccupancy)).Methods(http.MethodGet)
+
+	// Stanarina: kategorije cena, mesecni obracun, racuni i dugovanja
+	router.Handle("/api/housing/rent/categories", middleware.Require(middleware.Authenticated, hh.ListRentCategories)).Methods(http.MethodGet)
+	router.Handle("/api/housing/rent/categories", middleware.Require(middleware.Admin, hh.CreateRentCategory)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rent/categories/{id}", middleware.Require(middleware.Admin, hh.UpdateRentCategory)).Methods(http.MethodPut)
+	router.Handle("/api/housing/doms/{id}/rent-category", middleware.Require(middleware.Admin, hh.SetDomRentCategory)).Methods(http.MethodPut)
+	router.Handle("/api/housing/rooms/{id}/rent-category", middleware.Require(middleware.Admin, hh.SetRoomRentCategory)).Methods(http.MethodPut)
+	router.Handle("/api/housing/rent/billing/run", middleware.Require(middleware.Admin, hh.RunRentBilling)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rent/invoices", middleware.Require(middleware.Student, hh.ListMyInvoices)).Methods(http.MethodGet)
+	router.Handle("/api/housing/rent/invoices/{id}", middleware.Require(middleware.Authenticated, hh.GetInvoice)).Methods(http.MethodGet)
+	router.Handle("/api/housing/rent/invoices/{id}/payments", middleware.Require(middleware.Admin, hh.RecordRentPayment)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rent/arrears", middleware.Require(middleware.Admin, hh.ListArrears)).Methods(http.MethodGet)
+	router.Handle("/api/housing/doms/{id}/arrears", middleware.Require(middleware.Admin, hh.GetDomArrears)).Methods(http.MethodGet)
 
 	// Reviews & Faults
-	router.Handle("/api/housing/rooms/reviews", http.HandlerFunc(hh.AddRoomReview)).Methods(http.MethodPost)
-	router.Handle("/api/housing/rooms/faults", http.HandlerFunc(hh.ReportFault)).Methods(http.MethodPost)
-	router.Handle("/api/housing/faults/status", http.HandlerFunc(hh.ChangeFaultStatus)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rooms/reviews", middleware.Require(middleware.Student, hh.AddRoomReview)).Methods(http.MethodPost)
+	router.Handle("/api/housing/rooms/faults", middleware.Require(middleware.Student, hh.ReportFault)).Methods(http.MethodPost)
+	router.Handle("/api/housing/faults/status", middleware.Require(middleware.Admin, hh.ChangeFaultStatus)).Methods(http.MethodPost)
+
+	// Naplata obroka (dining_service): charge -> confirm | compensate, idempotentno po referenci
+	router.Handle("/internal/cards/charges", middleware.Require(middleware.Service, hh.ChargeMeal)).Methods(http.MethodPost)
+	router.Handle("/internal/cards/charges/{ref}/confirm", middleware.Require(middleware.Service, hh.ConfirmMealCharge)).Methods(http.MethodPost)
+	router.Handle("/internal/cards/charges/{ref}/compensate", middleware.Require(middleware.Service, hh.CompensateMealCharge)).Methods(http.MethodPost)
 
-	router.Handle("/api/housing/notifications/menus", http.HandlerFunc(hh.GetTodayDiningMenus)).Methods(http.MethodGet)
+	// Dogadjaji iz users_service outbox-a
+	router.Handle("/internal/events", middleware.Require(middleware.Service, hh.ReceiveEvents)).Methods(http.MethodPost)
+
+	router.Handle("/api/housing/notifications/menus", middleware.Require(middleware.Authenticated, hh.GetTodayDiningMenus)).Methods(http.MethodGet)
 
 	// === Server setup ===
 	port := os.Getenv("PORT")
@@ -74,7 +180,7 @@ func main() {
 		port = "8003"
 	}
 
-	appHandler := withCORS(router)
+	appHandler := withCORS(root)
 
 	server := http.Server{
 		Addr:         ":" + port,
@@ -105,6 +211,50 @@ func main() {
 	log.Println("server stopped")
 }
 
+func ownerOrAdmin(param string) middleware.Policy {
+	return middleware.AnyOf(middleware.Owner(param), middleware.Admin)
+}
+
+// paymentProvider bira platnog provajdera (PAYMENT_PROVIDER); bez njega (nil) dopuna kartice
+// preko provajdera je iskljucena. Lazni provajder dopunjuje kartice bez stvarnog placanja,
+// pa radi samo uz APP_ENV=development; produkcija sa dopunama trazi pravog provajdera.
+func paymentProvider() service.PaymentProvider {
+	p := os.Getenv("PAYMENT_PROVIDER")
+	if p == "" {
+		log.Print("PAYMENT_PROVIDER not set: card top-ups are disabled")
+		return nil
+	}
+	secret := mustEnv("PAYMENT_WEBHOOK_SECRET")
+	switch p {
+	case "fake":
+		if os.Getenv("APP_ENV") != "development" {
+			log.Fatal("PAYMENT_PROVIDER=fake is allowed only with APP_ENV=development")
+		}
+		return service.NewFakePaymentProvider(
+			secret,
+			envOr("PAYMENT_CHECKOUT_URL", "http://localhost:4200/cards/topups/checkout"),
+		)
+	default:
+		log.Fatalf("unknown PAYMENT_PROVIDER %q", p)
+		return nil
+	}
+}
+
+func mustEnv(k string) string {
+	v := os.Getenv(k)
+	if v == "" {
+		log.Fatalf("missing env %s", k)
+	}
+	return v
+}
+
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
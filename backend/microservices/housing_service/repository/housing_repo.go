@@ -3,15 +3,17 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"housing/domain"
 
 	"github.com/cockroachdb/cockroach-go/v2/crdb"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type HousingRepo struct {
@@ -115,17 +117,254 @@ func (dr *HousingRepo) Migrate() error {
 			student_username TEXT NOT NULL UNIQUE REFERENCES student(username) ON DELETE CASCADE
 		);`,
 		`CREATE INDEX IF NOT EXISTS studentska_kartica_student_username_idx ON studentska_kartica(student_username);`,
+
+		// Knjiga kartice — nepromenljiva istorija uplata i zaduzenja; stanje kartice je zbir iznosa
+		`CREATE TABLE IF NOT EXISTS kartica_transakcija (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			kartica_id UUID NOT NULL REFERENCES studentska_kartica(id),
+			student_username TEXT NOT NULL,
+			iznos NUMERIC NOT NULL CHECK (iznos <> 0),
+			tip TEXT NOT NULL CHECK (tip IN ('top_up','meal_charge','refund','adjustment')),
+			referenca_id TEXT NULL,
+			izvrsio TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS kartica_transakcija_username_created_idx
+			ON kartica_transakcija (student_username, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS kartica_transakcija_kartica_idx
+			ON kartica_transakcija (kartica_id);`,
+
+		// Naplate obroka po referenci kupovine (charge/confirm/compensate)
+		`CREATE TABLE IF NOT EXISTS kartica_naplata (
+			referenca_id TEXT PRIMARY KEY,
+			student_username TEXT NOT NULL,
+			iznos NUMERIC NOT NULL,
+			status TEXT NOT NULL CHECK (status IN ('naplaceno','potvrdjeno','ponisteno')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+
+		// Dopune kartice preko platnog provajdera
+		`CREATE TABLE IF NOT EXISTS kartica_uplata (
+			id UUID PRIMARY KEY,
+			student_username TEXT NOT NULL REFERENCES student(username) ON DELETE CASCADE,
+			iznos NUMERIC NOT NULL CHECK (iznos > 0),
+			status TEXT NOT NULL CHECK (status IN ('kreirana','uspesna','neuspesna','vracena')),
+			provajder TEXT NOT NULL,
+			intent_id TEXT NULL UNIQUE,
+			checkout_url TEXT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS kartica_uplata_username_idx
+			ON kartica_uplata (student_username, created_at DESC);`,
+
+		// Obradjeni dogadjaji drugih servisa (idempotentan prijem)
+		`CREATE TABLE IF NOT EXISTS processed_events (
+			event_id UUID PRIMARY KEY,
+			event_type TEXT NOT NULL,
+			processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+
+		// Prijave za smestaj po skolskoj godini, kriterijumi bodovanja i raspodela po sobama
+		`CREATE TABLE IF NOT EXISTS prijava_smestaj (
+			id UUID PRIMARY KEY,
+			skolska_godina TEXT NOT NULL,
+			student_username TEXT NOT NULL REFERENCES student(username) ON DELETE CASCADE,
+			prosek NUMERIC(4,2) NOT NULL CHECK (prosek BETWEEN 6 AND 10),
+			udaljenost_km INTEGER NOT NULL CHECK (udaljenost_km >= 0),
+			socijalni_status TEXT NOT NULL DEFAULT '',
+			domovi UUID[] NOT NULL DEFAULT '{}',
+			tip_sobe INTEGER NULL CHECK (tip_sobe > 0),
+			cimeri TEXT[] NOT NULL DEFAULT '{}',
+			status TEXT NOT NULL CHECK (status IN ('podneta','povucena','primljena','lista_cekanja')),
+			bodovi NUMERIC NULL,
+			rang INTEGER NULL,
+			soba_id UUID NULL REFERENCES soba(id) ON DELETE SET NULL,
+			pozicija_cekanja INTEGER NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			CONSTRAINT prijava_godina_student_unq UNIQUE (skolska_godina, student_username)
+		);`,
+		`CREATE INDEX IF NOT EXISTS prijava_smestaj_godina_idx ON prijava_smestaj (skolska_godina, status);`,
+		`CREATE TABLE IF NOT EXISTS kriterijumi_bodovanja (
+			skolska_godina TEXT PRIMARY KEY,
+			tezina_prosek NUMERIC NOT NULL CHECK (tezina_prosek >= 0),
+			tezina_udaljenost NUMERIC NOT NULL CHECK (tezina_udaljenost >= 0),
+			max_udaljenost_km INTEGER NOT NULL CHECK (max_udaljenost_km >= 0),
+			socijalni_bodovi JSONB NOT NULL DEFAULT '{}',
+			updated_by TEXT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE TABLE IF NOT EXISTS raspodela (
+			skolska_godina TEXT PRIMARY KEY,
+			status TEXT NOT NULL CHECK (status IN ('nacrt','objavljena')),
+			pokrenuo TEXT NOT NULL,
+			pokrenuta_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			objavljena_at TIMESTAMPTZ NULL,
+			primljeno INTEGER NOT NULL DEFAULT 0,
+			na_listi_cekanja INTEGER NOT NULL DEFAULT 0
+		);`,
+
+		// Lista cekanja po domu i vremenski ogranicene ponude mesta koje se oslobode
+		`CREATE TABLE IF NOT EXISTS lista_cekanja (
+			id UUID PRIMARY KEY,
+			dom_id UUID NOT NULL REFERENCES dom(id) ON DELETE CASCADE,
+			student_username TEXT NOT NULL REFERENCES student(username) ON DELETE CASCADE,
+			tip_sobe INTEGER NULL CHECK (tip_sobe > 0),
+			status TEXT NOT NULL CHECK (status IN ('ceka','smesten','uklonjen')),
+			redosled INTEGER NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			CONSTRAINT lista_cekanja_dom_student_unq UNIQUE (dom_id, student_username)
+		);`,
+		`CREATE INDEX IF NOT EXISTS lista_cekanja_dom_redosled_idx ON lista_cekanja (dom_id, status, redosled);`,
+		`CREATE TABLE IF NOT EXISTS ponuda_sobe (
+			id UUID PRIMARY KEY,
+			lista_id UUID NOT NULL REFERENCES lista_cekanja(id) ON DELETE CASCADE,
+			student_username TEXT NOT NULL REFERENCES student(username) ON DELETE CASCADE,
+			soba_id UUID NOT NULL REFERENCES soba(id) ON DELETE CASCADE,
+			status TEXT NOT NULL CHECK (status IN ('ponudjena','prihvacena','odbijena','istekla','ponistena')),
+			istice_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			resena_at TIMESTAMPTZ NULL
+		);`,
+		// student ima najvise jednu otvorenu ponudu
+		`CREATE UNIQUE INDEX IF NOT EXISTS ponuda_sobe_otvorena_unq ON ponuda_sobe (student_username) WHERE status = 'ponudjena';`,
+		`CREATE INDEX IF NOT EXISTS ponuda_sobe_istice_idx ON ponuda_sobe (status, istice_at);`,
+
+		// Zahtevi za premestaj u drugu sobu i zamenu soba izmedju dva studenta
+		`CREATE TABLE IF NOT EXISTS zahtev_premestaja (
+			id UUID PRIMARY KEY,
+			tip TEXT NOT NULL CHECK (tip IN ('premestaj','zamena')),
+			student_username TEXT NOT NULL REFERENCES student(username) ON DELETE CASCADE,
+			soba_id UUID NOT NULL REFERENCES soba(id) ON DELETE CASCADE,
+			ciljna_soba_id UUID NULL REFERENCES soba(id) ON DELETE CASCADE,
+			ciljni_dom_id UUID NULL REFERENCES dom(id) ON DELETE CASCADE,
+			drugi_student TEXT NULL REFERENCES student(username) ON DELETE CASCADE,
+			druga_soba_id UUID NULL REFERENCES soba(id) ON DELETE CASCADE,
+			obrazlozenje TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL CHECK (status IN ('ceka_cimera','ceka_odobrenje','odobren','odbijen','povucen')),
+			nova_soba_id UUID NULL REFERENCES soba(id) ON DELETE SET NULL,
+			resio TEXT NULL,
+			razlog_odbijanja TEXT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		// student ima najvise jedan otvoren zahtev
+		`CREATE UNIQUE INDEX IF NOT EXISTS zahtev_premestaja_otvoren_unq ON zahtev_premestaja (student_username)
+			WHERE status IN ('ceka_cimera','ceka_odobrenje');`,
+		`CREATE INDEX IF NOT EXISTS zahtev_premestaja_drugi_idx ON zahtev_premestaja (drugi_student);`,
+		`CREATE INDEX IF NOT EXISTS zahtev_premestaja_status_idx ON zahtev_premestaja (status, created_at);`,
+
+		// Boravci studenata u sobama (istorija useljenja i iseljenja); bez kaskadnog brisanja jer je to evidencija
+		`CREATE TABLE IF NOT EXISTS boravak (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			student_username TEXT NOT NULL,
+			soba_id UUID NOT NULL REFERENCES soba(id),
+			useljen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			iseljen_at TIMESTAMPTZ NULL,
+			CHECK (iseljen_at IS NULL OR iseljen_at >= useljen_at)
+		);`,
+		// student ima najvise jedan otvoren boravak
+		`CREATE UNIQUE INDEX IF NOT EXISTS boravak_otvoren_unq ON boravak (student_username) WHERE iseljen_at IS NULL;`,
+		`CREATE INDEX IF NOT EXISTS boravak_soba_idx ON boravak (soba_id, useljen_at);`,
+		`CREATE INDEX IF NOT EXISTS boravak_student_idx ON boravak (student_username, useljen_at);`,
+
+		// Kategorije cena smestaja (dom ima kategoriju, soba je moze promeniti) i mesecni racuni stanarine
+		`CREATE TABLE IF NOT EXISTS kategorija_cene (
+			id UUID PRIMARY KEY,
+			naziv TEXT NOT NULL UNIQUE,
+			mesecna_cena NUMERIC NOT NULL CHECK (mesecna_cena > 0),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE TABLE IF NOT EXISTS kategorija_doma (
+			dom_id UUID PRIMARY KEY REFERENCES dom(id) ON DELETE CASCADE,
+			kategorija_id UUID NOT NULL REFERENCES kategorija_cene(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS kategorija_sobe (
+			soba_id UUID PRIMARY KEY REFERENCES soba(id) ON DELETE CASCADE,
+			kategorija_id UUID NOT NULL REFERENCES kategorija_cene(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS racun_stanarine (
+			id UUID PRIMARY KEY,
+			boravak_id UUID NOT NULL REFERENCES boravak(id),
+			student_username TEXT NOT NULL,
+			soba_id UUID NOT NULL REFERENCES soba(id),
+			period DATE NOT NULL,
+			pocetak DATE NOT NULL,
+			kraj DATE NOT NULL,
+			dana INTEGER NOT NULL CHECK (dana > 0),
+			dana_u_mesecu INTEGER NOT NULL,
+			mesecna_cena NUMERIC NOT NULL,
+			iznos NUMERIC NOT NULL CHECK (iznos >= 0),
+			placeno NUMERIC NOT NULL DEFAULT 0 CHECK (placeno >= 0 AND placeno <= iznos),
+			rok_placanja DATE NOT NULL,
+			status TEXT NOT NULL CHECK (status IN ('otvoren','placen')),
+			placen_at TIMESTAMPTZ NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			CONSTRAINT racun_stanarine_boravak_period_unq UNIQUE (boravak_id, period)
+		);`,
+		`CREATE INDEX IF NOT EXISTS racun_stanarine_student_idx ON racun_stanarine (student_username, period DESC);`,
+		`CREATE INDEX IF NOT EXISTS racun_stanarine_dospece_idx ON racun_stanarine (status, rok_placanja);`,
+		`CREATE TABLE IF NOT EXISTS uplata_stanarine (
+			id UUID PRIMARY KEY,
+			racun_id UUID NOT NULL REFERENCES racun_stanarine(id),
+			iznos NUMERIC NOT NULL CHECK (iznos > 0),
+			napomena TEXT NOT NULL DEFAULT '',
+			evidentirao TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS uplata_stanarine_racun_idx ON uplata_stanarine (racun_id, created_at);`,
 	}
 
 	// Retry-abilna transakcija (CockroachDB)
-	return crdb.ExecuteTx(context.Background(), dr.DB, nil, func(tx *sql.Tx) error {
+	if err := crdb.ExecuteTx(context.Background(), dr.DB, nil, func(tx *sql.Tx) error {
 		for _, q := range stmts {
 			if _, err := tx.Exec(q); err != nil {
 				return err
 			}
 		}
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+
+	// Kartice nastale pre knjige dobijaju pocetni unos sa zatecenim stanjem.
+	// Odvojeno od DDL-a jer CockroachDB ne dozvoljava izmenu seme posle upisa u istoj transakciji.
+	if _, err := dr.DB.Exec(
+		`INSERT INTO kartica_transakcija (kartica_id, student_username, iznos, tip, izvrsio)
+		 SELECT k.id, k.student_username, k.stanje, 'adjustment', 'migracija'
+		   FROM studentska_kartica k
+		  WHERE k.stanje <> 0
+		    AND NOT EXISTS (SELECT 1 FROM kartica_transakcija t WHERE t.kartica_id = k.id)`); err != nil {
+		return err
+	}
+
+	// Seed studenti iz ranijih baza dobijaju id svog naloga iz users_service-a;
+	// student se nigde ne referencira po id-ju, vec po username-u.
+	for username, id := range seedStudentIDs {
+		if _, err := dr.DB.Exec(`UPDATE student SET id = $1 WHERE username = $2 AND id <> $1`, id, username); err != nil {
+			return err
+		}
+	}
+
+	// Studenti useljeni pre evidencije boravaka dobijaju otvoren boravak od trenutka migracije.
+	_, err := dr.DB.Exec(
+		`INSERT INTO boravak (student_username, soba_id)
+		 SELECT s.username, s.soba_id
+		   FROM student s
+		  WHERE s.soba_id IS NOT NULL
+		    AND NOT EXISTS (SELECT 1 FROM boravak b WHERE b.student_username = s.username AND b.iseljen_at IS NULL)`)
+	return err
+}
+
+// seedStudentIDs: id-jevi seed naloga iz users_service-a; student iz UserRegistered
+// dogadjaja dobija id korisnika, pa seed mora koristiti iste.
+var seedStudentIDs = map[string]uuid.UUID{
+	"nikola123": uuid.MustParse("550e8400-e29b-41d4-a716-446655440001"),
+	"jovana123": uuid.MustParse("550e8400-e29b-41d4-a716-446655440002"),
+	"marko123":  uuid.MustParse("550e8400-e29b-41d4-a716-446655440003"),
 }
 
 // InitData — osnovni seed
@@ -147,9 +386,9 @@ func (dr *HousingRepo) InitData(ctx context.Context) error {
 		soba101ID := uuid.New()
 		soba102ID := uuid.New()
 
-		studentNikolaID := uuid.New()
-		studentJovanaID := uuid.New()
-		studentMarkoID := uuid.New()
+		studentNikolaID := seedStudentIDs["nikola123"]
+		studentJovanaID := seedStudentIDs["jovana123"]
+		studentMarkoID := seedStudentIDs["marko123"]
 
 		rec1ID := uuid.New()
 		rec2ID := uuid.New()
@@ -226,6 +465,14 @@ func (dr *HousingRepo) InitData(ctx context.Context) error {
 		); err != nil {
 			return err
 		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO kartica_transakcija (kartica_id, student_username, iznos, tip, izvrsio) VALUES
+			 ($1, 'nikola123', 1500.00, 'top_up', 'seed'),
+			 ($2, 'jovana123',  800.00, 'top_up', 'seed')`,
+			karticaNikolaID, karticaJovanaID,
+		); err != nil {
+			return err
+		}
 
 		return nil
 	})
@@ -284,6 +531,8 @@ type SobaRepository interface {
 	Create(ctx context.Context, q DBTX, s *domain.Soba) error
 	SetSlobodna(ctx context.Context, q DBTX, sobaID uuid.UUID, slobodna bool) error
 	ListSlobodne(ctx context.Context, q DBTX, domID uuid.UUID) ([]domain.Soba, error)
+	// ListPopunjenost vraca sve sobe sa brojem zauzetih mesta (studenti i otvorene ponude), po domu i broju sobe.
+	ListPopunjenost(ctx context.Context, q DBTX) ([]domain.SobaPopunjenost, error)
 }
 
 type sobaRepo struct{}
@@ -346,10 +595,34 @@ func (r *sobaRepo) ListSlobodne(ctx context.Context, q DBTX, domID uuid.UUID) ([
 	return out, rows.Err()
 }
 
+func (r *sobaRepo) ListPopunjenost(ctx context.Context, q DBTX) ([]domain.SobaPopunjenost, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT s.id, s.broj, s.slobodna, s.kapacitet, s.dom_id,
+		        (SELECT count(*) FROM student st WHERE st.soba_id = s.id) +
+		        (SELECT count(*) FROM ponuda_sobe p WHERE p.soba_id = s.id AND p.status = 'ponudjena')
+		   FROM soba s
+		  ORDER BY s.dom_id, s.broj`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.SobaPopunjenost
+	for rows.Next() {
+		var s domain.SobaPopunjenost
+		if err := rows.Scan(&s.ID, &s.Broj, &s.Slobodna, &s.Kapacitet, &s.DomID, &s.Zauzeto); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
 /* ================== Student ================== */
 
 type StudentRepository interface {
 	Create(ctx context.Context, q DBTX, st *domain.Student) error
+	CreateIfNotExists(ctx context.Context, q DBTX, st *domain.Student) error
 	Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.Student, error)
 	GetByUsername(ctx context.Context, q DBTX, username string) (domain.Student, error)
 	AssignToSoba(ctx context.Context, q DBTX, studentID uuid.UUID, sobaID uuid.UUID) error
@@ -373,6 +646,20 @@ func (r *studentRepo) Create(ctx context.Context, q DBTX, st *domain.Student) er
 	).Scan(&st.ID)
 }
 
+// CreateIfNotExists ne dira postojeceg studenta sa istim username-om.
+func (r *studentRepo) CreateIfNotExists(ctx context.Context, q DBTX, st *domain.Student) error {
+	if st.ID == uuid.Nil {
+		st.ID = uuid.New()
+	}
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO student (id, ime, prezime, username, soba_id)
+		 VALUES ($1,$2,$3,$4,$5)
+		 ON CONFLICT (username) DO NOTHING`,
+		st.ID, st.Ime, st.Prezime, st.Username, st.SobaID,
+	)
+	return err
+}
+
 func (r *studentRepo) Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.Student, error) {
 	var s domain.Student
 	err := q.QueryRowContext(ctx,
@@ -519,12 +806,38 @@ func (r *kvarRepo) ListBySoba(ctx context.Context, q DBTX, sobaID uuid.UUID) ([]
 	return out, rows.Err()
 }
 
+/* ================== Dogadjaji ================== */
+
+type EventRepository interface {
+	// MarkProcessed vraca false ako je dogadjaj vec obradjen.
+	MarkProcessed(ctx context.Context, q DBTX, eventID uuid.UUID, eventType string) (bool, error)
+}
+
+type eventRepo struct{}
+
+func NewEventRepo() EventRepository { return &eventRepo{} }
+
+func (r *eventRepo) MarkProcessed(ctx context.Context, q DBTX, eventID uuid.UUID, eventType string) (bool, error) {
+	res, err := q.ExecContext(ctx,
+		`INSERT INTO processed_events (event_id, event_type) VALUES ($1, $2)
+		 ON CONFLICT (event_id) DO NOTHING`, eventID, eventType)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
 /* ============ Studentska kartica (po username) ============ */
 
 type StudentskaKarticaRepository interface {
 	CreateIfNotExistsByUsername(ctx context.Context, q DBTX, studentUsername string) (domain.StudentskaKartica, error)
 	GetByStudentUsername(ctx context.Context, q DBTX, studentUsername string) (domain.StudentskaKartica, error)
-	UpdateStanjeByUsername(ctx context.Context, q DBTX, studentUsername string, delta float64) (domain.StudentskaKartica, error)
+	// GetForUpdate zakljucava karticu do kraja transakcije.
+	GetForUpdate(ctx context.Context, q DBTX, studentUsername string) (domain.StudentskaKartica, error)
+	// Knjizi dodaje unos u knjigu kartice i iz knjige ponovo racuna stanje.
+	Knjizi(ctx context.Context, q DBTX, t *domain.KarticaTransakcija) (domain.StudentskaKartica, error)
+	ListTransakcije(ctx context.Context, q DBTX, studentUsername string, f domain.TransakcijeFilter) ([]domain.KarticaTransakcija, int, error)
 }
 
 type karticaRepo struct{}
@@ -541,6 +854,17 @@ func (r *karticaRepo) GetByStudentUsername(ctx context.Context, q DBTX, studentU
 	return k, err
 }
 
+func (r *karticaRepo) GetForUpdate(ctx context.Context, q DBTX, studentUsername string) (domain.StudentskaKartica, error) {
+	var k domain.StudentskaKartica
+	err := q.QueryRowContext(ctx,
+		`SELECT id, stanje, student_username
+		   FROM studentska_kartica
+		  WHERE student_username = $1
+		  FOR UPDATE`, studentUsername).
+		Scan(&k.ID, &k.Stanje, &k.StudentUsername)
+	return k, err
+}
+
 func (r *karticaRepo) CreateIfNotExistsByUsername(ctx context.Context, q DBTX, studentUsername string) (domain.StudentskaKartica, error) {
 	var k domain.StudentskaKartica
 	err := q.QueryRowContext(ctx,
@@ -556,18 +880,122 @@ func (r *karticaRepo) CreateIfNotExistsByUsername(ctx context.Context, q DBTX, s
 	return k, err
 }
 
-func (r *karticaRepo) UpdateStanjeByUsername(ctx context.Context, q DBTX, studentUsername string, delta float64) (domain.StudentskaKartica, error) {
+// Knjizi zakljucava karticu, upisuje unos i osvezava stanje kao zbir svih unosa.
+// Poziva se u transakciji da bi istovremena knjizenja na istoj kartici bila serijalizovana.
+func (r *karticaRepo) Knjizi(ctx context.Context, q DBTX, t *domain.KarticaTransakcija) (domain.StudentskaKartica, error) {
+	if err := q.QueryRowContext(ctx,
+		`SELECT id FROM studentska_kartica
+		  WHERE student_username = $1
+		  FOR UPDATE`, t.StudentUsername).Scan(&t.KarticaID); err != nil {
+		return domain.StudentskaKartica{}, err
+	}
+
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	if err := q.QueryRowContext(ctx,
+		`INSERT INTO kartica_transakcija (id, kartica_id, student_username, iznos, tip, referenca_id, izvrsio)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING created_at`,
+		t.ID, t.KarticaID, t.StudentUsername, t.Iznos, t.Tip, t.ReferencaID, t.Izvrsio).
+		Scan(&t.Vreme); err != nil {
+		return domain.StudentskaKartica{}, err
+	}
+
 	var k domain.StudentskaKartica
 	err := q.QueryRowContext(ctx,
 		`UPDATE studentska_kartica
-		    SET stanje = stanje + $1
-		  WHERE student_username = $2
-		  RETURNING id, stanje, student_username`,
-		delta, studentUsername).
+		    SET stanje = (SELECT COALESCE(SUM(iznos), 0) FROM kartica_transakcija WHERE kartica_id = $1)
+		  WHERE id = $1
+		  RETURNING id, stanje, student_username`, t.KarticaID).
 		Scan(&k.ID, &k.Stanje, &k.StudentUsername)
 	return k, err
 }
 
+// ListTransakcije vraca stranicu istorije kartice (najnovije prvo) i ukupan broj unosa u periodu.
+func (r *karticaRepo) ListTransakcije(ctx context.Context, q DBTX, studentUsername string, f domain.TransakcijeFilter) ([]domain.KarticaTransakcija, int, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, kartica_id, student_username, iznos, tip, referenca_id, izvrsio, created_at, count(*) OVER ()
+		   FROM kartica_transakcija
+		  WHERE student_username = $1
+		    AND ($2::TIMESTAMPTZ IS NULL OR created_at >= $2)
+		    AND ($3::TIMESTAMPTZ IS NULL OR created_at < $3)
+		  ORDER BY created_at DESC, id DESC
+		  LIMIT $4 OFFSET $5`,
+		studentUsername, f.Od, f.Do, f.Size, (f.Page-1)*f.Size)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := []domain.KarticaTransakcija{}
+	total := 0
+	for rows.Next() {
+		var t domain.KarticaTransakcija
+		if err := rows.Scan(&t.ID, &t.KarticaID, &t.StudentUsername, &t.Iznos, &t.Tip,
+			&t.ReferencaID, &t.Izvrsio, &t.Vreme, &total); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// stranica iza poslednjeg unosa nema redova pa ni ukupnog broja
+	if len(out) == 0 && f.Page > 1 {
+		if err := q.QueryRowContext(ctx,
+			`SELECT count(*) FROM kartica_transakcija
+			  WHERE student_username = $1
+			    AND ($2::TIMESTAMPTZ IS NULL OR created_at >= $2)
+			    AND ($3::TIMESTAMPTZ IS NULL OR created_at < $3)`,
+			studentUsername, f.Od, f.Do).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+	return out, total, nil
+}
+
+/* ============ Naplate obroka ============ */
+
+type NaplataRepository interface {
+	Get(ctx context.Context, q DBTX, referencaID string, forUpdate bool) (domain.Naplata, error)
+	Create(ctx context.Context, q DBTX, n *domain.Naplata) error
+	SetStatus(ctx context.Context, q DBTX, referencaID string, status domain.StatusNaplate) error
+}
+
+type naplataRepo struct{}
+
+func NewNaplataRepo() NaplataRepository { return &naplataRepo{} }
+
+func (r *naplataRepo) Get(ctx context.Context, q DBTX, referencaID string, forUpdate bool) (domain.Naplata, error) {
+	query := `SELECT referenca_id, student_username, iznos, status
+		   FROM kartica_naplata
+		  WHERE referenca_id = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var n domain.Naplata
+	err := q.QueryRowContext(ctx, query, referencaID).
+		Scan(&n.ReferencaID, &n.StudentUsername, &n.Iznos, &n.Status)
+	return n, err
+}
+
+func (r *naplataRepo) Create(ctx context.Context, q DBTX, n *domain.Naplata) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO kartica_naplata (referenca_id, student_username, iznos, status)
+		 VALUES ($1, $2, $3, $4)`,
+		n.ReferencaID, n.StudentUsername, n.Iznos, n.Status)
+	return err
+}
+
+func (r *naplataRepo) SetStatus(ctx context.Context, q DBTX, referencaID string, status domain.StatusNaplate) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE kartica_naplata SET status = $2, updated_at = now()
+		  WHERE referenca_id = $1`, referencaID, status)
+	return err
+}
+
 func (r *studentRepo) IsAssignedToAnySoba(ctx context.Context, q DBTX, studentID string) (bool, error) {
 	var hasRoom sql.NullString
 	err := q.QueryRowContext(ctx, `
@@ -583,3 +1011,988 @@ func (r *studentRepo) IsAssignedToAnySoba(ctx context.Context, q DBTX, studentID
 	}
 	return hasRoom.Valid, nil
 }
+
+/* ============ Dopune kartice ============ */
+
+type UplataRepository interface {
+	Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.Uplata, error)
+	GetByIntent(ctx context.Context, q DBTX, intentID string) (domain.Uplata, error)
+	Create(ctx context.Context, q DBTX, u *domain.Uplata) error
+	SetIntent(ctx context.Context, q DBTX, id uuid.UUID, intentID, checkoutURL string) error
+	SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusUplate) error
+	ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.Uplata, error)
+}
+
+type uplataRepo struct{}
+
+func NewUplataRepo() UplataRepository { return &uplataRepo{} }
+
+const uplataColumns = `id, student_username, iznos, status, provajder, intent_id, checkout_url, created_at, updated_at`
+
+func scanUplata(row interface{ Scan(...any) error }, u *domain.Uplata) error {
+	return row.Scan(&u.ID, &u.StudentUsername, &u.Iznos, &u.Status, &u.Provajder,
+		&u.IntentID, &u.CheckoutURL, &u.CreatedAt, &u.UpdatedAt)
+}
+
+func (r *uplataRepo) Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.Uplata, error) {
+	query := `SELECT ` + uplataColumns + ` FROM kartica_uplata WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var u domain.Uplata
+	err := scanUplata(q.QueryRowContext(ctx, query, id), &u)
+	return u, err
+}
+
+func (r *uplataRepo) GetByIntent(ctx context.Context, q DBTX, intentID string) (domain.Uplata, error) {
+	var u domain.Uplata
+	err := scanUplata(q.QueryRowContext(ctx,
+		`SELECT `+uplataColumns+` FROM kartica_uplata WHERE intent_id = $1`, intentID), &u)
+	return u, err
+}
+
+func (r *uplataRepo) Create(ctx context.Context, q DBTX, u *domain.Uplata) error {
+	return scanUplata(q.QueryRowContext(ctx,
+		`INSERT INTO kartica_uplata (id, student_username, iznos, status, provajder)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+uplataColumns,
+		u.ID, u.StudentUsername, u.Iznos, u.Status, u.Provajder), u)
+}
+
+func (r *uplataRepo) SetIntent(ctx context.Context, q DBTX, id uuid.UUID, intentID, checkoutURL string) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE kartica_uplata SET intent_id = $2, checkout_url = $3, updated_at = now() WHERE id = $1`,
+		id, intentID, checkoutURL)
+	return err
+}
+
+func (r *uplataRepo) SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusUplate) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE kartica_uplata SET status = $2, updated_at = now() WHERE id = $1`, id, status)
+	return err
+}
+
+func (r *uplataRepo) ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.Uplata, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT `+uplataColumns+` FROM kartica_uplata
+		 WHERE student_username = $1
+		 ORDER BY created_at DESC
+		 LIMIT 100`, studentUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Uplata{}
+	for rows.Next() {
+		var u domain.Uplata
+		if err := scanUplata(rows, &u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+/* ============ Prijave za smestaj ============ */
+
+type PrijavaRepository interface {
+	// Save upisuje prijavu ili menja postojecu prijavu studenta za istu godinu;
+	// izmenjena prijava se vraca u status podneta.
+	Save(ctx context.Context, q DBTX, p *domain.PrijavaZaSmestaj) error
+	Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.PrijavaZaSmestaj, error)
+	ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.PrijavaZaSmestaj, error)
+	ListByGodina(ctx context.Context, q DBTX, godina string) ([]domain.PrijavaZaSmestaj, error)
+	// ListZaRaspodelu: prijave godine koje ulaze u rangiranje (nisu povucene, student nije u sobi).
+	ListZaRaspodelu(ctx context.Context, q DBTX, godina string) ([]domain.PrijavaZaSmestaj, error)
+	SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusPrijave) error
+	// ResetRezultate vraca rangirane prijave godine u status podneta, pre novog pokretanja.
+	ResetRezultate(ctx context.Context, q DBTX, godina string) error
+	SetRezultat(ctx context.Context, q DBTX, p *domain.PrijavaZaSmestaj) error
+	Rezultati(ctx context.Context, q DBTX, godina string) ([]domain.StavkaRezultata, error)
+}
+
+type prijavaRepo struct{}
+
+func NewPrijavaRepo() PrijavaRepository { return &prijavaRepo{} }
+
+const prijavaColumns = `id, skolska_godina, student_username, prosek, udaljenost_km, socijalni_status, domovi, tip_sobe,
+	cimeri, status, bodovi, rang, soba_id, pozicija_cekanja, created_at, updated_at`
+
+func scanPrijava(row interface{ Scan(...any) error }, p *domain.PrijavaZaSmestaj) error {
+	var domovi, cimeri pq.StringArray
+	if err := row.Scan(&p.ID, &p.SkolskaGodina, &p.StudentUsername, &p.Prosek, &p.UdaljenostKm, &p.SocijalniStatus,
+		&domovi, &p.TipSobe, &cimeri, &p.Status, &p.Bodovi, &p.Rang, &p.SobaID, &p.PozicijaCekanja,
+		&p.CreatedAt, &p.UpdatedAt); err != nil {
+		return err
+	}
+	p.Domovi = make([]uuid.UUID, 0, len(domovi))
+	for _, d := range domovi {
+		id, err := uuid.Parse(d)
+		if err != nil {
+			return err
+		}
+		p.Domovi = append(p.Domovi, id)
+	}
+	p.Cimeri = append([]string{}, cimeri...)
+	return nil
+}
+
+func uuidArray(ids []uuid.UUID) pq.StringArray {
+	out := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+func (r *prijavaRepo) Save(ctx context.Context, q DBTX, p *domain.PrijavaZaSmestaj) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return scanPrijava(q.QueryRowContext(ctx,
+		`INSERT INTO prijava_smestaj (id, skolska_godina, student_username, prosek, udaljenost_km, socijalni_status,
+		                             domovi, tip_sobe, cimeri, status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7::UUID[], $8, $9, 'podneta')
+		 ON CONFLICT (skolska_godina, student_username) DO UPDATE
+		   SET prosek = EXCLUDED.prosek,
+		       udaljenost_km = EXCLUDED.udaljenost_km,
+		       socijalni_status = EXCLUDED.socijalni_status,
+		       domovi = EXCLUDED.domovi,
+		       tip_sobe = EXCLUDED.tip_sobe,
+		       cimeri = EXCLUDED.cimeri,
+		       status = 'podneta',
+		       bodovi = NULL, rang = NULL, soba_id = NULL, pozicija_cekanja = NULL,
+		       updated_at = now()
+		 RETURNING `+prijavaColumns,
+		p.ID, p.SkolskaGodina, p.StudentUsername, p.Prosek, p.UdaljenostKm, p.SocijalniStatus,
+		uuidArray(p.Domovi), p.TipSobe, pq.StringArray(p.Cimeri)), p)
+}
+
+func (r *prijavaRepo) Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.PrijavaZaSmestaj, error) {
+	var p domain.PrijavaZaSmestaj
+	err := scanPrijava(q.QueryRowContext(ctx, `SELECT `+prijavaColumns+` FROM prijava_smestaj WHERE id = $1`, id), &p)
+	return p, err
+}
+
+func (r *prijavaRepo) ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.PrijavaZaSmestaj, error) {
+	return r.list(ctx, q,
+		`SELECT `+prijavaColumns+` FROM prijava_smestaj
+		  WHERE student_username = $1
+		  ORDER BY skolska_godina DESC`, studentUsername)
+}
+
+func (r *prijavaRepo) ListByGodina(ctx context.Context, q DBTX, godina string) ([]domain.PrijavaZaSmestaj, error) {
+	return r.list(ctx, q,
+		`SELECT `+prijavaColumns+` FROM prijava_smestaj
+		  WHERE skolska_godina = $1
+		  ORDER BY rang NULLS LAST, created_at`, godina)
+}
+
+func (r *prijavaRepo) ListZaRaspodelu(ctx context.Context, q DBTX, godina string) ([]domain.PrijavaZaSmestaj, error) {
+	return r.list(ctx, q,
+		`SELECT `+prijavaColumns+` FROM prijava_smestaj
+		  WHERE skolska_godina = $1
+		    AND status <> 'povucena'
+		    AND student_username IN (SELECT username FROM student WHERE soba_id IS NULL)
+		  ORDER BY created_at`, godina)
+}
+
+func (r *prijavaRepo) list(ctx context.Context, q DBTX, query string, args ...any) ([]domain.PrijavaZaSmestaj, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.PrijavaZaSmestaj{}
+	for rows.Next() {
+		var p domain.PrijavaZaSmestaj
+		if err := scanPrijava(rows, &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *prijavaRepo) SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusPrijave) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE prijava_smestaj SET status = $2, updated_at = now() WHERE id = $1`, id, status)
+	return err
+}
+
+func (r *prijavaRepo) ResetRezultate(ctx context.Context, q DBTX, godina string) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE prijava_smestaj
+		    SET status = 'podneta', bodovi = NULL, rang = NULL, soba_id = NULL, pozicija_cekanja = NULL, updated_at = now()
+		  WHERE skolska_godina = $1 AND status IN ('primljena', 'lista_cekanja')`, godina)
+	return err
+}
+
+func (r *prijavaRepo) SetRezultat(ctx context.Context, q DBTX, p *domain.PrijavaZaSmestaj) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE prijava_smestaj
+		    SET status = $2, bodovi = $3, rang = $4, soba_id = $5, pozicija_cekanja = $6, updated_at = now()
+		  WHERE id = $1`,
+		p.ID, p.Status, p.Bodovi, p.Rang, p.SobaID, p.PozicijaCekanja)
+	return err
+}
+
+func (r *prijavaRepo) Rezultati(ctx context.Context, q DBTX, godina string) ([]domain.StavkaRezultata, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT p.rang, p.student_username, st.ime, st.prezime, p.bodovi, p.status,
+		        d.id, COALESCE(d.naziv, ''), p.soba_id, COALESCE(s.broj, ''), p.pozicija_cekanja
+		   FROM prijava_smestaj p
+		   JOIN student st ON st.username = p.student_username
+		   LEFT JOIN soba s ON s.id = p.soba_id
+		   LEFT JOIN dom d ON d.id = s.dom_id
+		  WHERE p.skolska_godina = $1 AND p.status IN ('primljena', 'lista_cekanja')
+		  ORDER BY p.rang`, godina)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.StavkaRezultata{}
+	for rows.Next() {
+		var x domain.StavkaRezultata
+		if err := rows.Scan(&x.Rang, &x.StudentUsername, &x.Ime, &x.Prezime, &x.Bodovi, &x.Status,
+			&x.DomID, &x.Dom, &x.SobaID, &x.BrojSobe, &x.PozicijaCekanja); err != nil {
+			return nil, err
+		}
+		out = append(out, x)
+	}
+	return out, rows.Err()
+}
+
+/* ============ Kriterijumi i raspodela ============ */
+
+type RaspodelaRepository interface {
+	GetKriterijumi(ctx context.Context, q DBTX, godina string) (domain.KriterijumiBodovanja, error)
+	SaveKriterijumi(ctx context.Context, q DBTX, k *domain.KriterijumiBodovanja) error
+	Get(ctx context.Context, q DBTX, godina string, forUpdate bool) (domain.Raspodela, error)
+	// Save upisuje novo pokretanje raspodele (nacrt) ili objavu postojece.
+	Save(ctx context.Context, q DBTX, r *domain.Raspodela) error
+}
+
+type raspodelaRepo struct{}
+
+func NewRaspodelaRepo() RaspodelaRepository { return &raspodelaRepo{} }
+
+func (r *raspodelaRepo) GetKriterijumi(ctx context.Context, q DBTX, godina string) (domain.KriterijumiBodovanja, error) {
+	var (
+		k   domain.KriterijumiBodovanja
+		soc []byte
+	)
+	err := q.QueryRowContext(ctx,
+		`SELECT skolska_godina, tezina_prosek, tezina_udaljenost, max_udaljenost_km, socijalni_bodovi, updated_by, updated_at
+		   FROM kriterijumi_bodovanja
+		  WHERE skolska_godina = $1`, godina).
+		Scan(&k.SkolskaGodina, &k.TezinaProsek, &k.TezinaUdaljenost, &k.MaxUdaljenostKm, &soc, &k.UpdatedBy, &k.UpdatedAt)
+	if err != nil {
+		return k, err
+	}
+	k.SocijalniBodovi = map[string]float64{}
+	return k, json.Unmarshal(soc, &k.SocijalniBodovi)
+}
+
+func (r *raspodelaRepo) SaveKriterijumi(ctx context.Context, q DBTX, k *domain.KriterijumiBodovanja) error {
+	soc, err := json.Marshal(k.SocijalniBodovi)
+	if err != nil {
+		return err
+	}
+	return q.QueryRowContext(ctx,
+		`INSERT INTO kriterijumi_bodovanja (skolska_godina, tezina_prosek, tezina_udaljenost, max_udaljenost_km, socijalni_bodovi, updated_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (skolska_godina) DO UPDATE
+		   SET tezina_prosek = EXCLUDED.tezina_prosek,
+		       tezina_udaljenost = EXCLUDED.tezina_udaljenost,
+		       max_udaljenost_km = EXCLUDED.max_udaljenost_km,
+		       socijalni_bodovi = EXCLUDED.socijalni_bodovi,
+		       updated_by = EXCLUDED.updated_by,
+		       updated_at = now()
+		 RETURNING updated_at`,
+		k.SkolskaGodina, k.TezinaProsek, k.TezinaUdaljenost, k.MaxUdaljenostKm, string(soc), k.UpdatedBy).
+		Scan(&k.UpdatedAt)
+}
+
+func (r *raspodelaRepo) Get(ctx context.Context, q DBTX, godina string, forUpdate bool) (domain.Raspodela, error) {
+	query := `SELECT skolska_godina, status, pokrenuo, pokrenuta_at, objavljena_at, primljeno, na_listi_cekanja
+		   FROM raspodela
+		  WHERE skolska_godina = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var x domain.Raspodela
+	err := q.QueryRowContext(ctx, query, godina).
+		Scan(&x.SkolskaGodina, &x.Status, &x.Pokrenuo, &x.PokrenutaAt, &x.ObjavljenaAt, &x.Primljeno, &x.NaListiCekanja)
+	return x, err
+}
+
+func (r *raspodelaRepo) Save(ctx context.Context, q DBTX, x *domain.Raspodela) error {
+	return q.QueryRowContext(ctx,
+		`INSERT INTO raspodela (skolska_godina, status, pokrenuo, pokrenuta_at, objavljena_at, primljeno, na_listi_cekanja)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (skolska_godina) DO UPDATE
+		   SET status = EXCLUDED.status,
+		       pokrenuo = EXCLUDED.pokrenuo,
+		       pokrenuta_at = EXCLUDED.pokrenuta_at,
+		       objavljena_at = EXCLUDED.objavljena_at,
+		       primljeno = EXCLUDED.primljeno,
+		       na_listi_cekanja = EXCLUDED.na_listi_cekanja
+		 RETURNING pokrenuta_at`,
+		x.SkolskaGodina, x.Status, x.Pokrenuo, x.PokrenutaAt, x.ObjavljenaAt, x.Primljeno, x.NaListiCekanja).
+		Scan(&x.PokrenutaAt)
+}
+
+/* ============ Lista cekanja i ponude ============ */
+
+type ListaCekanjaRepository interface {
+	// Upisi stavlja studenta na kraj liste doma; ko vec ceka zadrzava mesto, a ko je bio
+	// uklonjen ili smesten ponovo staje na kraj.
+	Upisi(ctx context.Context, q DBTX, l *domain.ListaCekanjaStavka) error
+	Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.ListaCekanjaStavka, error)
+	GetByDomStudent(ctx context.Context, q DBTX, domID uuid.UUID, studentUsername string) (domain.ListaCekanjaStavka, error)
+	// ListByDom: studenti koji cekaju u domu, po redosledu.
+	ListByDom(ctx context.Context, q DBTX, domID uuid.UUID) ([]domain.ListaCekanjaStavka, error)
+	ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.ListaCekanjaStavka, error)
+	SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusCekanja) error
+	// OznaciSmestenog skida studenta sa svih lista na kojima jos ceka.
+	OznaciSmestenog(ctx context.Context, q DBTX, studentUsername string) error
+	// Sledeci: prvi student u domu koji ceka, nije u sobi, nema otvorenu ponudu i prihvata sobu tog kapaciteta.
+	Sledeci(ctx context.Context, q DBTX, domID uuid.UUID, kapacitet int) (domain.ListaCekanjaStavka, error)
+}
+
+type listaCekanjaRepo struct{}
+
+func NewListaCekanjaRepo() ListaCekanjaRepository { return &listaCekanjaRepo{} }
+
+const listaCekanjaColumns = `l.id, l.dom_id, l.student_username, l.tip_sobe, l.status,
+	CASE WHEN l.status = 'ceka'
+	     THEN (SELECT count(*) FROM lista_cekanja x WHERE x.dom_id = l.dom_id AND x.status = 'ceka' AND x.redosled <= l.redosled)
+	     ELSE 0 END,
+	l.created_at, l.updated_at`
+
+func scanListaCekanja(row interface{ Scan(...any) error }, l *domain.ListaCekanjaStavka) error {
+	return row.Scan(&l.ID, &l.DomID, &l.StudentUsername, &l.TipSobe, &l.Status, &l.Pozicija, &l.CreatedAt, &l.UpdatedAt)
+}
+
+func (r *listaCekanjaRepo) Upisi(ctx context.Context, q DBTX, l *domain.ListaCekanjaStavka) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	var id uuid.UUID
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO lista_cekanja AS l (id, dom_id, student_username, tip_sobe, status, redosled)
+		 SELECT $1, $2, $3, $4, 'ceka', COALESCE(MAX(redosled), 0) + 1
+		   FROM lista_cekanja WHERE dom_id = $2
+		 ON CONFLICT (dom_id, student_username) DO UPDATE
+		   SET tip_sobe = EXCLUDED.tip_sobe,
+		       redosled = CASE WHEN l.status = 'ceka' THEN l.redosled ELSE EXCLUDED.redosled END,
+		       status = 'ceka',
+		       updated_at = now()
+		 RETURNING id`,
+		l.ID, l.DomID, l.StudentUsername, l.TipSobe).Scan(&id)
+	if err != nil {
+		return err
+	}
+	*l, err = r.Get(ctx, q, id)
+	return err
+}
+
+func (r *listaCekanjaRepo) Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.ListaCekanjaStavka, error) {
+	var l domain.ListaCekanjaStavka
+	err := scanListaCekanja(q.QueryRowContext(ctx,
+		`SELECT `+listaCekanjaColumns+` FROM lista_cekanja l WHERE l.id = $1`, id), &l)
+	return l, err
+}
+
+func (r *listaCekanjaRepo) GetByDomStudent(ctx context.Context, q DBTX, domID uuid.UUID, studentUsername string) (domain.ListaCekanjaStavka, error) {
+	var l domain.ListaCekanjaStavka
+	err := scanListaCekanja(q.QueryRowContext(ctx,
+		`SELECT `+listaCekanjaColumns+` FROM lista_cekanja l
+		  WHERE l.dom_id = $1 AND l.student_username = $2`, domID, studentUsername), &l)
+	return l, err
+}
+
+func (r *listaCekanjaRepo) ListByDom(ctx context.Context, q DBTX, domID uuid.UUID) ([]domain.ListaCekanjaStavka, error) {
+	return r.list(ctx, q,
+		`SELECT `+listaCekanjaColumns+` FROM lista_cekanja l
+		  WHERE l.dom_id = $1 AND l.status = 'ceka'
+		  ORDER BY l.redosled`, domID)
+}
+
+func (r *listaCekanjaRepo) ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.ListaCekanjaStavka, error) {
+	return r.list(ctx, q,
+		`SELECT `+listaCekanjaColumns+` FROM lista_cekanja l
+		  WHERE l.student_username = $1
+		  ORDER BY l.updated_at DESC`, studentUsername)
+}
+
+func (r *listaCekanjaRepo) list(ctx context.Context, q DBTX, query string, args ...any) ([]domain.ListaCekanjaStavka, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.ListaCekanjaStavka{}
+	for rows.Next() {
+		var l domain.ListaCekanjaStavka
+		if err := scanListaCekanja(rows, &l); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func (r *listaCekanjaRepo) SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusCekanja) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE lista_cekanja SET status = $2, updated_at = now() WHERE id = $1`, id, status)
+	return err
+}
+
+func (r *listaCekanjaRepo) OznaciSmestenog(ctx context.Context, q DBTX, studentUsername string) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE lista_cekanja SET status = 'smesten', updated_at = now()
+		  WHERE student_username = $1 AND status = 'ceka'`, studentUsername)
+	return err
+}
+
+func (r *listaCekanjaRepo) Sledeci(ctx context.Context, q DBTX, domID uuid.UUID, kapacitet int) (domain.ListaCekanjaStavka, error) {
+	var l domain.ListaCekanjaStavka
+	err := scanListaCekanja(q.QueryRowContext(ctx,
+		`SELECT `+listaCekanjaColumns+` FROM lista_cekanja l
+		  WHERE l.dom_id = $1 AND l.status = 'ceka'
+		    AND (l.tip_sobe IS NULL OR l.tip_sobe = $2)
+		    AND l.student_username IN (SELECT username FROM student WHERE soba_id IS NULL)
+		    AND NOT EXISTS (SELECT 1 FROM ponuda_sobe p
+		                     WHERE p.student_username = l.student_username AND p.status = 'ponudjena')
+		  ORDER BY l.redosled
+		  LIMIT 1`, domID, kapacitet), &l)
+	return l, err
+}
+
+type PonudaRepository interface {
+	Create(ctx context.Context, q DBTX, p *domain.PonudaSobe) error
+	Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.PonudaSobe, error)
+	ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.PonudaSobe, error)
+	// ListIstekle: otvorene ponude kojima je rok prosao pre datog trenutka.
+	ListIstekle(ctx context.Context, q DBTX, pre time.Time, limit int) ([]domain.PonudaSobe, error)
+	SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusPonude) error
+}
+
+type ponudaRepo struct{}
+
+func NewPonudaRepo() PonudaRepository { return &ponudaRepo{} }
+
+const ponudaColumns = `p.id, p.lista_id, p.student_username, p.soba_id, s.dom_id, s.broj, p.status, p.istice_at, p.created_at, p.resena_at`
+
+func scanPonuda(row interface{ Scan(...any) error }, p *domain.PonudaSobe) error {
+	return row.Scan(&p.ID, &p.ListaID, &p.StudentUsername, &p.SobaID, &p.DomID, &p.BrojSobe, &p.Status,
+		&p.IsticeAt, &p.CreatedAt, &p.ResenaAt)
+}
+
+func (r *ponudaRepo) Create(ctx context.Context, q DBTX, p *domain.PonudaSobe) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO ponuda_sobe (id, lista_id, student_username, soba_id, status, istice_at)
+		 VALUES ($1, $2, $3, $4, 'ponudjena', $5)`,
+		p.ID, p.ListaID, p.StudentUsername, p.SobaID, p.IsticeAt)
+	if err != nil {
+		return err
+	}
+	*p, err = r.Get(ctx, q, p.ID, false)
+	return err
+}
+
+func (r *ponudaRepo) Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.PonudaSobe, error) {
+	query := `SELECT ` + ponudaColumns + ` FROM ponuda_sobe p JOIN soba s ON s.id = p.soba_id WHERE p.id = $1`
+	if forUpdate {
+		query += " FOR UPDATE OF p"
+	}
+	var p domain.PonudaSobe
+	err := scanPonuda(q.QueryRowContext(ctx, query, id), &p)
+	return p, err
+}
+
+func (r *ponudaRepo) ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.PonudaSobe, error) {
+	return r.list(ctx, q,
+		`SELECT `+ponudaColumns+` FROM ponuda_sobe p JOIN soba s ON s.id = p.soba_id
+		  WHERE p.student_username = $1
+		  ORDER BY p.created_at DESC`, studentUsername)
+}
+
+func (r *ponudaRepo) ListIstekle(ctx context.Context, q DBTX, pre time.Time, limit int) ([]domain.PonudaSobe, error) {
+	return r.list(ctx, q,
+		`SELECT `+ponudaColumns+` FROM ponuda_sobe p JOIN soba s ON s.id = p.soba_id
+		  WHERE p.status = 'ponudjena' AND p.istice_at <= $1
+		  ORDER BY p.istice_at
+		  LIMIT $2`, pre, limit)
+}
+
+func (r *ponudaRepo) list(ctx context.Context, q DBTX, query string, args ...any) ([]domain.PonudaSobe, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.PonudaSobe{}
+	for rows.Next() {
+		var p domain.PonudaSobe
+		if err := scanPonuda(rows, &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *ponudaRepo) SetStatus(ctx context.Context, q DBTX, id uuid.UUID, status domain.StatusPonude) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE ponuda_sobe SET status = $2, resena_at = now() WHERE id = $1`, id, status)
+	return err
+}
+
+/* ============ Premestaj i zamena ============ */
+
+type ZahtevRepository interface {
+	Create(ctx context.Context, q DBTX, z *domain.ZahtevPremestaja) error
+	Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.ZahtevPremestaja, error)
+	// ListByStudent: zahtevi koje je student podneo ili u kojima je drugi student zamene.
+	ListByStudent(ctx context.Context, q DBTX, username string) ([]domain.ZahtevPremestaja, error)
+	// List: svi zahtevi, opciono samo sa datim statusom, od najstarijeg.
+	List(ctx context.Context, q DBTX, status *domain.StatusZahteva) ([]domain.ZahtevPremestaja, error)
+	// Update upisuje status, resenje i sobu u koju je student premesten.
+	Update(ctx context.Context, q DBTX, z *domain.ZahtevPremestaja) error
+}
+
+type zahtevRepo struct{}
+
+func NewZahtevRepo() ZahtevRepository { return &zahtevRepo{} }
+
+const zahtevColumns = `id, tip, student_username, soba_id, ciljna_soba_id, ciljni_dom_id, drugi_student, druga_soba_id,
+	obrazlozenje, status, nova_soba_id, resio, razlog_odbijanja, created_at, updated_at`
+
+func scanZahtev(row interface{ Scan(...any) error }, z *domain.ZahtevPremestaja) error {
+	return row.Scan(&z.ID, &z.Tip, &z.StudentUsername, &z.SobaID, &z.CiljnaSobaID, &z.CiljniDomID, &z.DrugiStudent,
+		&z.DrugaSobaID, &z.Obrazlozenje, &z.Status, &z.NovaSobaID, &z.Resio, &z.RazlogOdbijanja, &z.CreatedAt, &z.UpdatedAt)
+}
+
+func (r *zahtevRepo) Create(ctx context.Context, q DBTX, z *domain.ZahtevPremestaja) error {
+	if z.ID == uuid.Nil {
+		z.ID = uuid.New()
+	}
+	return scanZahtev(q.QueryRowContext(ctx,
+		`INSERT INTO zahtev_premestaja (id, tip, student_username, soba_id, ciljna_soba_id, ciljni_dom_id,
+		                               drugi_student, druga_soba_id, obrazlozenje, status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING `+zahtevColumns,
+		z.ID, z.Tip, z.StudentUsername, z.SobaID, z.CiljnaSobaID, z.CiljniDomID,
+		z.DrugiStudent, z.DrugaSobaID, z.Obrazlozenje, z.Status), z)
+}
+
+func (r *zahtevRepo) Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.ZahtevPremestaja, error) {
+	query := `SELECT ` + zahtevColumns + ` FROM zahtev_premestaja WHERE id = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var z domain.ZahtevPremestaja
+	err := scanZahtev(q.QueryRowContext(ctx, query, id), &z)
+	return z, err
+}
+
+func (r *zahtevRepo) ListByStudent(ctx context.Context, q DBTX, username string) ([]domain.ZahtevPremestaja, error) {
+	return r.list(ctx, q,
+		`SELECT `+zahtevColumns+` FROM zahtev_premestaja
+		  WHERE student_username = $1 OR drugi_student = $1
+		  ORDER BY created_at DESC`, username)
+}
+
+func (r *zahtevRepo) List(ctx context.Context, q DBTX, status *domain.StatusZahteva) ([]domain.ZahtevPremestaja, error) {
+	return r.list(ctx, q,
+		`SELECT `+zahtevColumns+` FROM zahtev_premestaja
+		  WHERE $1::TEXT IS NULL OR status = $1
+		  ORDER BY created_at`, status)
+}
+
+func (r *zahtevRepo) list(ctx context.Context, q DBTX, query string, args ...any) ([]domain.ZahtevPremestaja, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.ZahtevPremestaja{}
+	for rows.Next() {
+		var z domain.ZahtevPremestaja
+		if err := scanZahtev(rows, &z); err != nil {
+			return nil, err
+		}
+		out = append(out, z)
+	}
+	return out, rows.Err()
+}
+
+func (r *zahtevRepo) Update(ctx context.Context, q DBTX, z *domain.ZahtevPremestaja) error {
+	return q.QueryRowContext(ctx,
+		`UPDATE zahtev_premestaja
+		    SET status = $2, nova_soba_id = $3, resio = $4, razlog_odbijanja = $5, updated_at = now()
+		  WHERE id = $1
+		  RETURNING updated_at`,
+		z.ID, z.Status, z.NovaSobaID, z.Resio, z.RazlogOdbijanja).Scan(&z.UpdatedAt)
+}
+
+/* ============ Boravci ============ */
+
+type BoravakRepository interface {
+	// Otvori upisuje useljenje studenta u sobu.
+	Otvori(ctx context.Context, q DBTX, studentUsername string, sobaID uuid.UUID) error
+	// Zatvori upisuje iseljenje; bez otvorenog boravka nema efekta.
+	Zatvori(ctx context.Context, q DBTX, studentUsername string) error
+	// ListBySoba: boravci u sobi koji se preklapaju sa periodom [od, do]; granice su opcione.
+	ListBySoba(ctx context.Context, q DBTX, sobaID uuid.UUID, od, do *time.Time) ([]domain.Boravak, error)
+	ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.Boravak, error)
+}
+
+type boravakRepo struct{}
+
+func NewBoravakRepo() BoravakRepository { return &boravakRepo{} }
+
+const boravakColumns = `b.id, b.student_username, COALESCE(st.ime, ''), COALESCE(st.prezime, ''),
+	b.soba_id, s.broj, s.dom_id, d.naziv, b.useljen_at, b.iseljen_at`
+
+const boravakFrom = ` FROM boravak b
+	JOIN soba s ON s.id = b.soba_id
+	JOIN dom d ON d.id = s.dom_id
+	LEFT JOIN student st ON st.username = b.student_username`
+
+func (r *boravakRepo) Otvori(ctx context.Context, q DBTX, studentUsername string, sobaID uuid.UUID) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO boravak (student_username, soba_id) VALUES ($1, $2)`, studentUsername, sobaID)
+	return err
+}
+
+func (r *boravakRepo) Zatvori(ctx context.Context, q DBTX, studentUsername string) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE boravak SET iseljen_at = now() WHERE student_username = $1 AND iseljen_at IS NULL`, studentUsername)
+	return err
+}
+
+func (r *boravakRepo) ListBySoba(ctx context.Context, q DBTX, sobaID uuid.UUID, od, do *time.Time) ([]domain.Boravak, error) {
+	return r.list(ctx, q,
+		`SELECT `+boravakColumns+boravakFrom+`
+		  WHERE b.soba_id = $1
+		    AND ($2::TIMESTAMPTZ IS NULL OR b.iseljen_at IS NULL OR b.iseljen_at >= $2)
+		    AND ($3::TIMESTAMPTZ IS NULL OR b.useljen_at <= $3)
+		  ORDER BY b.useljen_at DESC`, sobaID, od, do)
+}
+
+func (r *boravakRepo) ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.Boravak, error) {
+	return r.list(ctx, q,
+		`SELECT `+boravakColumns+boravakFrom+`
+		  WHERE b.student_username = $1
+		  ORDER BY b.useljen_at DESC`, studentUsername)
+}
+
+func (r *boravakRepo) list(ctx context.Context, q DBTX, query string, args ...any) ([]domain.Boravak, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Boravak{}
+	for rows.Next() {
+		var b domain.Boravak
+		if err := rows.Scan(&b.ID, &b.StudentUsername, &b.Ime, &b.Prezime, &b.SobaID, &b.BrojSobe,
+			&b.DomID, &b.Dom, &b.UseljenAt, &b.IseljenAt); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+/* ============ Kategorije cena ============ */
+
+type KategorijaCeneRepository interface {
+	List(ctx context.Context, q DBTX) ([]domain.KategorijaCene, error)
+	Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.KategorijaCene, error)
+	// Save upisuje novu kategoriju ili menja naziv i cenu postojece.
+	Save(ctx context.Context, q DBTX, k *domain.KategorijaCene) error
+	// PostaviZaDom dodeljuje kategoriju domu; nil uklanja dodelu.
+	PostaviZaDom(ctx context.Context, q DBTX, domID uuid.UUID, kategorijaID *uuid.UUID) error
+	// PostaviZaSobu dodeljuje sobi kategoriju koja vazi umesto kategorije doma; nil uklanja dodelu.
+	PostaviZaSobu(ctx context.Context, q DBTX, sobaID uuid.UUID, kategorijaID *uuid.UUID) error
+}
+
+type kategorijaCeneRepo struct{}
+
+func NewKategorijaCeneRepo() KategorijaCeneRepository { return &kategorijaCeneRepo{} }
+
+const kategorijaCeneColumns = `id, naziv, mesecna_cena, updated_at`
+
+func scanKategorijaCene(row interface{ Scan(...any) error }, k *domain.KategorijaCene) error {
+	return row.Scan(&k.ID, &k.Naziv, &k.MesecnaCena, &k.UpdatedAt)
+}
+
+func (r *kategorijaCeneRepo) List(ctx context.Context, q DBTX) ([]domain.KategorijaCene, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+kategorijaCeneColumns+` FROM kategorija_cene ORDER BY naziv`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.KategorijaCene{}
+	for rows.Next() {
+		var k domain.KategorijaCene
+		if err := scanKategorijaCene(rows, &k); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *kategorijaCeneRepo) Get(ctx context.Context, q DBTX, id uuid.UUID) (domain.KategorijaCene, error) {
+	var k domain.KategorijaCene
+	err := scanKategorijaCene(q.QueryRowContext(ctx,
+		`SELECT `+kategorijaCeneColumns+` FROM kategorija_cene WHERE id = $1`, id), &k)
+	return k, err
+}
+
+func (r *kategorijaCeneRepo) Save(ctx context.Context, q DBTX, k *domain.KategorijaCene) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return scanKategorijaCene(q.QueryRowContext(ctx,
+		`INSERT INTO kategorija_cene (id, naziv, mesecna_cena)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (id) DO UPDATE
+		   SET naziv = EXCLUDED.naziv, mesecna_cena = EXCLUDED.mesecna_cena, updated_at = now()
+		 RETURNING `+kategorijaCeneColumns,
+		k.ID, k.Naziv, k.MesecnaCena), k)
+}
+
+func (r *kategorijaCeneRepo) PostaviZaDom(ctx context.Context, q DBTX, domID uuid.UUID, kategorijaID *uuid.UUID) error {
+	if kategorijaID == nil {
+		_, err := q.ExecContext(ctx, `DELETE FROM kategorija_doma WHERE dom_id = $1`, domID)
+		return err
+	}
+	_, err := q.ExecContext(ctx,
+		`UPSERT INTO kategorija_doma (dom_id, kategorija_id) VALUES ($1, $2)`, domID, *kategorijaID)
+	return err
+}
+
+func (r *kategorijaCeneRepo) PostaviZaSobu(ctx context.Context, q DBTX, sobaID uuid.UUID, kategorijaID *uuid.UUID) error {
+	if kategorijaID == nil {
+		_, err := q.ExecContext(ctx, `DELETE FROM kategorija_sobe WHERE soba_id = $1`, sobaID)
+		return err
+	}
+	_, err := q.ExecContext(ctx,
+		`UPSERT INTO kategorija_sobe (soba_id, kategorija_id) VALUES ($1, $2)`, sobaID, *kategorijaID)
+	return err
+}
+
+/* ============ Racuni stanarine ============ */
+
+type RacunRepository interface {
+	// ZaObracun: boravci koji se preklapaju sa [od, do), sa cenom sobe ili, ako je nema, cenom doma.
+	ZaObracun(ctx context.Context, q DBTX, od, do time.Time) ([]domain.BoravakZaObracun, error)
+	// PrviNeobracunat: najraniji mesec koji nije obracunat nekom boravku u sobi sa cenom (zatvorenom
+	// samo ako u tom mesecu ima dana pre iseljenja); nil ako takvog nema.
+	PrviNeobracunat(ctx context.Context, q DBTX) (*time.Time, error)
+	// Create upisuje racun; false ako boravak za taj mesec vec ima racun.
+	Create(ctx context.Context, q DBTX, r *domain.RacunStanarine) (bool, error)
+	Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.RacunStanarine, error)
+	ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.RacunStanarine, error)
+	// ListDospeli: neplaceni racuni kojima je prosao rok, opciono samo za jedan dom.
+	ListDospeli(ctx context.Context, q DBTX, domID *uuid.UUID) ([]domain.RacunStanarine, error)
+	DodajUplatu(ctx context.Context, q DBTX, u *domain.UplataStanarine) error
+	ListUplate(ctx context.Context, q DBTX, racunID uuid.UUID) ([]domain.UplataStanarine, error)
+	// SetPlaceno upisuje ukupno placeno; racun postaje placen kada je placen ceo iznos.
+	SetPlaceno(ctx context.Context, q DBTX, r *domain.RacunStanarine) error
+}
+
+type racunRepo struct{}
+
+func NewRacunRepo() RacunRepository { return &racunRepo{} }
+
+const racunColumns = `r.id, r.boravak_id, r.student_username, r.soba_id, s.broj, s.dom_id, d.naziv,
+	r.period, r.pocetak, r.kraj, r.dana, r.dana_u_mesecu, r.mesecna_cena, r.iznos, r.placeno,
+	r.rok_placanja, r.status, COALESCE(r.placen_at, now())::DATE > r.rok_placanja, r.placen_at, r.created_at`
+
+const racunFrom = ` FROM racun_stanarine r
+	JOIN soba s ON s.id = r.soba_id
+	JOIN dom d ON d.id = s.dom_id`
+
+func scanRacun(row interface{ Scan(...any) error }, r *domain.RacunStanarine) error {
+	return row.Scan(&r.ID, &r.BoravakID, &r.StudentUsername, &r.SobaID, &r.BrojSobe, &r.DomID, &r.Dom,
+		&r.Period, &r.Pocetak, &r.Kraj, &r.Dana, &r.DanaUMesecu, &r.MesecnaCena, &r.Iznos, &r.Placeno,
+		&r.RokPlacanja, &r.Status, &r.Kasni, &r.PlacenAt, &r.CreatedAt)
+}
+
+func (r *racunRepo) ZaObracun(ctx context.Context, q DBTX, od, do time.Time) ([]domain.BoravakZaObracun, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT b.id, b.student_username, b.soba_id, b.useljen_at, b.iseljen_at,
+		        COALESCE(kcs.mesecna_cena, kcd.mesecna_cena)
+		   FROM boravak b
+		   JOIN soba s ON s.id = b.soba_id
+		   LEFT JOIN kategorija_sobe ks ON ks.soba_id = s.id
+		   LEFT JOIN kategorija_cene kcs ON kcs.id = ks.kategorija_id
+		   LEFT JOIN kategorija_doma kd ON kd.dom_id = s.dom_id
+		   LEFT JOIN kategorija_cene kcd ON kcd.id = kd.kategorija_id
+		  WHERE b.useljen_at < $2
+		    AND (b.iseljen_at IS NULL OR b.iseljen_at > $1)
+		  ORDER BY b.useljen_at, b.id`, od, do)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.BoravakZaObracun{}
+	for rows.Next() {
+		var b domain.BoravakZaObracun
+		if err := rows.Scan(&b.BoravakID, &b.StudentUsername, &b.SobaID, &b.UseljenAt, &b.IseljenAt, &b.MesecnaCena); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func (r *racunRepo) PrviNeobracunat(ctx context.Context, q DBTX) (*time.Time, error) {
+	var t sql.NullTime
+	// zatvoren boravak ulazi dok mu neobracunat mesec ima bar jedan dan pre dana iseljenja
+	err := q.QueryRowContext(ctx,
+		`SELECT min(n.od)
+		   FROM (SELECT GREATEST(m.pocetak, COALESCE(m.poslednji + INTERVAL '1 month', m.pocetak)) AS od,
+		                m.useljen_at, m.iseljen_at
+		           FROM (SELECT date_trunc('month', b.useljen_at) AS pocetak,
+		                        (SELECT max(r.period)::TIMESTAMPTZ FROM racun_stanarine r WHERE r.boravak_id = b.id) AS poslednji,
+		                        b.useljen_at, b.iseljen_at
+		                   FROM boravak b
+		                   JOIN soba s ON s.id = b.soba_id
+		                   LEFT JOIN kategorija_sobe ks ON ks.soba_id = s.id
+		                   LEFT JOIN kategorija_doma kd ON kd.dom_id = s.dom_id
+		                  WHERE ks.kategorija_id IS NOT NULL OR kd.kategorija_id IS NOT NULL) m) n
+		  WHERE n.iseljen_at IS NULL
+		     OR GREATEST(n.od, date_trunc('day', n.useljen_at)) < date_trunc('day', n.iseljen_at)`).Scan(&t)
+	if err != nil || !t.Valid {
+		return nil, err
+	}
+	return &t.Time, nil
+}
+
+func (r *racunRepo) Create(ctx context.Context, q DBTX, rc *domain.RacunStanarine) (bool, error) {
+	if rc.ID == uuid.Nil {
+		rc.ID = uuid.New()
+	}
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO racun_stanarine (id, boravak_id, student_username, soba_id, period, pocetak, kraj,
+		                              dana, dana_u_mesecu, mesecna_cena, iznos, placeno, rok_placanja, status, placen_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		 ON CONFLICT (boravak_id, period) DO NOTHING
+		 RETURNING created_at`,
+		rc.ID, rc.BoravakID, rc.StudentUsername, rc.SobaID, rc.Period, rc.Pocetak, rc.Kraj,
+		rc.Dana, rc.DanaUMesecu, rc.MesecnaCena, rc.Iznos, rc.Placeno, rc.RokPlacanja, rc.Status, rc.PlacenAt).
+		Scan(&rc.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *racunRepo) Get(ctx context.Context, q DBTX, id uuid.UUID, forUpdate bool) (domain.RacunStanarine, error) {
+	query := `SELECT ` + racunColumns + racunFrom + ` WHERE r.id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE OF r`
+	}
+	var rc domain.RacunStanarine
+	err := scanRacun(q.QueryRowContext(ctx, query, id), &rc)
+	return rc, err
+}
+
+func (r *racunRepo) ListByStudent(ctx context.Context, q DBTX, studentUsername string) ([]domain.RacunStanarine, error) {
+	return r.list(ctx, q,
+		`SELECT `+racunColumns+racunFrom+`
+		  WHERE r.student_username = $1
+		  ORDER BY r.period DESC, r.pocetak DESC`, studentUsername)
+}
+
+func (r *racunRepo) ListDospeli(ctx context.Context, q DBTX, domID *uuid.UUID) ([]domain.RacunStanarine, error) {
+	return r.list(ctx, q,
+		`SELECT `+racunColumns+racunFrom+`
+		  WHERE r.status = 'otvoren'
+		    AND r.rok_placanja < current_date
+		    AND ($1::UUID IS NULL OR s.dom_id = $1)
+		  ORDER BY d.naziv, s.dom_id, r.student_username, r.period`, domID)
+}
+
+func (r *racunRepo) list(ctx context.Context, q DBTX, query string, args ...any) ([]domain.RacunStanarine, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.RacunStanarine{}
+	for rows.Next() {
+		var rc domain.RacunStanarine
+		if err := scanRacun(rows, &rc); err != nil {
+			return nil, err
+		}
+		out = append(out, rc)
+	}
+	return out, rows.Err()
+}
+
+func (r *racunRepo) DodajUplatu(ctx context.Context, q DBTX, u *domain.UplataStanarine) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return q.QueryRowContext(ctx,
+		`INSERT INTO uplata_stanarine (id, racun_id, iznos, napomena, evidentirao)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING created_at`,
+		u.ID, u.RacunID, u.Iznos, u.Napomena, u.Evidentirao).Scan(&u.CreatedAt)
+}
+
+func (r *racunRepo) ListUplate(ctx context.Context, q DBTX, racunID uuid.UUID) ([]domain.UplataStanarine, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, racun_id, iznos, napomena, evidentirao, created_at
+		   FROM uplata_stanarine
+		  WHERE racun_id = $1
+		  ORDER BY created_at`, racunID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.UplataStanarine{}
+	for rows.Next() {
+		var u domain.UplataStanarine
+		if err := rows.Scan(&u.ID, &u.RacunID, &u.Iznos, &u.Napomena, &u.Evidentirao, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+func (r *racunRepo) SetPlaceno(ctx context.Context, q DBTX, rc *domain.RacunStanarine) error {
+	return q.QueryRowContext(ctx,
+		`UPDATE racun_stanarine
+		    SET placeno = $2::NUMERIC,
+		        status = CASE WHEN $2::NUMERIC >= iznos THEN 'placen' ELSE 'otvoren' END,
+		        placen_at = CASE WHEN $2::NUMERIC >= iznos THEN now() END
+		  WHERE id = $1
+		  RETURNING status, placen_at, COALESCE(placen_at, now())::DATE > rok_placanja`,
+		rc.ID, rc.Placeno).Scan(&rc.Status, &rc.PlacenAt, &rc.Kasni)
+}
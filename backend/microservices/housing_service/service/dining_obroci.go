@@ -0,0 +1,87 @@
+package service
+
+import (
+	"bytes"
+	"common/servicetoken"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"housing/domain"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrDiningNedostupan = errors.New("dining servis nije dostupan")
+
+// DiningObroci je klijent za istoriju obroka stanara u dining servisu; poziva se sa
+// servisnim tokenom, pa dining ne veruje spisku stanara koji salje korisnik.
+type DiningObroci struct {
+	baseURL string
+	tokens  *servicetoken.Client
+	client  *http.Client
+}
+
+func NewDiningObroci(diningBaseURL string, tokens *servicetoken.Client) *DiningObroci {
+	return &DiningObroci{
+		baseURL: strings.TrimRight(diningBaseURL, "/"),
+		tokens:  tokens,
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (c *DiningObroci) Istorija(ctx context.Context, usernames []string) ([]domain.ObrokStanara, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiningNedostupan, err)
+	}
+	body, err := json.Marshal(map[string][]string{"usernames": usernames})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/canteens/meal-history/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiningNedostupan, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status code: %d", ErrDiningNedostupan, resp.StatusCode)
+	}
+
+	out := []domain.ObrokStanara{}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiningNedostupan, err)
+	}
+	return out, nil
+}
+
+// IstorijaObrokaMojeSobe vraca obroke svih stanara sobe u kojoj je student useljen.
+func (s *Services) IstorijaObrokaMojeSobe(ctx context.Context, username string) ([]domain.ObrokStanara, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	st, err := s.Student.GetByUsername(ctx, s.DB, username)
+	if err != nil {
+		return nil, ErrStudentNePostoji
+	}
+	if st.SobaID == nil {
+		return nil, ErrStudentNijeUSobi
+	}
+	stanari, err := s.Student.ListBySoba(ctx, s.DB, *st.SobaID)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, len(stanari))
+	for i, x := range stanari {
+		usernames[i] = x.Username
+	}
+	return s.Obroci.Istorija(ctx, usernames)
+}
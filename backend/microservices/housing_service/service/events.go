@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"housing/domain"
+	"housing/repository"
+)
+
+// HandleEvents obradjuje dogadjaje redom; svaki u svojoj transakciji, zajedno sa
+// upisom u processed_events, pa ponovljena isporuka nema efekta.
+func (s *Services) HandleEvents(ctx context.Context, events []domain.Event) error {
+	for _, e := range events {
+		if err := s.handleEvent(ctx, e); err != nil {
+			return fmt.Errorf("event %s (%s): %w", e.ID, e.Type, err)
+		}
+	}
+	return nil
+}
+
+func (s *Services) handleEvent(ctx context.Context, e domain.Event) (err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	first, err := s.Events.MarkProcessed(ctx, tx, e.ID, e.Type)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return tx.Commit()
+	}
+
+	switch e.Type {
+	case domain.EventUserRegistered:
+		var p domain.UserRegistered
+		if err = json.Unmarshal(e.Payload, &p); err != nil {
+			return err
+		}
+		if err = s.onUserRegistered(ctx, tx, p); err != nil {
+			return err
+		}
+	default:
+		log.Printf("events: ignoring unknown event type %q (%s)", e.Type, e.ID)
+	}
+
+	return tx.Commit()
+}
+
+// onUserRegistered: svaki novi student dobija red u student i studentsku karticu.
+func (s *Services) onUserRegistered(ctx context.Context, q repository.DBTX, p domain.UserRegistered) error {
+	if p.Role != "student" {
+		return nil
+	}
+	st := domain.Student{
+		ID:       p.UserID,
+		Ime:      p.FirstName,
+		Prezime:  p.LastName,
+		Username: p.Username,
+	}
+	if err := s.Student.CreateIfNotExists(ctx, q, &st); err != nil {
+		return err
+	}
+	_, err := s.Kartica.CreateIfNotExistsByUsername(ctx, q, p.Username)
+	return err
+}
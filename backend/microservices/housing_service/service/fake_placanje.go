@@ -0,0 +1,140 @@
+package service
+
+import (
+	"common/money"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FakeSignatureHeader nosi potpis webhook-a laznog provajdera: "t=<unix>,v1=<hex hmac>".
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// fakeSignatureTolerance: stariji potpisi se odbijaju (ponovno slanje snimljene isporuke).
+const fakeSignatureTolerance = 5 * time.Minute
+
+// FakePaymentProvider je lokalni provajder za razvoj: namere cuva u memoriji, a placanje
+// se "zavrsava" pozivom Complete koji pravi potpisan webhook kao pravi provajder.
+type FakePaymentProvider struct {
+	secret      []byte
+	checkoutURL string
+
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+}
+
+type fakeIntent struct {
+	reference string
+	amount    money.Money
+	refunded  bool
+}
+
+func NewFakePaymentProvider(webhookSecret, checkoutURL string) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		secret:      []byte(webhookSecret),
+		checkoutURL: strings.TrimRight(checkoutURL, "/"),
+		intents:     map[string]*fakeIntent{},
+	}
+}
+
+func (f *FakePaymentProvider) Name() string { return "fake" }
+
+func (f *FakePaymentProvider) CreateIntent(_ context.Context, reference string, amount money.Money) (PaymentIntent, error) {
+	id := "fake_pi_" + uuid.NewString()
+	f.mu.Lock()
+	f.intents[id] = &fakeIntent{reference: reference, amount: amount}
+	f.mu.Unlock()
+	return PaymentIntent{ID: id, CheckoutURL: f.checkoutURL + "/" + id}, nil
+}
+
+func (f *FakePaymentProvider) ParseWebhook(payload []byte, header http.Header) (PaymentEvent, error) {
+	var ts, sig string
+	for _, part := range strings.Split(header.Get(FakeSignatureHeader), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return PaymentEvent{}, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > fakeSignatureTolerance || age < -fakeSignatureTolerance {
+		return PaymentEvent{}, ErrInvalidSignature
+	}
+	want, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(want, f.sign(ts, payload)) {
+		return PaymentEvent{}, ErrInvalidSignature
+	}
+
+	var ev PaymentEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return PaymentEvent{}, err
+	}
+	return ev, nil
+}
+
+// Refund ne zahteva da namera bude u memoriji (posle restarta je nema); proverava
+// samo iznos poznate namere.
+func (f *FakePaymentProvider) Refund(_ context.Context, intentID string, amount money.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if in, ok := f.intents[intentID]; ok {
+		if in.amount != amount {
+			return fmt.Errorf("fake provider: refund %s does not match payment %s", amount, in.amount)
+		}
+		in.refunded = true
+	}
+	return nil
+}
+
+// Complete simulira ishod placanja na strani provajdera i vraca potpisanu webhook isporuku.
+func (f *FakePaymentProvider) Complete(intentID string, succeeded bool) ([]byte, http.Header, error) {
+	f.mu.Lock()
+	in, ok := f.intents[intentID]
+	f.mu.Unlock()
+	if !ok {
+		return nil, nil, errors.New("fake provider: unknown payment intent")
+	}
+
+	ev := PaymentEvent{
+		ID:        "fake_evt_" + uuid.NewString(),
+		Type:      PaymentFailed,
+		IntentID:  intentID,
+		Reference: in.reference,
+		Amount:    in.amount,
+	}
+	if succeeded {
+		ev.Type = PaymentSucceeded
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, "t="+ts+",v1="+hex.EncodeToString(f.sign(ts, payload)))
+	return payload, header, nil
+}
+
+func (f *FakePaymentProvider) sign(ts string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
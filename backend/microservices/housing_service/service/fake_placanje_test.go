@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"common/money"
+	"housing/domain"
+	"housing/repository"
+)
+
+/* ============ Transakcije bez baze ============ */
+
+// noopDriver daje *sql.DB ciji BeginTx/Commit/Rollback uspevaju bez baze; upite
+// ne podrzava, pa repozitorijumi u testu moraju biti u memoriji.
+type noopDriver struct{}
+
+func (noopDriver) Open(string) (driver.Conn, error) { return noopConn{}, nil }
+
+type noopConn struct{}
+
+func (noopConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("noop: upiti nisu podrzani")
+}
+func (noopConn) Close() error              { return nil }
+func (noopConn) Begin() (driver.Tx, error) { return noopTx{}, nil }
+
+type noopTx struct{}
+
+func (noopTx) Commit() error   { return nil }
+func (noopTx) Rollback() error { return nil }
+
+var registerNoop sync.Once
+
+func noopDB(t *testing.T) *sql.DB {
+	t.Helper()
+	registerNoop.Do(func() { sql.Register("housing-noop", noopDriver{}) })
+	db, err := sql.Open("housing-noop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+/* ============ Repozitorijumi u memoriji ============ */
+
+type memUplate struct {
+	repository.UplataRepository
+	byID map[uuid.UUID]domain.Uplata
+}
+
+func (m *memUplate) Get(_ context.Context, _ repository.DBTX, id uuid.UUID, _ bool) (domain.Uplata, error) {
+	u, ok := m.byID[id]
+	if !ok {
+		return domain.Uplata{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (m *memUplate) Create(_ context.Context, _ repository.DBTX, u *domain.Uplata) error {
+	m.byID[u.ID] = *u
+	return nil
+}
+
+func (m *memUplate) SetIntent(_ context.Context, _ repository.DBTX, id uuid.UUID, intentID, checkoutURL string) error {
+	u := m.byID[id]
+	u.IntentID, u.CheckoutURL = &intentID, &checkoutURL
+	m.byID[id] = u
+	return nil
+}
+
+func (m *memUplate) SetStatus(_ context.Context, _ repository.DBTX, id uuid.UUID, status domain.StatusUplate) error {
+	u := m.byID[id]
+	u.Status = status
+	m.byID[id] = u
+	return nil
+}
+
+type memKartica struct {
+	repository.StudentskaKarticaRepository
+	stanje  map[string]money.Money
+	knjizio []domain.KarticaTransakcija
+}
+
+func (m *memKartica) GetByStudentUsername(_ context.Context, _ repository.DBTX, username string) (domain.StudentskaKartica, error) {
+	st, ok := m.stanje[username]
+	if !ok {
+		return domain.StudentskaKartica{}, sql.ErrNoRows
+	}
+	return domain.StudentskaKartica{StudentUsername: username, Stanje: st}, nil
+}
+
+func (m *memKartica) Knjizi(ctx context.Context, q repository.DBTX, t *domain.KarticaTransakcija) (domain.StudentskaKartica, error) {
+	st, err := m.stanje[t.StudentUsername].Add(t.Iznos)
+	if err != nil {
+		return domain.StudentskaKartica{}, err
+	}
+	m.stanje[t.StudentUsername] = st
+	m.knjizio = append(m.knjizio, *t)
+	return m.GetByStudentUsername(ctx, q, t.StudentUsername)
+}
+
+/* ============ Tok placanja ============ */
+
+func newFakePlacanje(t *testing.T) (*Services, *FakePaymentProvider, *memKartica) {
+	fake := NewFakePaymentProvider("test-secret", "http://checkout")
+	kartica := &memKartica{stanje: map[string]money.Money{"nikola123": money.RSD(0)}}
+	s := &Services{
+		DB:       noopDB(t),
+		Kartica:  kartica,
+		Uplate:   &memUplate{byID: map[uuid.UUID]domain.Uplata{}},
+		Placanje: fake,
+	}
+	return s, fake, kartica
+}
+
+func TestFakePlacanjeDopunjujeKarticuJednom(t *testing.T) {
+	ctx := context.Background()
+	s, fake, kartica := newFakePlacanje(t)
+
+	u, err := s.ZapocniUplatu(ctx, "nikola123", money.RSD(150000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.IntentID == nil || !strings.HasPrefix(*u.CheckoutURL, "http://checkout/") {
+		t.Fatalf("namera nije otvorena: %+v", u)
+	}
+
+	payload, header, err := fake.Complete(*u.IntentID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ponovljena isporuka istog webhook-a
+	for i := 0; i < 2; i++ {
+		ev, err := fake.ParseWebhook(payload, header)
+		if err != nil {
+			t.Fatalf("isporuka %d: %v", i+1, err)
+		}
+		got, err := s.ObradiPlacanje(ctx, ev)
+		if err != nil {
+			t.Fatalf("isporuka %d: %v", i+1, err)
+		}
+		if got.Status != domain.UplataUspesna {
+			t.Fatalf("isporuka %d: status %s", i+1, got.Status)
+		}
+	}
+
+	if len(kartica.knjizio) != 1 {
+		t.Fatalf("kartica knjizena %d puta, ocekivano 1", len(kartica.knjizio))
+	}
+	if st := kartica.stanje["nikola123"]; st != money.RSD(150000) {
+		t.Errorf("stanje = %s, ocekivano 1500.00", st)
+	}
+}
+
+func TestFakePlacanjeNeuspesno(t *testing.T) {
+	ctx := context.Background()
+	s, fake, kartica := newFakePlacanje(t)
+
+	u, err := s.ZapocniUplatu(ctx, "nikola123", money.RSD(5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, header, err := fake.Complete(*u.IntentID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := fake.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.ObradiPlacanje(ctx, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.UplataNeuspesna || len(kartica.knjizio) != 0 {
+		t.Errorf("status = %s, knjizenja = %d", got.Status, len(kartica.knjizio))
+	}
+}
+
+func TestFakeWebhookPotpis(t *testing.T) {
+	fake := NewFakePaymentProvider("test-secret", "http://checkout")
+	intent, err := fake.CreateIntent(context.Background(), uuid.NewString(), money.RSD(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, header, err := fake.Complete(intent.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  http.Header
+		err     error
+	}{
+		{name: "ispravan", payload: payload, header: header},
+		{name: "bez potpisa", payload: payload, header: http.Header{}, err: ErrInvalidSignature},
+		{name: "izmenjen sadrzaj", payload: []byte(strings.Replace(string(payload), "payment.succeeded", "payment.failed", 1)), header: header, err: ErrInvalidSignature},
+		{name: "drugi kljuc", payload: payload, header: potpisano(NewFakePaymentProvider("drugi", ""), time.Now(), payload), err: ErrInvalidSignature},
+		{name: "zastareo potpis", payload: payload, header: potpisano(fake, time.Now().Add(-fakeSignatureTolerance-time.Minute), payload), err: ErrInvalidSignature},
+		{name: "potpis iz buducnosti", payload: payload, header: potpisano(fake, time.Now().Add(fakeSignatureTolerance+time.Minute), payload), err: ErrInvalidSignature},
+		{name: "unutar tolerancije", payload: payload, header: potpisano(fake, time.Now().Add(-fakeSignatureTolerance+time.Minute), payload)},
+	}
+	for _, tt := range tests {
+		ev, err := fake.ParseWebhook(tt.payload, tt.header)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && ev.IntentID != intent.ID {
+			t.Errorf("%s: intent = %s, want %s", tt.name, ev.IntentID, intent.ID)
+		}
+	}
+}
+
+// potpisano pravi zaglavlje webhook-a potpisano u trenutku ts.
+func potpisano(f *FakePaymentProvider, ts time.Time, payload []byte) http.Header {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	h := http.Header{}
+	h.Set(FakeSignatureHeader, "t="+unix+",v1="+hex.EncodeToString(f.sign(unix, payload)))
+	return h
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"common/money"
 	"housing/domain"
 	"housing/repository"
 )
@@ -15,13 +18,26 @@ import (
 const defaultTimeout = 5 * time.Second
 
 type Services struct {
-	DB      *sql.DB
-	Dom     repository.DomRepository
-	Soba    repository.SobaRepository
-	Student repository.StudentRepository
-	Rec     repository.RecenzijaRepository
-	Kvar    repository.KvarRepository
-	Kartica repository.StudentskaKarticaRepository
+	DB           *sql.DB
+	Dom          repository.DomRepository
+	Soba         repository.SobaRepository
+	Student      repository.StudentRepository
+	Rec          repository.RecenzijaRepository
+	Kvar         repository.KvarRepository
+	Kartica      repository.StudentskaKarticaRepository
+	Naplata      repository.NaplataRepository
+	Events       repository.EventRepository
+	Uplate       repository.UplataRepository
+	Prijave      repository.PrijavaRepository
+	Raspodele    repository.RaspodelaRepository
+	ListaCekanja repository.ListaCekanjaRepository
+	Ponude       repository.PonudaRepository
+	Zahtevi      repository.ZahtevRepository
+	Boravci      repository.BoravakRepository
+	Cene         repository.KategorijaCeneRepository
+	Racuni       repository.RacunRepository
+	Placanje     PaymentProvider
+	Obroci       *DiningObroci
 }
 
 func New(
@@ -32,15 +48,41 @@ func New(
 	rec repository.RecenzijaRepository,
 	kvar repository.KvarRepository,
 	kartica repository.StudentskaKarticaRepository,
+	naplata repository.NaplataRepository,
+	events repository.EventRepository,
+	uplate repository.UplataRepository,
+	prijave repository.PrijavaRepository,
+	raspodele repository.RaspodelaRepository,
+	listaCekanja repository.ListaCekanjaRepository,
+	ponude repository.PonudaRepository,
+	zahtevi repository.ZahtevRepository,
+	boravci repository.BoravakRepository,
+	cene repository.KategorijaCeneRepository,
+	racuni repository.RacunRepository,
+	placanje PaymentProvider,
+	obroci *DiningObroci,
 ) *Services {
 	return &Services{
-		DB:      db,
-		Dom:     dom,
-		Soba:    soba,
-		Student: student,
-		Rec:     rec,
-		Kvar:    kvar,
-		Kartica: kartica,
+		DB:           db,
+		Dom:          dom,
+		Soba:         soba,
+		Student:      student,
+		Rec:          rec,
+		Kvar:         kvar,
+		Kartica:      kartica,
+		Naplata:      naplata,
+		Events:       events,
+		Uplate:       uplate,
+		Prijave:      prijave,
+		Raspodele:    raspodele,
+		ListaCekanja: listaCekanja,
+		Ponude:       ponude,
+		Zahtevi:      zahtevi,
+		Boravci:      boravci,
+		Cene:         cene,
+		Racuni:       racuni,
+		Placanje:     placanje,
+		Obroci:       obroci,
 	}
 }
 
@@ -83,6 +125,12 @@ func (s *Services) CreateStudent(ctx context.Context, ime, prezime, username str
 	return st, nil
 }
 
+var (
+	ErrSobaPopunjena    = errors.New("soba je popunjena (nema slobodnih mesta)")
+	ErrStudentNePostoji = errors.New("student ne postoji")
+	ErrStudentVecUSobi  = errors.New("student je već dodeljen nekoj sobi")
+)
+
 // Upis postojeceg studenta (po username) u sobu
 func (s *Services) UpisiPostojecegStudentaUSobu(ctx context.Context, domID uuid.UUID, brojSobe, username string) (domain.Student, error) {
 	ctx, cancel := ctxTimeout(ctx)
@@ -104,40 +152,74 @@ func (s *Services) UpisiPostojecegStudentaUSobu(ctx context.Context, domID uuid.
 		return domain.Student{}, err
 	}
 
-	// 2) Proveri popunjenost
+	// 2) Useli studenta
+	st, err := s.useli(ctx, tx, soba, username)
+	if err != nil {
+		return domain.Student{}, err
+	}
+
+	// 3) Commit
+	if err = tx.Commit(); err != nil {
+		return domain.Student{}, err
+	}
+	return st, nil
+}
+
+// useli dodeljuje studenta sobi koju je pozivalac zakljucao u transakciji (GetByBroj forUpdate)
+// i zatvara sobu kada se popuni poslednje mesto.
+func (s *Services) useli(ctx context.Context, tx *sql.Tx, soba domain.Soba, username string) (domain.Student, error) {
+	// Proveri popunjenost
 	postojeci, err := s.Student.ListBySoba(ctx, tx, soba.ID)
 	if err != nil {
 		return domain.Student{}, err
 	}
 	if len(postojeci) >= soba.Kapacitet {
-		return domain.Student{}, errors.New("soba je popunjena (nema slobodnih mesta)")
+		return domain.Student{}, ErrSobaPopunjena
 	}
 
-	// 3) Nadji studenta po username
+	// Nadji studenta po username
 	st, err := s.Student.GetByUsername(ctx, tx, username)
 	if err != nil {
-		return domain.Student{}, errors.New("student ne postoji")
+		return domain.Student{}, ErrStudentNePostoji
 	}
 	if st.SobaID != nil {
-		return domain.Student{}, errors.New("student je već dodeljen nekoj sobi")
+		return domain.Student{}, ErrStudentVecUSobi
 	}
 
-	// 4) Povezi
+	// Povezi i otvori boravak
 	if err = s.Student.AssignToSoba(ctx, tx, st.ID, soba.ID); err != nil {
 		return domain.Student{}, err
 	}
+	if err = s.Boravci.Otvori(ctx, tx, st.Username, soba.ID); err != nil {
+		return domain.Student{}, err
+	}
 
-	// 5) Ako je poslednje mesto, zatvori sobu
+	// Ako je poslednje mesto, zatvori sobu
 	if len(postojeci)+1 >= soba.Kapacitet {
 		if err = s.Soba.SetSlobodna(ctx, tx, soba.ID, false); err != nil {
 			return domain.Student{}, err
 		}
 	}
 
-	// 6) Commit
-	if err = tx.Commit(); err != nil {
+	// Useljen student vise ne ceka; njegove otvorene ponude se ponistavaju i mesta nude dalje
+	if err = s.ListaCekanja.OznaciSmestenog(ctx, tx, st.Username); err != nil {
+		return domain.Student{}, err
+	}
+	ponude, err := s.Ponude.ListByStudent(ctx, tx, st.Username)
+	if err != nil {
 		return domain.Student{}, err
 	}
+	for _, p := range ponude {
+		if p.Status != domain.PonudaPonudjena {
+			continue
+		}
+		if err = s.Ponude.SetStatus(ctx, tx, p.ID, domain.PonudaPonistena); err != nil {
+			return domain.Student{}, err
+		}
+		if err = s.ponudiSlobodnaMesta(ctx, tx, p.DomID); err != nil {
+			return domain.Student{}, err
+		}
+	}
 
 	st.SobaID = &soba.ID
 	return st, nil
@@ -169,24 +251,58 @@ func (s *Services) OslobodiSobu(ctx context.Context, studentID uuid.UUID) (err e
 	if err = s.Student.UnassignSoba(ctx, tx, studentID); err != nil {
 		return err
 	}
+	if err = s.Boravci.Zatvori(ctx, tx, st.Username); err != nil {
+		return err
+	}
 
 	soba, err := s.Soba.Get(ctx, tx, *st.SobaID)
 	if err != nil {
 		return err
 	}
-	preostali, err := s.Student.ListBySoba(ctx, tx, soba.ID)
-	if err != nil {
+	if err = s.osveziSlobodnu(ctx, tx, soba); err != nil {
 		return err
 	}
 
-	shouldBeFree := len(preostali) < soba.Kapacitet
-	if err = s.Soba.SetSlobodna(ctx, tx, soba.ID, shouldBeFree); err != nil {
+	// oslobodjeno mesto se nudi sledecem sa liste cekanja doma
+	if err = s.ponudiSlobodnaMesta(ctx, tx, soba.DomID); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
+/* ======================= Boravci ======================= */
+
+// IstorijaSobe: ko je stanovao u sobi; od/do suzavaju na boravke koji se preklapaju sa periodom.
+func (s *Services) IstorijaSobe(ctx context.Context, sobaID uuid.UUID, od, do *time.Time) ([]domain.Boravak, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.Soba.Get(ctx, s.DB, sobaID); err != nil {
+		return nil, err
+	}
+	return s.Boravci.ListBySoba(ctx, s.DB, sobaID, od, do)
+}
+
+func (s *Services) IstorijaStudenta(ctx context.Context, studentUsername string) ([]domain.Boravak, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.Student.GetByUsername(ctx, s.DB, studentUsername); err != nil {
+		return nil, err
+	}
+	return s.Boravci.ListByStudent(ctx, s.DB, studentUsername)
+}
+
+// osveziSlobodnu uskladjuje oznaku slobodna sa brojem studenata u sobi.
+func (s *Services) osveziSlobodnu(ctx context.Context, tx *sql.Tx, soba domain.Soba) error {
+	studenti, err := s.Student.ListBySoba(ctx, tx, soba.ID)
+	if err != nil {
+		return err
+	}
+	return s.Soba.SetSlobodna(ctx, tx, soba.ID, len(studenti) < soba.Kapacitet)
+}
+
 /* ======================= Recenzije ======================= */
 
 func (s *Services) DodajRecenziju(ctx context.Context, sobaID uuid.UUID, autorUsername string, ocena int, komentar *string) (domain.RecenzijaSobe, error) {
@@ -280,10 +396,113 @@ func (s *Services) GetStudentskaKarticaByStudent(ctx context.Context, studentUse
 	return s.Kartica.GetByStudentUsername(ctx, s.DB, studentUsername)
 }
 
-func (s *Services) AzurirajStanjeStudentskeKartice(ctx context.Context, studentUsername string, delta float64) (domain.StudentskaKartica, error) {
+// ErrNeispravnaTransakcija: tip ili znak iznosa ne odgovaraju pravilima knjige.
+var ErrNeispravnaTransakcija = errors.New("neispravna transakcija")
+
+// NedovoljnoSredstavaError: naplata bi oborila stanje kartice ispod nule.
+type NedovoljnoSredstavaError struct {
+	Stanje money.Money
+	Iznos  money.Money
+}
+
+func (e *NedovoljnoSredstavaError) Error() string {
+	return fmt.Sprintf("nedovoljno sredstava: stanje %s, potrebno %s", e.Stanje, e.Iznos)
+}
+
+const (
+	defaultVelicinaStrane = 20
+	maxVelicinaStrane     = 100
+)
+
+// adminReferenca: prefiks referenci rucnih knjizenja, da se ne sudare sa referencama
+// kupovina i uplata koje knjize sami servisi.
+const adminReferenca = "admin:"
+
+// KnjiziNaKarticu upisuje rucno knjizenje administratora i vraca novo stanje. Dozvoljene
+// su samo uplata (pozitivna) i korekcija (bilo kog znaka); naplate obroka i povracaje
+// knjizi iskljucivo tok naplate (naplata.go). Referenca se cuva pod prefiksom "admin:".
+func (s *Services) KnjiziNaKarticu(ctx context.Context, t domain.KarticaTransakcija) (k domain.StudentskaKartica, err error) {
+	switch {
+	case t.Tip != domain.TransakcijaUplata && t.Tip != domain.TransakcijaKorekcija:
+		return domain.StudentskaKartica{}, fmt.Errorf("%w: rucno se knjize samo %s i %s", ErrNeispravnaTransakcija, domain.TransakcijaUplata, domain.TransakcijaKorekcija)
+	case t.Iznos.IsZero(), t.Izvrsio == "":
+		return domain.StudentskaKartica{}, ErrNeispravnaTransakcija
+	case t.Iznos.Currency != money.DefaultCurrency:
+		return domain.StudentskaKartica{}, money.ErrCurrencyMismatch
+	case t.Tip == domain.TransakcijaUplata && t.Iznos.IsNegative():
+		return domain.StudentskaKartica{}, ErrNeispravnaTransakcija
+	}
+	if t.ReferencaID != nil {
+		ref := strings.TrimSpace(*t.ReferencaID)
+		if ref == "" {
+			t.ReferencaID = nil
+		} else {
+			ref = adminReferenca + ref
+			t.ReferencaID = &ref
+		}
+	}
+
 	ctx, cancel := ctxTimeout(ctx)
 	defer cancel()
-	return s.Kartica.UpdateStanjeByUsername(ctx, s.DB, studentUsername, delta)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.StudentskaKartica{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	k, err = s.knjizi(ctx, tx, &t)
+	if err != nil {
+		return domain.StudentskaKartica{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.StudentskaKartica{}, err
+	}
+	return k, nil
+}
+
+// knjizi upisuje transakciju u okviru q; naplata obroka ne sme oboriti stanje ispod nule
+// (uplate, povracaji i korekcije administratora nisu ograniceni).
+func (s *Services) knjizi(ctx context.Context, q repository.DBTX, t *domain.KarticaTransakcija) (domain.StudentskaKartica, error) {
+	if t.Tip == domain.TransakcijaObrok {
+		k, err := s.Kartica.GetForUpdate(ctx, q, t.StudentUsername)
+		if err != nil {
+			return domain.StudentskaKartica{}, err
+		}
+		novo, err := k.Stanje.Add(t.Iznos)
+		if err != nil {
+			return domain.StudentskaKartica{}, err
+		}
+		if novo.IsNegative() {
+			return domain.StudentskaKartica{}, &NedovoljnoSredstavaError{Stanje: k.Stanje, Iznos: t.Iznos.Neg()}
+		}
+	}
+	return s.Kartica.Knjizi(ctx, q, t)
+}
+
+func (s *Services) IstorijaKartice(ctx context.Context, studentUsername string, f domain.TransakcijeFilter) (domain.TransakcijePage, error) {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Size < 1 {
+		f.Size = defaultVelicinaStrane
+	}
+	if f.Size > maxVelicinaStrane {
+		f.Size = maxVelicinaStrane
+	}
+
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	items, total, err := s.Kartica.ListTransakcije(ctx, s.DB, studentUsername, f)
+	if err != nil {
+		return domain.TransakcijePage{}, err
+	}
+	return domain.TransakcijePage{Items: items, Total: total, Page: f.Page, Size: f.Size}, nil
 }
 
 /* ======================= Slobodne sobe ======================= */
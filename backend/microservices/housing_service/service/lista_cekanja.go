@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"housing/domain"
+)
+
+const (
+	// ponudaTrajanje: koliko student ima vremena da prihvati ponudjeno mesto.
+	ponudaTrajanje = 48 * time.Hour
+	ponudeBatch    = 50
+)
+
+var (
+	ErrNijeNaListi        = errors.New("student ne ceka na listi doma")
+	ErrPonudaNijeOtvorena = errors.New("ponuda vise nije otvorena")
+	ErrPonudaIstekla      = errors.New("ponuda je istekla")
+)
+
+// UpisiNaListuCekanja stavlja studenta na kraj liste cekanja doma; ako u domu vec ima
+// slobodnih mesta, ponuda stize odmah.
+func (s *Services) UpisiNaListuCekanja(ctx context.Context, domID uuid.UUID, studentUsername string, tipSobe *int) (domain.ListaCekanjaStavka, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if tipSobe != nil && *tipSobe < 1 {
+		return domain.ListaCekanjaStavka{}, fmt.Errorf("%w: tip sobe je broj kreveta (najmanje 1)", ErrNeispravnaPrijava)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ListaCekanjaStavka{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = s.Dom.Get(ctx, tx, domID); err != nil {
+		return domain.ListaCekanjaStavka{}, err
+	}
+	st, err := s.Student.GetByUsername(ctx, tx, studentUsername)
+	if err != nil {
+		err = ErrStudentNePostoji
+		return domain.ListaCekanjaStavka{}, err
+	}
+	if st.SobaID != nil {
+		err = ErrStudentVecUSobi
+		return domain.ListaCekanjaStavka{}, err
+	}
+
+	l := domain.ListaCekanjaStavka{DomID: domID, StudentUsername: studentUsername, TipSobe: tipSobe}
+	if err = s.ListaCekanja.Upisi(ctx, tx, &l); err != nil {
+		return domain.ListaCekanjaStavka{}, err
+	}
+	if err = s.ponudiSlobodnaMesta(ctx, tx, domID); err != nil {
+		return domain.ListaCekanjaStavka{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.ListaCekanjaStavka{}, err
+	}
+	return l, nil
+}
+
+// OdjaviSaListeCekanja skida studenta sa liste doma; otvorena ponuda iz tog doma se
+// smatra odbijenom i mesto se nudi sledecem.
+func (s *Services) OdjaviSaListeCekanja(ctx context.Context, domID uuid.UUID, studentUsername string) (err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	l, err := s.ListaCekanja.GetByDomStudent(ctx, tx, domID, studentUsername)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && l.Status != domain.CekanjeCeka) {
+		return ErrNijeNaListi
+	}
+	if err != nil {
+		return err
+	}
+	if err = s.ListaCekanja.SetStatus(ctx, tx, l.ID, domain.CekanjeUklonjen); err != nil {
+		return err
+	}
+
+	ponude, err := s.Ponude.ListByStudent(ctx, tx, studentUsername)
+	if err != nil {
+		return err
+	}
+	for _, p := range ponude {
+		if p.ListaID == l.ID && p.Status == domain.PonudaPonudjena {
+			if err = s.Ponude.SetStatus(ctx, tx, p.ID, domain.PonudaOdbijena); err != nil {
+				return err
+			}
+		}
+	}
+	if err = s.ponudiSlobodnaMesta(ctx, tx, domID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Services) ListaCekanjaDoma(ctx context.Context, domID uuid.UUID) ([]domain.ListaCekanjaStavka, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+	return s.ListaCekanja.ListByDom(ctx, s.DB, domID)
+}
+
+func (s *Services) MojaListaCekanja(ctx context.Context, studentUsername string) ([]domain.ListaCekanjaStavka, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+	return s.ListaCekanja.ListByStudent(ctx, s.DB, studentUsername)
+}
+
+func (s *Services) MojePonude(ctx context.Context, studentUsername string) ([]domain.PonudaSobe, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+	return s.Ponude.ListByStudent(ctx, s.DB, studentUsername)
+}
+
+// ponudiSlobodnaMesta svako slobodno mesto u domu (koje nije vec ponudjeno) nudi sledecem
+// studentu sa liste koji prihvata sobu tog kapaciteta. Poziva se u transakciji koja je
+// oslobodila mesto, da ponuda i oslobadjanje budu upisani zajedno.
+func (s *Services) ponudiSlobodnaMesta(ctx context.Context, tx *sql.Tx, domID uuid.UUID) error {
+	sobe, err := s.Soba.ListPopunjenost(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, sb := range sobe {
+		if sb.DomID != domID {
+			continue
+		}
+		for n := sb.SlobodnaMesta(); n > 0; n-- {
+			l, err := s.ListaCekanja.Sledeci(ctx, tx, domID, sb.Kapacitet)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			p := domain.PonudaSobe{
+				ListaID:         l.ID,
+				StudentUsername: l.StudentUsername,
+				SobaID:          sb.ID,
+				IsticeAt:        time.Now().UTC().Add(ponudaTrajanje),
+			}
+			if err := s.Ponude.Create(ctx, tx, &p); err != nil {
+				return err
+			}
+			log.Printf("ponuda %s: soba %s nudi se studentu %s do %s", p.ID, sb.Broj, p.StudentUsername, p.IsticeAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// PrihvatiPonudu useljava studenta u ponudjenu sobu; soba se zakljucava (GetByBroj forUpdate)
+// pa istovremeni upis u istu sobu ne moze da prekoraci kapacitet. Ako je mesto u medjuvremenu
+// popunjeno, ponuda se ponistava, a student ostaje na listi.
+func (s *Services) PrihvatiPonudu(ctx context.Context, id uuid.UUID, studentUsername string) (domain.PonudaSobe, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	p, err := s.otvorenaPonuda(ctx, tx, id, studentUsername)
+	if errors.Is(err, ErrPonudaIstekla) {
+		// istek se upisuje iako ponuda nije prihvacena
+		if err = s.istekni(ctx, tx, p); err != nil {
+			return domain.PonudaSobe{}, err
+		}
+		if err = tx.Commit(); err != nil {
+			return domain.PonudaSobe{}, err
+		}
+		return domain.PonudaSobe{}, ErrPonudaIstekla
+	}
+	if err != nil {
+		return domain.PonudaSobe{}, err
+	}
+
+	soba, err := s.Soba.Get(ctx, tx, p.SobaID)
+	if err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	if soba, err = s.Soba.GetByBroj(ctx, tx, soba.DomID, soba.Broj, true); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+
+	// ponuda se prihvata pre useljenja, da je useli ne bi ponistio kao otvorenu ponudu
+	if err = s.Ponude.SetStatus(ctx, tx, p.ID, domain.PonudaPrihvacena); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	_, uselErr := s.useli(ctx, tx, soba, studentUsername)
+	switch {
+	case errors.Is(uselErr, ErrSobaPopunjena), errors.Is(uselErr, ErrStudentVecUSobi):
+		// mesto je popunjeno mimo ponude ili je student vec useljen; student (ako nije
+		// useljen) ostaje na listi i ceka sledecu ponudu
+		if err = s.Ponude.SetStatus(ctx, tx, p.ID, domain.PonudaPonistena); err != nil {
+			return domain.PonudaSobe{}, err
+		}
+		if err = s.ponudiSlobodnaMesta(ctx, tx, p.DomID); err != nil {
+			return domain.PonudaSobe{}, err
+		}
+		if err = tx.Commit(); err != nil {
+			return domain.PonudaSobe{}, err
+		}
+		return domain.PonudaSobe{}, uselErr
+	case uselErr != nil:
+		err = uselErr
+		return domain.PonudaSobe{}, err
+	}
+
+	if p, err = s.Ponude.Get(ctx, tx, p.ID, false); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	return p, nil
+}
+
+// OdbijPonudu: student odbija mesto i napusta listu doma; mesto se nudi sledecem.
+func (s *Services) OdbijPonudu(ctx context.Context, id uuid.UUID, studentUsername string) (domain.PonudaSobe, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	p, err := s.otvorenaPonuda(ctx, tx, id, studentUsername)
+	if err != nil && !errors.Is(err, ErrPonudaIstekla) {
+		return domain.PonudaSobe{}, err
+	}
+	status := domain.PonudaOdbijena
+	if err != nil {
+		status = domain.PonudaIstekla
+	}
+	if err = s.zatvoriPonudu(ctx, tx, p, status); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	if p, err = s.Ponude.Get(ctx, tx, p.ID, false); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	return p, nil
+}
+
+// otvorenaPonuda zakljucava ponudu studenta; istekla ponuda se vraca uz ErrPonudaIstekla.
+func (s *Services) otvorenaPonuda(ctx context.Context, tx *sql.Tx, id uuid.UUID, studentUsername string) (domain.PonudaSobe, error) {
+	p, err := s.Ponude.Get(ctx, tx, id, true)
+	if err != nil {
+		return domain.PonudaSobe{}, err
+	}
+	if p.StudentUsername != studentUsername {
+		// tudja ponuda se ne otkriva
+		return domain.PonudaSobe{}, sql.ErrNoRows
+	}
+	if p.Status != domain.PonudaPonudjena {
+		return domain.PonudaSobe{}, ErrPonudaNijeOtvorena
+	}
+	if !time.Now().Before(p.IsticeAt) {
+		return p, ErrPonudaIstekla
+	}
+	return p, nil
+}
+
+func (s *Services) istekni(ctx context.Context, tx *sql.Tx, p domain.PonudaSobe) error {
+	return s.zatvoriPonudu(ctx, tx, p, domain.PonudaIstekla)
+}
+
+// zatvoriPonudu: odbijena ili istekla ponuda skida studenta sa liste doma i nudi mesto dalje.
+func (s *Services) zatvoriPonudu(ctx context.Context, tx *sql.Tx, p domain.PonudaSobe, status domain.StatusPonude) error {
+	if err := s.Ponude.SetStatus(ctx, tx, p.ID, status); err != nil {
+		return err
+	}
+	if err := s.ListaCekanja.SetStatus(ctx, tx, p.ListaID, domain.CekanjeUklonjen); err != nil {
+		return err
+	}
+	return s.ponudiSlobodnaMesta(ctx, tx, p.DomID)
+}
+
+// RunIstekPonuda na svakih every zatvara ponude kojima je prosao rok i nudi mesta dalje.
+func (s *Services) RunIstekPonuda(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		istekle, err := s.Ponude.ListIstekle(ctx, s.DB, time.Now().UTC(), ponudeBatch)
+		if err != nil {
+			log.Printf("istek ponuda: %v", err)
+			continue
+		}
+		for _, p := range istekle {
+			if err := s.istekniPonudu(ctx, p.ID); err != nil {
+				log.Printf("ponuda %s: %v", p.ID, err)
+			}
+		}
+	}
+}
+
+func (s *Services) istekniPonudu(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	p, err := s.Ponude.Get(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+	// u medjuvremenu prihvacena ili odbijena
+	if p.Status != domain.PonudaPonudjena || time.Now().Before(p.IsticeAt) {
+		return tx.Commit()
+	}
+	if err = s.istekni(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
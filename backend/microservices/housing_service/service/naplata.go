@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"common/money"
+	"housing/domain"
+)
+
+var (
+	// ErrNaplataPonistena: naplata je vec ponistena (ili ponistenje stiglo pre naplate).
+	ErrNaplataPonistena = errors.New("naplata je ponistena")
+	// ErrNaplataPotvrdjena: potvrdjena naplata se ne moze ponistiti (koristi se povracaj).
+	ErrNaplataPotvrdjena = errors.New("naplata je potvrdjena")
+	// ErrNaplataKonflikt: ista referenca je vec iskoriscena za drugog studenta ili iznos.
+	ErrNaplataKonflikt = errors.New("referenca je vec iskoriscena za drugu naplatu")
+)
+
+// NaplatiObrok skida iznos sa kartice pod referencom kupovine. Ponovljen poziv sa istom
+// referencom vraca postojecu naplatu bez novog zaduzenja.
+func (s *Services) NaplatiObrok(ctx context.Context, n domain.Naplata, izvrsio string) (domain.Naplata, error) {
+	if err := validnaNaplata(n); err != nil {
+		return domain.Naplata{}, err
+	}
+	return s.naplataTx(ctx, func(tx *sql.Tx) (domain.Naplata, error) {
+		postojeca, err := s.Naplata.Get(ctx, tx, n.ReferencaID, true)
+		switch {
+		case err == nil:
+			if postojeca.StudentUsername != n.StudentUsername || postojeca.Iznos != n.Iznos {
+				return domain.Naplata{}, ErrNaplataKonflikt
+			}
+			if postojeca.Status == domain.NaplataPonistena {
+				return domain.Naplata{}, ErrNaplataPonistena
+			}
+			return s.saKarticom(ctx, tx, postojeca)
+		case !errors.Is(err, sql.ErrNoRows):
+			return domain.Naplata{}, err
+		}
+
+		k, err := s.knjizi(ctx, tx, &domain.KarticaTransakcija{
+			StudentUsername: n.StudentUsername,
+			Iznos:           n.Iznos.Neg(),
+			Tip:             domain.TransakcijaObrok,
+			ReferencaID:     &n.ReferencaID,
+			Izvrsio:         izvrsio,
+		})
+		if err != nil {
+			return domain.Naplata{}, err
+		}
+		n.Status = domain.NaplataNaplacena
+		if err := s.Naplata.Create(ctx, tx, &n); err != nil {
+			return domain.Naplata{}, err
+		}
+		n.Kartica = &k
+		return n, nil
+	})
+}
+
+// PotvrdiNaplatu zakljucuje naplatu; posle potvrde vise se ne moze ponistiti.
+func (s *Services) PotvrdiNaplatu(ctx context.Context, referencaID string) (domain.Naplata, error) {
+	return s.naplataTx(ctx, func(tx *sql.Tx) (domain.Naplata, error) {
+		n, err := s.Naplata.Get(ctx, tx, referencaID, true)
+		if err != nil {
+			return domain.Naplata{}, err
+		}
+		switch n.Status {
+		case domain.NaplataPonistena:
+			return domain.Naplata{}, ErrNaplataPonistena
+		case domain.NaplataNaplacena:
+			if err := s.Naplata.SetStatus(ctx, tx, referencaID, domain.NaplataPotvrdjena); err != nil {
+				return domain.Naplata{}, err
+			}
+			n.Status = domain.NaplataPotvrdjena
+		}
+		return s.saKarticom(ctx, tx, n)
+	})
+}
+
+// PonistiNaplatu vraca novac za nepotvrdjenu naplatu. Ako naplata jos nije stigla,
+// upisuje se kao ponistena pa zakasnela naplata sa istom referencom biva odbijena.
+func (s *Services) PonistiNaplatu(ctx context.Context, n domain.Naplata, izvrsio string) (domain.Naplata, error) {
+	if err := validnaNaplata(n); err != nil {
+		return domain.Naplata{}, err
+	}
+	return s.naplataTx(ctx, func(tx *sql.Tx) (domain.Naplata, error) {
+		postojeca, err := s.Naplata.Get(ctx, tx, n.ReferencaID, true)
+		if errors.Is(err, sql.ErrNoRows) {
+			n.Status = domain.NaplataPonistena
+			if err := s.Naplata.Create(ctx, tx, &n); err != nil {
+				return domain.Naplata{}, err
+			}
+			return n, nil
+		}
+		if err != nil {
+			return domain.Naplata{}, err
+		}
+
+		switch postojeca.Status {
+		case domain.NaplataPotvrdjena:
+			return domain.Naplata{}, ErrNaplataPotvrdjena
+		case domain.NaplataNaplacena:
+			if _, err := s.Kartica.Knjizi(ctx, tx, &domain.KarticaTransakcija{
+				StudentUsername: postojeca.StudentUsername,
+				Iznos:           postojeca.Iznos,
+				Tip:             domain.TransakcijaPovracaj,
+				ReferencaID:     &postojeca.ReferencaID,
+				Izvrsio:         izvrsio,
+			}); err != nil {
+				return domain.Naplata{}, err
+			}
+			if err := s.Naplata.SetStatus(ctx, tx, postojeca.ReferencaID, domain.NaplataPonistena); err != nil {
+				return domain.Naplata{}, err
+			}
+			postojeca.Status = domain.NaplataPonistena
+		}
+		return s.saKarticom(ctx, tx, postojeca)
+	})
+}
+
+func validnaNaplata(n domain.Naplata) error {
+	if n.ReferencaID == "" || n.StudentUsername == "" || !n.Iznos.IsPositive() {
+		return ErrNeispravnaTransakcija
+	}
+	if n.Iznos.Currency != money.DefaultCurrency {
+		return money.ErrCurrencyMismatch
+	}
+	return nil
+}
+
+func (s *Services) naplataTx(ctx context.Context, fn func(tx *sql.Tx) (domain.Naplata, error)) (n domain.Naplata, err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Naplata{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if n, err = fn(tx); err != nil {
+		return domain.Naplata{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.Naplata{}, err
+	}
+	return n, nil
+}
+
+func (s *Services) saKarticom(ctx context.Context, q *sql.Tx, n domain.Naplata) (domain.Naplata, error) {
+	k, err := s.Kartica.GetByStudentUsername(ctx, q, n.StudentUsername)
+	if err != nil {
+		return domain.Naplata{}, err
+	}
+	n.Kartica = &k
+	return n, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"common/money"
+	"housing/domain"
+)
+
+// PaymentProvider je spoljni platni provajder preko kog student dopunjuje karticu:
+// otvara nameru placanja, potvrdjuje je potpisanim webhook-om i vraca novac.
+type PaymentProvider interface {
+	Name() string
+	// CreateIntent otvara placanje iznosa; reference se vraca u webhook dogadjaju.
+	CreateIntent(ctx context.Context, reference string, amount money.Money) (PaymentIntent, error)
+	// ParseWebhook proverava potpis isporuke i vraca dogadjaj; ErrInvalidSignature ako potpis ne valja.
+	ParseWebhook(payload []byte, header http.Header) (PaymentEvent, error)
+	// Refund vraca novac za placanje; ponovljen poziv za isto placanje nema efekta.
+	Refund(ctx context.Context, intentID string, amount money.Money) error
+}
+
+type PaymentIntent struct {
+	ID          string
+	CheckoutURL string
+}
+
+type PaymentEventType string
+
+const (
+	PaymentSucceeded PaymentEventType = "payment.succeeded"
+	PaymentFailed    PaymentEventType = "payment.failed"
+)
+
+type PaymentEvent struct {
+	ID        string           `json:"id"`
+	Type      PaymentEventType `json:"type"`
+	IntentID  string           `json:"intentId"`
+	Reference string           `json:"reference"`
+	Amount    money.Money      `json:"amount"`
+}
+
+var (
+	ErrInvalidSignature = errors.New("neispravan potpis webhook-a")
+	// ErrUplataKonflikt: dogadjaj provajdera se ne slaze sa uplatom (druga namera ili iznos).
+	ErrUplataKonflikt = errors.New("dogadjaj se ne slaze sa uplatom")
+	// ErrUplataNijeUspesna: vratiti se moze samo uspesna uplata.
+	ErrUplataNijeUspesna = errors.New("uplata nije uspesna")
+)
+
+// maxUplata: najveca pojedinacna dopuna kartice (100.000,00 RSD).
+var maxUplata = money.RSD(10_000_000)
+
+// ZapocniUplatu otvara dopunu kartice kod provajdera; kartica se dopunjuje tek kada
+// provajder potvrdi placanje (ObradiPlacanje).
+func (s *Services) ZapocniUplatu(ctx context.Context, studentUsername string, iznos money.Money) (domain.Uplata, error) {
+	if iznos.Currency != money.DefaultCurrency {
+		return domain.Uplata{}, money.ErrCurrencyMismatch
+	}
+	if !iznos.IsPositive() || iznos.Minor > maxUplata.Minor {
+		return domain.Uplata{}, ErrNeispravnaTransakcija
+	}
+
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.Kartica.GetByStudentUsername(ctx, s.DB, studentUsername); err != nil {
+		return domain.Uplata{}, err
+	}
+	u := domain.Uplata{
+		ID:              uuid.New(),
+		StudentUsername: studentUsername,
+		Iznos:           iznos,
+		Status:          domain.UplataKreirana,
+		Provajder:       s.Placanje.Name(),
+	}
+	if err := s.Uplate.Create(ctx, s.DB, &u); err != nil {
+		return domain.Uplata{}, err
+	}
+
+	intent, err := s.Placanje.CreateIntent(ctx, u.ID.String(), iznos)
+	if err != nil {
+		if serr := s.Uplate.SetStatus(ctx, s.DB, u.ID, domain.UplataNeuspesna); serr != nil {
+			log.Printf("uplata %s: %v", u.ID, serr)
+		}
+		return domain.Uplata{}, err
+	}
+	if err := s.Uplate.SetIntent(ctx, s.DB, u.ID, intent.ID, intent.CheckoutURL); err != nil {
+		return domain.Uplata{}, err
+	}
+	u.IntentID, u.CheckoutURL = &intent.ID, &intent.CheckoutURL
+	return u, nil
+}
+
+// ObradiPlacanje primenjuje potvrdjen dogadjaj provajdera. Kartica se dopunjuje samo
+// pri prvoj uspesnoj potvrdi; ponovljene isporuke istog ishoda nemaju efekta.
+func (s *Services) ObradiPlacanje(ctx context.Context, ev PaymentEvent) (u domain.Uplata, err error) {
+	id, err := uuid.Parse(ev.Reference)
+	if err != nil {
+		return domain.Uplata{}, sql.ErrNoRows
+	}
+	return s.uplataTx(ctx, id, func(tx *sql.Tx, u *domain.Uplata) error {
+		if u.IntentID == nil || *u.IntentID != ev.IntentID || u.Iznos != ev.Amount {
+			return ErrUplataKonflikt
+		}
+		switch ev.Type {
+		case PaymentSucceeded:
+			// i zakasnela potvrdu posle neuspeha treba knjiziti: novac je naplacen
+			if u.Status != domain.UplataKreirana && u.Status != domain.UplataNeuspesna {
+				return nil
+			}
+			ref := u.ID.String()
+			if _, err := s.Kartica.Knjizi(ctx, tx, &domain.KarticaTransakcija{
+				StudentUsername: u.StudentUsername,
+				Iznos:           u.Iznos,
+				Tip:             domain.TransakcijaUplata,
+				ReferencaID:     &ref,
+				Izvrsio:         u.Provajder,
+			}); err != nil {
+				return err
+			}
+			u.Status = domain.UplataUspesna
+		case PaymentFailed:
+			if u.Status != domain.UplataKreirana {
+				return nil
+			}
+			u.Status = domain.UplataNeuspesna
+		default:
+			log.Printf("uplata %s: ignoring payment event %q (%s)", u.ID, ev.Type, ev.ID)
+			return nil
+		}
+		return s.Uplate.SetStatus(ctx, tx, u.ID, u.Status)
+	})
+}
+
+// VratiUplatu vraca novac uspesne uplate i skida isti iznos sa kartice; ne dozvoljava
+// povracaj vec potrosenog novca.
+func (s *Services) VratiUplatu(ctx context.Context, id uuid.UUID, izvrsio string) (domain.Uplata, error) {
+	return s.uplataTx(ctx, id, func(tx *sql.Tx, u *domain.Uplata) error {
+		switch u.Status {
+		case domain.UplataVracena:
+			return nil
+		case domain.UplataUspesna:
+		default:
+			return ErrUplataNijeUspesna
+		}
+		k, err := s.Kartica.GetForUpdate(ctx, tx, u.StudentUsername)
+		if err != nil {
+			return err
+		}
+		if k.Stanje.Minor < u.Iznos.Minor {
+			return &NedovoljnoSredstavaError{Stanje: k.Stanje, Iznos: u.Iznos}
+		}
+		ref := u.ID.String()
+		if _, err := s.Kartica.Knjizi(ctx, tx, &domain.KarticaTransakcija{
+			StudentUsername: u.StudentUsername,
+			Iznos:           u.Iznos.Neg(),
+			Tip:             domain.TransakcijaKorekcija,
+			ReferencaID:     &ref,
+			Izvrsio:         izvrsio,
+		}); err != nil {
+			return err
+		}
+		// provajder pre commit-a: ako povracaj ne uspe, kartica ostaje netaknuta
+		if err := s.Placanje.Refund(ctx, *u.IntentID, u.Iznos); err != nil {
+			return err
+		}
+		u.Status = domain.UplataVracena
+		return s.Uplate.SetStatus(ctx, tx, u.ID, u.Status)
+	})
+}
+
+// UplataZaNameru vraca uplatu po ID-u namere placanja kod provajdera.
+func (s *Services) UplataZaNameru(ctx context.Context, intentID string) (domain.Uplata, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	return s.Uplate.GetByIntent(ctx, s.DB, intentID)
+}
+
+func (s *Services) UplateStudenta(ctx context.Context, studentUsername string) ([]domain.Uplata, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	return s.Uplate.ListByStudent(ctx, s.DB, studentUsername)
+}
+
+// uplataTx zakljucava uplatu i izvrsava fn u istoj transakciji.
+func (s *Services) uplataTx(ctx context.Context, id uuid.UUID, fn func(tx *sql.Tx, u *domain.Uplata) error) (u domain.Uplata, err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.Uplata{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if u, err = s.Uplate.Get(ctx, tx, id, true); err != nil {
+		return domain.Uplata{}, err
+	}
+	if err = fn(tx, &u); err != nil {
+		return domain.Uplata{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.Uplata{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"housing/domain"
+)
+
+var (
+	// ErrNeispravanZahtev: zahtev za premestaj ili zamenu ne prolazi proveru; poruka nosi razlog.
+	ErrNeispravanZahtev     = errors.New("neispravan zahtev")
+	ErrStudentNijeUSobi     = errors.New("student nije useljen ni u jednu sobu")
+	ErrOtvorenZahtev        = errors.New("student vec ima otvoren zahtev za premestaj ili zamenu")
+	ErrZahtevResen          = errors.New("zahtev je vec resen")
+	ErrZamenaNijePotvrdjena = errors.New("zamenu jos nije potvrdio drugi student")
+	ErrNemaSlobodnogMesta   = errors.New("u domu nema slobodnog mesta")
+	// ErrZahtevZastareo: student vise nije u sobi iz koje je zahtev podnet.
+	ErrZahtevZastareo = errors.New("student vise nije u sobi iz zahteva")
+)
+
+// ZatraziPremestaj: student trazi premestaj u odredjenu sobu ili u bilo koju sobu doma.
+func (s *Services) ZatraziPremestaj(ctx context.Context, studentUsername string, ciljnaSobaID, ciljniDomID *uuid.UUID, obrazlozenje string) (domain.ZahtevPremestaja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if (ciljnaSobaID == nil) == (ciljniDomID == nil) {
+		return domain.ZahtevPremestaja{}, fmt.Errorf("%w: navedite ciljnu sobu ili ciljni dom", ErrNeispravanZahtev)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	st, err := s.studentZaZahtev(ctx, tx, studentUsername)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if ciljnaSobaID != nil {
+		if _, err = s.Soba.Get(ctx, tx, *ciljnaSobaID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("%w: ciljna soba ne postoji", ErrNeispravanZahtev)
+			}
+			return domain.ZahtevPremestaja{}, err
+		}
+		if *ciljnaSobaID == *st.SobaID {
+			err = fmt.Errorf("%w: student je vec u ciljnoj sobi", ErrNeispravanZahtev)
+			return domain.ZahtevPremestaja{}, err
+		}
+	} else if _, err = s.Dom.Get(ctx, tx, *ciljniDomID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("%w: ciljni dom ne postoji", ErrNeispravanZahtev)
+		}
+		return domain.ZahtevPremestaja{}, err
+	}
+
+	z := domain.ZahtevPremestaja{
+		Tip:             domain.ZahtevPremestaj,
+		StudentUsername: studentUsername,
+		SobaID:          *st.SobaID,
+		CiljnaSobaID:    ciljnaSobaID,
+		CiljniDomID:     ciljniDomID,
+		Obrazlozenje:    strings.TrimSpace(obrazlozenje),
+		Status:          domain.ZahtevCekaOdobrenje,
+	}
+	if err = s.Zahtevi.Create(ctx, tx, &z); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	return z, nil
+}
+
+// ZatraziZamenu: student predlaze zamenu soba drugom studentu; zahtev ide adminu tek
+// kada ga drugi student potvrdi.
+func (s *Services) ZatraziZamenu(ctx context.Context, studentUsername, drugiStudent, obrazlozenje string) (domain.ZahtevPremestaja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	drugiStudent = strings.TrimSpace(drugiStudent)
+	if drugiStudent == "" || drugiStudent == studentUsername {
+		return domain.ZahtevPremestaja{}, fmt.Errorf("%w: navedite drugog studenta za zamenu", ErrNeispravanZahtev)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	a, err := s.studentZaZahtev(ctx, tx, studentUsername)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	b, err := s.studentZaZahtev(ctx, tx, drugiStudent)
+	if err != nil {
+		if !errors.Is(err, ErrOtvorenZahtev) {
+			err = fmt.Errorf("%w: %s (%v)", ErrNeispravanZahtev, drugiStudent, err)
+		}
+		return domain.ZahtevPremestaja{}, err
+	}
+	if *a.SobaID == *b.SobaID {
+		err = fmt.Errorf("%w: studenti su u istoj sobi", ErrNeispravanZahtev)
+		return domain.ZahtevPremestaja{}, err
+	}
+
+	z := domain.ZahtevPremestaja{
+		Tip:             domain.ZahtevZamena,
+		StudentUsername: studentUsername,
+		SobaID:          *a.SobaID,
+		DrugiStudent:    &drugiStudent,
+		DrugaSobaID:     b.SobaID,
+		Obrazlozenje:    strings.TrimSpace(obrazlozenje),
+		Status:          domain.ZahtevCekaCimera,
+	}
+	if err = s.Zahtevi.Create(ctx, tx, &z); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	return z, nil
+}
+
+// studentZaZahtev: student mora biti useljen i ne sme imati drugi otvoren zahtev.
+func (s *Services) studentZaZahtev(ctx context.Context, tx *sql.Tx, username string) (domain.Student, error) {
+	st, err := s.Student.GetByUsername(ctx, tx, username)
+	if err != nil {
+		return domain.Student{}, ErrStudentNePostoji
+	}
+	if st.SobaID == nil {
+		return domain.Student{}, ErrStudentNijeUSobi
+	}
+	zahtevi, err := s.Zahtevi.ListByStudent(ctx, tx, username)
+	if err != nil {
+		return domain.Student{}, err
+	}
+	for _, z := range zahtevi {
+		if z.Status.Otvoren() {
+			return domain.Student{}, ErrOtvorenZahtev
+		}
+	}
+	return st, nil
+}
+
+// OdgovoriNaZamenu: drugi student potvrdjuje zamenu (ide adminu) ili je odbija.
+func (s *Services) OdgovoriNaZamenu(ctx context.Context, id uuid.UUID, username string, prihvata bool) (domain.ZahtevPremestaja, error) {
+	return s.resiZahtev(ctx, id, func(z *domain.ZahtevPremestaja) error {
+		if z.DrugiStudent == nil || *z.DrugiStudent != username {
+			return sql.ErrNoRows
+		}
+		if z.Status != domain.ZahtevCekaCimera {
+			return ErrZahtevResen
+		}
+		if prihvata {
+			z.Status = domain.ZahtevCekaOdobrenje
+			return nil
+		}
+		z.Status = domain.ZahtevOdbijen
+		z.Resio = &username
+		return nil
+	})
+}
+
+// PovuciZahtev: podnosilac povlaci zahtev dok nije resen.
+func (s *Services) PovuciZahtev(ctx context.Context, id uuid.UUID, username string) (domain.ZahtevPremestaja, error) {
+	return s.resiZahtev(ctx, id, func(z *domain.ZahtevPremestaja) error {
+		if z.StudentUsername != username {
+			return sql.ErrNoRows
+		}
+		if !z.Status.Otvoren() {
+			return ErrZahtevResen
+		}
+		z.Status = domain.ZahtevPovucen
+		return nil
+	})
+}
+
+func (s *Services) OdbijZahtev(ctx context.Context, id uuid.UUID, admin, razlog string) (domain.ZahtevPremestaja, error) {
+	return s.resiZahtev(ctx, id, func(z *domain.ZahtevPremestaja) error {
+		if !z.Status.Otvoren() {
+			return ErrZahtevResen
+		}
+		z.Status = domain.ZahtevOdbijen
+		z.Resio = &admin
+		if razlog = strings.TrimSpace(razlog); razlog != "" {
+			z.RazlogOdbijanja = &razlog
+		}
+		return nil
+	})
+}
+
+// resiZahtev zakljucava zahtev, primenjuje prelaz i upisuje ga.
+func (s *Services) resiZahtev(ctx context.Context, id uuid.UUID, prelaz func(z *domain.ZahtevPremestaja) error) (domain.ZahtevPremestaja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	z, err := s.Zahtevi.Get(ctx, tx, id, true)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if err = prelaz(&z); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if err = s.Zahtevi.Update(ctx, tx, &z); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	return z, nil
+}
+
+// OdobriZahtev izvrsava premestaj ili zamenu u jednoj transakciji: obe sobe se zakljucavaju
+// (GetByBroj forUpdate, uvek istim redom), menjaju se soba_id studenata i slobodna oznake
+// soba, a mesto oslobodjeno premestajem se nudi listi cekanja doma.
+func (s *Services) OdobriZahtev(ctx context.Context, id uuid.UUID, admin string) (domain.ZahtevPremestaja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	z, err := s.Zahtevi.Get(ctx, tx, id, true)
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	switch z.Status {
+	case domain.ZahtevCekaOdobrenje:
+	case domain.ZahtevCekaCimera:
+		err = ErrZamenaNijePotvrdjena
+		return domain.ZahtevPremestaja{}, err
+	default:
+		err = ErrZahtevResen
+		return domain.ZahtevPremestaja{}, err
+	}
+
+	if z.Tip == domain.ZahtevZamena {
+		err = s.zameni(ctx, tx, z)
+		z.NovaSobaID = z.DrugaSobaID
+	} else {
+		var nova uuid.UUID
+		nova, err = s.premesti(ctx, tx, z)
+		z.NovaSobaID = &nova
+	}
+	if err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+
+	z.Status = domain.ZahtevOdobren
+	z.Resio = &admin
+	if err = s.Zahtevi.Update(ctx, tx, &z); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.ZahtevPremestaja{}, err
+	}
+	return z, nil
+}
+
+func (s *Services) premesti(ctx context.Context, tx *sql.Tx, z domain.ZahtevPremestaja) (uuid.UUID, error) {
+	st, err := s.Student.GetByUsername(ctx, tx, z.StudentUsername)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if st.SobaID == nil || *st.SobaID != z.SobaID {
+		return uuid.Nil, ErrZahtevZastareo
+	}
+
+	// ciljna soba: navedena ili prva u domu sa slobodnim mestom
+	var cilj uuid.UUID
+	if z.CiljnaSobaID != nil {
+		cilj = *z.CiljnaSobaID
+		if cilj == z.SobaID {
+			return uuid.Nil, fmt.Errorf("%w: student je vec u ciljnoj sobi", ErrNeispravanZahtev)
+		}
+	} else {
+		sobe, err := s.Soba.ListPopunjenost(ctx, tx)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		for _, sb := range sobe {
+			if sb.DomID == *z.CiljniDomID && sb.ID != z.SobaID && sb.SlobodnaMesta() > 0 {
+				cilj = sb.ID
+				break
+			}
+		}
+		if cilj == uuid.Nil {
+			return uuid.Nil, ErrNemaSlobodnogMesta
+		}
+	}
+
+	sobe, err := s.zakljucajSobe(ctx, tx, z.SobaID, cilj)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	// mesto pod otvorenom ponudom sa liste cekanja se ne uzima
+	popunjenost, err := s.Soba.ListPopunjenost(ctx, tx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, sb := range popunjenost {
+		if sb.ID == cilj && sb.SlobodnaMesta() == 0 {
+			return uuid.Nil, ErrSobaPopunjena
+		}
+	}
+
+	if err = s.Student.UnassignSoba(ctx, tx, st.ID); err != nil {
+		return uuid.Nil, err
+	}
+	if err = s.Boravci.Zatvori(ctx, tx, st.Username); err != nil {
+		return uuid.Nil, err
+	}
+	if _, err = s.useli(ctx, tx, sobe[cilj], z.StudentUsername); err != nil {
+		return uuid.Nil, err
+	}
+	stara := sobe[z.SobaID]
+	if err = s.osveziSlobodnu(ctx, tx, stara); err != nil {
+		return uuid.Nil, err
+	}
+	if err = s.ponudiSlobodnaMesta(ctx, tx, stara.DomID); err != nil {
+		return uuid.Nil, err
+	}
+	return cilj, nil
+}
+
+func (s *Services) zameni(ctx context.Context, tx *sql.Tx, z domain.ZahtevPremestaja) error {
+	sobe, err := s.zakljucajSobe(ctx, tx, z.SobaID, *z.DrugaSobaID)
+	if err != nil {
+		return err
+	}
+	a, err := s.Student.GetByUsername(ctx, tx, z.StudentUsername)
+	if err != nil {
+		return err
+	}
+	b, err := s.Student.GetByUsername(ctx, tx, *z.DrugiStudent)
+	if err != nil {
+		return err
+	}
+	if a.SobaID == nil || *a.SobaID != z.SobaID || b.SobaID == nil || *b.SobaID != *z.DrugaSobaID {
+		return ErrZahtevZastareo
+	}
+
+	for _, x := range []struct {
+		st   domain.Student
+		nova uuid.UUID
+	}{{a, *z.DrugaSobaID}, {b, z.SobaID}} {
+		if err = s.Student.AssignToSoba(ctx, tx, x.st.ID, x.nova); err != nil {
+			return err
+		}
+		if err = s.Boravci.Zatvori(ctx, tx, x.st.Username); err != nil {
+			return err
+		}
+		if err = s.Boravci.Otvori(ctx, tx, x.st.Username, x.nova); err != nil {
+			return err
+		}
+	}
+	for _, soba := range sobe {
+		if err = s.osveziSlobodnu(ctx, tx, soba); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zakljucajSobe zakljucava sobe po rastucem ID-u, da dve istovremene transakcije nad
+// istim sobama ne bi cekale jedna na drugu.
+func (s *Services) zakljucajSobe(ctx context.Context, tx *sql.Tx, ids ...uuid.UUID) (map[uuid.UUID]domain.Soba, error) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	out := make(map[uuid.UUID]domain.Soba, len(ids))
+	for _, id := range ids {
+		if _, ok := out[id]; ok {
+			continue
+		}
+		soba, err := s.Soba.Get(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if soba, err = s.Soba.GetByBroj(ctx, tx, soba.DomID, soba.Broj, true); err != nil {
+			return nil, err
+		}
+		out[id] = soba
+	}
+	return out, nil
+}
+
+func (s *Services) MojiZahtevi(ctx context.Context, username string) ([]domain.ZahtevPremestaja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+	return s.Zahtevi.ListByStudent(ctx, s.DB, username)
+}
+
+func (s *Services) ZahteviPremestaja(ctx context.Context, status *domain.StatusZahteva) ([]domain.ZahtevPremestaja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+	return s.Zahtevi.List(ctx, s.DB, status)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"housing/domain"
+	"housing/repository"
+)
+
+type memStudenti struct {
+	repository.StudentRepository
+	byUsername map[string]domain.Student
+}
+
+func (m *memStudenti) GetByUsername(_ context.Context, _ repository.DBTX, username string) (domain.Student, error) {
+	st, ok := m.byUsername[username]
+	if !ok {
+		return domain.Student{}, sql.ErrNoRows
+	}
+	return st, nil
+}
+
+type memSobe struct {
+	repository.SobaRepository
+	byID map[uuid.UUID]domain.Soba
+}
+
+func (m *memSobe) Get(_ context.Context, _ repository.DBTX, id uuid.UUID) (domain.Soba, error) {
+	sb, ok := m.byID[id]
+	if !ok {
+		return domain.Soba{}, sql.ErrNoRows
+	}
+	return sb, nil
+}
+
+type memZahtevi struct {
+	repository.ZahtevRepository
+	byID map[uuid.UUID]domain.ZahtevPremestaja
+}
+
+func (m *memZahtevi) Create(_ context.Context, _ repository.DBTX, z *domain.ZahtevPremestaja) error {
+	z.ID = uuid.New()
+	m.byID[z.ID] = *z
+	return nil
+}
+
+func (m *memZahtevi) Get(_ context.Context, _ repository.DBTX, id uuid.UUID, _ bool) (domain.ZahtevPremestaja, error) {
+	z, ok := m.byID[id]
+	if !ok {
+		return domain.ZahtevPremestaja{}, sql.ErrNoRows
+	}
+	return z, nil
+}
+
+func (m *memZahtevi) ListByStudent(_ context.Context, _ repository.DBTX, username string) ([]domain.ZahtevPremestaja, error) {
+	out := []domain.ZahtevPremestaja{}
+	for _, z := range m.byID {
+		if z.StudentUsername == username {
+			out = append(out, z)
+		}
+	}
+	return out, nil
+}
+
+func TestZatraziPremestaj(t *testing.T) {
+	dom := uuid.New()
+	soba101 := domain.Soba{ID: uuid.New(), Broj: "101", Kapacitet: 2, DomID: dom}
+	soba102 := domain.Soba{ID: uuid.New(), Broj: "102", Kapacitet: 2, DomID: dom}
+	nepostojeca := uuid.New()
+
+	tests := []struct {
+		name   string
+		soba   *uuid.UUID
+		dom    *uuid.UUID
+		err    error
+		kreira bool
+	}{
+		{name: "druga soba", soba: &soba102.ID, kreira: true},
+		{name: "trenutna soba", soba: &soba101.ID, err: ErrNeispravanZahtev},
+		{name: "nepostojeca soba", soba: &nepostojeca, err: ErrNeispravanZahtev},
+		{name: "ni soba ni dom", err: ErrNeispravanZahtev},
+		{name: "i soba i dom", soba: &soba102.ID, dom: &dom, err: ErrNeispravanZahtev},
+	}
+	for _, tt := range tests {
+		zahtevi := &memZahtevi{byID: map[uuid.UUID]domain.ZahtevPremestaja{}}
+		s := &Services{
+			DB:      noopDB(t),
+			Soba:    &memSobe{byID: map[uuid.UUID]domain.Soba{soba101.ID: soba101, soba102.ID: soba102}},
+			Student: &memStudenti{byUsername: map[string]domain.Student{"nikola123": {ID: uuid.New(), Username: "nikola123", SobaID: &soba101.ID}}},
+			Zahtevi: zahtevi,
+		}
+
+		_, err := s.ZatraziPremestaj(context.Background(), "nikola123", tt.soba, tt.dom, "")
+		if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if kreiran := len(zahtevi.byID) > 0; kreiran != tt.kreira {
+			t.Errorf("%s: zahtev kreiran = %v, want %v", tt.name, kreiran, tt.kreira)
+		}
+	}
+}
+
+// Zahtev za trenutnu sobu (podnet pre provere) se ne izvrsava: student bi bio iseljen
+// i ponovo useljen u istu sobu.
+func TestOdobriPremestajUTrenutnuSobu(t *testing.T) {
+	soba := domain.Soba{ID: uuid.New(), Broj: "101", Kapacitet: 2, DomID: uuid.New()}
+	z := domain.ZahtevPremestaja{
+		ID: uuid.New(), Tip: domain.ZahtevPremestaj, StudentUsername: "nikola123",
+		SobaID: soba.ID, CiljnaSobaID: &soba.ID, Status: domain.ZahtevCekaOdobrenje,
+	}
+	s := &Services{
+		DB:      noopDB(t),
+		Soba:    &memSobe{byID: map[uuid.UUID]domain.Soba{soba.ID: soba}},
+		Student: &memStudenti{byUsername: map[string]domain.Student{"nikola123": {ID: uuid.New(), Username: "nikola123", SobaID: &soba.ID}}},
+		Zahtevi: &memZahtevi{byID: map[uuid.UUID]domain.ZahtevPremestaja{z.ID: z}},
+	}
+
+	if _, err := s.OdobriZahtev(context.Background(), z.ID, "admin"); !errors.Is(err, ErrNeispravanZahtev) {
+		t.Fatalf("err = %v, want %v", err, ErrNeispravanZahtev)
+	}
+}
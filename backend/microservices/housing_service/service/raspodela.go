@@ -0,0 +1,659 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"housing/domain"
+)
+
+var (
+	// ErrNeispravnaPrijava: podaci prijave ili kriterijuma ne prolaze proveru; poruka nosi razlog.
+	ErrNeispravnaPrijava = errors.New("neispravna prijava")
+	// ErrPrijaveZatvorene: raspodela za godinu je objavljena, prijave se vise ne menjaju.
+	ErrPrijaveZatvorene    = errors.New("raspodela za godinu je objavljena, prijave su zatvorene")
+	ErrNemaKriterijuma     = errors.New("kriterijumi bodovanja za godinu nisu definisani")
+	ErrNemaRaspodele       = errors.New("raspodela za godinu nije pokrenuta")
+	ErrRaspodelaObjavljena = errors.New("raspodela za godinu je vec objavljena")
+	// ErrRaspodelaZastarela: od pokretanja raspodele neko je useljen mimo nje; raspodelu treba pokrenuti ponovo.
+	ErrRaspodelaZastarela = errors.New("stanje soba se promenilo od pokretanja raspodele")
+)
+
+// maxCimera: koliko zeljenih cimera student moze da navede.
+const maxCimera = 3
+
+var skolskaGodinaRe = regexp.MustCompile(`^(\d{4})/(\d{4})$`)
+
+// ProveriSkolskuGodinu prihvata godinu oblika "2025/2026" (druga godina je prva + 1).
+func ProveriSkolskuGodinu(godina string) error {
+	m := skolskaGodinaRe.FindStringSubmatch(godina)
+	if m == nil {
+		return fmt.Errorf("%w: skolska godina mora biti oblika 2025/2026", ErrNeispravnaPrijava)
+	}
+	od, _ := strconv.Atoi(m[1])
+	do, _ := strconv.Atoi(m[2])
+	if do != od+1 {
+		return fmt.Errorf("%w: skolska godina mora obuhvatati dve uzastopne godine", ErrNeispravnaPrijava)
+	}
+	return nil
+}
+
+/* ======================= Prijave ======================= */
+
+// PodnesiPrijavu upisuje prijavu studenta za godinu; ponovna prijava za istu godinu menja
+// postojecu i vraca je u status podneta, sve dok raspodela ne bude objavljena.
+func (s *Services) PodnesiPrijavu(ctx context.Context, p domain.PrijavaZaSmestaj, studentUsername string) (domain.PrijavaZaSmestaj, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	p.StudentUsername = studentUsername
+	if err := proveriPrijavu(&p); err != nil {
+		return domain.PrijavaZaSmestaj{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.PrijavaZaSmestaj{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = s.prijaveOtvorene(ctx, tx, p.SkolskaGodina); err != nil {
+		return domain.PrijavaZaSmestaj{}, err
+	}
+	st, err := s.Student.GetByUsername(ctx, tx, studentUsername)
+	if err != nil {
+		err = ErrStudentNePostoji
+		return domain.PrijavaZaSmestaj{}, err
+	}
+	if st.SobaID != nil {
+		err = ErrStudentVecUSobi
+		return domain.PrijavaZaSmestaj{}, err
+	}
+	for _, c := range p.Cimeri {
+		if _, err = s.Student.GetByUsername(ctx, tx, c); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("%w: cimer %s ne postoji", ErrNeispravnaPrijava, c)
+			}
+			return domain.PrijavaZaSmestaj{}, err
+		}
+	}
+	for _, d := range p.Domovi {
+		if _, err = s.Dom.Get(ctx, tx, d); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("%w: dom %s ne postoji", ErrNeispravnaPrijava, d)
+			}
+			return domain.PrijavaZaSmestaj{}, err
+		}
+	}
+
+	if err = s.Prijave.Save(ctx, tx, &p); err != nil {
+		return domain.PrijavaZaSmestaj{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.PrijavaZaSmestaj{}, err
+	}
+	return p, nil
+}
+
+func proveriPrijavu(p *domain.PrijavaZaSmestaj) error {
+	if err := ProveriSkolskuGodinu(p.SkolskaGodina); err != nil {
+		return err
+	}
+	if p.Prosek < 6 || p.Prosek > 10 {
+		return fmt.Errorf("%w: prosek mora biti izmedju 6 i 10", ErrNeispravnaPrijava)
+	}
+	if p.UdaljenostKm < 0 {
+		return fmt.Errorf("%w: udaljenost ne moze biti negativna", ErrNeispravnaPrijava)
+	}
+	if p.TipSobe != nil && *p.TipSobe < 1 {
+		return fmt.Errorf("%w: tip sobe je broj kreveta (najmanje 1)", ErrNeispravnaPrijava)
+	}
+	p.SocijalniStatus = strings.TrimSpace(p.SocijalniStatus)
+
+	domovi := make([]uuid.UUID, 0, len(p.Domovi))
+	vidjen := map[uuid.UUID]bool{}
+	for _, d := range p.Domovi {
+		if !vidjen[d] {
+			vidjen[d] = true
+			domovi = append(domovi, d)
+		}
+	}
+	p.Domovi = domovi
+
+	cimeri := make([]string, 0, len(p.Cimeri))
+	for _, c := range p.Cimeri {
+		c = strings.TrimSpace(c)
+		if c == "" || c == p.StudentUsername || contains(cimeri, c) {
+			continue
+		}
+		cimeri = append(cimeri, c)
+	}
+	if len(cimeri) > maxCimera {
+		return fmt.Errorf("%w: najvise %d zeljena cimera", ErrNeispravnaPrijava, maxCimera)
+	}
+	if p.TipSobe != nil && len(cimeri) >= *p.TipSobe {
+		return fmt.Errorf("%w: zeljeni cimeri ne staju u sobu od %d kreveta", ErrNeispravnaPrijava, *p.TipSobe)
+	}
+	p.Cimeri = cimeri
+	return nil
+}
+
+// prijaveOtvorene: prijave za godinu se menjaju samo dok raspodela nije objavljena.
+func (s *Services) prijaveOtvorene(ctx context.Context, tx *sql.Tx, godina string) error {
+	r, err := s.Raspodele.Get(ctx, tx, godina, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.Status == domain.RaspodelaObjavljena {
+		return ErrPrijaveZatvorene
+	}
+	return nil
+}
+
+// PovuciPrijavu: student povlaci svoju prijavu pre objave raspodele.
+func (s *Services) PovuciPrijavu(ctx context.Context, id uuid.UUID, studentUsername string) (err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	p, err := s.Prijave.Get(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if p.StudentUsername != studentUsername {
+		// tudja prijava se ne otkriva
+		return sql.ErrNoRows
+	}
+	if err = s.prijaveOtvorene(ctx, tx, p.SkolskaGodina); err != nil {
+		return err
+	}
+	if err = s.Prijave.SetStatus(ctx, tx, id, domain.PrijavaPovucena); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Services) MojePrijave(ctx context.Context, studentUsername string) ([]domain.PrijavaZaSmestaj, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+	return s.Prijave.ListByStudent(ctx, s.DB, studentUsername)
+}
+
+func (s *Services) PrijaveZaGodinu(ctx context.Context, godina string) ([]domain.PrijavaZaSmestaj, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if err := ProveriSkolskuGodinu(godina); err != nil {
+		return nil, err
+	}
+	return s.Prijave.ListByGodina(ctx, s.DB, godina)
+}
+
+/* ======================= Kriterijumi ======================= */
+
+func (s *Services) KriterijumiBodovanja(ctx context.Context, godina string) (domain.KriterijumiBodovanja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if err := ProveriSkolskuGodinu(godina); err != nil {
+		return domain.KriterijumiBodovanja{}, err
+	}
+	k, err := s.Raspodele.GetKriterijumi(ctx, s.DB, godina)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.KriterijumiBodovanja{}, ErrNemaKriterijuma
+	}
+	return k, err
+}
+
+func (s *Services) SacuvajKriterijume(ctx context.Context, k domain.KriterijumiBodovanja, admin string) (domain.KriterijumiBodovanja, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if err := ProveriSkolskuGodinu(k.SkolskaGodina); err != nil {
+		return domain.KriterijumiBodovanja{}, err
+	}
+	if k.TezinaProsek < 0 || k.TezinaUdaljenost < 0 {
+		return domain.KriterijumiBodovanja{}, fmt.Errorf("%w: tezine ne mogu biti negativne", ErrNeispravnaPrijava)
+	}
+	if k.TezinaUdaljenost > 0 && k.MaxUdaljenostKm <= 0 {
+		return domain.KriterijumiBodovanja{}, fmt.Errorf("%w: maxUdaljenostKm mora biti pozitivan", ErrNeispravnaPrijava)
+	}
+	soc := map[string]float64{}
+	for status, b := range k.SocijalniBodovi {
+		status = strings.TrimSpace(status)
+		if status == "" || b < 0 {
+			return domain.KriterijumiBodovanja{}, fmt.Errorf("%w: socijalni status mora imati naziv i nenegativne bodove", ErrNeispravnaPrijava)
+		}
+		soc[status] = b
+	}
+	k.SocijalniBodovi = soc
+	k.UpdatedBy = admin
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.KriterijumiBodovanja{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// posle objave bodovi se vise ne menjaju
+	if err = s.prijaveOtvorene(ctx, tx, k.SkolskaGodina); err != nil {
+		return domain.KriterijumiBodovanja{}, err
+	}
+	if err = s.Raspodele.SaveKriterijumi(ctx, tx, &k); err != nil {
+		return domain.KriterijumiBodovanja{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.KriterijumiBodovanja{}, err
+	}
+	return k, nil
+}
+
+// bodujPrijavu: prosek 6..10 linearno do TezinaProsek, udaljenost linearno do MaxUdaljenostKm
+// (dalje se ne boduje vise) i bodovi socijalnog statusa; zaokruzeno na dve decimale.
+func bodujPrijavu(k domain.KriterijumiBodovanja, p domain.PrijavaZaSmestaj) float64 {
+	b := (p.Prosek - 6) / 4 * k.TezinaProsek
+	if k.MaxUdaljenostKm > 0 {
+		km := math.Min(float64(p.UdaljenostKm), float64(k.MaxUdaljenostKm))
+		b += km / float64(k.MaxUdaljenostKm) * k.TezinaUdaljenost
+	}
+	b += k.SocijalniBodovi[p.SocijalniStatus]
+	return math.Round(b*100) / 100
+}
+
+/* ======================= Raspodela ======================= */
+
+// PokreniRaspodelu rangira prijave za godinu i rasporedjuje ih po slobodnim mestima u sobama.
+// Rezultat je nacrt: studenti se ne useljavaju dok se raspodela ne objavi, pa se moze
+// pokretati ponovo (npr. posle izmene kriterijuma).
+func (s *Services) PokreniRaspodelu(ctx context.Context, godina, admin string) (domain.RezultatRaspodele, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if err := ProveriSkolskuGodinu(godina); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	r, err := s.Raspodele.Get(ctx, tx, godina, true)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		r = domain.Raspodela{SkolskaGodina: godina}
+	case err != nil:
+		return domain.RezultatRaspodele{}, err
+	case r.Status == domain.RaspodelaObjavljena:
+		err = ErrRaspodelaObjavljena
+		return domain.RezultatRaspodele{}, err
+	}
+
+	k, err := s.Raspodele.GetKriterijumi(ctx, tx, godina)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNemaKriterijuma
+		}
+		return domain.RezultatRaspodele{}, err
+	}
+	if err = s.Prijave.ResetRezultate(ctx, tx, godina); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	prijave, err := s.Prijave.ListZaRaspodelu(ctx, tx, godina)
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	sobe, err := s.Soba.ListPopunjenost(ctx, tx)
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+
+	rasporedi(k, prijave, sobe)
+
+	r.Status = domain.RaspodelaNacrt
+	r.Pokrenuo = admin
+	r.PokrenutaAt = time.Now().UTC()
+	r.Primljeno, r.NaListiCekanja = 0, 0
+	for i := range prijave {
+		if prijave[i].Status == domain.PrijavaPrimljena {
+			r.Primljeno++
+		} else {
+			r.NaListiCekanja++
+		}
+		if err = s.Prijave.SetRezultat(ctx, tx, &prijave[i]); err != nil {
+			return domain.RezultatRaspodele{}, err
+		}
+	}
+	if err = s.Raspodele.Save(ctx, tx, &r); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	return s.rezultati(ctx, r)
+}
+
+// rasporedi boduje i rangira prijave (vise bodova, pa veci prosek, pa ranija prijava) i
+// redom ih smesta u sobe sa slobodnim mestima. Soba mora odgovarati zeljama studenta
+// (domovi, tip sobe); medju takvim sobama prednost ima soba u kojoj je vec rasporedjen
+// zeljeni cimer, zatim dom po redosledu zelja i soba u kojoj ima mesta i za cimere koji
+// tek dolaze. Ko ne dobije sobu ide na listu cekanja.
+func rasporedi(k domain.KriterijumiBodovanja, prijave []domain.PrijavaZaSmestaj, sobe []domain.SobaPopunjenost) {
+	for i := range prijave {
+		b := bodujPrijavu(k, prijave[i])
+		prijave[i].Bodovi = &b
+	}
+	sort.SliceStable(prijave, func(i, j int) bool {
+		a, b := prijave[i], prijave[j]
+		if *a.Bodovi != *b.Bodovi {
+			return *a.Bodovi > *b.Bodovi
+		}
+		if a.Prosek != b.Prosek {
+			return a.Prosek > b.Prosek
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+
+	slobodno := make(map[uuid.UUID]int, len(sobe))
+	for _, sb := range sobe {
+		slobodno[sb.ID] = sb.SlobodnaMesta()
+	}
+	prijavljen := make(map[string]bool, len(prijave))
+	for _, p := range prijave {
+		prijavljen[p.StudentUsername] = true
+	}
+	smesten := map[string]uuid.UUID{}
+
+	odgovara := func(p domain.PrijavaZaSmestaj, sb domain.SobaPopunjenost) bool {
+		if slobodno[sb.ID] == 0 {
+			return false
+		}
+		if p.TipSobe != nil && sb.Kapacitet != *p.TipSobe {
+			return false
+		}
+		return len(p.Domovi) == 0 || containsUUID(p.Domovi, sb.DomID)
+	}
+
+	pozicija := 0
+	for i := range prijave {
+		p := &prijave[i]
+		rang := i + 1
+		p.Rang = &rang
+
+		// cimeri koji su na rang listi a jos nisu smesteni trebaju mesto uz studenta
+		potrebno := 1
+		for _, c := range p.Cimeri {
+			if _, ok := smesten[c]; !ok && prijavljen[c] {
+				potrebno++
+			}
+		}
+
+		var izbor *domain.SobaPopunjenost
+		for _, c := range p.Cimeri {
+			sobaID, ok := smesten[c]
+			if !ok {
+				continue
+			}
+			for j := range sobe {
+				if sobe[j].ID == sobaID && odgovara(*p, sobe[j]) {
+					izbor = &sobe[j]
+					break
+				}
+			}
+			if izbor != nil {
+				break
+			}
+		}
+		if izbor == nil {
+			izbor = izaberiSobu(p, sobe, odgovara, slobodno, potrebno)
+		}
+
+		if izbor == nil {
+			pozicija++
+			poz := pozicija
+			p.Status = domain.PrijavaListaCekanja
+			p.PozicijaCekanja = &poz
+			continue
+		}
+		id := izbor.ID
+		slobodno[id]--
+		smesten[p.StudentUsername] = id
+		p.Status = domain.PrijavaPrimljena
+		p.SobaID = &id
+	}
+}
+
+// izaberiSobu trazi sobu po redosledu zeljenih domova (bez zelja: svi domovi redom),
+// birajuci prvu sobu u domu sa dovoljno mesta za studenta i njegove cimere, inace prvu slobodnu.
+func izaberiSobu(p *domain.PrijavaZaSmestaj, sobe []domain.SobaPopunjenost,
+	odgovara func(domain.PrijavaZaSmestaj, domain.SobaPopunjenost) bool,
+	slobodno map[uuid.UUID]int, potrebno int) *domain.SobaPopunjenost {
+
+	domovi := p.Domovi
+	if len(domovi) == 0 {
+		domovi = []uuid.UUID{uuid.Nil}
+	}
+	for _, d := range domovi {
+		var prva *domain.SobaPopunjenost
+		for j := range sobe {
+			sb := &sobe[j]
+			if (d != uuid.Nil && sb.DomID != d) || !odgovara(*p, *sb) {
+				continue
+			}
+			if slobodno[sb.ID] >= potrebno {
+				return sb
+			}
+			if prva == nil {
+				prva = sb
+			}
+		}
+		if prva != nil {
+			return prva
+		}
+	}
+	return nil
+}
+
+// ObjaviRaspodelu useljava primljene studente u dodeljene sobe i cini rezultate javnim.
+// Sve se radi u jednoj transakciji: ako je neka soba u medjuvremenu popunjena ili je student
+// vec useljen, nista se ne upisuje i vraca se ErrRaspodelaZastarela.
+func (s *Services) ObjaviRaspodelu(ctx context.Context, godina, admin string) (domain.RezultatRaspodele, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if err := ProveriSkolskuGodinu(godina); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	r, err := s.Raspodele.Get(ctx, tx, godina, true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNemaRaspodele
+		}
+		return domain.RezultatRaspodele{}, err
+	}
+	if r.Status == domain.RaspodelaObjavljena {
+		err = ErrRaspodelaObjavljena
+		return domain.RezultatRaspodele{}, err
+	}
+
+	prijave, err := s.Prijave.ListByGodina(ctx, tx, godina)
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	for _, p := range prijave {
+		if p.Status != domain.PrijavaPrimljena {
+			continue
+		}
+		if p.SobaID == nil {
+			// soba je obrisana posle pokretanja
+			err = fmt.Errorf("%w: soba za %s vise ne postoji", ErrRaspodelaZastarela, p.StudentUsername)
+			return domain.RezultatRaspodele{}, err
+		}
+		var soba domain.Soba
+		if soba, err = s.Soba.Get(ctx, tx, *p.SobaID); err != nil {
+			return domain.RezultatRaspodele{}, err
+		}
+		if soba, err = s.Soba.GetByBroj(ctx, tx, soba.DomID, soba.Broj, true); err != nil {
+			return domain.RezultatRaspodele{}, err
+		}
+		if _, err = s.useli(ctx, tx, soba, p.StudentUsername); err != nil {
+			if errors.Is(err, ErrSobaPopunjena) || errors.Is(err, ErrStudentVecUSobi) {
+				err = fmt.Errorf("%w: %s (%v)", ErrRaspodelaZastarela, p.StudentUsername, err)
+			}
+			return domain.RezultatRaspodele{}, err
+		}
+	}
+	if err = s.upisiNeprimljene(ctx, tx, prijave); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+
+	now := time.Now().UTC()
+	r.Status = domain.RaspodelaObjavljena
+	r.ObjavljenaAt = &now
+	if err = s.Raspodele.Save(ctx, tx, &r); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	return s.rezultati(ctx, r)
+}
+
+// upisiNeprimljene stavlja studente sa liste cekanja raspodele, po rangu, na liste cekanja
+// zeljenih domova (bez zelja: svih domova), da bi dobili ponudu kada se mesto oslobodi.
+func (s *Services) upisiNeprimljene(ctx context.Context, tx *sql.Tx, prijave []domain.PrijavaZaSmestaj) error {
+	svi, err := s.Dom.GetAll(ctx, tx)
+	if err != nil {
+		return err
+	}
+	sviID := make([]uuid.UUID, len(svi))
+	for i, d := range svi {
+		sviID[i] = d.ID
+	}
+
+	upisani := map[uuid.UUID]bool{}
+	for _, p := range prijave {
+		if p.Status != domain.PrijavaListaCekanja {
+			continue
+		}
+		domovi := p.Domovi
+		if len(domovi) == 0 {
+			domovi = sviID
+		}
+		for _, d := range domovi {
+			l := domain.ListaCekanjaStavka{DomID: d, StudentUsername: p.StudentUsername, TipSobe: p.TipSobe}
+			if err := s.ListaCekanja.Upisi(ctx, tx, &l); err != nil {
+				return err
+			}
+			upisani[d] = true
+		}
+	}
+	for d := range upisani {
+		if err := s.ponudiSlobodnaMesta(ctx, tx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RezultatiRaspodele: admin vidi i nacrt, ostali tek objavljenu raspodelu.
+func (s *Services) RezultatiRaspodele(ctx context.Context, godina string, admin bool) (domain.RezultatRaspodele, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if err := ProveriSkolskuGodinu(godina); err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	r, err := s.Raspodele.Get(ctx, s.DB, godina, false)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !admin && r.Status != domain.RaspodelaObjavljena) {
+		return domain.RezultatRaspodele{}, ErrNemaRaspodele
+	}
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	return s.rezultati(ctx, r)
+}
+
+func (s *Services) rezultati(ctx context.Context, r domain.Raspodela) (domain.RezultatRaspodele, error) {
+	stavke, err := s.Prijave.Rezultati(ctx, s.DB, r.SkolskaGodina)
+	if err != nil {
+		return domain.RezultatRaspodele{}, err
+	}
+	out := domain.RezultatRaspodele{
+		Raspodela:    r,
+		Primljeni:    []domain.StavkaRezultata{},
+		ListaCekanja: []domain.StavkaRezultata{},
+	}
+	for _, x := range stavke {
+		if x.Status == string(domain.PrijavaPrimljena) {
+			out.Primljeni = append(out.Primljeni, x)
+		} else {
+			out.ListaCekanja = append(out.ListaCekanja, x)
+		}
+	}
+	return out, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUUID(list []uuid.UUID, v uuid.UUID) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
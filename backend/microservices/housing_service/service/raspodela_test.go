@@ -0,0 +1,185 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"housing/domain"
+)
+
+var (
+	dom1 = uuid.MustParse("00000000-0000-0000-0000-0000000000d1")
+	dom2 = uuid.MustParse("00000000-0000-0000-0000-0000000000d2")
+)
+
+// kriterijumi: prosek 6..10 nosi do 40 bodova, udaljenost do 100 km do 20.
+var testKriterijumi = domain.KriterijumiBodovanja{
+	TezinaProsek:     40,
+	TezinaUdaljenost: 20,
+	MaxUdaljenostKm:  100,
+	SocijalniBodovi:  map[string]float64{"bez_roditelja": 15},
+}
+
+func TestBodujPrijavu(t *testing.T) {
+	tests := []struct {
+		name string
+		p    domain.PrijavaZaSmestaj
+		want float64
+	}{
+		{name: "minimalni prosek, bez udaljenosti", p: domain.PrijavaZaSmestaj{Prosek: 6}, want: 0},
+		{name: "maksimalni prosek", p: domain.PrijavaZaSmestaj{Prosek: 10}, want: 40},
+		{name: "udaljenost linearno", p: domain.PrijavaZaSmestaj{Prosek: 6, UdaljenostKm: 25}, want: 5},
+		{name: "udaljenost preko maksimuma", p: domain.PrijavaZaSmestaj{Prosek: 6, UdaljenostKm: 400}, want: 20},
+		{name: "socijalni status", p: domain.PrijavaZaSmestaj{Prosek: 8, SocijalniStatus: "bez_roditelja"}, want: 35},
+		{name: "nepoznat socijalni status", p: domain.PrijavaZaSmestaj{Prosek: 8, SocijalniStatus: "nepoznat"}, want: 20},
+		{name: "zaokruzivanje na dve decimale", p: domain.PrijavaZaSmestaj{Prosek: 8.37, UdaljenostKm: 33}, want: 30.3},
+	}
+	for _, tt := range tests {
+		if got := bodujPrijavu(testKriterijumi, tt.p); got != tt.want {
+			t.Errorf("%s: bodovi = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// raspored je ocekivani ishod za studenta: soba (po broju) ili pozicija na listi cekanja.
+type raspored struct {
+	soba    string
+	cekanje int
+}
+
+func TestRasporedi(t *testing.T) {
+	pocetak := time.Date(2026, time.July, 1, 10, 0, 0, 0, time.UTC)
+	prijava := func(username string, prosek float64, km, minut int) domain.PrijavaZaSmestaj {
+		return domain.PrijavaZaSmestaj{
+			ID: uuid.New(), StudentUsername: username, Prosek: prosek, UdaljenostKm: km,
+			CreatedAt: pocetak.Add(time.Duration(minut) * time.Minute),
+		}
+	}
+	saCimerima := func(p domain.PrijavaZaSmestaj, cimeri ...string) domain.PrijavaZaSmestaj {
+		p.Cimeri = cimeri
+		return p
+	}
+	saTipom := func(p domain.PrijavaZaSmestaj, kreveta int) domain.PrijavaZaSmestaj {
+		p.TipSobe = &kreveta
+		return p
+	}
+	saDomovima := func(p domain.PrijavaZaSmestaj, domovi ...uuid.UUID) domain.PrijavaZaSmestaj {
+		p.Domovi = domovi
+		return p
+	}
+	soba := func(broj string, dom uuid.UUID, kapacitet, zauzeto int) domain.SobaPopunjenost {
+		return domain.SobaPopunjenost{
+			Soba:    domain.Soba{ID: uuid.NewSHA1(uuid.NameSpaceURL, []byte("soba:"+broj)), Broj: broj, Kapacitet: kapacitet, DomID: dom},
+			Zauzeto: zauzeto,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		prijave []domain.PrijavaZaSmestaj
+		sobe    []domain.SobaPopunjenost
+		rang    []string // ocekivani redosled rang liste
+		want    map[string]raspored
+	}{
+		{
+			// ana i vuk imaju po 30 bodova: ana ima veci prosek; ana i iva imaju isti prosek,
+			// ana se prijavila ranije
+			name: "isti bodovi: veci prosek, pa ranija prijava",
+			prijave: []domain.PrijavaZaSmestaj{
+				prijava("vuk", 8, 50, 0),
+				prijava("iva", 9, 0, 2),
+				prijava("ana", 9, 0, 1),
+			},
+			sobe: []domain.SobaPopunjenost{soba("101", dom1, 4, 0)},
+			rang: []string{"ana", "iva", "vuk"},
+			want: map[string]raspored{"ana": {soba: "101"}, "iva": {soba: "101"}, "vuk": {soba: "101"}},
+		},
+		{
+			name: "popunjena mesta: ostali na listi cekanja po rangu",
+			prijave: []domain.PrijavaZaSmestaj{
+				prijava("c", 7, 0, 0),
+				prijava("a", 10, 0, 0),
+				prijava("d", 6.5, 0, 0),
+				prijava("b", 9, 0, 0),
+			},
+			sobe: []domain.SobaPopunjenost{soba("101", dom1, 2, 1), soba("102", dom1, 2, 2)},
+			rang: []string{"a", "b", "c", "d"},
+			want: map[string]raspored{"a": {soba: "101"}, "b": {cekanje: 1}, "c": {cekanje: 2}, "d": {cekanje: 3}},
+		},
+		{
+			// 100 ima jedno mesto, pa ana bira 101 gde ima mesta i za cimera; marko bi inace
+			// dobio prvu slobodnu sobu (100), ali ide kod ane
+			name: "zeljeni cimer u istoj sobi",
+			prijave: []domain.PrijavaZaSmestaj{
+				saCimerima(prijava("marko", 9, 0, 0), "ana"),
+				saCimerima(prijava("ana", 10, 0, 0), "marko"),
+				prijava("iva", 8, 0, 0),
+			},
+			sobe: []domain.SobaPopunjenost{soba("100", dom1, 3, 2), soba("101", dom1, 2, 0)},
+			rang: []string{"ana", "marko", "iva"},
+			want: map[string]raspored{"ana": {soba: "101"}, "marko": {soba: "101"}, "iva": {soba: "100"}},
+		},
+		{
+			name: "cimer bez prijave ne zauzima mesto",
+			prijave: []domain.PrijavaZaSmestaj{
+				saCimerima(prijava("ana", 10, 0, 0), "niko"),
+			},
+			sobe: []domain.SobaPopunjenost{soba("101", dom1, 2, 1), soba("102", dom1, 2, 0)},
+			rang: []string{"ana"},
+			want: map[string]raspored{"ana": {soba: "101"}},
+		},
+		{
+			name: "tip sobe: samo sobe sa zeljenim brojem kreveta",
+			prijave: []domain.PrijavaZaSmestaj{
+				saTipom(prijava("ana", 10, 0, 0), 3),
+				saTipom(prijava("vuk", 9, 0, 0), 4),
+				prijava("iva", 8, 0, 0),
+				saTipom(prijava("eva", 7, 0, 0), 3),
+			},
+			sobe: []domain.SobaPopunjenost{soba("101", dom1, 2, 0), soba("201", dom1, 3, 2)},
+			rang: []string{"ana", "vuk", "iva", "eva"},
+			want: map[string]raspored{"ana": {soba: "201"}, "vuk": {cekanje: 1}, "iva": {soba: "101"}, "eva": {cekanje: 2}},
+		},
+		{
+			name: "zeljeni domovi redom",
+			prijave: []domain.PrijavaZaSmestaj{
+				saDomovima(prijava("ana", 10, 0, 0), dom2, dom1),
+				saDomovima(prijava("vuk", 9, 0, 0), dom2, dom1),
+				saDomovima(prijava("iva", 8, 0, 0), dom2),
+			},
+			sobe: []domain.SobaPopunjenost{soba("101", dom1, 2, 0), soba("D2-1", dom2, 1, 0)},
+			rang: []string{"ana", "vuk", "iva"},
+			want: map[string]raspored{"ana": {soba: "D2-1"}, "vuk": {soba: "101"}, "iva": {cekanje: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		rasporedi(testKriterijumi, tt.prijave, tt.sobe)
+
+		brojSobe := map[uuid.UUID]string{}
+		for _, sb := range tt.sobe {
+			brojSobe[sb.ID] = sb.Broj
+		}
+		for i, p := range tt.prijave {
+			if i < len(tt.rang) && p.StudentUsername != tt.rang[i] {
+				t.Errorf("%s: rang %d = %s, want %s", tt.name, i+1, p.StudentUsername, tt.rang[i])
+			}
+			if p.Rang == nil || *p.Rang != i+1 {
+				t.Errorf("%s: %s ima rang %v, want %d", tt.name, p.StudentUsername, p.Rang, i+1)
+			}
+			want := tt.want[p.StudentUsername]
+			switch {
+			case want.soba != "":
+				if p.Status != domain.PrijavaPrimljena || p.SobaID == nil || brojSobe[*p.SobaID] != want.soba || p.PozicijaCekanja != nil {
+					t.Errorf("%s: %s status %s soba %v, want soba %s", tt.name, p.StudentUsername, p.Status, p.SobaID, want.soba)
+				}
+			default:
+				if p.Status != domain.PrijavaListaCekanja || p.SobaID != nil || p.PozicijaCekanja == nil || *p.PozicijaCekanja != want.cekanje {
+					t.Errorf("%s: %s status %s pozicija %v, want lista cekanja %d", tt.name, p.StudentUsername, p.Status, p.PozicijaCekanja, want.cekanje)
+				}
+			}
+		}
+	}
+}
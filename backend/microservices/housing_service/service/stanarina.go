@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"common/money"
+	"housing/domain"
+)
+
+// danRokaPlacanja: racun za mesec dospeva tog dana narednog meseca.
+const danRokaPlacanja = 15
+
+var (
+	// ErrNeispravnaKategorija: kategorija cene ne prolazi proveru; poruka nosi razlog.
+	ErrNeispravnaKategorija = errors.New("neispravna kategorija cene")
+	// ErrPeriodNijeZavrsen: mesec se obracunava tek kada se zavrsi, da bi iseljenja bila uracunata.
+	ErrPeriodNijeZavrsen = errors.New("obracunski mesec jos nije zavrsen")
+	ErrRacunPlacen       = errors.New("racun je vec placen")
+	ErrNeispravnaUplata  = errors.New("neispravna uplata")
+)
+
+/* ======================= Kategorije cena ======================= */
+
+func (s *Services) KategorijeCena(ctx context.Context) ([]domain.KategorijaCene, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	return s.Cene.List(ctx, s.DB)
+}
+
+// SacuvajKategoriju upisuje novu kategoriju (bez ID-a) ili menja postojecu. Nova cena vazi
+// za mesece koji jos nisu obracunati; izdati racuni se ne menjaju.
+func (s *Services) SacuvajKategoriju(ctx context.Context, k domain.KategorijaCene) (domain.KategorijaCene, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	k.Naziv = strings.TrimSpace(k.Naziv)
+	if k.Naziv == "" {
+		return domain.KategorijaCene{}, fmt.Errorf("%w: naziv je obavezan", ErrNeispravnaKategorija)
+	}
+	if !k.MesecnaCena.IsPositive() {
+		return domain.KategorijaCene{}, fmt.Errorf("%w: mesecna cena mora biti pozitivna", ErrNeispravnaKategorija)
+	}
+	if k.MesecnaCena.Currency != money.DefaultCurrency {
+		return domain.KategorijaCene{}, money.ErrCurrencyMismatch
+	}
+	if k.ID != uuid.Nil {
+		if _, err := s.Cene.Get(ctx, s.DB, k.ID); err != nil {
+			return domain.KategorijaCene{}, err
+		}
+	}
+	if err := s.Cene.Save(ctx, s.DB, &k); err != nil {
+		return domain.KategorijaCene{}, err
+	}
+	return k, nil
+}
+
+// PostaviKategorijuDoma dodeljuje domu kategoriju cene; nil uklanja dodelu.
+func (s *Services) PostaviKategorijuDoma(ctx context.Context, domID uuid.UUID, kategorijaID *uuid.UUID) error {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.Dom.Get(ctx, s.DB, domID); err != nil {
+		return err
+	}
+	if err := s.postojiKategorija(ctx, kategorijaID); err != nil {
+		return err
+	}
+	return s.Cene.PostaviZaDom(ctx, s.DB, domID, kategorijaID)
+}
+
+// PostaviKategorijuSobe dodeljuje sobi kategoriju koja vazi umesto kategorije doma; nil uklanja dodelu.
+func (s *Services) PostaviKategorijuSobe(ctx context.Context, sobaID uuid.UUID, kategorijaID *uuid.UUID) error {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.Soba.Get(ctx, s.DB, sobaID); err != nil {
+		return err
+	}
+	if err := s.postojiKategorija(ctx, kategorijaID); err != nil {
+		return err
+	}
+	return s.Cene.PostaviZaSobu(ctx, s.DB, sobaID, kategorijaID)
+}
+
+func (s *Services) postojiKategorija(ctx context.Context, kategorijaID *uuid.UUID) error {
+	if kategorijaID == nil {
+		return nil
+	}
+	_, err := s.Cene.Get(ctx, s.DB, *kategorijaID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: kategorija ne postoji", ErrNeispravnaKategorija)
+	}
+	return err
+}
+
+/* ======================= Mesecni obracun ======================= */
+
+// ObracunajStanarinu izdaje racune za sve boravke u zavrsenom mesecu. Boravci koji su vec
+// obracunati za taj mesec se preskacu, pa se obracun moze ponavljati bez dvostrukog zaduzenja.
+func (s *Services) ObracunajStanarinu(ctx context.Context, period time.Time) (o domain.ObracunStanarine, err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	od := pocetakMeseca(period)
+	do := od.AddDate(0, 1, 0)
+	if do.After(time.Now()) {
+		return domain.ObracunStanarine{}, ErrPeriodNijeZavrsen
+	}
+	o = domain.ObracunStanarine{Period: od, SobeBezCene: []uuid.UUID{}}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.ObracunStanarine{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	boravci, err := s.Racuni.ZaObracun(ctx, tx, od, do)
+	if err != nil {
+		return domain.ObracunStanarine{}, err
+	}
+	for _, b := range boravci {
+		if b.MesecnaCena == nil {
+			if !containsUUID(o.SobeBezCene, b.SobaID) {
+				o.SobeBezCene = append(o.SobeBezCene, b.SobaID)
+			}
+			continue
+		}
+		r, ok := racunZaBoravak(b, od)
+		if !ok {
+			continue
+		}
+		kreiran, err := s.Racuni.Create(ctx, tx, &r)
+		if err != nil {
+			return domain.ObracunStanarine{}, err
+		}
+		if kreiran {
+			o.Kreirano++
+		} else {
+			o.VecObracunato++
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.ObracunStanarine{}, err
+	}
+	return o, nil
+}
+
+// racunZaBoravak racuna stanarinu za deo boravka u mesecu koji pocinje sa od. Naplacuju se dani
+// od dana useljenja do dana iseljenja; boravak krace od jednog dana ne pravi racun.
+func racunZaBoravak(b domain.BoravakZaObracun, od time.Time) (domain.RacunStanarine, bool) {
+	do := od.AddDate(0, 1, 0)
+	pocetak := maxTime(od, dan(b.UseljenAt))
+	kraj := do
+	if b.IseljenAt != nil {
+		kraj = minTime(do, dan(*b.IseljenAt))
+	}
+	dana := brojDana(pocetak, kraj)
+	if dana <= 0 {
+		return domain.RacunStanarine{}, false
+	}
+	danaUMesecu := brojDana(od, do)
+
+	cena := *b.MesecnaCena
+	// zaokruzivanje na najblizu paru
+	iznos := money.Money{
+		Minor:    (cena.Minor*int64(dana)*2 + int64(danaUMesecu)) / (2 * int64(danaUMesecu)),
+		Currency: cena.Currency,
+	}
+
+	r := domain.RacunStanarine{
+		BoravakID:       b.BoravakID,
+		StudentUsername: b.StudentUsername,
+		SobaID:          b.SobaID,
+		Period:          od,
+		Pocetak:         pocetak,
+		Kraj:            kraj,
+		Dana:            dana,
+		DanaUMesecu:     danaUMesecu,
+		MesecnaCena:     cena,
+		Iznos:           iznos,
+		Placeno:         money.RSD(0),
+		RokPlacanja:     do.AddDate(0, 0, danRokaPlacanja-1),
+		Status:          domain.RacunOtvoren,
+	}
+	if iznos.IsZero() {
+		now := time.Now()
+		r.Status = domain.RacunPlacen
+		r.PlacenAt = &now
+	}
+	return r, true
+}
+
+// RunObracunStanarine na svakih every obracunava sve zavrsene mesece od najranijeg neobracunatog
+// boravka, pa i mesece propustene dok servis nije radio. Obracunati boravci se preskacu.
+func (s *Services) RunObracunStanarine(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.obracunajZaostale(ctx, time.Now())
+	}
+}
+
+func (s *Services) obracunajZaostale(ctx context.Context, now time.Time) {
+	od, err := s.Racuni.PrviNeobracunat(ctx, s.DB)
+	if err != nil {
+		log.Printf("obracun stanarine: %v", err)
+		return
+	}
+	if od == nil {
+		return
+	}
+	for _, mesec := range zavrseniMeseci(*od, now) {
+		o, err := s.ObracunajStanarinu(ctx, mesec)
+		if err != nil {
+			// kasniji meseci cekaju da bi obracun isao redom
+			log.Printf("obracun stanarine %s: %v", mesec.Format("2006-01"), err)
+			return
+		}
+		if o.Kreirano > 0 {
+			log.Printf("obracun stanarine %s: %d novih racuna, %d soba bez cene",
+				mesec.Format("2006-01"), o.Kreirano, len(o.SobeBezCene))
+		}
+	}
+}
+
+// zavrseniMeseci: pocetci meseci od meseca u kom je od do poslednjeg zavrsenog pre now.
+func zavrseniMeseci(od, now time.Time) []time.Time {
+	tekuci := pocetakMeseca(now)
+	out := []time.Time{}
+	for m := pocetakMeseca(od); m.Before(tekuci); m = m.AddDate(0, 1, 0) {
+		out = append(out, m)
+	}
+	return out
+}
+
+// pocetakMeseca: prvi dan meseca u UTC, kao i datumi koje prima API.
+func pocetakMeseca(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func dan(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func brojDana(od, do time.Time) int {
+	return int(do.Sub(od).Hours() / 24)
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+/* ======================= Racuni i uplate ======================= */
+
+func (s *Services) MojiRacuni(ctx context.Context, studentUsername string) ([]domain.RacunStanarine, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	return s.Racuni.ListByStudent(ctx, s.DB, studentUsername)
+}
+
+// Racun vraca racun sa uplatama; student vidi samo svoje racune.
+func (s *Services) Racun(ctx context.Context, id uuid.UUID, username string, admin bool) (domain.RacunStanarine, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	r, err := s.Racuni.Get(ctx, s.DB, id, false)
+	if err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	if !admin && r.StudentUsername != username {
+		// tudji racun se ne otkriva
+		return domain.RacunStanarine{}, sql.ErrNoRows
+	}
+	if r.Uplate, err = s.Racuni.ListUplate(ctx, s.DB, id); err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	return r, nil
+}
+
+// EvidentirajUplatu upisuje uplatu po racunu; racun je placen kada zbir uplata dostigne iznos.
+func (s *Services) EvidentirajUplatu(ctx context.Context, racunID uuid.UUID, u domain.UplataStanarine) (r domain.RacunStanarine, err error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	if !u.Iznos.IsPositive() {
+		return domain.RacunStanarine{}, fmt.Errorf("%w: iznos mora biti pozitivan", ErrNeispravnaUplata)
+	}
+	if u.Iznos.Currency != money.DefaultCurrency {
+		return domain.RacunStanarine{}, money.ErrCurrencyMismatch
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	r, err = s.Racuni.Get(ctx, tx, racunID, true)
+	if err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	if r.Status == domain.RacunPlacen {
+		err = ErrRacunPlacen
+		return domain.RacunStanarine{}, err
+	}
+	placeno, err := r.Placeno.Add(u.Iznos)
+	if err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	if c, _ := placeno.Cmp(r.Iznos); c > 0 {
+		err = fmt.Errorf("%w: uplata je veca od preostalog duga (%s)", ErrNeispravnaUplata, preostaloZaUplatu(r))
+		return domain.RacunStanarine{}, err
+	}
+
+	u.RacunID = r.ID
+	if err = s.Racuni.DodajUplatu(ctx, tx, &u); err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	r.Placeno = placeno
+	if err = s.Racuni.SetPlaceno(ctx, tx, &r); err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	if r.Uplate, err = s.Racuni.ListUplate(ctx, tx, r.ID); err != nil {
+		return domain.RacunStanarine{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.RacunStanarine{}, err
+	}
+	return r, nil
+}
+
+/* ======================= Dugovanja ======================= */
+
+// DugovanjaPoDomovima: dospeli neplaceni racuni grupisani po domu i studentu; domovi bez duga se izostavljaju.
+func (s *Services) DugovanjaPoDomovima(ctx context.Context) ([]domain.DugovanjaDoma, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	racuni, err := s.Racuni.ListDospeli(ctx, s.DB, nil)
+	if err != nil {
+		return nil, err
+	}
+	return grupisiDugovanja(racuni), nil
+}
+
+func (s *Services) DugovanjaDoma(ctx context.Context, domID uuid.UUID) (domain.DugovanjaDoma, error) {
+	ctx, cancel := ctxTimeout(ctx)
+	defer cancel()
+
+	d, err := s.Dom.Get(ctx, s.DB, domID)
+	if err != nil {
+		return domain.DugovanjaDoma{}, err
+	}
+	racuni, err := s.Racuni.ListDospeli(ctx, s.DB, &domID)
+	if err != nil {
+		return domain.DugovanjaDoma{}, err
+	}
+	if g := grupisiDugovanja(racuni); len(g) > 0 {
+		return g[0], nil
+	}
+	return domain.DugovanjaDoma{DomID: d.ID, Dom: d.Naziv, Ukupno: money.RSD(0), Studenti: []domain.DugovanjeStudenta{}}, nil
+}
+
+// grupisiDugovanja ocekuje racune poredjane po domu pa po studentu.
+func grupisiDugovanja(racuni []domain.RacunStanarine) []domain.DugovanjaDoma {
+	out := []domain.DugovanjaDoma{}
+	for _, r := range racuni {
+		if len(out) == 0 || out[len(out)-1].DomID != r.DomID {
+			out = append(out, domain.DugovanjaDoma{DomID: r.DomID, Dom: r.Dom, Ukupno: money.RSD(0), Studenti: []domain.DugovanjeStudenta{}})
+		}
+		d := &out[len(out)-1]
+		if len(d.Studenti) == 0 || d.Studenti[len(d.Studenti)-1].StudentUsername != r.StudentUsername {
+			d.Studenti = append(d.Studenti, domain.DugovanjeStudenta{StudentUsername: r.StudentUsername, Dug: money.RSD(0)})
+		}
+		st := &d.Studenti[len(d.Studenti)-1]
+
+		preostalo := preostaloZaUplatu(r)
+		st.Dug = money.RSD(st.Dug.Minor + preostalo.Minor)
+		st.Racuni = append(st.Racuni, r)
+		d.Ukupno = money.RSD(d.Ukupno.Minor + preostalo.Minor)
+	}
+	return out
+}
+
+func preostaloZaUplatu(r domain.RacunStanarine) money.Money {
+	return money.RSD(r.Iznos.Minor - r.Placeno.Minor)
+}
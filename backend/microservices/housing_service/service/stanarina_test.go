@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"common/money"
+	"housing/domain"
+	"housing/repository"
+)
+
+func datum(y int, m time.Month, d, h int) time.Time {
+	return time.Date(y, m, d, h, 0, 0, 0, time.UTC)
+}
+
+func TestRacunZaBoravak(t *testing.T) {
+	septembar := datum(2026, time.September, 1, 0)
+	februar := datum(2028, time.February, 1, 0) // prestupna godina
+	cena := money.RSD(1_000_000)                // 10.000,00 RSD
+	premestaj := datum(2026, time.September, 15, 10)
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name        string
+		period      time.Time
+		useljen     time.Time
+		iseljen     *time.Time
+		cena        money.Money
+		ok          bool
+		dana        int
+		danaUMesecu int
+		iznos       money.Money
+	}{
+		{
+			name: "ceo mesec", period: septembar,
+			useljen: datum(2026, time.June, 3, 12), cena: cena,
+			ok: true, dana: 30, danaUMesecu: 30, iznos: cena,
+		},
+		{
+			name: "dan useljenja se naplacuje, dan iseljenja ne", period: septembar,
+			useljen: datum(2026, time.September, 10, 23), iseljen: ptr(datum(2026, time.September, 20, 1)), cena: cena,
+			ok: true, dana: 10, danaUMesecu: 30, iznos: money.RSD(333_333),
+		},
+		{
+			name: "iseljenje posle kraja meseca", period: septembar,
+			useljen: datum(2026, time.September, 30, 8), iseljen: ptr(datum(2026, time.October, 5, 8)), cena: cena,
+			ok: true, dana: 1, danaUMesecu: 30, iznos: money.RSD(33_333),
+		},
+		{
+			name: "zaokruzivanje navise na najblizu paru", period: septembar,
+			useljen: datum(2026, time.September, 29, 8), cena: cena,
+			ok: true, dana: 2, danaUMesecu: 30, iznos: money.RSD(66_667),
+		},
+		{
+			name: "pola pare se zaokruzuje navise", period: septembar,
+			useljen: datum(2026, time.September, 30, 8), cena: money.RSD(15),
+			ok: true, dana: 1, danaUMesecu: 30, iznos: money.RSD(1),
+		},
+		{
+			name: "premestaj: stari boravak do dana premestaja", period: septembar,
+			useljen: datum(2026, time.August, 1, 0), iseljen: ptr(premestaj), cena: cena,
+			ok: true, dana: 14, danaUMesecu: 30, iznos: money.RSD(466_667),
+		},
+		{
+			name: "premestaj: novi boravak od dana premestaja", period: septembar,
+			useljen: premestaj, cena: cena,
+			ok: true, dana: 16, danaUMesecu: 30, iznos: money.RSD(533_333),
+		},
+		{
+			name: "februar prestupne godine", period: februar,
+			useljen: datum(2028, time.February, 15, 9), cena: money.RSD(2_900_000),
+			ok: true, dana: 15, danaUMesecu: 29, iznos: money.RSD(1_500_000),
+		},
+		{
+			name: "useljenje i iseljenje istog dana", period: septembar,
+			useljen: datum(2026, time.September, 10, 8), iseljen: ptr(datum(2026, time.September, 10, 20)), cena: cena,
+		},
+		{
+			name: "iseljenje prvog dana meseca", period: septembar,
+			useljen: datum(2026, time.August, 10, 8), iseljen: ptr(datum(2026, time.September, 1, 9)), cena: cena,
+		},
+	}
+	for _, tt := range tests {
+		c := tt.cena
+		r, ok := racunZaBoravak(domain.BoravakZaObracun{
+			BoravakID: uuid.New(), StudentUsername: "nikola123", SobaID: uuid.New(),
+			UseljenAt: tt.useljen, IseljenAt: tt.iseljen, MesecnaCena: &c,
+		}, tt.period)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if r.Dana != tt.dana || r.DanaUMesecu != tt.danaUMesecu || r.Iznos != tt.iznos {
+			t.Errorf("%s: dana %d/%d iznos %s, want %d/%d %s",
+				tt.name, r.Dana, r.DanaUMesecu, r.Iznos, tt.dana, tt.danaUMesecu, tt.iznos)
+		}
+		if got := r.Kraj.Sub(r.Pocetak).Hours() / 24; int(got) != r.Dana {
+			t.Errorf("%s: pocetak %s, kraj %s ne odgovaraju broju dana %d", tt.name, r.Pocetak, r.Kraj, r.Dana)
+		}
+		rok := tt.period.AddDate(0, 1, danRokaPlacanja-1)
+		if !r.RokPlacanja.Equal(rok) || r.Status != domain.RacunOtvoren || !r.Placeno.IsZero() {
+			t.Errorf("%s: rok %s status %s placeno %s", tt.name, r.RokPlacanja, r.Status, r.Placeno)
+		}
+	}
+}
+
+func TestZavrseniMeseci(t *testing.T) {
+	got := zavrseniMeseci(datum(2026, time.November, 20, 5), datum(2027, time.February, 1, 0))
+	want := []time.Time{datum(2026, time.November, 1, 0), datum(2026, time.December, 1, 0), datum(2027, time.January, 1, 0)}
+	if len(got) != len(want) {
+		t.Fatalf("zavrseniMeseci = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("mesec %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if got := zavrseniMeseci(datum(2027, time.February, 3, 0), datum(2027, time.February, 20, 0)); len(got) != 0 {
+		t.Errorf("tekuci mesec se ne obracunava: %v", got)
+	}
+}
+
+type memRacuni struct {
+	repository.RacunRepository
+	boravci []domain.BoravakZaObracun
+	racuni  map[string]domain.RacunStanarine
+}
+
+func (m *memRacuni) PrviNeobracunat(context.Context, repository.DBTX) (*time.Time, error) {
+	var prvi *time.Time
+	for _, b := range m.boravci {
+		od := pocetakMeseca(b.UseljenAt)
+		for {
+			if _, ok := m.racuni[b.BoravakID.String()+od.Format("2006-01")]; !ok {
+				break
+			}
+			od = od.AddDate(0, 1, 0)
+		}
+		if b.IseljenAt != nil && !maxTime(od, dan(b.UseljenAt)).Before(dan(*b.IseljenAt)) {
+			continue
+		}
+		if prvi == nil || od.Before(*prvi) {
+			prvi = &od
+		}
+	}
+	return prvi, nil
+}
+
+func (m *memRacuni) ZaObracun(_ context.Context, _ repository.DBTX, od, do time.Time) ([]domain.BoravakZaObracun, error) {
+	out := []domain.BoravakZaObracun{}
+	for _, b := range m.boravci {
+		if b.UseljenAt.Before(do) && (b.IseljenAt == nil || b.IseljenAt.After(od)) {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRacuni) Create(_ context.Context, _ repository.DBTX, r *domain.RacunStanarine) (bool, error) {
+	k := r.BoravakID.String() + r.Period.Format("2006-01")
+	if _, ok := m.racuni[k]; ok {
+		return false, nil
+	}
+	m.racuni[k] = *r
+	return true, nil
+}
+
+// Servis koji nije radio preko granice meseca obracunava sve propustene mesece, svaki jednom.
+func TestObracunZaostalihMeseci(t *testing.T) {
+	cena := money.RSD(900_000)
+	tekuci := pocetakMeseca(time.Now())
+	useljen := tekuci.AddDate(0, -4, 9)
+	iseljen := tekuci.AddDate(0, -2, 10)
+	racuni := &memRacuni{
+		boravci: []domain.BoravakZaObracun{
+			{BoravakID: uuid.New(), StudentUsername: "nikola123", SobaID: uuid.New(), UseljenAt: useljen, MesecnaCena: &cena},
+			{BoravakID: uuid.New(), StudentUsername: "marko123", SobaID: uuid.New(), UseljenAt: useljen, IseljenAt: &iseljen, MesecnaCena: &cena},
+		},
+		racuni: map[string]domain.RacunStanarine{},
+	}
+	s := &Services{DB: noopDB(t), Racuni: racuni}
+
+	for i := 0; i < 2; i++ {
+		s.obracunajZaostale(context.Background(), time.Now())
+	}
+
+	meseci := zavrseniMeseci(useljen, time.Now())
+	if len(meseci) != 4 {
+		t.Fatalf("ocekivana 4 zavrsena meseca, dobijeno %d", len(meseci))
+	}
+	for _, b := range racuni.boravci {
+		for _, m := range meseci {
+			_, ima := racuni.racuni[b.BoravakID.String()+m.Format("2006-01")]
+			ocekivan := b.IseljenAt == nil || m.Before(*b.IseljenAt)
+			if ima != ocekivan {
+				t.Errorf("%s %s: racun %v, ocekivan %v", b.StudentUsername, m.Format("2006-01"), ima, ocekivan)
+			}
+		}
+	}
+	if n := len(racuni.racuni); n != 4+3 {
+		t.Errorf("izdato %d racuna, ocekivano 7", n)
+	}
+}
+
+// Boravak zatvoren usred meseca dobija racun za poslednji mesec iako je jedini otvoren
+// boravak useljen tek posle toga.
+func TestObracunZatvorenogBoravka(t *testing.T) {
+	cena := money.RSD(900_000)
+	tekuci := pocetakMeseca(time.Now())
+	useljen := tekuci.AddDate(0, -3, 0)
+	iseljen := tekuci.AddDate(0, -2, 14)
+	kasnije := tekuci.AddDate(0, -1, 4)
+	stari := domain.BoravakZaObracun{BoravakID: uuid.New(), StudentUsername: "marko123", SobaID: uuid.New(),
+		UseljenAt: useljen, IseljenAt: &iseljen, MesecnaCena: &cena}
+	novi := domain.BoravakZaObracun{BoravakID: uuid.New(), StudentUsername: "nikola123", SobaID: uuid.New(),
+		UseljenAt: kasnije, MesecnaCena: &cena}
+	racuni := &memRacuni{
+		boravci: []domain.BoravakZaObracun{stari, novi},
+		racuni: map[string]domain.RacunStanarine{
+			stari.BoravakID.String() + useljen.Format("2006-01"): {},
+		},
+	}
+	s := &Services{DB: noopDB(t), Racuni: racuni}
+
+	od, _ := racuni.PrviNeobracunat(context.Background(), nil)
+	if od == nil || !od.Equal(pocetakMeseca(iseljen)) {
+		t.Fatalf("prvi neobracunat = %v, want %s", od, pocetakMeseca(iseljen))
+	}
+
+	s.obracunajZaostale(context.Background(), time.Now())
+
+	r, ok := racuni.racuni[stari.BoravakID.String()+iseljen.Format("2006-01")]
+	if !ok {
+		t.Fatal("nema racuna za mesec iseljenja")
+	}
+	if r.Dana != 14 {
+		t.Errorf("racun za mesec iseljenja: %d dana, want 14", r.Dana)
+	}
+	if _, ok := racuni.racuni[novi.BoravakID.String()+kasnije.Format("2006-01")]; !ok {
+		t.Error("nema racuna za novi boravak")
+	}
+
+	// posle obracuna zatvoren boravak vise ne drzi najraniji mesec
+	if od, _ := racuni.PrviNeobracunat(context.Background(), nil); od == nil || !od.Equal(tekuci) {
+		t.Errorf("prvi neobracunat posle obracuna = %v, want %s", od, tekuci)
+	}
+}
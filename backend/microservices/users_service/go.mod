@@ -9,3 +9,7 @@ require (
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.25.0
 )
+
+require common v0.0.0
+
+replace common => ../common
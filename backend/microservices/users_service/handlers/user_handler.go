@@ -3,11 +3,17 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 
+	"common/middleware"
 	"users_module/models"
 	"users_module/services"
 
+	"github.com/google/uuid"
+
 	"github.com/gorilla/mux"
 )
 
@@ -44,13 +50,20 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		httpError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
+	req.ClientIP = clientIP(r)
 	resp, err := h.Svc.Login(r.Context(), req) // prosleđujemo ctx
 	if err != nil {
+		var locked *services.LockedError
 		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			httpError(w, http.StatusTooManyRequests, "too many failed login attempts")
 		case errors.Is(err, services.ErrInvalidCredentials):
 			httpError(w, http.StatusUnauthorized, "invalid credentials")
 		case errors.Is(err, services.ErrUserDisabled):
 			httpError(w, http.StatusForbidden, "user disabled")
+		case errors.Is(err, services.ErrEmailNotVerified):
+			httpError(w, http.StatusForbidden, "email not verified")
 		default:
 			httpError(w, http.StatusBadRequest, err.Error())
 		}
@@ -59,19 +72,380 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func (a *AuthHandler) GetUser(rw http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["username"]
+// LoginMFA: drugi korak prijave {challenge_token, code | recovery_code}
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.ClientIP = clientIP(r)
+	resp, err := h.Svc.LoginMFA(r.Context(), req)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// LoginMFASetup vraca tajni kljuc i otpauth URI za nalog kome je 2FA obavezan.
+func (h *AuthHandler) LoginMFASetup(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.ClientIP = clientIP(r)
+	enrollment, err := h.Svc.LoginMFASetup(r.Context(), req)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) LoginMFASetupConfirm(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	req.ClientIP = clientIP(r)
+	resp, err := h.Svc.LoginMFASetupConfirm(r.Context(), req)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := h.Svc.VerifyEmail(r.Context(), req); err != nil {
+		tokenError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "verified"})
+}
+
+// ResendVerification uvek vraca 202 da ne bi otkrio koji email postoji.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := h.Svc.ResendVerification(r.Context(), req); err != nil {
+		httpError(w, http.StatusInternalServerError, "could not send email")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "if the account exists, an email was sent"})
+}
+
+// ForgotPassword uvek vraca 202 da ne bi otkrio koji email postoji.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := h.Svc.ForgotPassword(r.Context(), req); err != nil {
+		httpError(w, http.StatusInternalServerError, "could not send email")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "if the account exists, an email was sent"})
+}
+
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := h.Svc.ResetPassword(r.Context(), req); err != nil {
+		tokenError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "password changed"})
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	resp, err := h.Svc.Refresh(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRefreshToken):
+			httpError(w, http.StatusUnauthorized, "invalid or expired refresh token")
+		case errors.Is(err, services.ErrUserDisabled):
+			httpError(w, http.StatusForbidden, "user disabled")
+		default:
+			httpError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+	}
+
+	id, _ := middleware.IdentityFromContext(r.Context())
+	jti, err := uuid.Parse(id.TokenID)
+	if err != nil {
+		httpError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	session := models.Session{UserId: id.UserID, TokenId: jti, ExpiresAt: id.ExpiresAt}
+
+	if err := h.Svc.Logout(r.Context(), session, req); err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) Revocations(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Svc.Revocations(r.Context())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, "database exception")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ServiceToken: POST /api/token/service {client_id, client_secret}
+func (h *AuthHandler) ServiceToken(w http.ResponseWriter, r *http.Request) {
+	var req models.ServiceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	resp, err := h.Svc.ServiceToken(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidClient) {
+			httpError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// JWKS objavljuje javne kljuceve za verifikaciju JWT-a; verifikatori ih smeju kesirati.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.Svc.JWKS())
+}
+
+// Unlock ponistava lockout naloga nakon neuspelih prijava.
+func (h *AuthHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	if err := h.Svc.UnlockUser(r.Context(), mux.Vars(r)["id"]); err != nil {
+		accountError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	user, err := a.Svc.GetUser(r.Context(), id)
+// Deactivate iskljucuje nalog i odmah opoziva sve njegove sesije.
+func (h *AuthHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	if err := h.Svc.SetActive(r.Context(), mux.Vars(r)["id"], false); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			httpError(w, http.StatusNotFound, "user not found")
+		default:
+			httpError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
+func (a *AuthHandler) GetUser(rw http.ResponseWriter, r *http.Request) {
+	user, err := a.Svc.GetUser(r.Context(), mux.Vars(r)["username"])
 	if err != nil {
-		http.Error(rw, "Database exception", http.StatusInternalServerError)
+		accountError(rw, err)
 		return
 	}
 
 	writeJSON(rw, http.StatusOK, user)
 }
 
+// ListUsers: GET /api/users?q=&role=&page=&size=
+func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := models.UserFilter{Query: q.Get("q"), Role: q.Get("role")}
+	var err error
+	if v := q.Get("page"); v != "" {
+		if f.Page, err = strconv.Atoi(v); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid page")
+			return
+		}
+	}
+	if v := q.Get("size"); v != "" {
+		if f.Size, err = strconv.Atoi(v); err != nil {
+			httpError(w, http.StatusBadRequest, "invalid size")
+			return
+		}
+	}
+
+	page, err := h.Svc.ListUsers(r.Context(), f)
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// UpdateUser: PATCH /api/users/{id} {is_active?, role?}
+func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+
+	user, err := h.Svc.UpdateUser(r.Context(), id.UserID, mux.Vars(r)["id"], req)
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	id, _ := middleware.IdentityFromContext(r.Context())
+	user, err := h.Svc.Me(r.Context(), id.UserID)
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (h *AuthHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+
+	user, err := h.Svc.UpdateProfile(r.Context(), id.UserID, req)
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ChangePassword odjavljuje korisnika sa svih uredjaja; klijent mora ponovo da se prijavi.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+
+	if err := h.Svc.ChangePassword(r.Context(), id.UserID, req); err != nil {
+		accountError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) MFAStatus(w http.ResponseWriter, r *http.Request) {
+	id, _ := middleware.IdentityFromContext(r.Context())
+	st, err := h.Svc.MFAStatus(r.Context(), id.UserID)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// EnrollMFA generise nov tajni kljuc; 2FA se ukljucuje tek potvrdom koda (ConfirmMFA).
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	id, _ := middleware.IdentityFromContext(r.Context())
+	enrollment, err := h.Svc.EnrollMFA(r.Context(), id.UserID)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+// ConfirmMFA ukljucuje 2FA i vraca rezervne kodove (prikazuju se samo jednom).
+func (h *AuthHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	codes, err := h.Svc.ConfirmMFA(r.Context(), id.UserID, req)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, codes)
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	codes, err := h.Svc.RegenerateRecoveryCodes(r.Context(), id.UserID, req)
+	if err != nil {
+		mfaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, codes)
+}
+
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.DisableMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	id, _ := middleware.IdentityFromContext(r.Context())
+	if err := h.Svc.DisableMFA(r.Context(), id.UserID, req); err != nil {
+		mfaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetMFA (admin) brise 2FA naloga koji je izgubio uredjaj.
+func (h *AuthHandler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	if err := h.Svc.ResetMFA(r.Context(), mux.Vars(r)["id"]); err != nil {
+		mfaError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // helpers
 
 func writeJSON(w http.ResponseWriter, code int, v any) {
@@ -80,6 +454,63 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+func tokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidToken):
+		httpError(w, http.StatusBadRequest, "invalid or expired token")
+	case errors.Is(err, services.ErrWeakPassword):
+		httpError(w, http.StatusBadRequest, err.Error())
+	default:
+		httpError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func accountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		httpError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, services.ErrUserExists):
+		httpError(w, http.StatusConflict, "email or username already exists")
+	case errors.Is(err, services.ErrSelfChange):
+		httpError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidRole),
+		errors.Is(err, services.ErrWeakPassword),
+		errors.Is(err, services.ErrInvalidPassword):
+		httpError(w, http.StatusBadRequest, err.Error())
+	default:
+		httpError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func mfaError(w http.ResponseWriter, err error) {
+	var locked *services.LockedError
+	switch {
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		httpError(w, http.StatusTooManyRequests, "too many failed login attempts")
+	case errors.Is(err, services.ErrInvalidChallenge),
+		errors.Is(err, services.ErrInvalidMFACode):
+		httpError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, services.ErrUserDisabled),
+		errors.Is(err, services.ErrMFARequired):
+		httpError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnabled):
+		httpError(w, http.StatusConflict, err.Error())
+	default:
+		accountError(w, err)
+	}
+}
+
+// clientIP vraca adresu sa koje je stigao zahtev (bez porta).
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func httpError(w http.ResponseWriter, code int, msg string) {
 	writeJSON(w, code, map[string]string{"error": msg})
 }
@@ -0,0 +1,52 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK je javni kljuc u JSON Web Key formatu (RFC 7517, RFC 8037 za Ed25519).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS vraca sve javne kljuceve iz skupa, sortirane po kid-u.
+func (s *Set) JWKS() JWKS {
+	out := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+		switch pub := k.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64(pub)
+		default:
+			continue
+		}
+		out.Keys = append(out.Keys, jwk)
+	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Kid < out.Keys[j].Kid })
+	return out
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,174 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSABits = 2048
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key je jedan kljuc iz skupa. Private je nil za penzionisane kljuceve
+// koji se jos objavljuju samo da bi vec izdati tokeni ostali validni.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// Set je skup kljuceva ucitanih iz direktorijuma: jednim se potpisuju novi tokeni,
+// a svi se objavljuju u JWKS tako da rotacija ne odjavljuje korisnike.
+type Set struct {
+	keys    map[string]Key
+	signing Key
+}
+
+// FromEnv ucitava kljuceve iz JWT_KEYS_DIR (podrazumevano "jwt-keys");
+// JWT_SIGNING_KID bira kljuc za potpisivanje, inace se koristi najnoviji privatni kljuc.
+func FromEnv() (*Set, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "jwt-keys"
+	}
+	return Load(dir, os.Getenv("JWT_SIGNING_KID"))
+}
+
+// Load cita <kid>.pem fajlove iz dir: PKCS#8/PKCS#1 RSA ili Ed25519 privatne kljuceve,
+// odnosno "PUBLIC KEY" za penzionisane kljuceve. Ako privatnog kljuca nema,
+// generise se novi Ed25519 kljuc i upisuje u dir.
+func Load(dir, signingKID string) (*Set, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Set{keys: map[string]Key{}}
+	var newest time.Time
+	for _, p := range paths {
+		k, err := readKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		s.keys[k.ID] = k
+
+		if k.Private == nil || signingKID != "" {
+			continue
+		}
+		if fi, err := os.Stat(p); err == nil && fi.ModTime().After(newest) {
+			newest, s.signing = fi.ModTime(), k
+		}
+	}
+
+	if signingKID != "" {
+		k, ok := s.keys[signingKID]
+		if !ok || k.Private == nil {
+			return nil, fmt.Errorf("signing key %q not found in %s", signingKID, dir)
+		}
+		s.signing = k
+	}
+	if s.signing.Private == nil {
+		k, err := generate(dir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("keys: no private key in %s, generated %s", dir, k.ID)
+		s.keys[k.ID] = k
+		s.signing = k
+	}
+	return s, nil
+}
+
+// Signing vraca kljuc kojim se potpisuju novi tokeni.
+func (s *Set) Signing() Key {
+	return s.signing
+}
+
+// PublicKey vraca javni kljuc za dati kid (middleware.KeySource).
+func (s *Set) PublicKey(_ context.Context, kid string) (crypto.PublicKey, error) {
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return k.Public, nil
+}
+
+func readKey(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM type %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	k := Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		k.Private = signer
+		parsed = signer.Public()
+	}
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return Key{}, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		k.Method, k.Public = jwt.SigningMethodRS256, pub
+	case ed25519.PublicKey:
+		k.Method, k.Public = jwt.SigningMethodEdDSA, pub
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return k, nil
+}
+
+func generate(dir string) (Key, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return Key{}, err
+	}
+
+	kid := "ed25519-" + time.Now().UTC().Format("20060102T150405")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Key{}, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		return Key{}, err
+	}
+	return Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: priv, Public: pub}, nil
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer ne salje poruke, vec ih upisuje u fajl (ili u log ako path nije zadat).
+// Namenjen je lokalnom razvoju i testiranju.
+type LogMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	entry := fmt.Sprintf("=== %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+
+	if m.path == "" {
+		log.Printf("mail (not sent):\n%s", entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
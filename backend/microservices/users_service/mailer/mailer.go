@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Message je jednostavna tekstualna poruka.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer salje poruke korisnicima (verifikacija email-a, reset lozinke...).
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv bira implementaciju po MAILER env promenljivoj:
+//   - "smtp": SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, MAIL_FROM
+//   - "log" (podrazumevano): poruke se upisuju u MAIL_LOG_FILE, ili u log ako fajl nije zadat
+func FromEnv() (Mailer, error) {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		host, port := os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT")
+		from := os.Getenv("MAIL_FROM")
+		if host == "" || port == "" || from == "" {
+			return nil, fmt.Errorf("env variables SMTP_HOST, SMTP_PORT, MAIL_FROM must be set for smtp mailer")
+		}
+		return NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	case "", "log":
+		return NewLogMailer(os.Getenv("MAIL_LOG_FILE")), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", os.Getenv("MAILER"))
+	}
+}
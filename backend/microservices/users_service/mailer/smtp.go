@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: net.JoinHostPort(host, port), from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid header value")
+	}
+
+	body := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		msg.Body,
+	}, "\r\n")
+
+	// net/smtp nema podrsku za context, pa slanje radimo u gorutini
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(body))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
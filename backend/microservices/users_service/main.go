@@ -6,10 +6,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"common/middleware"
 	"users_module/handlers"
+	"users_module/keys"
+	"users_module/mailer"
 	"users_module/repositories"
 	"users_module/services"
 
@@ -28,8 +32,29 @@ func main() {
 	}
 
 	// Service init
-	jwtSecret := mustEnv("JWT_SECRET")
-	authSvc := services.NewAuthService(*repo, jwtSecret)
+	tokenSecret := mustEnv("TOKEN_SECRET")
+	keySet, err := keys.FromEnv()
+	if err != nil {
+		log.Fatal("signing keys init error: ", err)
+	}
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal("mailer init error: ", err)
+	}
+	appURL := os.Getenv("APP_BASE_URL")
+	if appURL == "" {
+		appURL = "http://localhost:4200"
+	}
+	serviceClients := services.ParseServiceClients(os.Getenv("SERVICE_CLIENTS"))
+	authSvc := services.NewAuthService(*repo, keySet, tokenSecret, mail, appURL, serviceClients)
+
+	// Pozadinski poslovi zive dok server radi
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+
+	// Outbox: dogadjaji (UserRegistered...) se isporucuju pretplatnicima
+	relay := services.NewOutboxRelay(*repo, keySet, strings.Split(envOr("OUTBOX_SUBSCRIBERS", "http://housing-server:8003/internal/events"), ","))
+	go relay.Run(bgCtx, 2*time.Second)
 
 	// Handler init
 	authHandler := handlers.NewAuthHandler(authSvc)
@@ -37,8 +62,39 @@ func main() {
 	// Routes
 	router.Handle("/api/register", http.HandlerFunc(authHandler.Register)).Methods(http.MethodPost)
 	router.Handle("/api/login", http.HandlerFunc(authHandler.Login)).Methods(http.MethodPost)
-
-	router.Handle("/api/users/{username}", http.HandlerFunc(authHandler.GetUser)).Methods(http.MethodGet)
+	router.Handle("/api/login/2fa", http.HandlerFunc(authHandler.LoginMFA)).Methods(http.MethodPost)
+	router.Handle("/api/login/2fa/setup", http.HandlerFunc(authHandler.LoginMFASetup)).Methods(http.MethodPost)
+	router.Handle("/api/login/2fa/setup/confirm", http.HandlerFunc(authHandler.LoginMFASetupConfirm)).Methods(http.MethodPost)
+	router.Handle("/api/email/verify", http.HandlerFunc(authHandler.VerifyEmail)).Methods(http.MethodPost)
+	router.Handle("/api/email/verify/resend", http.HandlerFunc(authHandler.ResendVerification)).Methods(http.MethodPost)
+	router.Handle("/api/password/forgot", http.HandlerFunc(authHandler.ForgotPassword)).Methods(http.MethodPost)
+	router.Handle("/api/password/reset", http.HandlerFunc(authHandler.ResetPassword)).Methods(http.MethodPost)
+	router.Handle("/api/token/refresh", http.HandlerFunc(authHandler.Refresh)).Methods(http.MethodPost)
+	router.Handle("/api/token/service", http.HandlerFunc(authHandler.ServiceToken)).Methods(http.MethodPost) // client credentials za servise
+	router.Handle("/.well-known/jwks.json", http.HandlerFunc(authHandler.JWKS)).Methods(http.MethodGet)      // javni kljucevi za verifikaciju JWT-a
+
+	// Zasticene rute (JWT + politika pristupa po ruti)
+	api := router.NewRoute().Subrouter()
+	api.Use(middleware.Authenticate(keySet, repo))
+
+	api.Handle("/api/logout", middleware.Require(middleware.Authenticated, authHandler.Logout)).Methods(http.MethodPost)
+	api.Handle("/api/me", middleware.Require(middleware.Authenticated, authHandler.Me)).Methods(http.MethodGet)
+	api.Handle("/api/me", middleware.Require(middleware.Authenticated, authHandler.UpdateMe)).Methods(http.MethodPatch)
+	api.Handle("/api/me/password", middleware.Require(middleware.Authenticated, authHandler.ChangePassword)).Methods(http.MethodPost)
+	api.Handle("/api/me/2fa", middleware.Require(middleware.Authenticated, authHandler.MFAStatus)).Methods(http.MethodGet)
+	api.Handle("/api/me/2fa", middleware.Require(middleware.Authenticated, authHandler.DisableMFA)).Methods(http.MethodDelete)
+	api.Handle("/api/me/2fa/enroll", middleware.Require(middleware.Authenticated, authHandler.EnrollMFA)).Methods(http.MethodPost)
+	api.Handle("/api/me/2fa/confirm", middleware.Require(middleware.Authenticated, authHandler.ConfirmMFA)).Methods(http.MethodPost)
+	api.Handle("/api/me/2fa/recovery-codes", middleware.Require(middleware.Authenticated, authHandler.RegenerateRecoveryCodes)).Methods(http.MethodPost)
+
+	api.Handle("/api/token/revocations", middleware.Require(middleware.Service, authHandler.Revocations)).Methods(http.MethodGet) // citaju je ostali servisi
+
+	api.Handle("/api/users", middleware.Require(middleware.Admin, authHandler.ListUsers)).Methods(http.MethodGet)
+	api.Handle("/api/users/{username}", middleware.Require(ownerOrAdmin("username"), authHandler.GetUser)).Methods(http.MethodGet)
+	api.Handle("/api/users/{id}", middleware.Require(middleware.Admin, authHandler.UpdateUser)).Methods(http.MethodPatch)
+	api.Handle("/api/users/{id}/deactivate", middleware.Require(middleware.Admin, authHandler.Deactivate)).Methods(http.MethodPost)
+	api.Handle("/api/users/{id}/unlock", middleware.Require(middleware.Admin, authHandler.Unlock)).Methods(http.MethodPost)
+	api.Handle("/api/users/{id}/2fa", middleware.Require(middleware.Admin, authHandler.ResetMFA)).Methods(http.MethodDelete)
 
 	// Wrap with CORS middleware
 	handler := withCORS(router)
@@ -79,6 +135,17 @@ func main() {
 	log.Println("user-server stopped")
 }
 
+func ownerOrAdmin(param string) middleware.Policy {
+	return middleware.AnyOf(middleware.Owner(param), middleware.Admin)
+}
+
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
 func mustEnv(k string) string {
 	v := os.Getenv(k)
 	if v == "" {
@@ -91,8 +158,9 @@ func mustEnv(k string) string {
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
 
 		// Ako je preflight request
 		if r.Method == http.MethodOptions {
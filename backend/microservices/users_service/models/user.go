@@ -1,7 +1,9 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,6 +18,8 @@ type User struct {
 	Password  string    `json:"password"`
 	IsActive  bool      `json:"is_active"`
 	Role      string    `json:"role"`
+
+	EmailVerified bool `json:"email_verified"`
 }
 
 type UserDTO struct {
@@ -28,6 +32,39 @@ type UserDTO struct {
 	Role      string    `json:"role"`
 }
 
+// UserFilter su parametri pretrage naloga (admin).
+type UserFilter struct {
+	Query string // deo imena, prezimena, username-a ili email-a
+	Role  string
+	Page  int
+	Size  int
+}
+
+type UserPage struct {
+	Items []UserDTO `json:"items"`
+	Total int       `json:"total"`
+	Page  int       `json:"page"`
+	Size  int       `json:"size"`
+}
+
+// UpdateUserRequest menja nalog (admin); polja koja nisu poslata se ne menjaju.
+type UpdateUserRequest struct {
+	IsActive *bool   `json:"is_active"`
+	Role     *string `json:"role"`
+}
+
+// UpdateProfileRequest menja sopstveni profil; polja koja nisu poslata se ne menjaju.
+type UpdateProfileRequest struct {
+	FirstName *string `json:"firstname"`
+	LastName  *string `json:"lastname"`
+	Email     *string `json:"email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
 type RegisterRequest struct {
 	FirstName string `json:"firstname"`
 	LastName  string `json:"lastname"`
@@ -36,14 +73,149 @@ type RegisterRequest struct {
 	Password  string `json:"password"`
 }
 
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 type LoginRequest struct {
 	Identifier string `json:"identifier"`
 	Password   string `json:"password"`
+	ClientIP   string `json:"-"` // popunjava handler, koristi se za ogranicavanje pokusaja
 }
 
+// LoginResponse nosi sesiju ili, ako nalog koristi 2FA, samo challenge token
+// za drugi korak prijave (POST /api/login/2fa ili /api/login/2fa/setup).
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // trajanje access tokena u sekundama
+	User         *User  `json:"user,omitempty"`
+
+	MFARequired      bool     `json:"mfa_required,omitempty"`
+	MFASetupRequired bool     `json:"mfa_setup_required,omitempty"` // admin bez 2FA mora prvo da ga podesi
+	ChallengeToken   string   `json:"challenge_token,omitempty"`
+	RecoveryCodes    []string `json:"recovery_codes,omitempty"` // samo pri potvrdi podesavanja 2FA
+}
+
+// MFALoginRequest je drugi korak prijave: TOTP kod ili jedan od rezervnih kodova.
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+	ClientIP       string `json:"-"`
+}
+
+// MFA je TOTP podesavanje naloga; Enabled je false dok korisnik ne potvrdi prvi kod.
+type MFA struct {
+	UserId   uuid.UUID
+	Secret   string
+	Enabled  bool
+	LastStep int64 // poslednji iskorisceni TOTP korak, stiti od ponovne upotrebe koda
+}
+
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type MFAStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"` // obavezno za rolu admin
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"` // odjava sa svih uredjaja
+}
+
+// RefreshToken je serverski zapis o izdatom refresh tokenu; sam token se cuva samo kao hash.
+// Svi tokeni nastali rotacijom iz istog logina dele FamilyId.
+type RefreshToken struct {
+	Id         uuid.UUID
+	UserId     uuid.UUID
+	FamilyId   uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *uuid.UUID
+}
+
+// Session opisuje access token kojim je zahtev autentifikovan.
+type Session struct {
+	UserId    uuid.UUID
+	TokenId   uuid.UUID
+	ExpiresAt time.Time
+}
+
+type RevokedUser struct {
+	UserId    uuid.UUID `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"` // access tokeni izdati pre ovog trenutka su nevazeci
+}
+
+// ServiceTokenRequest: client credentials servisa (SERVICE_CLIENTS).
+type ServiceTokenRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+type ServiceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // sekunde
+}
+
+// RevocationList je lista opozvanih access tokena koju citaju ostali servisi.
+type RevocationList struct {
+	Tokens []string      `json:"tokens"` // jti opozvanih tokena koji jos nisu istekli
+	Users  []RevokedUser `json:"users"`
+}
+
+// EventUserRegistered objavljuje se pri kreiranju naloga; housing_service na osnovu
+// njega pravi studenta i studentsku karticu.
+const EventUserRegistered = "UserRegistered"
+
+// OutboxEvent je dogadjaj upisan u outbox u istoj transakciji kao i promena koju opisuje.
+type OutboxEvent struct {
+	Id          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	AggregateId uuid.UUID       `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type UserRegistered struct {
+	UserId    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"firstname"`
+	LastName  string    `json:"lastname"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
 }
 
 type ErrRespTmp struct {
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"users_module/models"
+)
+
+// likeEscaper: %, _ i \ iz pretrage se traze doslovno (uz ESCAPE '\' u upitu).
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers vraca stranicu naloga koji odgovaraju filteru i ukupan broj pogodaka.
+func (r *UserRepository) ListUsers(ctx context.Context, f models.UserFilter) ([]models.UserDTO, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	pattern := ""
+	if f.Query != "" {
+		pattern = "%" + likeEscaper.Replace(f.Query) + "%"
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT id, firstname, lastname, username, email, is_active, role, count(*) OVER ()
+FROM users
+WHERE ($1 = '' OR firstname ILIKE $1 ESCAPE '\' OR lastname ILIKE $1 ESCAPE '\'
+       OR username ILIKE $1 ESCAPE '\' OR email ILIKE $1 ESCAPE '\')
+  AND ($2 = '' OR role = $2)
+ORDER BY lastname, firstname, username
+LIMIT $3 OFFSET $4;
+`, pattern, f.Role, f.Size, (f.Page-1)*f.Size)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.UserDTO{}
+	total := 0
+	for rows.Next() {
+		var u models.UserDTO
+		if err := rows.Scan(&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &u.IsActive, &u.Role, &total); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// stranica iza poslednjeg rezultata nema redova pa ni ukupnog broja
+	if len(users) == 0 && f.Page > 1 {
+		if err := r.DB.QueryRowContext(ctx, `
+SELECT count(*) FROM users
+WHERE ($1 = '' OR firstname ILIKE $1 ESCAPE '\' OR lastname ILIKE $1 ESCAPE '\'
+       OR username ILIKE $1 ESCAPE '\' OR email ILIKE $1 ESCAPE '\')
+  AND ($2 = '' OR role = $2);
+`, pattern, f.Role).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+	}
+	return users, total, nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE users SET role = $1, updated_at = now() WHERE id = $2;`, role, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateProfile cuva ime, prezime i email; promena email-a ponistava njegovu verifikaciju.
+func (r *UserRepository) UpdateProfile(ctx context.Context, u *models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.DB.QueryRowContext(ctx, `
+UPDATE users
+SET firstname = $1, lastname = $2, email = $3,
+    email_verified = email_verified AND email = $3,
+    updated_at = now()
+WHERE id = $4
+RETURNING id, firstname, lastname, username, email, is_active, role, email_verified;
+`, u.FirstName, u.LastName, u.Email, u.Id).Scan(
+		&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &u.IsActive, &u.Role, &u.EmailVerified,
+	)
+}
+
+func (r *UserRepository) GetPasswordHash(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var hash []byte
+	err := r.DB.QueryRowContext(ctx, `SELECT password_hash FROM users WHERE id = $1;`, id).Scan(&hash)
+	return hash, err
+}
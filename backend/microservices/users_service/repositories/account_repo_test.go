@@ -0,0 +1,18 @@
+package repositories
+
+import "testing"
+
+func TestLikeEscaper(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"nikola", "nikola"},
+		{"100%", `100\%`},
+		{"a_b", `a\_b`},
+		{`c:\temp`, `c:\\temp`},
+		{`\%_`, `\\\%\_`},
+	}
+	for _, tt := range tests {
+		if got := likeEscaper.Replace(tt.in); got != tt.want {
+			t.Errorf("likeEscaper(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
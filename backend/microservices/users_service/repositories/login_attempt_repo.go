@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LoginLockedUntil vraca najkasniji aktivan lockout medju zadatim kljucevima (nulti time ako ga nema).
+func (r *UserRepository) LoginLockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var until pq.NullTime
+	err := r.DB.QueryRowContext(ctx, `
+SELECT max(locked_until) FROM login_attempts
+WHERE key = ANY($1) AND locked_until > now();
+`, pq.Array(keys)).Scan(&until)
+	if err != nil || !until.Valid {
+		return time.Time{}, err
+	}
+	return until.Time, nil
+}
+
+// RecordLoginFailure uvecava broj neuspelih pokusaja za kljuc i vraca novi broj.
+// Brojac krece od nule ako je poslednji neuspeh stariji od window.
+func (r *UserRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var failures int
+	err := r.DB.QueryRowContext(ctx, `
+INSERT INTO login_attempts (key, failures, last_failure) VALUES ($1, 1, now())
+ON CONFLICT (key) DO UPDATE SET
+	failures = CASE
+		WHEN login_attempts.last_failure < now() - $2 * INTERVAL '1 second' THEN 1
+		ELSE login_attempts.failures + 1
+	END,
+	last_failure = now()
+RETURNING failures;
+`, key, int64(window.Seconds())).Scan(&failures)
+	return failures, err
+}
+
+// LockLogin zakljucava kljuc do zadatog trenutka.
+func (r *UserRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE login_attempts SET locked_until = $1 WHERE key = $2;`, until, key)
+	return err
+}
+
+// ClearLoginAttempts brise brojace i lockout za zadate kljuceve (uspesna prijava ili admin unlock).
+func (r *UserRepository) ClearLoginAttempts(ctx context.Context, keys ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = ANY($1);`, pq.Array(keys))
+	return err
+}
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"users_module/models"
+)
+
+// ErrStepUsed znaci da je TOTP kod za dati korak vec iskoriscen.
+var ErrStepUsed = errors.New("totp code already used")
+
+// GetMFA vraca TOTP podesavanje naloga; sql.ErrNoRows ako ga nema.
+func (r *UserRepository) GetMFA(ctx context.Context, userID uuid.UUID) (*models.MFA, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	m := models.MFA{UserId: userID}
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT secret, enabled, last_step FROM user_mfa WHERE user_id = $1;`, userID,
+	).Scan(&m.Secret, &m.Enabled, &m.LastStep)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SavePendingMFA upisuje nov, jos nepotvrdjen tajni kljuc (ponovno podesavanje pregazi prethodni).
+func (r *UserRepository) SavePendingMFA(ctx context.Context, userID uuid.UUID, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `
+UPSERT INTO user_mfa (user_id, secret, enabled, last_step, created_at, enabled_at)
+VALUES ($1, $2, false, 0, now(), NULL);
+`, userID, secret)
+	return err
+}
+
+// UseTOTPStep belezi iskorisceni korak; kod iz istog ili starijeg koraka se odbija.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE user_mfa SET last_step = $1 WHERE user_id = $2 AND last_step < $1;`, step, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStepUsed
+	}
+	return nil
+}
+
+// EnableMFA ukljucuje 2FA i zamenjuje rezervne kodove, u jednoj transakciji.
+func (r *UserRepository) EnableMFA(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE user_mfa SET enabled = true, enabled_at = now() WHERE user_id = $1;`, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+	if err = replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *UserRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID uuid.UUID, codeHashes [][]byte) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (code_hash, user_id) VALUES ($1, $2);`, h, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode trosi rezervni kod; ErrTokenInvalid ako ne postoji ili je vec iskoriscen.
+func (r *UserRepository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx, `
+UPDATE mfa_recovery_codes SET used_at = now()
+WHERE code_hash = $1 AND user_id = $2 AND used_at IS NULL;
+`, codeHash, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenInvalid
+	}
+	return nil
+}
+
+func (r *UserRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var n int
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT count(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL;`, userID).Scan(&n)
+	return n, err
+}
+
+// DeleteMFA brise TOTP podesavanje i rezervne kodove naloga.
+func (r *UserRepository) DeleteMFA(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1;`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
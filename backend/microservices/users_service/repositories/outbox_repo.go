@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"users_module/models"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertUserRegistered upisuje UserRegistered u outbox. Id dogadjaja je izveden iz id-a naloga,
+// pa ponovljen upis (seed) ne pravi duplikat.
+func insertUserRegistered(ctx context.Context, q execer, u models.User) error {
+	payload, err := json.Marshal(models.UserRegistered{
+		UserId:    u.Id,
+		Username:  u.Username,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Role:      u.Role,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `
+INSERT INTO outbox_events (id, aggregate_id, event_type, payload)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO NOTHING;
+`, uuid.NewSHA1(u.Id, []byte(models.EventUserRegistered)), u.Id, models.EventUserRegistered, payload)
+	return err
+}
+
+// PendingOutboxEvents vraca najstarije neobjavljene dogadjaje.
+func (r *UserRepository) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, `
+SELECT id, event_type, aggregate_id, created_at, payload
+FROM outbox_events
+WHERE published_at IS NULL
+ORDER BY created_at, id
+LIMIT $1;
+`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.OutboxEvent{}
+	for rows.Next() {
+		var (
+			e       models.OutboxEvent
+			payload []byte
+		)
+		if err := rows.Scan(&e.Id, &e.Type, &e.AggregateId, &e.OccurredAt, &payload); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *UserRepository) MarkOutboxPublished(ctx context.Context, ids []uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `
+UPDATE outbox_events SET published_at = now(), attempts = attempts + 1, last_error = NULL
+WHERE id = ANY($1::UUID[]);
+`, pq.Array(uuidStrings(ids)))
+	return err
+}
+
+// MarkOutboxFailed belezi neuspelu isporuku; dogadjaji ostaju u redu za sledeci pokusaj.
+func (r *UserRepository) MarkOutboxFailed(ctx context.Context, ids []uuid.UUID, cause string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `
+UPDATE outbox_events SET attempts = attempts + 1, last_error = $2
+WHERE id = ANY($1::UUID[]);
+`, pq.Array(uuidStrings(ids)), cause)
+	return err
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"users_module/models"
+)
+
+// ErrTokenAlreadyRotated znaci da je refresh token vec iskoriscen (moguca kradja tokena).
+var ErrTokenAlreadyRotated = errors.New("refresh token already rotated")
+
+func (r *UserRepository) CreateRefreshToken(ctx context.Context, t *models.RefreshToken, tokenHash []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `
+INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+`, t.Id, t.UserId, t.FamilyId, tokenHash, t.ExpiresAt)
+	return err
+}
+
+func (r *UserRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash []byte) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var t models.RefreshToken
+	err := r.DB.QueryRowContext(ctx, `
+SELECT id, user_id, family_id, expires_at, revoked_at, replaced_by
+FROM refresh_tokens
+WHERE token_hash = $1;
+`, tokenHash).Scan(&t.Id, &t.UserId, &t.FamilyId, &t.ExpiresAt, &t.RevokedAt, &t.ReplacedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RotateRefreshToken opoziva stari token i upisuje novi iz iste familije, u jednoj transakciji.
+func (r *UserRepository) RotateRefreshToken(ctx context.Context, oldID uuid.UUID, next *models.RefreshToken, nextHash []byte) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+UPDATE refresh_tokens SET revoked_at = now(), replaced_by = $1
+WHERE id = $2 AND revoked_at IS NULL;
+`, next.Id, oldID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = ErrTokenAlreadyRotated
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+`, next.Id, next.UserId, next.FamilyId, nextHash, next.ExpiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) RevokeRefreshFamily(ctx context.Context, familyID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL;`, familyID)
+	return err
+}
+
+func (r *UserRepository) RevokeAccessToken(ctx context.Context, jti, userID uuid.UUID, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `
+INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+`, jti, userID, expiresAt)
+	return err
+}
+
+// RevokeAllForUser opoziva sve refresh tokene korisnika i ponistava sve do sada izdate access tokene.
+func (r *UserRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL;`, userID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+INSERT INTO user_revocations (user_id, revoked_at) VALUES ($1, now())
+ON CONFLICT (user_id) DO UPDATE SET revoked_at = excluded.revoked_at;
+`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListRevocations vraca opozvane tokene koji jos nisu istekli i opoziv po korisniku noviji od since.
+func (r *UserRepository) ListRevocations(ctx context.Context, since time.Time) (models.RevocationList, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	list := models.RevocationList{Tokens: []string{}, Users: []models.RevokedUser{}}
+
+	rows, err := r.DB.QueryContext(ctx, `SELECT jti FROM revoked_tokens WHERE expires_at > now();`)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var jti string
+		if err := rows.Scan(&jti); err != nil {
+			return list, err
+		}
+		list.Tokens = append(list.Tokens, jti)
+	}
+	if err := rows.Err(); err != nil {
+		return list, err
+	}
+
+	urows, err := r.DB.QueryContext(ctx,
+		`SELECT user_id, revoked_at FROM user_revocations WHERE revoked_at > $1;`, since)
+	if err != nil {
+		return list, err
+	}
+	defer urows.Close()
+	for urows.Next() {
+		var u models.RevokedUser
+		if err := urows.Scan(&u.UserId, &u.RevokedAt); err != nil {
+			return list, err
+		}
+		list.Users = append(list.Users, u)
+	}
+	return list, urows.Err()
+}
+
+// IsRevoked proverava access token direktno u bazi (users_service je vlasnik ovih tabela).
+func (r *UserRepository) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var revoked bool
+	err := r.DB.QueryRowContext(ctx, `
+SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+    OR EXISTS (SELECT 1 FROM user_revocations WHERE user_id = $2 AND revoked_at > $3);
+`, jti, userID, issuedAt).Scan(&revoked)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	return revoked, nil
+}
+
+func (r *UserRepository) GetUserByUUID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var u models.User
+	err := r.DB.QueryRowContext(ctx, `
+SELECT id, firstname, lastname, username, email, is_active, role, email_verified
+FROM users WHERE id = $1;
+`, id).Scan(&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &u.IsActive, &u.Role, &u.EmailVerified)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *UserRepository) SetActive(ctx context.Context, id uuid.UUID, active bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE users SET is_active = $1, updated_at = now() WHERE id = $2;`, active, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
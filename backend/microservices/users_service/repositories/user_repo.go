@@ -74,6 +74,89 @@ func (r *UserRepository) migrateAndSeed() error {
 		return err
 	}
 
+	stmts := []string{
+		// postojeci nalozi se smatraju verifikovanim; novi moraju da potvrde email
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOL NOT NULL DEFAULT true;`,
+		`ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT false;`,
+
+		// jednokratni tokeni za verifikaciju email-a i reset lozinke
+		`CREATE TABLE IF NOT EXISTS user_tokens (
+			id         UUID PRIMARY KEY,
+			user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose    STRING NOT NULL,
+			token_hash BYTES UNIQUE NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at    TIMESTAMPTZ NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS user_tokens_user_idx ON user_tokens(user_id, purpose);`,
+
+		// sesije: refresh tokeni i opozvani access tokeni
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id          UUID PRIMARY KEY,
+			user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id   UUID NOT NULL,
+			token_hash  BYTES UNIQUE NOT NULL,
+			expires_at  TIMESTAMPTZ NOT NULL,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			revoked_at  TIMESTAMPTZ NULL,
+			replaced_by UUID NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens(user_id);`,
+		`CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens(family_id);`,
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti        UUID PRIMARY KEY,
+			user_id    UUID NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS user_revocations (
+			user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			revoked_at TIMESTAMPTZ NOT NULL
+		);`,
+
+		// neuspesne prijave po nalogu ("user:<id>" / "ident:<identifier>") i po IP adresi ("ip:<adresa>")
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			key          STRING PRIMARY KEY,
+			failures     INT NOT NULL DEFAULT 0,
+			last_failure TIMESTAMPTZ NOT NULL DEFAULT now(),
+			locked_until TIMESTAMPTZ NULL
+		);`,
+
+		// dvofaktorska prijava (TOTP) i jednokratni rezervni kodovi
+		`CREATE TABLE IF NOT EXISTS user_mfa (
+			user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret     STRING NOT NULL,
+			enabled    BOOL NOT NULL DEFAULT false,
+			last_step  INT8 NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			enabled_at TIMESTAMPTZ NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+			code_hash BYTES PRIMARY KEY,
+			user_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			used_at   TIMESTAMPTZ NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user_idx ON mfa_recovery_codes(user_id);`,
+
+		// transakcioni outbox: dogadjaji za ostale servise (isporucuje ih OutboxRelay)
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			id           UUID PRIMARY KEY,
+			aggregate_id UUID NOT NULL,
+			event_type   STRING NOT NULL,
+			payload      JSONB NOT NULL,
+			created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+			published_at TIMESTAMPTZ NULL,
+			attempts     INT NOT NULL DEFAULT 0,
+			last_error   STRING NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events(created_at) WHERE published_at IS NULL;`,
+	}
+	for _, q := range stmts {
+		if _, err := r.DB.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+
 	// inicijalni korisnici sa hardkodovanim UUID-ovima
 	users := []struct {
 		ID        string
@@ -96,41 +179,31 @@ func (r *UserRepository) migrateAndSeed() error {
 
 		// INSERT u users (idempotentno po username)
 		_, err := r.DB.ExecContext(ctx, `
-			INSERT INTO users (id, firstname, lastname, username, email, password_hash, is_active, role)
-			VALUES ($1,$2,$3,$4,$5,$6,true,$7)
+			INSERT INTO users (id, firstname, lastname, username, email, password_hash, is_active, role, email_verified)
+			VALUES ($1,$2,$3,$4,$5,$6,true,$7,true)
 			ON CONFLICT (username) DO NOTHING;
 		`, userID, u.FirstName, u.LastName, u.Username, u.Email, hash, u.Role)
 		if err != nil {
 			return err
 		}
 
-		// Ako je student — kreiraj i red u housing.student (idempotentno)
-		if u.Role == "student" {
-			if _, err := r.DB.ExecContext(ctx, `
-				INSERT INTO student (ime, prezime, username, soba_id)
-				VALUES ($1, $2, $3, NULL)
-				ON CONFLICT (username) DO NOTHING;
-			`, u.FirstName, u.LastName, u.Username); err != nil {
-				return err
-			}
+		// UserRegistered i za seed naloge; id dogadjaja je deterministican pa se ne duplira
+		seeded := models.User{Id: userID, FirstName: u.FirstName, LastName: u.LastName, Username: u.Username, Email: u.Email, Role: u.Role}
+		if err := insertUserRegistered(ctx, r.DB, seeded); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// CreateUser: kreira user-a i, ako je rola 'student', kreira i red u housing.student.
-// Sve u JEDNOJ transakciji radi konzistentnosti.
+// CreateUser upisuje nalog i UserRegistered dogadjaj u outbox, u JEDNOJ transakciji;
+// housing_service iz dogadjaja pravi studenta (vidi OutboxRelay).
 func (r *UserRepository) CreateUser(ctx context.Context, u *models.User, passwordHash []byte) error {
 	const qInsertUser = `
-INSERT INTO users (id, firstname, lastname, username, email, password_hash, is_active, role)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, firstname, lastname, username, email, is_active, role;
-`
-	const qInsertStudentIfRole = `
-INSERT INTO student (ime, prezime, username, soba_id)
-VALUES ($1, $2, $3, NULL)
-ON CONFLICT (username) DO NOTHING;
+INSERT INTO users (id, firstname, lastname, username, email, password_hash, is_active, role, email_verified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, firstname, lastname, username, email, is_active, role, email_verified;
 `
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -148,17 +221,15 @@ ON CONFLICT (username) DO NOTHING;
 
 	// 1) upiši user-a
 	err = tx.QueryRowContext(ctx, qInsertUser,
-		u.Id, u.FirstName, u.LastName, u.Username, u.Email, passwordHash, u.IsActive, u.Role,
-	).Scan(&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &u.IsActive, &u.Role)
+		u.Id, u.FirstName, u.LastName, u.Username, u.Email, passwordHash, u.IsActive, u.Role, u.EmailVerified,
+	).Scan(&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &u.IsActive, &u.Role, &u.EmailVerified)
 	if err != nil {
 		return err
 	}
 
-	// 2) ako je student — kreiraj red i u 'student'
-	if u.Role == "student" {
-		if _, err = tx.ExecContext(ctx, qInsertStudentIfRole, u.FirstName, u.LastName, u.Username); err != nil {
-			return err
-		}
+	// 2) dogadjaj za ostale servise
+	if err = insertUserRegistered(ctx, tx, *u); err != nil {
+		return err
 	}
 
 	// 3) commit
@@ -170,7 +241,7 @@ ON CONFLICT (username) DO NOTHING;
 
 func (r *UserRepository) GetByEmailOrUsername(ctx context.Context, identifier string) (*models.User, []byte, error) {
 	const q = `
-SELECT id, firstname, lastname, username, email, password_hash, is_active, role
+SELECT id, firstname, lastname, username, email, password_hash, is_active, role, email_verified
 FROM users
 WHERE email = $1 OR username = $2
 LIMIT 1;
@@ -183,7 +254,7 @@ LIMIT 1;
 	defer cancel()
 
 	err := r.DB.QueryRowContext(ctx, q, identifier, identifier).Scan(
-		&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &pwd, &u.IsActive, &u.Role,
+		&u.Id, &u.FirstName, &u.LastName, &u.Username, &u.Email, &pwd, &u.IsActive, &u.Role, &u.EmailVerified,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -191,8 +262,9 @@ LIMIT 1;
 	return &u, pwd, nil
 }
 
-func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.UserDTO, error) {
-	const q = `SELECT id, firstname, lastname, username, email, is_active, role 
+// GetUserByUsername vraca nalog po username-u; sql.ErrNoRows ako ne postoji.
+func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.UserDTO, error) {
+	const q = `SELECT id, firstname, lastname, username, email, is_active, role
                FROM users WHERE username = $1 LIMIT 1`
 
 	var (
@@ -202,7 +274,7 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.Us
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	err := r.DB.QueryRowContext(ctx, q, id).Scan(
+	err := r.DB.QueryRowContext(ctx, q, username).Scan(
 		&u.Id,
 		&u.FirstName,
 		&u.LastName,
@@ -211,14 +283,9 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.Us
 		&u.IsActive,
 		&u.Role,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user with id %s not found", id)
-		}
-		fmt.Printf("Database scan error: %v\n", err)
-		return nil, fmt.Errorf("database error: %v", err)
+		return nil, err
 	}
-	
+
 	return &u, nil
 }
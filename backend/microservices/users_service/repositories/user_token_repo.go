@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrTokenInvalid znaci da token ne postoji, istekao je ili je vec iskoriscen.
+var ErrTokenInvalid = errors.New("token invalid, expired or already used")
+
+func (r *UserRepository) CreateUserToken(ctx context.Context, id, userID uuid.UUID, purpose string, tokenHash []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, `
+INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+`, id, userID, purpose, tokenHash, expiresAt)
+	return err
+}
+
+// ConsumeUserToken atomicno oznacava token kao iskoriscen i vraca njegovog vlasnika.
+func (r *UserRepository) ConsumeUserToken(ctx context.Context, tokenHash []byte, purpose string) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var userID uuid.UUID
+	err := r.DB.QueryRowContext(ctx, `
+UPDATE user_tokens SET used_at = now()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+RETURNING user_id;
+`, tokenHash, purpose).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrTokenInvalid
+	}
+	return userID, err
+}
+
+// InvalidateUserTokens ponistava sve neiskoriscene tokene date namene za korisnika.
+func (r *UserRepository) InvalidateUserTokens(ctx context.Context, userID uuid.UUID, purpose string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE user_tokens SET used_at = now() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;`,
+		userID, purpose)
+	return err
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE users SET email_verified = true, updated_at = now() WHERE id = $1;`, userID)
+	return err
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE users SET password_hash = $1, updated_at = now() WHERE id = $2;`, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
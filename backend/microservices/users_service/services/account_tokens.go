@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"users_module/mailer"
+	"users_module/models"
+	"users_module/repositories"
+)
+
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var (
+	ErrInvalidToken     = errors.New("invalid or expired token")
+	ErrEmailNotVerified = errors.New("email not verified")
+	ErrWeakPassword     = errors.New("weak password (min 8 chars)")
+)
+
+// issueUserToken pravi potpisan jednokratni token oblika <payload>.<hmac>;
+// u bazi se cuva samo hash celog tokena.
+func (s *authService) issueUserToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(buf)
+	raw := payload + "." + s.signUserToken(purpose, payload)
+
+	if err := s.repo.CreateUserToken(ctx, uuid.New(), userID, purpose, hashToken(raw), time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken proverava potpis i namenu tokena i trosi ga (jednokratna upotreba).
+func (s *authService) consumeUserToken(ctx context.Context, raw, purpose string) (uuid.UUID, error) {
+	raw = strings.TrimSpace(raw)
+	payload, sig, ok := strings.Cut(raw, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.signUserToken(purpose, payload))) {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	userID, err := s.repo.ConsumeUserToken(ctx, hashToken(raw), purpose)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTokenInvalid) {
+			return uuid.Nil, ErrInvalidToken
+		}
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+func (s *authService) signUserToken(purpose, payload string) string {
+	mac := hmac.New(sha256.New, s.tokenSecret)
+	mac.Write([]byte(purpose + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *authService) sendVerification(ctx context.Context, u models.User) error {
+	token, err := s.issueUserToken(ctx, u.Id, purposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "eUprava - potvrda email adrese",
+		Body: fmt.Sprintf("Zdravo %s,\n\npotvrdite email adresu otvaranjem linka:\n%s/verify-email?token=%s\n\nLink vazi %s.\n",
+			u.FirstName, s.appURL, token, verifyEmailTTL),
+	})
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, req models.TokenRequest) error {
+	userID, err := s.consumeUserToken(ctx, req.Token, purposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	return s.repo.MarkEmailVerified(ctx, userID)
+}
+
+// ResendVerification ne otkriva da li nalog postoji: nepoznat ili vec verifikovan email se tiho ignorise.
+func (s *authService) ResendVerification(ctx context.Context, req models.EmailRequest) error {
+	u, _, err := s.repo.GetByEmailOrUsername(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if u.EmailVerified {
+		return nil
+	}
+	if err := s.repo.InvalidateUserTokens(ctx, u.Id, purposeVerifyEmail); err != nil {
+		return err
+	}
+	return s.sendVerification(ctx, *u)
+}
+
+// ForgotPassword salje link za reset lozinke; kao i ResendVerification ne otkriva da li nalog postoji.
+func (s *authService) ForgotPassword(ctx context.Context, req models.EmailRequest) error {
+	u, _, err := s.repo.GetByEmailOrUsername(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !u.IsActive {
+		return nil
+	}
+
+	if err := s.repo.InvalidateUserTokens(ctx, u.Id, purposeResetPassword); err != nil {
+		return err
+	}
+	token, err := s.issueUserToken(ctx, u.Id, purposeResetPassword, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "eUprava - reset lozinke",
+		Body: fmt.Sprintf("Zdravo %s,\n\nlozinku mozete promeniti na linku:\n%s/reset-password?token=%s\n\nLink vazi %s. Ako niste trazili reset, ignorisite ovu poruku.\n",
+			u.FirstName, s.appURL, token, resetPasswordTTL),
+	})
+}
+
+// ResetPassword postavlja novu lozinku i odjavljuje korisnika sa svih uredjaja.
+func (s *authService) ResetPassword(ctx context.Context, req models.ResetPasswordRequest) error {
+	if len(req.Password) < 8 {
+		return ErrWeakPassword
+	}
+	userID, err := s.consumeUserToken(ctx, req.Token, purposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, hash); err != nil {
+		return err
+	}
+	if err := s.repo.InvalidateUserTokens(ctx, userID, purposeResetPassword); err != nil {
+		log.Printf("reset password: invalidate tokens for %s: %v", userID, err)
+	}
+	return s.repo.RevokeAllForUser(ctx, userID)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"users_module/models"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrSelfChange      = errors.New("cannot deactivate or demote your own account")
+	ErrInvalidPassword = errors.New("current password is incorrect")
+)
+
+// validRoles su role koje admin moze da dodeli.
+var validRoles = map[string]bool{
+	"admin":   true,
+	"student": true,
+	"cashier": true,
+}
+
+func (s *authService) ListUsers(ctx context.Context, f models.UserFilter) (models.UserPage, error) {
+	f.Query = strings.TrimSpace(f.Query)
+	f.Role = strings.TrimSpace(f.Role)
+	if f.Role != "" && !validRoles[f.Role] {
+		return models.UserPage{}, ErrInvalidRole
+	}
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Size < 1 {
+		f.Size = defaultPageSize
+	}
+	if f.Size > maxPageSize {
+		f.Size = maxPageSize
+	}
+
+	users, total, err := s.repo.ListUsers(ctx, f)
+	if err != nil {
+		return models.UserPage{}, err
+	}
+	return models.UserPage{Items: users, Total: total, Page: f.Page, Size: f.Size}, nil
+}
+
+// UpdateUser menja status i rolu naloga. Promena role opoziva postojece sesije
+// jer je rola upisana u vec izdate access tokene.
+func (s *authService) UpdateUser(ctx context.Context, actor uuid.UUID, id string, req models.UpdateUserRequest) (*models.UserDTO, error) {
+	userID, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if req.Role != nil && !validRoles[*req.Role] {
+		return nil, ErrInvalidRole
+	}
+	if userID == actor && ((req.IsActive != nil && !*req.IsActive) || (req.Role != nil && *req.Role != u.Role)) {
+		return nil, ErrSelfChange
+	}
+
+	if req.Role != nil && *req.Role != u.Role {
+		if err := s.repo.SetRole(ctx, userID, *req.Role); err != nil {
+			return nil, err
+		}
+		if err := s.repo.RevokeAllForUser(ctx, userID); err != nil {
+			return nil, err
+		}
+		u.Role = *req.Role
+	}
+	if req.IsActive != nil && *req.IsActive != u.IsActive {
+		if err := s.SetActive(ctx, userID.String(), *req.IsActive); err != nil {
+			return nil, err
+		}
+		u.IsActive = *req.IsActive
+	}
+
+	dto := toDTO(*u)
+	return &dto, nil
+}
+
+func (s *authService) Me(ctx context.Context, userID uuid.UUID) (*models.UserDTO, error) {
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	dto := toDTO(*u)
+	return &dto, nil
+}
+
+// UpdateProfile menja ime, prezime i email prijavljenog korisnika.
+// Nov email mora ponovo da se potvrdi pre sledece prijave.
+func (s *authService) UpdateProfile(ctx context.Context, userID uuid.UUID, req models.UpdateProfileRequest) (*models.UserDTO, error) {
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	oldEmail := u.Email
+
+	if req.FirstName != nil {
+		u.FirstName = strings.TrimSpace(*req.FirstName)
+	}
+	if req.LastName != nil {
+		u.LastName = strings.TrimSpace(*req.LastName)
+	}
+	if req.Email != nil {
+		u.Email = strings.ToLower(strings.TrimSpace(*req.Email))
+	}
+	if u.FirstName == "" || u.LastName == "" || u.Email == "" {
+		return nil, errors.New("firstname, lastname and email must not be empty")
+	}
+
+	if err := s.repo.UpdateProfile(ctx, u); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+
+	if u.Email != oldEmail {
+		if err := s.repo.InvalidateUserTokens(ctx, u.Id, purposeVerifyEmail); err != nil {
+			return nil, err
+		}
+		if err := s.sendVerification(ctx, *u); err != nil {
+			log.Printf("update profile: sending verification email to %s failed: %v", u.Email, err)
+		}
+	}
+
+	dto := toDTO(*u)
+	return &dto, nil
+}
+
+// ChangePassword menja lozinku uz proveru trenutne i odjavljuje korisnika sa svih uredjaja.
+func (s *authService) ChangePassword(ctx context.Context, userID uuid.UUID, req models.ChangePasswordRequest) error {
+	if len(req.NewPassword) < 8 {
+		return ErrWeakPassword
+	}
+	hash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.CurrentPassword)); err != nil {
+		return ErrInvalidPassword
+	}
+
+	next, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, next); err != nil {
+		return err
+	}
+	return s.repo.RevokeAllForUser(ctx, userID)
+}
+
+func toDTO(u models.User) models.UserDTO {
+	return models.UserDTO{
+		Id:        u.Id,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+		Email:     u.Email,
+		IsActive:  u.IsActive,
+		Role:      u.Role,
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unique") || strings.Contains(msg, "duplicate") || strings.Contains(msg, "23505")
+}
@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
 	"errors"
+	"log"
 	"strings"
 	"time"
 
@@ -10,37 +15,95 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"users_module/keys"
+	"users_module/mailer"
 	"users_module/models"
 	"users_module/repositories"
 )
 
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserExists         = errors.New("email or username already exists")
-	ErrUserDisabled       = errors.New("user disabled")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrUserExists          = errors.New("email or username already exists")
+	ErrUserDisabled        = errors.New("user disabled")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
 )
 
 type AuthService interface {
 	Register(ctx context.Context, req models.RegisterRequest) (models.User, error)
 	Login(ctx context.Context, req models.LoginRequest) (models.LoginResponse, error)
-	GetUser(ctx context.Context, id string) (*models.UserDTO, error)
+	Refresh(ctx context.Context, req models.RefreshRequest) (models.LoginResponse, error)
+	Logout(ctx context.Context, session models.Session, req models.LogoutRequest) error
+	Revocations(ctx context.Context) (models.RevocationList, error)
+	SetActive(ctx context.Context, id string, active bool) error
+	GetUser(ctx context.Context, username string) (*models.UserDTO, error)
+
+	ListUsers(ctx context.Context, f models.UserFilter) (models.UserPage, error)
+	UpdateUser(ctx context.Context, actor uuid.UUID, id string, req models.UpdateUserRequest) (*models.UserDTO, error)
+	Me(ctx context.Context, userID uuid.UUID) (*models.UserDTO, error)
+	UpdateProfile(ctx context.Context, userID uuid.UUID, req models.UpdateProfileRequest) (*models.UserDTO, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, req models.ChangePasswordRequest) error
+	UnlockUser(ctx context.Context, id string) error
+
+	LoginMFA(ctx context.Context, req models.MFALoginRequest) (models.LoginResponse, error)
+	LoginMFASetup(ctx context.Context, req models.MFALoginRequest) (models.MFAEnrollment, error)
+	LoginMFASetupConfirm(ctx context.Context, req models.MFALoginRequest) (models.LoginResponse, error)
+	MFAStatus(ctx context.Context, userID uuid.UUID) (models.MFAStatus, error)
+	EnrollMFA(ctx context.Context, userID uuid.UUID) (models.MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, userID uuid.UUID, req models.MFACodeRequest) (models.RecoveryCodes, error)
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req models.MFACodeRequest) (models.RecoveryCodes, error)
+	DisableMFA(ctx context.Context, userID uuid.UUID, req models.DisableMFARequest) error
+	ResetMFA(ctx context.Context, id string) error
+
+	VerifyEmail(ctx context.Context, req models.TokenRequest) error
+	ResendVerification(ctx context.Context, req models.EmailRequest) error
+	ForgotPassword(ctx context.Context, req models.EmailRequest) error
+	ResetPassword(ctx context.Context, req models.ResetPasswordRequest) error
+
+	ServiceToken(ctx context.Context, req models.ServiceTokenRequest) (models.ServiceTokenResponse, error)
+
+	JWKS() keys.JWKS
 }
 
 type authService struct {
-	repo      repositories.UserRepository
-	jwtSecret []byte
+	repo        repositories.UserRepository
+	attempts    loginAttempts
+	keys        *keys.Set // potpisivanje access tokena
+	tokenSecret []byte    // HMAC za jednokratne tokene (verifikacija email-a, reset lozinke)
+	mailer      mailer.Mailer
+	appURL      string // bazni URL frontenda za linkove u email porukama
+
+	serviceClients map[string]string // client_id -> client_secret servisa koji traze servisni token
 }
 
-func (s *authService) GetUser(ctx context.Context, id string) (*models.UserDTO, error) {
-	cleanID := strings.Trim(strings.TrimSpace(id), "\"")
-	return s.repo.GetUserByID(ctx, cleanID)
+func (s *authService) GetUser(ctx context.Context, username string) (*models.UserDTO, error) {
+	clean := strings.Trim(strings.TrimSpace(username), "\"")
+	u, err := s.repo.GetUserByUsername(ctx, clean)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return u, nil
 }
 
-func NewAuthService(repo repositories.UserRepository, jwtSecret string) AuthService {
-	return &authService{
-		repo:      repo,
-		jwtSecret: []byte(jwtSecret),
+func NewAuthService(repo repositories.UserRepository, ks *keys.Set, tokenSecret string, m mailer.Mailer, appURL string, serviceClients map[string]string) AuthService {
+	s := &authService{
+		repo:           repo,
+		keys:           ks,
+		tokenSecret:    []byte(tokenSecret),
+		mailer:         m,
+		appURL:         strings.TrimRight(appURL, "/"),
+		serviceClients: serviceClients,
 	}
+	s.attempts = &s.repo
+	return s
 }
 
 func (s *authService) Register(ctx context.Context, req models.RegisterRequest) (models.User, error) {
@@ -58,6 +121,8 @@ func (s *authService) Register(ctx context.Context, req models.RegisterRequest)
 		Email:     email,
 		IsActive:  true,
 		Role:      "student",
+
+		EmailVerified: false,
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -66,13 +131,16 @@ func (s *authService) Register(ctx context.Context, req models.RegisterRequest)
 	}
 
 	if err := s.repo.CreateUser(ctx, &u, hash); err != nil {
-		msg := strings.ToLower(err.Error())
-		if strings.Contains(msg, "unique") || strings.Contains(msg, "duplicate") || strings.Contains(msg, "23505") {
+		if isUniqueViolation(err) {
 			return models.User{}, ErrUserExists
 		}
 		return models.User{}, err
 	}
 
+	if err := s.sendVerification(ctx, u); err != nil {
+		log.Printf("register: sending verification email to %s failed: %v", u.Email, err)
+	}
+
 	return u, nil
 }
 
@@ -83,15 +151,108 @@ func (s *authService) Login(ctx context.Context, req models.LoginRequest) (model
 	}
 
 	u, hash, err := s.repo.GetByEmailOrUsername(ctx, ident)
-	if err != nil {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.LoginResponse{}, err
+	}
+	var userID *uuid.UUID
+	if u != nil {
+		userID = &u.Id
+	}
+
+	// zakljucan nalog ili IP se odbija pre bcrypt provere
+	keys := loginKeys(userID, ident, req.ClientIP)
+	if err := s.checkLoginLock(ctx, keys); err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	if u == nil || bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil {
+		s.recordLoginFailure(ctx, keys)
 		return models.LoginResponse{}, ErrInvalidCredentials
 	}
 	if !u.IsActive {
 		return models.LoginResponse{}, ErrUserDisabled
 	}
+	if !u.EmailVerified {
+		return models.LoginResponse{}, ErrEmailNotVerified
+	}
 
-	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil {
-		return models.LoginResponse{}, ErrInvalidCredentials
+	// nalog sa 2FA (i admin koji ga jos nije podesio) dobija challenge umesto sesije
+	if resp, ok, err := s.mfaChallenge(ctx, *u); err != nil || ok {
+		return resp, err
+	}
+
+	// uspesna prijava brise brojac naloga; brojac IP adrese ostaje
+	s.clearLoginFailures(ctx, keys)
+
+	return s.issueSession(ctx, *u)
+}
+
+// issueSession izdaje access token i refresh token nove familije (novi login).
+func (s *authService) issueSession(ctx context.Context, u models.User) (models.LoginResponse, error) {
+	token, err := s.signJWT(u)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	rt, raw, hash, err := newRefreshToken(u.Id, uuid.New())
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if err := s.repo.CreateRefreshToken(ctx, &rt, hash); err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return models.LoginResponse{
+		Token:        token,
+		RefreshToken: raw,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+		User:         &u,
+	}, nil
+}
+
+// Refresh rotira refresh token: stari se opoziva, izdaje se novi par tokena.
+// Ponovna upotreba vec rotiranog tokena opoziva celu familiju.
+func (s *authService) Refresh(ctx context.Context, req models.RefreshRequest) (models.LoginResponse, error) {
+	raw := strings.TrimSpace(req.RefreshToken)
+	if raw == "" {
+		return models.LoginResponse{}, ErrInvalidRefreshToken
+	}
+
+	old, err := s.repo.GetRefreshTokenByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginResponse{}, ErrInvalidRefreshToken
+		}
+		return models.LoginResponse{}, err
+	}
+	if old.RevokedAt != nil {
+		if old.ReplacedBy != nil {
+			_ = s.repo.RevokeRefreshFamily(ctx, old.FamilyId)
+		}
+		return models.LoginResponse{}, ErrInvalidRefreshToken
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return models.LoginResponse{}, ErrInvalidRefreshToken
+	}
+
+	u, err := s.repo.GetUserByUUID(ctx, old.UserId)
+	if err != nil {
+		return models.LoginResponse{}, ErrInvalidRefreshToken
+	}
+	if !u.IsActive {
+		return models.LoginResponse{}, ErrUserDisabled
+	}
+
+	next, nextRaw, nextHash, err := newRefreshToken(u.Id, old.FamilyId)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if err := s.repo.RotateRefreshToken(ctx, old.Id, &next, nextHash); err != nil {
+		if errors.Is(err, repositories.ErrTokenAlreadyRotated) {
+			_ = s.repo.RevokeRefreshFamily(ctx, old.FamilyId)
+			return models.LoginResponse{}, ErrInvalidRefreshToken
+		}
+		return models.LoginResponse{}, err
 	}
 
 	token, err := s.signJWT(*u)
@@ -99,18 +260,104 @@ func (s *authService) Login(ctx context.Context, req models.LoginRequest) (model
 		return models.LoginResponse{}, err
 	}
 
-	return models.LoginResponse{Token: token, User: *u}, nil
+	return models.LoginResponse{
+		Token:        token,
+		RefreshToken: nextRaw,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+		User:         u,
+	}, nil
+}
+
+// Logout opoziva tekuci access token i refresh token sesije; sa All opoziva sve sesije korisnika.
+func (s *authService) Logout(ctx context.Context, session models.Session, req models.LogoutRequest) error {
+	if req.All {
+		return s.repo.RevokeAllForUser(ctx, session.UserId)
+	}
+
+	if err := s.repo.RevokeAccessToken(ctx, session.TokenId, session.UserId, session.ExpiresAt); err != nil {
+		return err
+	}
+
+	if raw := strings.TrimSpace(req.RefreshToken); raw != "" {
+		rt, err := s.repo.GetRefreshTokenByHash(ctx, hashToken(raw))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if rt.UserId != session.UserId {
+			return nil
+		}
+		return s.repo.RevokeRefreshFamily(ctx, rt.FamilyId)
+	}
+	return nil
+}
+
+// Revocations vraca listu opoziva; opozivi stariji od zivotnog veka access tokena nisu potrebni.
+func (s *authService) Revocations(ctx context.Context) (models.RevocationList, error) {
+	return s.repo.ListRevocations(ctx, time.Now().Add(-accessTokenTTL))
+}
+
+// SetActive ukljucuje/iskljucuje nalog; deaktivacija odmah opoziva sve sesije korisnika.
+func (s *authService) SetActive(ctx context.Context, id string, active bool) error {
+	userID, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.repo.SetActive(ctx, userID, active); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if !active {
+		return s.repo.RevokeAllForUser(ctx, userID)
+	}
+	return nil
 }
 
 func (s *authService) signJWT(u models.User) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"sub":   u.Id.String(),
 		"usr":   u.Username,
 		"role":  u.Role,
 		"email": u.Email,
-		"exp":   time.Now().Add(24 * time.Hour).Unix(),
-		"iat":   time.Now().Unix(),
+		"typ":   "access",
+		"jti":   uuid.New().String(),
+		"exp":   now.Add(accessTokenTTL).Unix(),
+		"iat":   now.Unix(),
+	}
+	key := s.keys.Signing()
+	tok := jwt.NewWithClaims(key.Method, claims)
+	tok.Header["kid"] = key.ID
+	return tok.SignedString(key.Private)
+}
+
+// JWKS vraca javne kljuceve kojima ostali servisi verifikuju access tokene.
+func (s *authService) JWKS() keys.JWKS {
+	return s.keys.JWKS()
+}
+
+// newRefreshToken generise nasumican refresh token; u bazu ide samo njegov hash.
+func newRefreshToken(userID, familyID uuid.UUID) (models.RefreshToken, string, []byte, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return models.RefreshToken{}, "", nil, err
 	}
-	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return tok.SignedString(s.jwtSecret)
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+
+	t := models.RefreshToken{
+		Id:        uuid.New(),
+		UserId:    userID,
+		FamilyId:  familyID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	return t, raw, hashToken(raw), nil
+}
+
+func hashToken(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
 }
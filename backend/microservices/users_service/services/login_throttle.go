@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pravila za zakljucavanje prijave: posle praga neuspelih pokusaja kljuc se zakljucava
+// na lockBase, pa se trajanje duplira za svaki sledeci neuspeh (do lockMax).
+const (
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+	failureWindow           = 15 * time.Minute
+	lockBase                = 30 * time.Second
+	lockMax                 = time.Hour
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LockedError nosi vreme do isteka lockout-a; errors.Is(err, ErrTooManyAttempts) vazi za njega.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Unwrap() error { return ErrTooManyAttempts }
+
+// loginAttempts je deo repozitorijuma koji vodi brojace neuspelih prijava i lockout.
+type loginAttempts interface {
+	LoginLockedUntil(ctx context.Context, keys ...string) (time.Time, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ClearLoginAttempts(ctx context.Context, keys ...string) error
+}
+
+func accountKey(userID uuid.UUID) string { return "user:" + userID.String() }
+
+func identifierKey(ident string) string { return "ident:" + ident }
+
+func ipKey(ip string) string { return "ip:" + ip }
+
+// loginKeys vraca kljuceve pod kojima se broje neuspesi za dati pokusaj.
+// Postojeci nalog se broji po id-u da bi email i username delili brojac.
+func loginKeys(u *uuid.UUID, ident, ip string) []string {
+	keys := make([]string, 0, 2)
+	if u != nil {
+		keys = append(keys, accountKey(*u))
+	} else {
+		keys = append(keys, identifierKey(ident))
+	}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func (s *authService) checkLoginLock(ctx context.Context, keys []string) error {
+	until, err := s.attempts.LoginLockedUntil(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	if wait := time.Until(until); wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure belezi neuspeh za svaki kljuc i zakljucava one koji su presli prag.
+func (s *authService) recordLoginFailure(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		failures, err := s.attempts.RecordLoginFailure(ctx, key, failureWindow)
+		if err != nil {
+			log.Printf("login throttle: record failure for %s: %v", key, err)
+			continue
+		}
+
+		lock := lockFor(key, failures)
+		if lock == 0 {
+			continue
+		}
+		if err := s.attempts.LockLogin(ctx, key, time.Now().Add(lock)); err != nil {
+			log.Printf("login throttle: lock %s: %v", key, err)
+			continue
+		}
+		log.Printf("AUDIT login_locked key=%s failures=%d for=%s", key, failures, lock)
+	}
+}
+
+// lockFor vraca trajanje lockout-a za kljuc posle failures uzastopnih neuspeha (0 ispod praga).
+func lockFor(key string, failures int) time.Duration {
+	threshold := accountFailureThreshold
+	if strings.HasPrefix(key, "ip:") {
+		threshold = ipFailureThreshold
+	}
+	if failures < threshold {
+		return 0
+	}
+	return min(lockBase<<min(failures-threshold, 16), lockMax)
+}
+
+// clearLoginFailures posle uspesne prijave brise brojac naloga (keys[0]); brojac IP adrese ostaje.
+func (s *authService) clearLoginFailures(ctx context.Context, keys []string) {
+	if err := s.attempts.ClearLoginAttempts(ctx, keys[0]); err != nil {
+		log.Printf("login throttle: clear %s: %v", keys[0], err)
+	}
+}
+
+// UnlockUser ponistava lockout i brojac neuspelih prijava za nalog.
+func (s *authService) UnlockUser(ctx context.Context, id string) error {
+	userID, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return ErrUserNotFound
+	}
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return s.attempts.ClearLoginAttempts(ctx,
+		accountKey(u.Id),
+		identifierKey(strings.ToLower(u.Username)),
+		identifierKey(u.Email),
+	)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memAttempts vodi brojace neuspelih prijava u memoriji (bez isteka prozora).
+type memAttempts struct {
+	failures map[string]int
+	locked   map[string]time.Time
+}
+
+func newMemAttempts() *memAttempts {
+	return &memAttempts{failures: map[string]int{}, locked: map[string]time.Time{}}
+}
+
+func (m *memAttempts) LoginLockedUntil(_ context.Context, keys ...string) (time.Time, error) {
+	var until time.Time
+	for _, k := range keys {
+		if t := m.locked[k]; t.After(time.Now()) && t.After(until) {
+			until = t
+		}
+	}
+	return until, nil
+}
+
+func (m *memAttempts) RecordLoginFailure(_ context.Context, key string, _ time.Duration) (int, error) {
+	m.failures[key]++
+	return m.failures[key], nil
+}
+
+func (m *memAttempts) LockLogin(_ context.Context, key string, until time.Time) error {
+	m.locked[key] = until
+	return nil
+}
+
+func (m *memAttempts) ClearLoginAttempts(_ context.Context, keys ...string) error {
+	for _, k := range keys {
+		delete(m.failures, k)
+		delete(m.locked, k)
+	}
+	return nil
+}
+
+func TestLockFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		failures int
+		want     time.Duration
+	}{
+		{name: "nalog ispod praga", key: "user:x", failures: accountFailureThreshold - 1, want: 0},
+		{name: "nalog na pragu", key: "user:x", failures: accountFailureThreshold, want: lockBase},
+		{name: "nalog prag+1", key: "user:x", failures: accountFailureThreshold + 1, want: 2 * lockBase},
+		{name: "nalog prag+3", key: "user:x", failures: accountFailureThreshold + 3, want: 8 * lockBase},
+		{name: "nepoznat identifikator kao nalog", key: "ident:x", failures: accountFailureThreshold, want: lockBase},
+		{name: "IP ispod svog praga", key: "ip:1.2.3.4", failures: ipFailureThreshold - 1, want: 0},
+		{name: "IP na pragu", key: "ip:1.2.3.4", failures: ipFailureThreshold, want: lockBase},
+		{name: "ograniceno na lockMax", key: "user:x", failures: accountFailureThreshold + 7, want: lockMax},
+		{name: "bez prelivanja", key: "user:x", failures: 1000, want: lockMax},
+	}
+	for _, tt := range tests {
+		if got := lockFor(tt.key, tt.failures); got != tt.want {
+			t.Errorf("%s: lockFor(%s, %d) = %s, want %s", tt.name, tt.key, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginKeys(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name string
+		user *uuid.UUID
+		ip   string
+		want []string
+	}{
+		{name: "postojeci nalog po id-u", user: &id, ip: "1.2.3.4", want: []string{accountKey(id), "ip:1.2.3.4"}},
+		{name: "nepostojeci nalog po identifikatoru", ip: "1.2.3.4", want: []string{"ident:nikola", "ip:1.2.3.4"}},
+		{name: "bez IP adrese", user: &id, want: []string{accountKey(id)}},
+	}
+	for _, tt := range tests {
+		got := loginKeys(tt.user, "nikola", tt.ip)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: loginKeys = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: loginKeys = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRecordLoginFailureLockSchedule(t *testing.T) {
+	ctx := context.Background()
+	store := newMemAttempts()
+	s := &authService{attempts: store}
+	id := uuid.New()
+	keys := loginKeys(&id, "", "1.2.3.4")
+
+	for i := 1; i < accountFailureThreshold; i++ {
+		s.recordLoginFailure(ctx, keys)
+		if err := s.checkLoginLock(ctx, keys); err != nil {
+			t.Fatalf("neuspeh %d: %v, want bez lockout-a", i, err)
+		}
+	}
+
+	// svaki sledeci neuspeh duplira lockout naloga; IP je jos ispod svog praga
+	for i, want := range []time.Duration{lockBase, 2 * lockBase, 4 * lockBase} {
+		s.recordLoginFailure(ctx, keys)
+		got := time.Until(store.locked[keys[0]])
+		if got <= want-time.Second || got > want {
+			t.Errorf("neuspeh %d: lockout %s, want %s", accountFailureThreshold+i, got, want)
+		}
+	}
+	if _, ok := store.locked[keys[1]]; ok {
+		t.Errorf("IP zakljucan posle %d neuspeha, prag je %d", store.failures[keys[1]], ipFailureThreshold)
+	}
+}
+
+func TestCheckLoginLockRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	store := newMemAttempts()
+	s := &authService{attempts: store}
+	keys := []string{"user:x", "ip:1.2.3.4"}
+
+	store.locked["user:x"] = time.Now().Add(30 * time.Second)
+	store.locked["ip:1.2.3.4"] = time.Now().Add(90 * time.Second)
+	store.locked["ip:5.6.7.8"] = time.Now().Add(time.Hour)
+
+	err := s.checkLoginLock(ctx, keys)
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("err = %v, want LockedError", err)
+	}
+	// ceka se najkasniji lockout medju kljucevima pokusaja
+	if locked.RetryAfter <= 89*time.Second || locked.RetryAfter > 90*time.Second {
+		t.Errorf("RetryAfter = %s, want ~90s", locked.RetryAfter)
+	}
+
+	store.locked["user:x"] = time.Now().Add(-time.Second)
+	store.locked["ip:1.2.3.4"] = time.Now().Add(-time.Second)
+	if err := s.checkLoginLock(ctx, keys); err != nil {
+		t.Errorf("istekao lockout: %v, want nil", err)
+	}
+}
+
+func TestClearLoginFailuresKeepsIP(t *testing.T) {
+	ctx := context.Background()
+	store := newMemAttempts()
+	s := &authService{attempts: store}
+	keys := []string{"user:x", "ip:1.2.3.4"}
+
+	for range accountFailureThreshold {
+		s.recordLoginFailure(ctx, keys)
+	}
+	s.clearLoginFailures(ctx, keys)
+
+	if _, ok := store.failures["user:x"]; ok {
+		t.Error("brojac naloga nije obrisan posle uspesne prijave")
+	}
+	if _, ok := store.locked["user:x"]; ok {
+		t.Error("lockout naloga nije obrisan posle uspesne prijave")
+	}
+	if got := store.failures["ip:1.2.3.4"]; got != accountFailureThreshold {
+		t.Errorf("brojac IP adrese = %d, want %d", got, accountFailureThreshold)
+	}
+
+	// posle uspeha brojac naloga krece iz pocetka
+	s.recordLoginFailure(ctx, keys)
+	if err := s.checkLoginLock(ctx, keys); err != nil {
+		t.Errorf("prvi neuspeh posle uspeha: %v, want bez lockout-a", err)
+	}
+}
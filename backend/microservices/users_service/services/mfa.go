@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"users_module/models"
+	"users_module/repositories"
+)
+
+const (
+	// challenge token vezuje drugi korak prijave za nalog cija je lozinka vec proverena
+	challengeMFA      = "mfa"
+	challengeMFASetup = "mfa_setup"
+	challengeTTL      = 5 * time.Minute
+
+	recoveryCodeCount    = 10
+	recoveryCodeLen      = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrInvalidChallenge  = errors.New("invalid or expired 2fa challenge")
+	ErrInvalidMFACode    = errors.New("invalid 2fa code")
+	ErrMFAAlreadyEnabled = errors.New("2fa already enabled")
+	ErrMFANotEnabled     = errors.New("2fa not enabled")
+	ErrMFARequired       = errors.New("2fa is required for admin accounts")
+)
+
+// mfaRequired: admin nalozi moraju imati podesen 2FA.
+func mfaRequired(u models.User) bool {
+	return u.Role == "admin"
+}
+
+// mfaChallenge vraca challenge umesto sesije ako nalog ima 2FA ili ga mora podesiti.
+func (s *authService) mfaChallenge(ctx context.Context, u models.User) (models.LoginResponse, bool, error) {
+	m, err := s.repo.GetMFA(ctx, u.Id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.LoginResponse{}, false, err
+	}
+
+	typ := ""
+	switch {
+	case m != nil && m.Enabled:
+		typ = challengeMFA
+	case mfaRequired(u):
+		typ = challengeMFASetup
+	default:
+		return models.LoginResponse{}, false, nil
+	}
+
+	token, err := s.signChallenge(u.Id, typ)
+	if err != nil {
+		return models.LoginResponse{}, false, err
+	}
+	return models.LoginResponse{
+		MFARequired:      typ == challengeMFA,
+		MFASetupRequired: typ == challengeMFASetup,
+		ChallengeToken:   token,
+	}, true, nil
+}
+
+// LoginMFA zavrsava prijavu TOTP kodom ili rezervnim kodom.
+func (s *authService) LoginMFA(ctx context.Context, req models.MFALoginRequest) (models.LoginResponse, error) {
+	u, keys, err := s.challengeUser(ctx, req, challengeMFA)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	m, err := s.repo.GetMFA(ctx, u.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginResponse{}, ErrInvalidChallenge
+		}
+		return models.LoginResponse{}, err
+	}
+	if !m.Enabled {
+		return models.LoginResponse{}, ErrInvalidChallenge
+	}
+
+	if rc := normalizeRecoveryCode(req.RecoveryCode); rc != "" {
+		err = s.repo.ConsumeRecoveryCode(ctx, u.Id, hashToken(rc))
+		if errors.Is(err, repositories.ErrTokenInvalid) {
+			err = ErrInvalidMFACode
+		}
+		if err == nil {
+			log.Printf("AUDIT mfa_recovery_code_used user=%s", u.Id)
+		}
+	} else {
+		err = s.verifyTOTP(ctx, m, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, keys)
+		}
+		return models.LoginResponse{}, err
+	}
+
+	s.clearLoginFailures(ctx, keys)
+	return s.issueSession(ctx, *u)
+}
+
+// LoginMFASetup pokrece podesavanje 2FA za nalog koji bez njega ne moze da se prijavi.
+func (s *authService) LoginMFASetup(ctx context.Context, req models.MFALoginRequest) (models.MFAEnrollment, error) {
+	u, _, err := s.challengeUser(ctx, req, challengeMFASetup)
+	if err != nil {
+		return models.MFAEnrollment{}, err
+	}
+	return s.enroll(ctx, *u)
+}
+
+// LoginMFASetupConfirm potvrdjuje podesavanje prvim kodom i izdaje sesiju i rezervne kodove.
+func (s *authService) LoginMFASetupConfirm(ctx context.Context, req models.MFALoginRequest) (models.LoginResponse, error) {
+	u, keys, err := s.challengeUser(ctx, req, challengeMFASetup)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	codes, err := s.confirm(ctx, u.Id, req.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, keys)
+		}
+		return models.LoginResponse{}, err
+	}
+
+	s.clearLoginFailures(ctx, keys)
+	resp, err := s.issueSession(ctx, *u)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	resp.RecoveryCodes = codes
+	return resp, nil
+}
+
+func (s *authService) MFAStatus(ctx context.Context, userID uuid.UUID) (models.MFAStatus, error) {
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAStatus{}, ErrUserNotFound
+		}
+		return models.MFAStatus{}, err
+	}
+	st := models.MFAStatus{Required: mfaRequired(*u)}
+
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return st, nil
+		}
+		return models.MFAStatus{}, err
+	}
+	st.Enabled = m.Enabled
+	if m.Enabled {
+		if st.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+			return models.MFAStatus{}, err
+		}
+	}
+	return st, nil
+}
+
+func (s *authService) EnrollMFA(ctx context.Context, userID uuid.UUID) (models.MFAEnrollment, error) {
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAEnrollment{}, ErrUserNotFound
+		}
+		return models.MFAEnrollment{}, err
+	}
+	return s.enroll(ctx, *u)
+}
+
+func (s *authService) ConfirmMFA(ctx context.Context, userID uuid.UUID, req models.MFACodeRequest) (models.RecoveryCodes, error) {
+	codes, err := s.confirm(ctx, userID, req.Code)
+	if err != nil {
+		return models.RecoveryCodes{}, err
+	}
+	return models.RecoveryCodes{Codes: codes}, nil
+}
+
+// RegenerateRecoveryCodes ponistava stare rezervne kodove i izdaje nove.
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, req models.MFACodeRequest) (models.RecoveryCodes, error) {
+	m, err := s.enabledMFA(ctx, userID)
+	if err != nil {
+		return models.RecoveryCodes{}, err
+	}
+	if err := s.verifyTOTP(ctx, m, req.Code); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return models.RecoveryCodes{}, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+	return models.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableMFA iskljucuje 2FA uz lozinku i vazeci kod; admin nalozi ga ne mogu iskljuciti.
+func (s *authService) DisableMFA(ctx context.Context, userID uuid.UUID, req models.DisableMFARequest) error {
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if mfaRequired(*u) {
+		return ErrMFARequired
+	}
+
+	hash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil {
+		return ErrInvalidPassword
+	}
+	m, err := s.enabledMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyTOTP(ctx, m, req.Code); err != nil {
+		return err
+	}
+	return s.repo.DeleteMFA(ctx, userID)
+}
+
+// ResetMFA (admin) brise 2FA naloga koji je izgubio uredjaj i odjavljuje ga svuda;
+// admin nalog ce pri sledecoj prijavi morati ponovo da ga podesi.
+func (s *authService) ResetMFA(ctx context.Context, id string) error {
+	userID, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if _, err := s.repo.GetUserByUUID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if err := s.repo.DeleteMFA(ctx, userID); err != nil {
+		return err
+	}
+	log.Printf("AUDIT mfa_reset user=%s", userID)
+	return s.repo.RevokeAllForUser(ctx, userID)
+}
+
+func (s *authService) enroll(ctx context.Context, u models.User) (models.MFAEnrollment, error) {
+	m, err := s.repo.GetMFA(ctx, u.Id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.MFAEnrollment{}, err
+	}
+	if m != nil && m.Enabled {
+		return models.MFAEnrollment{}, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return models.MFAEnrollment{}, err
+	}
+	if err := s.repo.SavePendingMFA(ctx, u.Id, secret); err != nil {
+		return models.MFAEnrollment{}, err
+	}
+	return models.MFAEnrollment{Secret: secret, URI: totpURI(secret, u.Username)}, nil
+}
+
+func (s *authService) confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if m.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.verifyTOTP(ctx, m, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableMFA(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	log.Printf("AUDIT mfa_enabled user=%s", userID)
+	return codes, nil
+}
+
+func (s *authService) enabledMFA(ctx context.Context, userID uuid.UUID) (*models.MFA, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if !m.Enabled {
+		return nil, ErrMFANotEnabled
+	}
+	return m, nil
+}
+
+// verifyTOTP proverava kod i trosi njegov vremenski korak.
+func (s *authService) verifyTOTP(ctx context.Context, m *models.MFA, code string) error {
+	step, ok := matchTOTP(m.Secret, code, time.Now())
+	if !ok || step <= m.LastStep {
+		return ErrInvalidMFACode
+	}
+	if err := s.repo.UseTOTPStep(ctx, m.UserId, step); err != nil {
+		if errors.Is(err, repositories.ErrStepUsed) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	return nil
+}
+
+// challengeUser proverava challenge token i vraca aktivan nalog i kljuceve za ogranicavanje pokusaja.
+func (s *authService) challengeUser(ctx context.Context, req models.MFALoginRequest, typ string) (*models.User, []string, error) {
+	userID, err := s.parseChallenge(ctx, req.ChallengeToken, typ)
+	if err != nil {
+		return nil, nil, ErrInvalidChallenge
+	}
+	u, err := s.repo.GetUserByUUID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrInvalidChallenge
+		}
+		return nil, nil, err
+	}
+	if !u.IsActive {
+		return nil, nil, ErrUserDisabled
+	}
+
+	keys := loginKeys(&u.Id, "", req.ClientIP)
+	if err := s.checkLoginLock(ctx, keys); err != nil {
+		return nil, nil, err
+	}
+	return u, keys, nil
+}
+
+func (s *authService) signChallenge(userID uuid.UUID, typ string) (string, error) {
+	now := time.Now()
+	key := s.keys.Signing()
+	tok := jwt.NewWithClaims(key.Method, jwt.MapClaims{
+		"sub": userID.String(),
+		"typ": typ,
+		"jti": uuid.New().String(),
+		"exp": now.Add(challengeTTL).Unix(),
+		"iat": now.Unix(),
+	})
+	tok.Header["kid"] = key.ID
+	return tok.SignedString(key.Private)
+}
+
+func (s *authService) parseChallenge(ctx context.Context, raw, typ string) (uuid.UUID, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(raw), claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return s.keys.PublicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if t, _ := claims["typ"].(string); t != typ {
+		return uuid.Nil, ErrInvalidChallenge
+	}
+	sub, _ := claims["sub"].(string)
+	return uuid.Parse(sub)
+}
+
+// newRecoveryCodes vraca kodove za prikaz korisniku (xxxxx-xxxxx) i njihove hash-eve za bazu.
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+	buf := make([]byte, recoveryCodeLen)
+	for range recoveryCodeCount {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := make([]byte, recoveryCodeLen)
+		for i, b := range buf {
+			code[i] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		codes = append(codes, string(code[:recoveryCodeLen/2])+"-"+string(code[recoveryCodeLen/2:]))
+		hashes = append(hashes, hashToken(string(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
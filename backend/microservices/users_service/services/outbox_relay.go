@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"users_module/keys"
+	"users_module/models"
+	"users_module/repositories"
+)
+
+const (
+	outboxBatchSize = 100
+	serviceTokenTTL = time.Minute
+)
+
+// OutboxRelay isporucuje dogadjaje iz outbox tabele pretplatnicima (POST {"events": [...]}).
+// Dogadjaj je objavljen tek kada ga prihvate svi pretplatnici; isporuka je "at least once",
+// pa pretplatnici dogadjaje obradjuju idempotentno po id-u.
+type OutboxRelay struct {
+	repo        repositories.UserRepository
+	keys        *keys.Set
+	subscribers []string
+	client      *http.Client
+}
+
+func NewOutboxRelay(repo repositories.UserRepository, ks *keys.Set, subscribers []string) *OutboxRelay {
+	return &OutboxRelay{
+		repo:        repo,
+		keys:        ks,
+		subscribers: subscribers,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Run isporucuje dogadjaje na svakih every dok se ctx ne otkaze.
+func (o *OutboxRelay) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		for {
+			n, err := o.publishPending(ctx)
+			if err != nil {
+				log.Printf("outbox: publish failed: %v", err)
+			}
+			if err != nil || n < outboxBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (o *OutboxRelay) publishPending(ctx context.Context) (int, error) {
+	events, err := o.repo.PendingOutboxEvents(ctx, outboxBatchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	ids := make([]uuid.UUID, len(events))
+	for i, e := range events {
+		ids[i] = e.Id
+	}
+
+	body, err := json.Marshal(map[string][]models.OutboxEvent{"events": events})
+	if err != nil {
+		return 0, err
+	}
+	token, err := o.serviceToken()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, url := range o.subscribers {
+		if err := o.deliver(ctx, url, token, body); err != nil {
+			err = fmt.Errorf("%s: %w", url, err)
+			if mErr := o.repo.MarkOutboxFailed(ctx, ids, err.Error()); mErr != nil {
+				log.Printf("outbox: mark failed: %v", mErr)
+			}
+			return 0, err
+		}
+	}
+	return len(events), o.repo.MarkOutboxPublished(ctx, ids)
+}
+
+func (o *OutboxRelay) deliver(ctx context.Context, url, token string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// serviceToken je kratkotrajan access token kojim se relay predstavlja pretplatnicima.
+func (o *OutboxRelay) serviceToken() (string, error) {
+	return signServiceToken(o.keys, "users_service", serviceTokenTTL)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"users_module/keys"
+	"users_module/models"
+)
+
+// clientTokenTTL: servisni tokeni izdati drugim servisima (client credentials).
+const clientTokenTTL = 5 * time.Minute
+
+var ErrInvalidClient = errors.New("invalid client credentials")
+
+// ParseServiceClients cita SERVICE_CLIENTS oblika "dining_service:tajna,housing_service:tajna2".
+func ParseServiceClients(s string) map[string]string {
+	clients := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && id != "" && secret != "" {
+			clients[id] = secret
+		}
+	}
+	return clients
+}
+
+// ServiceToken izdaje access token sa rolom "service" servisu koji se predstavi
+// svojim client_id/client_secret; koristi ga za pozive bez korisnika (pozadinski poslovi).
+func (s *authService) ServiceToken(ctx context.Context, req models.ServiceTokenRequest) (models.ServiceTokenResponse, error) {
+	secret, ok := s.serviceClients[req.ClientID]
+	want := sha256.Sum256([]byte(secret))
+	got := sha256.Sum256([]byte(req.ClientSecret))
+	if subtle.ConstantTimeCompare(want[:], got[:]) != 1 || !ok {
+		log.Printf("AUDIT service_token_denied client=%q", req.ClientID)
+		return models.ServiceTokenResponse{}, ErrInvalidClient
+	}
+
+	token, err := signServiceToken(s.keys, req.ClientID, clientTokenTTL)
+	if err != nil {
+		return models.ServiceTokenResponse{}, err
+	}
+	return models.ServiceTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(clientTokenTTL.Seconds()),
+	}, nil
+}
+
+// serviceSubject je stabilan "sub" servisnog tokena za dati servis.
+func serviceSubject(name string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("urn:eUprava:service:"+name))
+}
+
+// signServiceToken potpisuje access token sa rolom "service"; verifikuje se preko JWKS-a
+// kao i korisnicki tokeni.
+func signServiceToken(ks *keys.Set, name string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	key := ks.Signing()
+	tok := jwt.NewWithClaims(key.Method, jwt.MapClaims{
+		"sub":  serviceSubject(name).String(),
+		"usr":  name,
+		"role": "service",
+		"typ":  "access",
+		"jti":  uuid.New().String(),
+		"exp":  now.Add(ttl).Unix(),
+		"iat":  now.Unix(),
+	})
+	tok.Header["kid"] = key.ID
+	return tok.SignedString(key.Private)
+}
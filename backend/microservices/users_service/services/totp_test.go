@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238, Dodatak B (SHA1, kljuc "12345678901234567890"); ocekivani kodovi su
+// poslednjih totpDigits cifara osmocifrenih vrednosti iz RFC-a.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, v := range rfc6238Vectors {
+		want := v.code[len(v.code)-totpDigits:]
+		if got := totpCode(key, v.unix/totpPeriod); got != want {
+			t.Errorf("T=%d: totpCode = %s, want %s", v.unix, got, want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+	step := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+		step int64
+	}{
+		{name: "tekuci korak", code: "081804", ok: true, step: step},
+		{name: "sa razmakom", code: " 081 804 ", ok: true, step: step},
+		{name: "prethodni korak", code: totpCode(key, step-1), ok: true, step: step - 1},
+		{name: "sledeci korak", code: totpCode(key, step+1), ok: true, step: step + 1},
+		{name: "van tolerancije", code: totpCode(key, step-totpSkew-1)},
+		{name: "pogresan kod", code: "000000"},
+		{name: "kratak kod", code: "08180"},
+	}
+	for _, tt := range tests {
+		got, ok := matchTOTP(secret, tt.code, now)
+		if ok != tt.ok || (ok && got != tt.step) {
+			t.Errorf("%s: matchTOTP = %d, %v; want %d, %v", tt.name, got, ok, tt.step, tt.ok)
+		}
+	}
+
+	if _, ok := matchTOTP("nije-base32!", "081804", now); ok {
+		t.Error("neispravna tajna ne sme proci")
+	}
+}
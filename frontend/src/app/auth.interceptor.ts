@@ -0,0 +1,35 @@
+import { inject } from '@angular/core';
+import { HttpErrorResponse, HttpInterceptorFn } from '@angular/common/http';
+import { catchError, switchMap, throwError } from 'rxjs';
+import { AuthService } from './services/auth.service';
+
+const AUTH_ENDPOINTS = ['/api/login', '/api/register', '/api/token/refresh'];
+
+// Dodaje JWT (Authorization: Bearer ...) na sve zahteve ka backend servisima.
+// Na 401 jednom pokusava refresh tokena pa ponavlja zahtev.
+export const authInterceptor: HttpInterceptorFn = (req, next) => {
+  const auth = inject(AuthService);
+  if (AUTH_ENDPOINTS.some(p => req.url.includes(p))) {
+    return next(req);
+  }
+
+  const withToken = (token: string | null) =>
+    token && !req.headers.has('Authorization')
+      ? req.clone({ setHeaders: { Authorization: `Bearer ${token}` } })
+      : req;
+
+  return next(withToken(auth.token)).pipe(
+    catchError((err: HttpErrorResponse) => {
+      if (err.status !== 401 || !auth.refreshToken) {
+        return throwError(() => err);
+      }
+      return auth.refresh().pipe(
+        switchMap(token => next(withToken(token))),
+        catchError(refreshErr => {
+          auth.clearSession();
+          return throwError(() => refreshErr);
+        })
+      );
+    })
+  );
+};
@@ -3,7 +3,7 @@ import { CommonModule } from '@angular/common';
 import { FormsModule } from '@angular/forms';
 import { Router, RouterLink } from '@angular/router';
 import { AuthService } from '../services/auth.service';
-import { LoginRequest } from '../model/auth';
+import { LoginRequest, LoginResponse, MFAEnrollment } from '../model/auth';
 
 
 @Component({
@@ -21,20 +21,73 @@ export class Login {
   loading = false;
   error: string | null = null;
 
+  // drugi korak prijave (2FA)
+  challengeToken: string | null = null;
+  setupRequired = false;
+  enrollment: MFAEnrollment | null = null;
+  recoveryCodes: string[] = [];
+  code = '';
+  useRecoveryCode = false;
+
   onSubmit() {
     if (this.loading) return;
     this.error = null;
     this.loading = true;
 
     this.auth.login(this.model).subscribe({
-      next: () => {
-        this.loading = false;
-        this.router.navigateByUrl('/home');
-      },
+      next: (res) => this.handleLogin(res),
       error: (e) => {
         this.loading = false;
         this.error = e.message || 'Neuspešna prijava';
       }
     });
   }
+
+  onSubmitCode() {
+    if (this.loading || !this.challengeToken) return;
+    this.error = null;
+    this.loading = true;
+
+    const body = this.useRecoveryCode
+      ? { challenge_token: this.challengeToken, recovery_code: this.code }
+      : { challenge_token: this.challengeToken, code: this.code };
+    const req = this.setupRequired ? this.auth.mfaSetupConfirm(body) : this.auth.loginMfa(body);
+
+    req.subscribe({
+      next: (res) => this.handleLogin(res),
+      error: (e) => {
+        this.loading = false;
+        this.code = '';
+        this.error = e.message || 'Neispravan kod';
+      }
+    });
+  }
+
+  continueToHome() {
+    this.router.navigateByUrl('/home');
+  }
+
+  private handleLogin(res: LoginResponse) {
+    this.loading = false;
+    if (res.challenge_token) {
+      this.challengeToken = res.challenge_token;
+      this.setupRequired = !!res.mfa_setup_required;
+      if (this.setupRequired) this.startSetup();
+      return;
+    }
+    // rezervni kodovi se prikazuju samo jednom, posle podesavanja 2FA
+    if (res.recovery_codes?.length) {
+      this.recoveryCodes = res.recovery_codes;
+      this.challengeToken = null;
+      return;
+    }
+    this.router.navigateByUrl('/home');
+  }
+
+  private startSetup() {
+    this.auth.mfaSetup(this.challengeToken!).subscribe({
+      next: (e) => this.enrollment = e,
+      error: (e) => this.error = e.message || 'Podešavanje 2FA nije uspelo'
+    });
+  }
 }
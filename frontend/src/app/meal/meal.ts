@@ -2,21 +2,26 @@ import { Component, OnInit, inject, ChangeDetectorRef } from '@angular/core';
 import { ActivatedRoute } from '@angular/router';
 import { CommonModule } from '@angular/common';
 import { FormBuilder, FormControl, FormGroup, ReactiveFormsModule } from '@angular/forms';
-import { MenuService, MenuWithCard } from '../services/menu.service';
-import { Menu } from '../model/menus';
+import { DishChoice, MealPass, MealQuote, MealSlot, MenuService, MenuWithCard } from '../services/menu.service';
+import { Dish, Menu } from '../model/menus';
 import { AuthService } from '../services/auth.service';
+import { Money, toMinor, fromMinor } from '../model/money';
+import { MoneyPipe } from '../money.pipe';
 
 @Component({
   selector: 'app-meal',
   standalone: true,
-  imports: [CommonModule, ReactiveFormsModule],
+  imports: [CommonModule, ReactiveFormsModule, MoneyPipe],
   templateUrl: './meal.html'
 })
 export class MealComponent implements OnInit {
   menu: Menu | null = null;
-  studentCard?: { id: string; stanje: number; studentID: string };
+  studentCard?: { id: string; stanje: Money; studentID: string };
   form!: FormGroup;
-  totalPrice = 0;
+  totalPrice = 0; // u parama
+  quote: MealQuote | null = null; // cena sa subvencijama, racuna je server
+  mealPass: MealPass | null = null; // QR za kasu
+  private purchaseKey = crypto.randomUUID(); // novi kljuc za svaku novu kupovinu
   studentId = null;
   menuId = null;
 
@@ -37,7 +42,11 @@ export class MealComponent implements OnInit {
     this.form = new FormGroup({
       breakfast: new FormControl(false),
       lunch: new FormControl(false),
-      dinner: new FormControl(false)
+      dinner: new FormControl(false),
+      // izabrano jelo po slotu ('' = podrazumevano)
+      breakfastDish: new FormControl(''),
+      lunchDish: new FormControl(''),
+      dinnerDish: new FormControl('')
     });
 
     // Učitaj userId iz localStorage
@@ -53,20 +62,73 @@ export class MealComponent implements OnInit {
         this.menu = res.menu;
         this.studentCard = res.card;
 
-        this.form.reset({ breakfast: false, lunch: false, dinner: false });
+        this.form.reset({ breakfast: false, lunch: false, dinner: false, breakfastDish: '', lunchDish: '', dinnerDish: '' });
         this.cd.detectChanges();
       },
       error: err => console.error('Error loading menu:', err)
     });
 
     this.form.valueChanges.subscribe(val => {
+      this.purchaseKey = crypto.randomUUID();
+      this.mealPass = null;
       this.totalPrice = 0;
-      if (val.breakfast && this.menu?.breakfast) this.totalPrice += this.menu.breakfast.price;
-      if (val.lunch && this.menu?.lunch) this.totalPrice += this.menu.lunch.price;
-      if (val.dinner && this.menu?.dinner) this.totalPrice += this.menu.dinner.price;
+      for (const slot of this.slots) {
+        const dish = this.chosenDish(slot);
+        if (dish) this.totalPrice += this.priceOf(dish.price);
+        else if (this.menu?.[slot]) this.totalPrice += this.priceOf(this.menu[slot].price);
+      }
+      this.refreshQuote();
+    });
+  }
+
+
+  get total(): Money {
+    return this.quote?.amount ?? fromMinor(this.totalPrice, this.studentCard?.stanje.currency);
+  }
+
+  canAfford(): boolean {
+    return !!this.studentCard && toMinor(this.studentCard.stanje) >= toMinor(this.total);
+  }
+
+  private get slots(): MealSlot[] {
+    const val = this.form.value;
+    return (['breakfast', 'lunch', 'dinner'] as MealSlot[]).filter(s => val[s]);
+  }
+
+  dishesFor(slot: MealSlot): Dish[] {
+    return (this.menu?.dishes ?? []).filter(d => d.slot === slot);
+  }
+
+  private chosenDish(slot: MealSlot): Dish | undefined {
+    const id = this.form.value[slot + 'Dish'];
+    return id ? this.dishesFor(slot).find(d => d.id === id) : undefined;
+  }
+
+  private get dishes(): DishChoice {
+    const choice: DishChoice = {};
+    for (const slot of this.slots) {
+      const id = this.form.value[slot + 'Dish'];
+      if (id) choice[slot] = id;
+    }
+    return choice;
+  }
+
+  private refreshQuote() {
+    this.quote = null;
+    const slots = this.slots;
+    if (!this.menuId || slots.length === 0) return;
+    this.menuService.quoteMeal({ menuId: this.menuId, slots, dishes: this.dishes }).subscribe({
+      next: q => {
+        this.quote = q;
+        this.cd.detectChanges();
+      },
+      error: err => console.error('Error loading price:', err)
     });
   }
 
+  private priceOf(p: Money | number): number {
+    return typeof p === 'number' ? Math.round(p * 100) : toMinor(p);
+  }
 
   submit() {
     if (!this.studentCard) {
@@ -74,26 +136,48 @@ export class MealComponent implements OnInit {
       return;
     }
 
-    if (this.studentCard.stanje < this.totalPrice) {
+    if (!this.canAfford()) {
       alert("You do not have enough balance on your student card for this purchase!");
       return;
     }
 
     const payload = {
-      studentUsername: this.authService.username,
-      delta: -this.totalPrice,
-      menuId: this.menuId,
-      studentId: this.authService.userId
+      menuId: this.menuId!,
+      slots: this.slots,
+      dishes: this.dishes
     };
 
-    this.menuService.takeMeal(payload).subscribe({
+    this.menuService.takeMeal(payload, this.purchaseKey).subscribe({
       next: res => {
-        console.log("Purchase successful:", res);
-        alert("Meal successfully purchased!");
+        this.purchaseKey = crypto.randomUUID();
+        alert(res.state === 'completed'
+          ? "Meal successfully purchased!"
+          : "Your purchase is being processed.");
       },
       error: err => {
         console.error("Purchase failed:", err);
-        alert("Error while purchasing meal");
+        if (err.status === 402) {
+          alert("You do not have enough balance on your student card for this purchase!");
+        } else if (err.status === 409 && typeof err.error === 'string') {
+          alert(err.error); // zatvorena kantina, van vremena izdavanja, obrok vec uzet danas ili jelo rasprodato
+        } else {
+          alert("Error while purchasing meal");
+        }
+      }
+    });
+  }
+
+  // QR propusnica: kasa je skenira i tada se obrok naplacuje
+  showPass() {
+    if (!this.menuId || this.slots.length === 0) return;
+    this.menuService.getMealPass({ menuId: this.menuId, slots: this.slots, dishes: this.dishes }).subscribe({
+      next: pass => {
+        this.mealPass = pass;
+        this.cd.detectChanges();
+      },
+      error: err => {
+        console.error("Meal pass failed:", err);
+        alert(err.status === 409 && typeof err.error === 'string' ? err.error : "Error while creating meal pass");
       }
     });
   }
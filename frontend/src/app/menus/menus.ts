@@ -6,11 +6,12 @@ import { Menu, Weekday } from '../model/menus';
 import { MenuService } from '../services/menu.service';
 import { AuthService} from '../services/auth.service';
 import {Observable} from 'rxjs';
+import { MoneyPipe } from '../money.pipe';
 
 @Component({
   selector: 'app-menus',
   standalone: true,
-  imports: [CommonModule, FormsModule, RouterModule],
+  imports: [CommonModule, FormsModule, RouterModule, MoneyPipe],
   templateUrl: './menus.html',
   styleUrls: ['./menus.css']
 })
@@ -4,8 +4,27 @@ export interface LoginRequest {
 }
 
 export interface LoginResponse {
-  token: string;
-  user: User;
+  token?: string;
+  refresh_token?: string;
+  expires_in?: number; // sekunde
+  user?: User;
+
+  // nalog sa 2FA: umesto sesije stize challenge za drugi korak prijave
+  mfa_required?: boolean;
+  mfa_setup_required?: boolean;
+  challenge_token?: string;
+  recovery_codes?: string[];
+}
+
+export interface MFALoginRequest {
+  challenge_token: string;
+  code?: string;
+  recovery_code?: string;
+}
+
+export interface MFAEnrollment {
+  secret: string;
+  otpauth_uri: string;
 }
 
 export interface RegisterRequest {
@@ -1,3 +1,5 @@
+import { Money } from './money';
+
 export interface UUID extends String {}
 
 export interface Dom {
@@ -52,15 +54,242 @@ export interface Kvar {
 
 export interface StudentskaKartica {
   id: string;
-  stanje: number;
+  stanje: Money;
   studentUsername: string; // server šalje 'studentID' (camel case kao u domen modelu)
 }
 
+export type TipTransakcije = 'top_up' | 'meal_charge' | 'refund' | 'adjustment';
+
+export interface KarticaTransakcija {
+  id: string;
+  karticaId: string;
+  studentUsername: string;
+  iznos: Money;
+  tip: TipTransakcije;
+  referencaId?: string;
+  izvrsio: string;
+  vreme: string;
+}
+
+export interface TransakcijePage {
+  items: KarticaTransakcija[];
+  total: number;
+  page: number;
+  size: number;
+}
+
+export type StatusUplate = 'kreirana' | 'uspesna' | 'neuspesna' | 'vracena';
+
+// Dopuna kartice preko platnog provajdera; kartica se dopunjuje kada provajder potvrdi placanje
+export interface Uplata {
+  id: string;
+  studentUsername: string;
+  iznos: Money;
+  status: StatusUplate;
+  provajder: string;
+  intentId?: string;
+  checkoutUrl?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export type StatusPrijave = 'podneta' | 'povucena' | 'primljena' | 'lista_cekanja';
+
+// Prijava za smestaj za skolsku godinu (npr. "2025/2026"), jedna po studentu i godini
+export interface PrijavaZaSmestaj {
+  id: string;
+  skolskaGodina: string;
+  studentUsername: string;
+  prosek: number;
+  udaljenostKm: number;
+  socijalniStatus?: string;
+  domovi: string[];      // zeljeni domovi redom; prazno = bilo koji
+  tipSobe?: number;      // zeljeni broj kreveta
+  cimeri: string[];
+  status: StatusPrijave;
+  bodovi?: number;
+  rang?: number;
+  sobaId?: string;
+  pozicijaCekanja?: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface KriterijumiBodovanja {
+  skolskaGodina: string;
+  tezinaProsek: number;
+  tezinaUdaljenost: number;
+  maxUdaljenostKm: number;
+  socijalniBodovi: { [status: string]: number };
+  updatedBy?: string;
+  updatedAt?: string;
+}
+
+export interface Raspodela {
+  skolskaGodina: string;
+  status: 'nacrt' | 'objavljena';
+  pokrenuo: string;
+  pokrenutaAt: string;
+  objavljenaAt?: string;
+  primljeno: number;
+  naListiCekanja: number;
+}
+
+export interface StavkaRezultata {
+  rang: number;
+  studentUsername: string;
+  ime: string;
+  prezime: string;
+  bodovi: number;
+  status: StatusPrijave;
+  domId?: string;
+  dom?: string;
+  sobaId?: string;
+  brojSobe?: string;
+  pozicijaCekanja?: number;
+}
+
+export interface RezultatRaspodele {
+  raspodela: Raspodela;
+  primljeni: StavkaRezultata[];
+  listaCekanja: StavkaRezultata[];
+}
+
+export type StatusCekanja = 'ceka' | 'smesten' | 'uklonjen';
+
+// Mesto studenta na listi cekanja doma
+export interface ListaCekanjaStavka {
+  id: string;
+  domId: string;
+  studentUsername: string;
+  tipSobe?: number;
+  status: StatusCekanja;
+  pozicija?: number;     // trenutno mesto na listi, dok student ceka
+  createdAt: string;
+  updatedAt: string;
+}
+
+export type StatusPonude = 'ponudjena' | 'prihvacena' | 'odbijena' | 'istekla' | 'ponistena';
+
+// Vremenski ogranicena ponuda oslobodjenog mesta u sobi
+export interface PonudaSobe {
+  id: string;
+  listaId: string;
+  studentUsername: string;
+  sobaId: string;
+  domId: string;
+  brojSobe: string;
+  status: StatusPonude;
+  isticeAt: string;
+  createdAt: string;
+  resenaAt?: string;
+}
+
+export type TipZahteva = 'premestaj' | 'zamena';
+export type StatusZahteva = 'ceka_cimera' | 'ceka_odobrenje' | 'odobren' | 'odbijen' | 'povucen';
+
+// Zahtev za premestaj (u sobu ili bilo koju sobu doma) ili zamenu soba sa drugim studentom
+export interface ZahtevPremestaja {
+  id: string;
+  tip: TipZahteva;
+  studentUsername: string;
+  sobaId: string;
+  ciljnaSobaId?: string;
+  ciljniDomId?: string;
+  drugiStudent?: string;
+  drugaSobaId?: string;
+  obrazlozenje?: string;
+  status: StatusZahteva;
+  novaSobaId?: string;
+  resio?: string;
+  razlogOdbijanja?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+// Boravak studenta u sobi; bez iseljenAt dok student jos stanuje u sobi
+export interface Boravak {
+  id: string;
+  studentUsername: string;
+  ime: string;
+  prezime: string;
+  sobaId: string;
+  brojSobe: string;
+  domId: string;
+  dom: string;
+  useljenAt: string;
+  iseljenAt?: string;
+}
+
+// Kategorija cene smestaja; dodeljuje se domu, a soba moze imati svoju
+export interface KategorijaCene {
+  id: string;
+  naziv: string;
+  mesecnaCena: Money;
+  updatedAt: string;
+}
+
+export type StatusRacuna = 'otvoren' | 'placen';
+
+export interface UplataStanarine {
+  id: string;
+  racunId: string;
+  iznos: Money;
+  napomena?: string;
+  evidentirao: string;
+  createdAt: string;
+}
+
+// Mesecni racun stanarine za jedan boravak, srazmeran broju dana (kraj je prvi nenaplaceni dan)
+export interface RacunStanarine {
+  id: string;
+  boravakId: string;
+  studentUsername: string;
+  sobaId: string;
+  brojSobe: string;
+  domId: string;
+  dom: string;
+  period: string;
+  pocetak: string;
+  kraj: string;
+  dana: number;
+  danaUMesecu: number;
+  mesecnaCena: Money;
+  iznos: Money;
+  placeno: Money;
+  rokPlacanja: string;
+  status: StatusRacuna;
+  kasni: boolean;
+  placenAt?: string;
+  createdAt: string;
+  uplate?: UplataStanarine[];
+}
+
+export interface ObracunStanarine {
+  period: string;
+  kreirano: number;
+  vecObracunato: number;
+  sobeBezCene: string[];
+}
+
+export interface DugovanjeStudenta {
+  studentUsername: string;
+  dug: Money;
+  racuni: RacunStanarine[];
+}
+
+export interface DugovanjaDoma {
+  domId: string;
+  dom: string;
+  ukupno: Money;
+  studenti: DugovanjeStudenta[];
+}
+
 export interface DiningMeal {
   id: string;
   name: string;
   description: string;
-  price: number;
+  price: Money;
 }
 
 export interface DiningMenu {
@@ -1,14 +1,91 @@
+import { Money } from './money';
+
+// 14 alergena koje EU propisi traze na jelovniku
+export type Allergen =
+  'gluten' | 'crustaceans' | 'eggs' | 'fish' | 'peanuts' | 'soybeans' | 'milk' |
+  'nuts' | 'celery' | 'mustard' | 'sesame' | 'sulphites' | 'lupin' | 'molluscs';
+
+export type DietaryTag = 'vegetarian' | 'vegan' | 'halal' | 'gluten_free';
+
+// po porciji; makronutrijenti u gramima
+export interface Nutrition {
+  calories: number;
+  protein_g: number;
+  carbs_g: number;
+  fat_g: number;
+}
+
 export interface Meal {
   id: string;          // UUID u string formatu
   name: string;
   description: string;
-  price: number;
+  price: Money;
+  allergens: Allergen[];
+  tags: DietaryTag[];
+  nutrition?: Nutrition;
+  warnings?: string[]; // npr. "contains gluten" - obrok ne odgovara filteru ili preferencama
 }
 
 export interface MealDTO {
   name: string;
   description: string;
-  price: number;
+  price: Money | number; // broj samo pri unosu u formi; server vraca Money
+  allergens?: Allergen[];
+  tags?: DietaryTag[];
+  nutrition?: Nutrition;
+  warnings?: string[];
+}
+
+// jelo koje se bira za slot; podrazumevano jelo slota ima position 0
+export interface Dish extends Meal {
+  menu_id: string;
+  slot: 'breakfast' | 'lunch' | 'dinner';
+  position: number;
+  stock?: number;      // porcija dnevno; bez vrednosti neograniceno
+  remaining?: number;  // preostalo za trazeni dan
+}
+
+export interface DishReview {
+  id: string;
+  meal_id: string;
+  user_id: string;
+  rating: number;      // 1-5
+  comment: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface DishHistory {
+  purchase_id: string;
+  meal_id: string;
+  dish_name: string;
+  slot: 'breakfast' | 'lunch' | 'dinner';
+  menu_id: string;
+  menu_name: string;
+  canteen_id: string;
+  selected_at: string;
+  review?: DishReview;
+}
+
+export interface DishStats {
+  meal_id: string;
+  dish_name: string;
+  times_selected: number;
+  avg_rating: number;
+  reviews: number;
+}
+
+export interface DietaryPreferences {
+  user_id: string;
+  avoid_allergens: Allergen[];
+  tags: DietaryTag[];
+  updated_at?: string;
+}
+
+// filter za liste menija: ?exclude=gluten,milk&tag=vegan
+export interface MealFilter {
+  exclude?: Allergen[];
+  tags?: DietaryTag[];
 }
 
 export interface TopMenu {
@@ -46,4 +123,62 @@ export interface Menu {
   breakfast: MealDTO;
   lunch: MealDTO;
   dinner: MealDTO;
+  dishes?: Dish[];      // sva jela po slotu, ukljucujuci podrazumevana
+}
+
+// plan: meni vazi za dan u nedelji od valid_from do valid_to (ukljucivo, bez kraja ako ga nema)
+export interface MenuPlan {
+  id: string;
+  canteen_id: string;
+  menu_id: string;
+  weekday: Weekday;
+  valid_from: string;  // YYYY-MM-DD
+  valid_to?: string;
+  created_at: string;
+}
+
+// meniji kantine za jedan datum; source kaze odakle su (plan, objavljeni kalendar ili nacrt)
+export interface MenuDay {
+  date: string;        // YYYY-MM-DD
+  canteen_id: string;
+  source: 'plan' | 'calendar' | 'draft';
+  closed: boolean;
+  note?: string;
+  menus: Menu[];
+}
+
+// rezervacija obroka unapred; naplacuje se kada obrok pocne da se izdaje
+export interface Reservation {
+  id: string;
+  user_id: string;
+  username: string;
+  canteen_id: string;
+  menu_id: string;
+  slot: 'breakfast' | 'lunch' | 'dinner';
+  dish_id: string;
+  date: string;         // YYYY-MM-DD
+  status: 'reserved' | 'cancelled' | 'fulfilled' | 'released';
+  purchase_id?: string;
+  reason?: string;      // zasto je oslobodjena
+  created_at: string;
+  updated_at: string;
+}
+
+export interface SlotCapacity {
+  slot: 'breakfast' | 'lunch' | 'dinner';
+  capacity: number;
+}
+
+export interface SlotForecast {
+  slot: 'breakfast' | 'lunch' | 'dinner';
+  capacity?: number;
+  reserved: number;
+  expected: number;     // rezervacije umanjene za istorijski odziv
+  dishes: { dish_id: string; dish_name: string; reserved: number }[];
+}
+
+export interface Forecast {
+  canteen_id: string;
+  show_rate: number;
+  days: { date: string; slots: SlotForecast[] }[];
 }
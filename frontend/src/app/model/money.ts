@@ -0,0 +1,19 @@
+// Novcani iznos kako ga salje backend: decimalni string (tacno, bez float-a) i valuta.
+export interface Money {
+  amount: string;   // npr. "350.00"
+  currency: string; // ISO 4217, npr. "RSD"
+}
+
+// Racunanje radi u celim parama da se ne gomilaju greske zaokruzivanja.
+export function toMinor(m: Money): number {
+  const neg = m.amount.startsWith('-');
+  const [whole, frac = ''] = m.amount.replace('-', '').split('.');
+  const minor = Number(whole) * 100 + Number((frac + '00').slice(0, 2));
+  return neg ? -minor : minor;
+}
+
+export function fromMinor(minor: number, currency = 'RSD'): Money {
+  const abs = Math.abs(minor);
+  const amount = `${minor < 0 ? '-' : ''}${Math.floor(abs / 100)}.${String(abs % 100).padStart(2, '0')}`;
+  return { amount, currency };
+}
@@ -0,0 +1,12 @@
+import { Pipe, PipeTransform } from '@angular/core';
+import { Money } from './model/money';
+
+// Prikaz iznosa: "350.00 RSD"; goli broj (unos u formi) se prikazuje sa dve decimale.
+@Pipe({ name: 'money', standalone: true })
+export class MoneyPipe implements PipeTransform {
+  transform(value: Money | number | null | undefined): string {
+    if (value == null) return '';
+    if (typeof value === 'number') return value.toFixed(2);
+    return `${value.amount} ${value.currency}`;
+  }
+}
@@ -5,11 +5,12 @@ import { of } from 'rxjs';
 
 import { HousingService } from '../services/housing.service';
 import { DiningMenu } from '../model/housing';
+import { MoneyPipe } from '../money.pipe';
 
 @Component({
   selector: 'app-notification-meal',
   standalone: true,
-  imports: [CommonModule, DatePipe, CurrencyPipe],
+  imports: [CommonModule, DatePipe, CurrencyPipe, MoneyPipe],
   templateUrl: './notification.meal.component.html',
   styleUrls: ['./notification.meal.component.css']
 })
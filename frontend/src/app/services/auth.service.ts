@@ -1,6 +1,6 @@
 import { Injectable, inject } from '@angular/core';
 import { HttpClient, HttpErrorResponse } from '@angular/common/http';
-import { RegisterRequest, LoginRequest, LoginResponse, User } from '../model/auth';
+import { RegisterRequest, LoginRequest, LoginResponse, MFAEnrollment, MFALoginRequest, User } from '../model/auth';
 import { catchError, map, throwError } from 'rxjs';
 
 @Injectable({ providedIn: 'root' })
@@ -13,22 +13,60 @@ export class AuthService {
       .pipe(catchError(this.handle));
   }
 
+  // Ako nalog ima 2FA, odgovor nosi challenge_token umesto sesije (vidi loginMfa)
   login(body: LoginRequest) {
     return this.http.post<LoginResponse>(`${this.baseUrl}/api/login`, body)
+      .pipe(map(res => this.storeSession(res)), catchError(this.handle));
+  }
+
+  // Drugi korak prijave: TOTP kod ili rezervni kod
+  loginMfa(body: MFALoginRequest) {
+    return this.http.post<LoginResponse>(`${this.baseUrl}/api/login/2fa`, body)
+      .pipe(map(res => this.storeSession(res)), catchError(this.handle));
+  }
+
+  // Admin bez 2FA mora da ga podesi pre prve prijave
+  mfaSetup(challengeToken: string) {
+    return this.http.post<MFAEnrollment>(`${this.baseUrl}/api/login/2fa/setup`, { challenge_token: challengeToken })
+      .pipe(catchError(this.handle));
+  }
+
+  mfaSetupConfirm(body: MFALoginRequest) {
+    return this.http.post<LoginResponse>(`${this.baseUrl}/api/login/2fa/setup/confirm`, body)
+      .pipe(map(res => this.storeSession(res)), catchError(this.handle));
+  }
+
+  private storeSession(res: LoginResponse) {
+    if (typeof window !== 'undefined' && res.token && res.user) {
+      localStorage.setItem('token', res.token);
+      localStorage.setItem('refreshToken', res.refresh_token ?? '');
+      localStorage.setItem('userId', res.user.id); // direktan string
+      localStorage.setItem('role', res.user.role); // direktan string
+      localStorage.setItem('user', res.user.username);
+    }
+    return res;
+  }
+
+  // Rotira refresh token i cuva novi par tokena
+  refresh() {
+    return this.http.post<LoginResponse>(`${this.baseUrl}/api/token/refresh`, { refresh_token: this.refreshToken })
       .pipe(
         map(res => {
           if (typeof window !== 'undefined') {
-            localStorage.setItem('token', res.token);
-            localStorage.setItem('userId', res.user.id); // direktan string
-            localStorage.setItem('role', res.user.role); // direktan string
-            localStorage.setItem('user', res.user.username);
+            localStorage.setItem('token', res.token ?? '');
+            localStorage.setItem('refreshToken', res.refresh_token ?? '');
           }
-          return res;
+          return res.token ?? '';
         }),
         catchError(this.handle)
       );
   }
 
+  get refreshToken(): string | null {
+    if (typeof window === 'undefined') return null;
+    return localStorage.getItem('refreshToken');
+  }
+
   get userRole(): string | null {
     if (typeof window === 'undefined') return null;
     return localStorage.getItem('role'); // vrati direktno string
@@ -56,8 +94,18 @@ export class AuthService {
   }
 
   logout() {
+    if (this.token) {
+      // opozovi sesiju na serveru; lokalno se odjavljujemo u svakom slucaju
+      this.http.post(`${this.baseUrl}/api/logout`, { refresh_token: this.refreshToken })
+        .subscribe({ error: () => {} });
+    }
+    this.clearSession();
+  }
+
+  clearSession() {
     if (typeof window !== 'undefined') {
       localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
       localStorage.removeItem('user');
       localStorage.removeItem('role');
       localStorage.removeItem('userId');
@@ -2,12 +2,20 @@ import { Injectable, inject } from '@angular/core';
 import { HttpClient } from '@angular/common/http';
 import { Observable } from 'rxjs';
 
+// Prozor izdavanja obroka; start/end su "HH:MM"
+export interface ServingWindow {
+  slot: 'breakfast' | 'lunch' | 'dinner';
+  start: string;
+  end: string;
+}
+
 export interface CanteenDto {
   id: string;
   name: string;
   address: string;
   open_at: string;
   close_at: string;
+  serving_windows?: ServingWindow[];
 }
 
 @Injectable({
@@ -37,5 +45,13 @@ export class CanteenService {
     return this.http.get<any[]>(`${this.baseUrl}/popular-meals/${id}`);
   }
 
+  getServingWindows(id: string): Observable<ServingWindow[]> {
+    return this.http.get<ServingWindow[]>(`${this.baseUrl}${id}/windows`);
+  }
+
+  setServingWindows(id: string, windows: ServingWindow[]): Observable<ServingWindow[]> {
+    return this.http.put<ServingWindow[]>(`${this.baseUrl}${id}/windows`, windows);
+  }
+
 
 }
@@ -1,11 +1,14 @@
 import { Injectable } from '@angular/core';
 import { HttpClient, HttpParams , HttpHeaders} from '@angular/common/http';
 
-import {Dom, Student, Soba, RecenzijaSobe, Kvar, StatusKvara, StudentskaKartica , DiningMeal , DiningMenu , MealRoomHistory
+import {Dom, Student, Soba, RecenzijaSobe, Kvar, StatusKvara, StudentskaKartica , TransakcijePage , Uplata , DiningMeal , DiningMenu , MealRoomHistory ,
+  PrijavaZaSmestaj , KriterijumiBodovanja , RezultatRaspodele , ListaCekanjaStavka , PonudaSobe , ZahtevPremestaja , StatusZahteva , Boravak ,
+  KategorijaCene , RacunStanarine , ObracunStanarine , DugovanjaDoma
 
 } from '../model/housing';
 import { Observable } from 'rxjs';
 import { AuthService } from './auth.service';
+import { Money } from '../model/money';
 
 @Injectable({ providedIn: 'root' })
 export class HousingService {
@@ -17,10 +20,9 @@ export class HousingService {
     return this.http.get<Dom[]>(`${this.base}/doms`);
   }
 
-  getMealHistoryForUsernames(usernames: string[]): Observable<MealRoomHistory[]> {
-    return this.http.post<MealRoomHistory[]>(`${this.base}/rooms/meal-history/`, {
-      usernames
-    });
+  // Obroci stanara sobe ulogovanog studenta
+  getMyRoomMealHistory(): Observable<MealRoomHistory[]> {
+    return this.http.get<MealRoomHistory[]>(`${this.base}/rooms/mine/meal-history`);
   }
 
 
@@ -52,6 +54,33 @@ export class HousingService {
     return this.http.post<StudentskaKartica>(`${this.base}/students/cards/balance`, { studentUsername, delta });
   }
 
+  // Istorija kartice ulogovanog studenta; from/to u formatu YYYY-MM-DD
+  getStudentCardHistory(page = 1, size = 20, from?: string, to?: string): Observable<TransakcijePage> {
+    let params = new HttpParams().set('page', page).set('size', size);
+    if (from) params = params.set('from', from);
+    if (to) params = params.set('to', to);
+    return this.http.get<TransakcijePage>(`${this.base}/students/cards/history`, { params });
+  }
+
+  // Dopuna kartice: otvara placanje kod provajdera (checkoutUrl), stanje se menja tek posle potvrde
+  startCardTopUp(amount: Money): Observable<Uplata> {
+    return this.http.post<Uplata>(`${this.base}/students/cards/topups`, { amount });
+  }
+
+  listCardTopUps(): Observable<Uplata[]> {
+    return this.http.get<Uplata[]>(`${this.base}/students/cards/topups`);
+  }
+
+  // Samo sa laznim provajderom (razvoj): simulira zavrsetak placanja
+  completeFakePayment(intentId: string, outcome: 'succeeded' | 'failed' = 'succeeded'): Observable<Uplata> {
+    const params = new HttpParams().set('outcome', outcome);
+    return this.http.post<Uplata>(`${this.base}/payments/fake/${intentId}/complete`, null, { params });
+  }
+
+  refundCardTopUp(id: string): Observable<Uplata> {
+    return this.http.post<Uplata>(`${this.base}/payments/topups/${id}/refund`, null);
+  }
+
   // Rooms
   getRoom(id: string): Observable<Soba> {
     const params = new HttpParams().set('id', id);
@@ -108,6 +137,169 @@ export class HousingService {
     return this.http.post<{ status: string }>(`${this.base}/faults/status`, { kvarId, status });
   }
 
+  // Prijave za smestaj i raspodela
+  submitApplication(prijava: Partial<PrijavaZaSmestaj>): Observable<PrijavaZaSmestaj> {
+    return this.http.post<PrijavaZaSmestaj>(`${this.base}/applications`, prijava);
+  }
+
+  getMyApplications(): Observable<PrijavaZaSmestaj[]> {
+    return this.http.get<PrijavaZaSmestaj[]>(`${this.base}/applications`);
+  }
+
+  withdrawApplication(id: string): Observable<void> {
+    return this.http.delete<void>(`${this.base}/applications/${id}`);
+  }
+
+  getApplications(godina: string): Observable<PrijavaZaSmestaj[]> {
+    const params = new HttpParams().set('godina', godina);
+    return this.http.get<PrijavaZaSmestaj[]>(`${this.base}/applications/all`, { params });
+  }
+
+  getAllocationCriteria(godina: string): Observable<KriterijumiBodovanja> {
+    const params = new HttpParams().set('godina', godina);
+    return this.http.get<KriterijumiBodovanja>(`${this.base}/allocation/criteria`, { params });
+  }
+
+  saveAllocationCriteria(k: KriterijumiBodovanja): Observable<KriterijumiBodovanja> {
+    return this.http.put<KriterijumiBodovanja>(`${this.base}/allocation/criteria`, k);
+  }
+
+  runAllocation(skolskaGodina: string): Observable<RezultatRaspodele> {
+    return this.http.post<RezultatRaspodele>(`${this.base}/allocation/run`, { skolskaGodina });
+  }
+
+  publishAllocation(skolskaGodina: string): Observable<RezultatRaspodele> {
+    return this.http.post<RezultatRaspodele>(`${this.base}/allocation/publish`, { skolskaGodina });
+  }
+
+  getAllocationResults(godina: string): Observable<RezultatRaspodele> {
+    const params = new HttpParams().set('godina', godina);
+    return this.http.get<RezultatRaspodele>(`${this.base}/allocation/results`, { params });
+  }
+
+  // Lista cekanja i ponude mesta
+  joinWaitlist(domId: string, tipSobe?: number): Observable<ListaCekanjaStavka> {
+    return this.http.post<ListaCekanjaStavka>(`${this.base}/doms/${domId}/waitlist`, tipSobe ? { tipSobe } : {});
+  }
+
+  leaveWaitlist(domId: string): Observable<void> {
+    return this.http.delete<void>(`${this.base}/doms/${domId}/waitlist`);
+  }
+
+  getDomWaitlist(domId: string): Observable<ListaCekanjaStavka[]> {
+    return this.http.get<ListaCekanjaStavka[]>(`${this.base}/doms/${domId}/waitlist`);
+  }
+
+  getMyWaitlist(): Observable<ListaCekanjaStavka[]> {
+    return this.http.get<ListaCekanjaStavka[]>(`${this.base}/waitlist`);
+  }
+
+  getMyOffers(): Observable<PonudaSobe[]> {
+    return this.http.get<PonudaSobe[]>(`${this.base}/offers`);
+  }
+
+  acceptOffer(id: string): Observable<PonudaSobe> {
+    return this.http.post<PonudaSobe>(`${this.base}/offers/${id}/accept`, {});
+  }
+
+  declineOffer(id: string): Observable<PonudaSobe> {
+    return this.http.post<PonudaSobe>(`${this.base}/offers/${id}/decline`, {});
+  }
+
+  // Premestaj i zamena soba
+  requestTransfer(target: { ciljnaSobaId?: string; ciljniDomId?: string }, obrazlozenje = ''): Observable<ZahtevPremestaja> {
+    return this.http.post<ZahtevPremestaja>(`${this.base}/transfers`, { ...target, obrazlozenje });
+  }
+
+  requestSwap(drugiStudent: string, obrazlozenje = ''): Observable<ZahtevPremestaja> {
+    return this.http.post<ZahtevPremestaja>(`${this.base}/swaps`, { drugiStudent, obrazlozenje });
+  }
+
+  getMyTransfers(): Observable<ZahtevPremestaja[]> {
+    return this.http.get<ZahtevPremestaja[]>(`${this.base}/transfers`);
+  }
+
+  getTransfers(status?: StatusZahteva): Observable<ZahtevPremestaja[]> {
+    let params = new HttpParams();
+    if (status) params = params.set('status', status);
+    return this.http.get<ZahtevPremestaja[]>(`${this.base}/transfers/all`, { params });
+  }
+
+  respondToSwap(id: string, prihvata: boolean): Observable<ZahtevPremestaja> {
+    return this.http.post<ZahtevPremestaja>(`${this.base}/transfers/${id}/respond`, { prihvata });
+  }
+
+  withdrawTransfer(id: string): Observable<ZahtevPremestaja> {
+    return this.http.post<ZahtevPremestaja>(`${this.base}/transfers/${id}/withdraw`, {});
+  }
+
+  approveTransfer(id: string): Observable<ZahtevPremestaja> {
+    return this.http.post<ZahtevPremestaja>(`${this.base}/transfers/${id}/approve`, {});
+  }
+
+  rejectTransfer(id: string, razlog = ''): Observable<ZahtevPremestaja> {
+    return this.http.post<ZahtevPremestaja>(`${this.base}/transfers/${id}/reject`, { razlog });
+  }
+
+  // Istorija boravaka; from/to su datumi (YYYY-MM-DD)
+  getRoomOccupancy(sobaId: string, from?: string, to?: string): Observable<Boravak[]> {
+    let params = new HttpParams();
+    if (from) params = params.set('from', from);
+    if (to) params = params.set('to', to);
+    return this.http.get<Boravak[]>(`${this.base}/rooms/${sobaId}/occupancy`, { params });
+  }
+
+  getStudentOccupancy(username: string): Observable<Boravak[]> {
+    return this.http.get<Boravak[]>(`${this.base}/students/${encodeURIComponent(username)}/occupancy`);
+  }
+
+  // Stanarina
+  getRentCategories(): Observable<KategorijaCene[]> {
+    return this.http.get<KategorijaCene[]>(`${this.base}/rent/categories`);
+  }
+
+  createRentCategory(naziv: string, mesecnaCena: Money): Observable<KategorijaCene> {
+    return this.http.post<KategorijaCene>(`${this.base}/rent/categories`, { naziv, mesecnaCena });
+  }
+
+  updateRentCategory(id: string, naziv: string, mesecnaCena: Money): Observable<KategorijaCene> {
+    return this.http.put<KategorijaCene>(`${this.base}/rent/categories/${id}`, { naziv, mesecnaCena });
+  }
+
+  // null uklanja kategoriju
+  setDomRentCategory(domId: string, kategorijaId: string | null): Observable<void> {
+    return this.http.put<void>(`${this.base}/doms/${domId}/rent-category`, { kategorijaId });
+  }
+
+  setRoomRentCategory(sobaId: string, kategorijaId: string | null): Observable<void> {
+    return this.http.put<void>(`${this.base}/rooms/${sobaId}/rent-category`, { kategorijaId });
+  }
+
+  // period je YYYY-MM (zavrsen mesec)
+  runRentBilling(period: string): Observable<ObracunStanarine> {
+    return this.http.post<ObracunStanarine>(`${this.base}/rent/billing/run`, { period });
+  }
+
+  getMyInvoices(): Observable<RacunStanarine[]> {
+    return this.http.get<RacunStanarine[]>(`${this.base}/rent/invoices`);
+  }
+
+  getInvoice(id: string): Observable<RacunStanarine> {
+    return this.http.get<RacunStanarine>(`${this.base}/rent/invoices/${id}`);
+  }
+
+  recordRentPayment(id: string, iznos: Money, napomena = ''): Observable<RacunStanarine> {
+    return this.http.post<RacunStanarine>(`${this.base}/rent/invoices/${id}/payments`, { iznos, napomena });
+  }
+
+  getArrears(): Observable<DugovanjaDoma[]> {
+    return this.http.get<DugovanjaDoma[]>(`${this.base}/rent/arrears`);
+  }
+
+  getDomArrears(domId: string): Observable<DugovanjaDoma> {
+    return this.http.get<DugovanjaDoma>(`${this.base}/doms/${domId}/arrears`);
+  }
+
   // GET /dining/menus/today  (proksi ka Dining servisu)
   getTodayDiningMenus() {
     return this.http.get<DiningMenu[]>(`${this.base}/notifications/menus`);
@@ -1,13 +1,49 @@
 import {inject, Injectable} from '@angular/core';
 import {CanteenDto} from './canteen.service';
-import {HttpClient, HttpHeaders} from '@angular/common/http';
-import {Menu} from '../model/menus';
+import {HttpClient, HttpHeaders, HttpParams} from '@angular/common/http';
+import {DietaryPreferences, Dish, DishHistory, DishReview, DishStats, Forecast, MealFilter, Menu, MenuDay, MenuPlan, Reservation, SlotCapacity, Weekday} from '../model/menus';
 import {Observable} from 'rxjs';
 import {AuthService} from './auth.service';
+import {Money} from '../model/money';
+
+export type MealSlot = 'breakfast' | 'lunch' | 'dinner';
+
+// izabrano jelo po slotu; slot bez izbora dobija podrazumevano jelo
+export type DishChoice = Partial<Record<MealSlot, string>>;
+
+export interface MealPurchase {
+  id: string;
+  menu_id: string;
+  slots: MealSlot[];
+  dishes: DishChoice;
+  list_price: Money;
+  discount: Money;
+  amount: Money;
+  state: 'pending' | 'charged' | 'recorded' | 'completed' | 'refunding' | 'refunded' | 'failed';
+  last_error?: string;
+  failure_code?: 'insufficient_funds' | 'charge_rejected';
+}
+
+// cena koju racuna server (cene iz menija umanjene za subvencije)
+export interface MealQuote {
+  menu_id: string;
+  slots: MealSlot[];
+  dishes: DishChoice;
+  list_price: Money;
+  discount: Money;
+  amount: Money;
+}
+
+// QR propusnica za kasu; vazi kratko i moze se iskoristiti samo jednom
+export interface MealPass {
+  token: string;
+  pass: { id: string; menu_id: string; slots: MealSlot[]; dishes?: DishChoice; expires_at: string };
+  qr_png: string; // data URL
+}
 
 export interface MenuWithCard {
   menu: Menu;
-  card?: { id: string; stanje: number; studentID: string };
+  card?: { id: string; stanje: Money; studentID: string };
 }
 
 @Injectable({
@@ -19,13 +55,14 @@ export class MenuService {
   private authService = inject(AuthService);
   private baseUrl = 'http://localhost:8001/api/menus/';
   private baseUrl2 = 'http://localhost:8001/api/menu/';
+  private canteensUrl = 'http://localhost:8001/api/canteens/';
 
   create(menu: Menu) {
     return this.http.post<Menu>(`${this.baseUrl}`, menu);
   }
 
-  getAll(canteenId: string): Observable<Menu[]> {
-    return this.http.get<Menu[]>(`${this.baseUrl}/${canteenId}`);
+  getAll(canteenId: string, filter?: MealFilter): Observable<Menu[]> {
+    return this.http.get<Menu[]>(`${this.baseUrl}/${canteenId}`, { params: filterParams(filter) });
   }
 
   delete(id: string) {
@@ -37,16 +74,151 @@ export class MenuService {
   }
 
   getMenu(menuId: string, userId: string): Observable<MenuWithCard> {
-    const headers = new HttpHeaders({ 'X-Student-ID': userId });
-    return this.http.get<MenuWithCard>(`${this.baseUrl2}${menuId}`, { headers });
+    return this.http.get<MenuWithCard>(`${this.baseUrl2}${menuId}`);
   }
 
   checkStudent(userId: string | null): Observable<boolean> {
     return this.http.get<boolean>(`${this.baseUrl}checkStudent/${userId}`);
   }
 
-  takeMeal(payload: { studentId: string | null; delta: number; menuId: any, studentUsername: string | null}) {
-    return this.http.post("http://localhost:8001/api/meal/", payload);
+  // date je YYYY-MM-DD; bez njega server vraca danasnji dan
+  getMenusForDate(canteenId: string, date?: string, filter?: MealFilter): Observable<MenuDay> {
+    const q = date ? `?date=${date}` : '';
+    return this.http.get<MenuDay>(`${this.canteensUrl}${canteenId}/calendar${q}`, { params: filterParams(filter) });
+  }
+
+  // drafts=true prikazuje i neobjavljene nacrte (samo za admina)
+  getMenusForWeek(canteenId: string, date?: string, drafts = false): Observable<MenuDay[]> {
+    const params: string[] = [];
+    if (date) params.push(`date=${date}`);
+    if (drafts) params.push('drafts=true');
+    const q = params.length ? `?${params.join('&')}` : '';
+    return this.http.get<MenuDay[]>(`${this.canteensUrl}${canteenId}/calendar/week${q}`);
+  }
+
+  overrideDate(canteenId: string, date: string, body: { menu_ids?: string[]; closed?: boolean; note?: string }): Observable<MenuDay> {
+    return this.http.put<MenuDay>(`${this.canteensUrl}${canteenId}/calendar/${date}`, body);
+  }
+
+  clearOverride(canteenId: string, date: string): Observable<MenuDay> {
+    return this.http.delete<MenuDay>(`${this.canteensUrl}${canteenId}/calendar/${date}`);
+  }
+
+  copyWeek(canteenId: string, from: string, to: string): Observable<MenuDay[]> {
+    return this.http.post<MenuDay[]>(`${this.canteensUrl}${canteenId}/calendar/week/copy`, { from, to });
+  }
+
+  publishWeek(canteenId: string, date: string): Observable<{ week_start: string; published_days: number }> {
+    return this.http.post<{ week_start: string; published_days: number }>(`${this.canteensUrl}${canteenId}/calendar/week/publish`, { date });
+  }
+
+  getMenuPlans(canteenId: string): Observable<MenuPlan[]> {
+    return this.http.get<MenuPlan[]>(`${this.canteensUrl}${canteenId}/menu-plans`);
+  }
+
+  createMenuPlan(canteenId: string, plan: { menu_id: string; weekday?: Weekday; valid_from: string; valid_to?: string }): Observable<MenuPlan> {
+    return this.http.post<MenuPlan>(`${this.canteensUrl}${canteenId}/menu-plans`, plan);
+  }
+
+  endMenuPlan(planId: string, validTo: string) {
+    return this.http.put(`http://localhost:8001/api/menu-plans/${planId}`, { valid_to: validTo });
+  }
+
+  deleteMenuPlan(planId: string) {
+    return this.http.delete(`http://localhost:8001/api/menu-plans/${planId}`);
+  }
+
+  addDish(menuId: string, dish: Partial<Dish> & { slot: MealSlot; name: string; price: Money | number }): Observable<Dish> {
+    return this.http.post<Dish>(`${this.baseUrl}${menuId}/dishes`, dish);
+  }
+
+  // stock je broj porcija dnevno; null ukida ogranicenje
+  setDishStock(dishId: string, stock: number | null) {
+    return this.http.put(`http://localhost:8001/api/dishes/${dishId}/stock`, { stock });
+  }
+
+  deleteDish(dishId: string) {
+    return this.http.delete(`http://localhost:8001/api/dishes/${dishId}`);
+  }
+
+  reviewDish(dishId: string, rating: number, comment = ''): Observable<DishReview> {
+    return this.http.put<DishReview>(`http://localhost:8001/api/dishes/${dishId}/review`, { rating, comment });
   }
 
+  getDishReviews(dishId: string): Observable<DishReview[]> {
+    return this.http.get<DishReview[]>(`http://localhost:8001/api/dishes/${dishId}/reviews`);
+  }
+
+  getDishHistory(userId: string): Observable<DishHistory[]> {
+    return this.http.get<DishHistory[]>(`http://localhost:8001/api/dishes/history/${userId}`);
+  }
+
+  getPopularDishes(canteenId: string): Observable<DishStats[]> {
+    return this.http.get<DishStats[]>(`${this.canteensUrl}${canteenId}/popular-dishes`);
+  }
+
+  getTopRatedDishes(): Observable<DishStats[]> {
+    return this.http.get<DishStats[]>(`http://localhost:8001/api/dishes/top-rated`);
+  }
+
+  getDietaryPreferences(userId: string): Observable<DietaryPreferences> {
+    return this.http.get<DietaryPreferences>(`http://localhost:8001/api/dietary-preferences/${userId}`);
+  }
+
+  saveDietaryPreferences(userId: string, prefs: { avoid_allergens: string[]; tags: string[] }): Observable<DietaryPreferences> {
+    return this.http.put<DietaryPreferences>(`http://localhost:8001/api/dietary-preferences/${userId}`, prefs);
+  }
+
+  quoteMeal(payload: { menuId: string; slots: MealSlot[]; dishes?: DishChoice }): Observable<MealQuote> {
+    return this.http.post<MealQuote>("http://localhost:8001/api/meal/quote", payload);
+  }
+
+  // idempotencyKey je isti za ponovljene pokusaje iste kupovine, pa se kartica ne zaduzuje dvaput
+  takeMeal(payload: { menuId: string; slots: MealSlot[]; dishes?: DishChoice }, idempotencyKey: string) {
+    const headers = new HttpHeaders({ 'Idempotency-Key': idempotencyKey });
+    return this.http.post<MealPurchase>("http://localhost:8001/api/meal/", payload, { headers });
+  }
+
+  getMealPass(payload: { menuId: string; slots: MealSlot[]; dishes?: DishChoice }): Observable<MealPass> {
+    return this.http.post<MealPass>("http://localhost:8001/api/meal/pass", payload);
+  }
+
+  // kasa: iskoriscava skeniranu propusnicu i izdaje obroke
+  redeemMealPass(token: string): Observable<MealPurchase> {
+    return this.http.post<MealPurchase>("http://localhost:8001/api/meal/redeem", { token });
+  }
+
+  reserve(payload: { menu_id: string; slot: MealSlot; date: string; dish_id?: string }): Observable<Reservation> {
+    return this.http.post<Reservation>("http://localhost:8001/api/reservations/", payload);
+  }
+
+  getReservations(userId: string): Observable<Reservation[]> {
+    return this.http.get<Reservation[]>(`http://localhost:8001/api/reservations/user/${userId}`);
+  }
+
+  cancelReservation(id: string): Observable<Reservation> {
+    return this.http.post<Reservation>(`http://localhost:8001/api/reservations/${id}/cancel`, {});
+  }
+
+  getSlotCapacities(canteenId: string): Observable<SlotCapacity[]> {
+    return this.http.get<SlotCapacity[]>(`${this.canteensUrl}${canteenId}/capacity`);
+  }
+
+  setSlotCapacities(canteenId: string, caps: SlotCapacity[]): Observable<SlotCapacity[]> {
+    return this.http.put<SlotCapacity[]>(`${this.canteensUrl}${canteenId}/capacity`, caps);
+  }
+
+  getForecast(canteenId: string, from?: string, days = 7): Observable<Forecast> {
+    let params = new HttpParams().set('days', days);
+    if (from) params = params.set('from', from);
+    return this.http.get<Forecast>(`${this.canteensUrl}${canteenId}/forecast`, { params });
+  }
+
+}
+
+function filterParams(filter?: MealFilter): HttpParams {
+  let params = new HttpParams();
+  if (filter?.exclude?.length) params = params.set('exclude', filter.exclude.join(','));
+  if (filter?.tags?.length) params = params.set('tag', filter.tags.join(','));
+  return params;
 }
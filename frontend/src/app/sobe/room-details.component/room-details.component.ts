@@ -49,13 +49,13 @@ export class RoomDetailsComponent {
             slobodno,
           };
 
-          if (!soba.studenti?.length) {
+          // istoriju obroka vide samo stanari sobe
+          const stanar = soba.studenti?.some(st => st.username === this.auth.username);
+          if (!stanar) {
             return of<ViewModel>({ ...baseVm, mealHistory: [] });
           }
 
-          const usernames = soba.studenti.map(st => st.username);
-
-          return this.roomService.getMealHistoryForUsernames(usernames).pipe(
+          return this.roomService.getMyRoomMealHistory().pipe(
             map(mealHistory => ({ ...baseVm, mealHistory })),
             startWith<ViewModel>({ ...baseVm, loading: true }),
             catchError(() => of<ViewModel>({ ...baseVm, error: 'Greška pri učitavanju istorije obroka.' }))
@@ -9,13 +9,14 @@ import { UserService } from '../services/user.service';
 import {AuthService} from '../services/auth.service';
 import {StudentskaKartica} from '../model/housing';
 import {HousingService} from '../services/housing.service';
+import { MoneyPipe } from '../money.pipe';
 
 declare var bootstrap: any;
 
 @Component({
   selector: 'app-user-details',
   standalone: true,
-  imports: [CommonModule, HttpClientModule, RouterModule, FormsModule],
+  imports: [CommonModule, HttpClientModule, RouterModule, FormsModule, MoneyPipe],
   templateUrl: './user-details.html',
   styleUrls: ['./user-details.css']
 })